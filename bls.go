@@ -0,0 +1,66 @@
+package fibrechannel
+
+import (
+	"io"
+
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+const (
+	RCtlUnsolicitedControl = 0x02 // FC-4 Device_Data: unsolicited control, e.g. a CT request
+	RCtlSolicitedControl   = 0x03 // FC-4 Device_Data: solicited control, e.g. a CT reply
+	RCtlELSRequest         = 0x22 // Extended Link Service request
+	RCtlELSReply           = 0x23 // Extended Link Service reply
+	RCtlABTS               = 0x81 // Basic Link Service: Abort Sequence
+	RCtlBAACC              = 0x84 // Basic Link Service: Basic Accept
+	RCtlBARJT              = 0x85 // Basic Link Service: Basic Reject
+
+	SeqIDValid   = 0x80 // SEQ_ID of BAACC is valid
+	SeqIDInvalid = 0x00 // SEQ_ID of BAACC is invalid
+
+	BLSReasonInvalidCommand  = 0x01 // Invalid command code
+	BLSReasonLogicalError    = 0x03 // Logical error
+	BLSReasonLogicalBusy     = 0x05 // Logical busy
+	BLSReasonProtocolError   = 0x07 // Protocol error
+	BLSReasonUnableToPerform = 0x09 // Unable to perform command request
+	BLSReasonVendorSpecific  = 0xff // Vendor unique error
+
+	BLSExplNone       = 0x00 // No additional explanation
+	BLSExplInvalidIDs = 0x03 // Invalid OX_ID-RX_ID combination
+	BLSExplSeqAborted = 0x05 // Sequence aborted, no Sequence information provided
+)
+
+// BAACC is the payload of a BA_ACC answering an ABTS, an ABTS itself has no
+// payload. Basic Link Service frames have TYPE 0 and are selected by R_CTL,
+// so they are kept as []byte in Frame.Payload and decoded from there.
+type BAACC struct {
+	SeqIDValidity uint8  `fc:"@0"`
+	SeqID         uint8  `fc:"@1"`
+	OXID          uint16 `fc:"@4"`
+	RXID          uint16 `fc:"@6"`
+	LowSeqCount   uint16 `fc:"@8"`
+	HighSeqCount  uint16 `fc:"@10"`
+}
+
+// BARJT is the payload of a BA_RJT.
+type BARJT struct {
+	Reason         uint8 `fc:"@1"`
+	Explanation    uint8 `fc:"@2"`
+	VendorSpecific uint8 `fc:"@3"`
+}
+
+func (o *BAACC) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *BAACC) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
+
+func (o *BARJT) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *BARJT) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
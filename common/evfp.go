@@ -0,0 +1,68 @@
+package common
+
+import (
+	"io"
+
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+const (
+	EVFPSync   = 0x01 // EVFP_SYNC
+	EVFPCommit = 0x02 // EVFP_COMMIT
+
+	TaggingOff  = 0x00 // Tagging administratively off
+	TaggingOn   = 0x01 // Tagging administratively on
+	TaggingAuto = 0x02 // Tagging negotiated
+
+	MaxVFID = 0xfff
+)
+
+// EVFP is the Exchange Virtual Fabrics Parameters payload, shared by the
+// EVFP ELS (FC-LS) and the EVFP SW_ILS (FC-SW).
+type EVFP struct {
+	Revision           uint8    `fc:"@3"`
+	MessageCode        uint8    `fc:"@4"`
+	PortName           WWN      `fc:"@7"`
+	CoreSwitchName     WWN      `fc:"@15"`
+	CoreSwitchPriority uint8    `fc:"@23"`
+	TaggingAdminStatus uint8    `fc:"@24"`
+	PortVFID           uint16   `fc:"@25"`
+	VFIDs              VFIDList `fc:"@27"`
+}
+
+// VFIDList is the list of VF_IDs enabled on a port, encoded as a bitmap
+// of all 4096 possible VF_IDs.
+type VFIDList []uint16
+
+func (s *EVFP) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *EVFP) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, s)
+}
+
+func (p *VFIDList) ReadFrom(r io.Reader) (int64, error) {
+	var bm [(MaxVFID + 1) / 8]byte
+	n, err := io.ReadFull(r, bm[:])
+	if err != nil {
+		return int64(n), err
+	}
+	*p = VFIDList{}
+	for i := 0; i <= MaxVFID; i++ {
+		if bm[i/8]&(0x80>>uint(i%8)) != 0 {
+			*p = append(*p, uint16(i))
+		}
+	}
+	return int64(n), nil
+}
+
+func (p *VFIDList) WriteTo(w io.Writer) (int64, error) {
+	var bm [(MaxVFID + 1) / 8]byte
+	for _, id := range *p {
+		id &= MaxVFID
+		bm[id/8] |= 0x80 >> (id % 8)
+	}
+	n, err := w.Write(bm[:])
+	return int64(n), err
+}
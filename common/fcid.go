@@ -0,0 +1,160 @@
+package common
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FCID is a 24 bit address identifier, used as D_ID and S_ID and as the
+// N_Port_ID in link services. Fabric assigned addresses are made up of a
+// Domain_ID, an Area_ID and a Port_ID.
+type FCID [3]byte
+
+// WellKnownAddr classifies the well-known addresses of FC-FS.
+type WellKnownAddr int
+
+const (
+	AddrNone                  WellKnownAddr = iota // Not a well-known address
+	AddrBroadcast                                  // Broadcast alias_ID
+	AddrFPortController                            // Fabric F_Port Controller
+	AddrFabricController                           // Fabric Controller
+	AddrDirectoryServer                            // Directory Server
+	AddrTimeServer                                 // Time Server
+	AddrManagementServer                           // Management Server
+	AddrQoSFacilitator                             // Quality of Service Facilitator
+	AddrAliasServer                                // Alias Server
+	AddrKeyDistributionServer                      // Key Distribution Server
+	AddrClockSyncServer                            // Clock Synchronization Server
+	AddrMulticastServer                            // Multicast Server
+	AddrReserved                                   // Reserved well-known address
+	AddrDomainController                           // Domain Controller, FFFCxx
+)
+
+var (
+	Broadcast             = FCID{0xff, 0xff, 0xff}
+	FPortController       = FCID{0xff, 0xff, 0xfe}
+	FabricController      = FCID{0xff, 0xff, 0xfd}
+	DirectoryServer       = FCID{0xff, 0xff, 0xfc}
+	TimeServer            = FCID{0xff, 0xff, 0xfb}
+	ManagementServer      = FCID{0xff, 0xff, 0xfa}
+	QoSFacilitator        = FCID{0xff, 0xff, 0xf9}
+	AliasServer           = FCID{0xff, 0xff, 0xf8}
+	KeyDistributionServer = FCID{0xff, 0xff, 0xf7}
+	ClockSyncServer       = FCID{0xff, 0xff, 0xf6}
+	MulticastServer       = FCID{0xff, 0xff, 0xf5}
+
+	errFCID = errors.New("FCID is not 6 hex digits")
+
+	// wellKnown holds the addresses FFFFF0 and up by their last byte
+	wellKnown = map[byte]WellKnownAddr{
+		0xff: AddrBroadcast,
+		0xfe: AddrFPortController,
+		0xfd: AddrFabricController,
+		0xfc: AddrDirectoryServer,
+		0xfb: AddrTimeServer,
+		0xfa: AddrManagementServer,
+		0xf9: AddrQoSFacilitator,
+		0xf8: AddrAliasServer,
+		0xf7: AddrKeyDistributionServer,
+		0xf6: AddrClockSyncServer,
+		0xf5: AddrMulticastServer,
+	}
+
+	wellKnownNames = map[WellKnownAddr]string{
+		AddrNone:                  "None",
+		AddrBroadcast:             "Broadcast",
+		AddrFPortController:       "F_Port Controller",
+		AddrFabricController:      "Fabric Controller",
+		AddrDirectoryServer:       "Directory Server",
+		AddrTimeServer:            "Time Server",
+		AddrManagementServer:      "Management Server",
+		AddrQoSFacilitator:        "QoS Facilitator",
+		AddrAliasServer:           "Alias Server",
+		AddrKeyDistributionServer: "Key Distribution Server",
+		AddrClockSyncServer:       "Clock Synchronization Server",
+		AddrMulticastServer:       "Multicast Server",
+		AddrReserved:              "Reserved",
+		AddrDomainController:      "Domain Controller",
+	}
+)
+
+// NewFCID returns the address of a port from its Domain_ID, Area_ID and
+// Port_ID.
+func NewFCID(domain, area, port uint8) FCID {
+	return FCID{domain, area, port}
+}
+
+// DomainController returns the address of the Domain Controller of a
+// domain.
+func DomainController(domain uint8) FCID {
+	return FCID{0xff, 0xfc, domain}
+}
+
+// ParseFCID parses an address given as six hex digits, optionally prefixed
+// by "0x".
+func ParseFCID(s string) (FCID, error) {
+	var f FCID
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(s) != 6 {
+		return f, errFCID
+	}
+	if _, err := hex.Decode(f[:], []byte(s)); err != nil {
+		return f, errFCID
+	}
+	return f, nil
+}
+
+func (f FCID) Domain() uint8 {
+	return f[0]
+}
+
+func (f FCID) Area() uint8 {
+	return f[1]
+}
+
+func (f FCID) Port() uint8 {
+	return f[2]
+}
+
+func (f FCID) String() string {
+	return hex.EncodeToString(f[:])
+}
+
+func (f FCID) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+func (f *FCID) UnmarshalText(b []byte) error {
+	p, err := ParseFCID(string(b))
+	if err != nil {
+		return err
+	}
+	*f = p
+	return nil
+}
+
+// WellKnown classifies f if it is one of the well-known addresses.
+func (f FCID) WellKnown() WellKnownAddr {
+	switch {
+	case f[0] != 0xff:
+		return AddrNone
+	case f[1] == 0xff && f[2] >= 0xf0:
+		if a, ok := wellKnown[f[2]]; ok {
+			return a
+		}
+		return AddrReserved
+	case f[1] == 0xfc:
+		return AddrDomainController
+	default:
+		return AddrNone
+	}
+}
+
+func (a WellKnownAddr) String() string {
+	if n, ok := wellKnownNames[a]; ok {
+		return n
+	}
+	return fmt.Sprintf("WellKnownAddr(%d)", int(a))
+}
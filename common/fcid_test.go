@@ -0,0 +1,69 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFCID(t *testing.T) {
+	f := NewFCID(0x0a, 0x1b, 0x2c)
+	if f.Domain() != 0x0a || f.Area() != 0x1b || f.Port() != 0x2c {
+		t.Fatalf("unexpected domain/area/port of %v", f)
+	}
+	if f.String() != "0a1b2c" {
+		t.Fatalf("got %q, wanted \"0a1b2c\"", f.String())
+	}
+	for _, s := range []string{"0a1b2c", "0x0A1B2C"} {
+		if p, err := ParseFCID(s); err != nil || p != f {
+			t.Errorf("ParseFCID(%q) = %v, %v", s, p, err)
+		}
+	}
+	for _, s := range []string{"", "0a1b2", "0a1b2c3d", "0a1b2g"} {
+		if _, err := ParseFCID(s); err != errFCID {
+			t.Errorf("ParseFCID(%q) got unexpected error %v", s, err)
+		}
+	}
+
+	b, err := json.Marshal(map[FCID]FCID{f: FPortController})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if want := `{"0a1b2c":"fffffe"}`; string(b) != want {
+		t.Fatalf("got %s, wanted %s", b, want)
+	}
+	var m map[FCID]FCID
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if m[f] != FPortController {
+		t.Fatalf("unexpected map %v", m)
+	}
+}
+
+func TestWellKnown(t *testing.T) {
+	var tests = []struct {
+		id   FCID
+		want WellKnownAddr
+	}{
+		{Broadcast, AddrBroadcast},
+		{FPortController, AddrFPortController},
+		{FabricController, AddrFabricController},
+		{DirectoryServer, AddrDirectoryServer},
+		{TimeServer, AddrTimeServer},
+		{ManagementServer, AddrManagementServer},
+		{QoSFacilitator, AddrQoSFacilitator},
+		{AliasServer, AddrAliasServer},
+		{KeyDistributionServer, AddrKeyDistributionServer},
+		{ClockSyncServer, AddrClockSyncServer},
+		{MulticastServer, AddrMulticastServer},
+		{FCID{0xff, 0xff, 0xf0}, AddrReserved},
+		{DomainController(0x0a), AddrDomainController},
+		{FCID{0xff, 0xff, 0xef}, AddrNone},
+		{FCID{0x0a, 0x1b, 0x2c}, AddrNone},
+	}
+	for _, tt := range tests {
+		if got := tt.id.WellKnown(); got != tt.want {
+			t.Errorf("%v got %v, wanted %v", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+)
+
+// OUI is an IEEE Organizationally Unique Identifier.
+type OUI [3]byte
+
+// VendorLookup resolves an OUI to the name of its vendor. It defaults to the
+// built-in table and can be replaced to consult a full IEEE registry.
+var VendorLookup = LookupVendor
+
+var (
+	vendorsMu sync.RWMutex
+
+	// vendors holds the storage and HBA vendors commonly seen in WWNs
+	vendors = map[OUI]string{
+		{0x00, 0x00, 0xc9}: "Emulex",
+		{0x00, 0x10, 0x9b}: "Emulex",
+		{0x00, 0x90, 0xfa}: "Emulex",
+		{0x00, 0xe0, 0x8b}: "QLogic",
+		{0x00, 0x1b, 0x32}: "QLogic",
+		{0x00, 0x24, 0xff}: "QLogic",
+		{0x00, 0xc0, 0xdd}: "QLogic",
+		{0x00, 0x0e, 0x1e}: "QLogic",
+		{0x00, 0x05, 0x1e}: "Brocade",
+		{0x00, 0x05, 0x33}: "Brocade",
+		{0x00, 0x27, 0xf8}: "Brocade",
+		{0x00, 0x60, 0x69}: "Brocade",
+		{0x08, 0x00, 0x88}: "Brocade",
+		{0x00, 0x05, 0x30}: "Cisco",
+		{0x00, 0x0d, 0xec}: "Cisco",
+		{0x00, 0x25, 0xb5}: "Cisco",
+		{0x00, 0x60, 0x16}: "EMC",
+		{0x00, 0x60, 0x48}: "EMC",
+		{0x00, 0x01, 0x44}: "EMC",
+		{0x00, 0xa0, 0x98}: "NetApp",
+		{0x00, 0x60, 0xe8}: "Hitachi",
+		{0x00, 0x50, 0x76}: "IBM",
+		{0x00, 0x17, 0x38}: "IBM",
+		{0x00, 0x14, 0x5e}: "IBM",
+		{0x00, 0xa0, 0xb8}: "LSI",
+		{0x00, 0x02, 0xac}: "HPE 3PAR",
+		{0x00, 0x14, 0x38}: "HPE",
+		{0x00, 0x14, 0x4f}: "Oracle",
+		{0x24, 0xa9, 0x37}: "Pure Storage",
+		{0x00, 0xe0, 0xfc}: "Huawei",
+	}
+)
+
+// LookupVendor looks up an OUI in the built-in table.
+func LookupVendor(o OUI) (string, bool) {
+	vendorsMu.RLock()
+	defer vendorsMu.RUnlock()
+	n, ok := vendors[o]
+	return n, ok
+}
+
+// RegisterVendor adds or replaces an entry of the built-in table.
+func RegisterVendor(o OUI, name string) {
+	vendorsMu.Lock()
+	defer vendorsMu.Unlock()
+	vendors[o] = name
+}
+
+// Vendor returns the vendor of the OUI as found by VendorLookup, or "" if
+// it is not known.
+func (o OUI) Vendor() string {
+	n, _ := VendorLookup(o)
+	return n
+}
+
+func (o OUI) String() string {
+	return fmt.Sprintf("%02x:%02x:%02x", o[0], o[1], o[2])
+}
@@ -0,0 +1,127 @@
+package common
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// NAA is the Network Address Authority, the format of a WWN given by its
+// first four bits.
+type NAA uint8
+
+const (
+	NAAIEEE                   NAA = 0x1 // IEEE 48-bit address
+	NAAIEEEExtended           NAA = 0x2 // IEEE Extended
+	NAALocal                  NAA = 0x3 // Locally assigned
+	NAAIEEERegistered         NAA = 0x5 // IEEE Registered
+	NAAIEEERegisteredExtended NAA = 0x6 // IEEE Registered Extended, first 64 bits
+)
+
+var (
+	errWWN = errors.New("WWN is not 8 hex bytes")
+	errNAA = errors.New("WWN has no IEEE assigned NAA format")
+
+	naaNames = map[NAA]string{
+		NAAIEEE:                   "IEEE",
+		NAAIEEEExtended:           "IEEE Extended",
+		NAALocal:                  "Locally Assigned",
+		NAAIEEERegistered:         "IEEE Registered",
+		NAAIEEERegisteredExtended: "IEEE Registered Extended",
+	}
+)
+
+// NAAName holds the parts of a WWN in one of the IEEE based NAA formats.
+// VendorSpecific is what follows the OUI, 24 bits for NAA 1 and 2 and 36
+// bits for NAA 5 and 6. Extension is the 12 bit vendor specific field in
+// front of the OUI of NAA 2, often used to number the ports of a node.
+type NAAName struct {
+	NAA            NAA
+	OUI            OUI
+	VendorSpecific uint64
+	Extension      uint16
+}
+
+// ParseWWN parses a WWN written as eight hex bytes, separated by colons or
+// dashes or not at all, optionally prefixed by "0x".
+func ParseWWN(s string) (WWN, error) {
+	var w WWN
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if strings.ContainsAny(s, ":-") {
+		p := strings.FieldsFunc(s, func(r rune) bool { return r == ':' || r == '-' })
+		if len(p) != len(w) || len(s) != 3*len(w)-1 {
+			return w, errWWN
+		}
+		for _, b := range p {
+			if len(b) != 2 {
+				return w, errWWN
+			}
+		}
+		s = strings.Join(p, "")
+	}
+	if len(s) != 2*len(w) {
+		return w, errWWN
+	}
+	if _, err := hex.Decode(w[:], []byte(s)); err != nil {
+		return w, errWWN
+	}
+	return w, nil
+}
+
+func (s WWN) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *WWN) UnmarshalText(b []byte) error {
+	w, err := ParseWWN(string(b))
+	if err != nil {
+		return err
+	}
+	*s = w
+	return nil
+}
+
+// NAA returns the format of the WWN.
+func (s *WWN) NAA() NAA {
+	return NAA(s[0] >> 4)
+}
+
+// DecodeNAA splits a WWN in an IEEE based NAA format into its parts.
+func (s *WWN) DecodeNAA() (NAAName, error) {
+	v := binary.BigEndian.Uint64(s[:])
+	n := NAAName{NAA: s.NAA()}
+	switch n.NAA {
+	case NAAIEEE, NAAIEEEExtended:
+		copy(n.OUI[:], s[2:5])
+		n.VendorSpecific = v & 0xffffff
+		if n.NAA == NAAIEEEExtended {
+			n.Extension = uint16(v>>48) & 0xfff
+		}
+	case NAAIEEERegistered, NAAIEEERegisteredExtended:
+		o := uint32(v >> 36 & 0xffffff)
+		n.OUI = OUI{byte(o >> 16), byte(o >> 8), byte(o)}
+		n.VendorSpecific = v & 0xfffffffff
+	default:
+		return n, errNAA
+	}
+	return n, nil
+}
+
+// Vendor returns the name of the vendor that assigned the WWN, or "" if
+// it is not known.
+func (s *WWN) Vendor() string {
+	n, err := s.DecodeNAA()
+	if err != nil {
+		return ""
+	}
+	return n.OUI.Vendor()
+}
+
+func (n NAA) String() string {
+	if s, ok := naaNames[n]; ok {
+		return s
+	}
+	return fmt.Sprintf("NAA(%d)", uint8(n))
+}
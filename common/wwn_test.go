@@ -0,0 +1,106 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var emulex = WWN{0x10, 0x00, 0x00, 0x00, 0xc9, 0x12, 0x34, 0x56}
+
+func TestParseWWN(t *testing.T) {
+	for _, s := range []string{
+		"10:00:00:00:c9:12:34:56",
+		"10-00-00-00-C9-12-34-56",
+		"10000000c9123456",
+		"0x10000000c9123456",
+	} {
+		if w, err := ParseWWN(s); err != nil || w != emulex {
+			t.Errorf("ParseWWN(%q) = %v, %v", s, &w, err)
+		}
+	}
+	for _, s := range []string{
+		"",
+		"10:00:00:00:c9:12:34",
+		"10:00:00:00:c9:12:34:5",
+		"1:00:00:00:c9:12:34:567",
+		"10000000c912345",
+		"10000000c912345g",
+	} {
+		if _, err := ParseWWN(s); err != errWWN {
+			t.Errorf("ParseWWN(%q) got unexpected error %v", s, err)
+		}
+	}
+}
+
+func TestWWNJSON(t *testing.T) {
+	v := struct{ PortName WWN }{emulex}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if want := `{"PortName":"10:00:00:00:c9:12:34:56"}`; string(b) != want {
+		t.Fatalf("got %s, wanted %s", b, want)
+	}
+	v.PortName = WWN{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if v.PortName != emulex {
+		t.Fatalf("got %v, wanted %v", &v.PortName, &emulex)
+	}
+}
+
+func TestDecodeNAA(t *testing.T) {
+	var tests = []struct {
+		wwn    string
+		want   NAAName
+		vendor string
+	}{
+		{"10:00:00:00:c9:12:34:56", NAAName{NAA: NAAIEEE, OUI: OUI{0x00, 0x00, 0xc9}, VendorSpecific: 0x123456}, "Emulex"},
+		{"21:00:00:24:ff:3d:39:a0", NAAName{NAA: NAAIEEEExtended, OUI: OUI{0x00, 0x24, 0xff}, VendorSpecific: 0x3d39a0, Extension: 0x100}, "QLogic"},
+		{"50:06:01:60:3b:a0:12:34", NAAName{NAA: NAAIEEERegistered, OUI: OUI{0x00, 0x60, 0x16}, VendorSpecific: 0x03ba01234}, "EMC"},
+		{"52:4a:93:7d:f3:5a:a1:00", NAAName{NAA: NAAIEEERegistered, OUI: OUI{0x24, 0xa9, 0x37}, VendorSpecific: 0xdf35aa100}, "Pure Storage"},
+		{"60:0a:09:80:00:00:00:01", NAAName{NAA: NAAIEEERegisteredExtended, OUI: OUI{0x00, 0xa0, 0x98}, VendorSpecific: 0x000000001}, "NetApp"},
+	}
+	for _, tt := range tests {
+		w, err := ParseWWN(tt.wwn)
+		if err != nil {
+			t.Fatalf("ParseWWN: %v", err)
+		}
+		got, err := w.DecodeNAA()
+		if err != nil {
+			t.Fatalf("DecodeNAA(%v): %v", tt.wwn, err)
+		}
+		if got != tt.want {
+			t.Errorf("DecodeNAA(%v) = %+v, wanted %+v", tt.wwn, got, tt.want)
+		}
+		if v := w.Vendor(); v != tt.vendor {
+			t.Errorf("%v got vendor %q, wanted %q", tt.wwn, v, tt.vendor)
+		}
+	}
+
+	local := WWN{0x30, 0, 0, 0, 0, 0, 0, 1}
+	if _, err := local.DecodeNAA(); err != errNAA {
+		t.Errorf("got unexpected error %v, wanted %v", err, errNAA)
+	}
+	if local.NAA() != NAALocal || local.Vendor() != "" {
+		t.Errorf("unexpected NAA %v or vendor %q", local.NAA(), local.Vendor())
+	}
+}
+
+func TestVendorLookup(t *testing.T) {
+	o := OUI{0x12, 0x34, 0x56}
+	if o.Vendor() != "" {
+		t.Fatalf("unknown OUI has a vendor")
+	}
+	RegisterVendor(o, "Example")
+	if v := o.Vendor(); v != "Example" {
+		t.Fatalf("got %q after RegisterVendor", v)
+	}
+
+	defer func(f func(OUI) (string, bool)) { VendorLookup = f }(VendorLookup)
+	VendorLookup = func(OUI) (string, bool) { return "Registry", true }
+	if v := emulex.Vendor(); v != "Registry" {
+		t.Fatalf("got %q from a replaced lookup", v)
+	}
+}
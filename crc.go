@@ -0,0 +1,88 @@
+package fibrechannel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/bits"
+)
+
+const (
+	CRCLength = 4
+)
+
+// CRCError is returned when the CRC of a frame does not match its contents.
+type CRCError struct {
+	Got  uint32
+	Want uint32
+}
+
+func (e *CRCError) Error() string {
+	return fmt.Sprintf("CRC mismatch: got 0x%08x, want 0x%08x", e.Got, e.Want)
+}
+
+// CRC returns the FC-FS CRC of b, which is everything between the SOF and
+// the CRC: any Extended_Headers, the Frame_Header and the data field. The
+// CRC is the IEEE 802.3 CRC-32, which is transmitted least significant byte
+// first. The returned value is as read big-endian from the wire.
+func CRC(b []byte) uint32 {
+	return bits.ReverseBytes32(crc32.ChecksumIEEE(b))
+}
+
+// VerifyCRC checks the CRC in the last four bytes of b against the rest of
+// b. A mismatch is returned as a *CRCError.
+func VerifyCRC(b []byte) error {
+	if len(b) < CRCLength {
+		return io.ErrUnexpectedEOF
+	}
+	got := binary.BigEndian.Uint32(b[len(b)-CRCLength:])
+	if want := CRC(b[:len(b)-CRCLength]); got != want {
+		return &CRCError{Got: got, Want: want}
+	}
+	return nil
+}
+
+// readWithCRC reads a frame followed by its CRC. The frame is decoded even
+// if the CRC does not match, in which case a *CRCError is returned.
+func (o *Frame) readWithCRC(r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) < CRCLength {
+		if len(b) == 0 {
+			return 0, io.EOF
+		}
+		return int64(len(b)), io.ErrUnexpectedEOF
+	}
+	l := len(b) - CRCLength
+	o.CRC = binary.BigEndian.Uint32(b[l:])
+	o.CRCTrailer = false
+	n, err := o.ReadFrom(bytes.NewReader(b[:l]))
+	o.CRCTrailer = true
+	if err != nil {
+		return n, err
+	}
+	if want := CRC(b[:l]); o.CRC != want {
+		return n + CRCLength, &CRCError{Got: o.CRC, Want: want}
+	}
+	return n + CRCLength, nil
+}
+
+// writeWithCRC writes the frame followed by its CRC, which is stored in CRC.
+func (o *Frame) writeWithCRC(w io.Writer) (int64, error) {
+	buf := new(bytes.Buffer)
+	o.CRCTrailer = false
+	_, err := o.WriteTo(buf)
+	o.CRCTrailer = true
+	if err != nil {
+		return 0, err
+	}
+	o.CRC = CRC(buf.Bytes())
+	var c [CRCLength]byte
+	binary.BigEndian.PutUint32(c[:], o.CRC)
+	buf.Write(c[:])
+	return buf.WriteTo(w)
+}
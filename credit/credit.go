@@ -0,0 +1,377 @@
+// Package credit analyzes buffer-to-buffer flow control of a link from a
+// timestamped trace of frames and primitive signals in both directions. It
+// learns the BB_Credit and BB_SC_N values from the FLOGI, PLOGI and ELP
+// exchanges in the trace, tracks the credit outstanding in each direction,
+// and reports periods without credit, credit violations and the credit
+// recovered by the BB_SCs and BB_SCr primitives. The report helps telling a
+// slow-drain device from a trace.
+package credit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/els"
+	"github.com/bluecmd/fibrechannel/primitive"
+	"github.com/bluecmd/fibrechannel/swils"
+)
+
+// Direction is the direction of transmission on a link between port A and
+// port B.
+type Direction int
+
+const (
+	AToB Direction = iota
+	BToA
+)
+
+var errOrder = errors.New("event is older than the previous one")
+
+func (d Direction) String() string {
+	if d == AToB {
+		return "A->B"
+	}
+	return "B->A"
+}
+
+// Reverse returns the opposite direction, in which the R_RDYs for the frames
+// sent in d are returned.
+func (d Direction) Reverse() Direction {
+	return 1 - d
+}
+
+// Event is a frame or an ordered set seen on the link. Only R_RDY, BB_SCs
+// and BB_SCr are considered of the ordered sets.
+type Event struct {
+	Time      time.Time
+	Direction Direction
+	Frame     *fc.Frame
+	Primitive primitive.Primitive
+}
+
+// Stats is the part of a Report covering one direction.
+type Stats struct {
+	BBCredit int // BB_Credit the transmitter was given, 0 if unknown
+	BBSCN    int // BB_SC_N in use, 0 if credit recovery is disabled
+
+	Frames         int
+	RRDYs          int
+	MaxOutstanding int
+
+	// ZeroCredit counts the times the transmitter ran out of credit, for
+	// ZeroCreditTime in total and LongestZeroCredit at the most
+	ZeroCredit        int
+	ZeroCreditTime    time.Duration
+	LongestZeroCredit time.Duration
+
+	Violations  int // frames sent without credit
+	ExcessRRDYs int // R_RDYs received without frames outstanding
+	LostFrames  int // frames the receiver missed according to BB_SCs
+	LostRRDYs   int // R_RDYs the transmitter missed according to BB_SCr or the simulation
+}
+
+// Report is the result of an analysis.
+type Report struct {
+	Duration   time.Duration
+	Directions [2]Stats
+}
+
+// Analyzer keeps the credit state of a link. Events have to be added in
+// order of time.
+type Analyzer struct {
+	// SimulateBBSC recovers credit as BB_SCs and BB_SCr would have every
+	// 2^BB_SC_N frames, for links or traces without them. Credit not
+	// returned within a full window is considered lost and recovered.
+	SimulateBBSC bool
+
+	// advertised holds the BB_Credit and BB_SC_N advertised in the
+	// logins sent in each direction
+	advertised [2]*login
+	// pending holds the outstanding login requests by OX_ID
+	pending [2]map[uint16]interface{}
+	// fabric is set once a FLOGI has been accepted by an F_Port, from
+	// when on PLOGIs are with ports beyond the link
+	fabric bool
+
+	dirs        [2]direction
+	first, last time.Time
+}
+
+type login struct {
+	credit int
+	bbscn  int
+}
+
+// direction is the state of the transmitter and receiver of one direction
+type direction struct {
+	Stats
+	outstanding int
+	zeroSince   time.Time
+	zero        bool
+
+	// frames and rrdys count since the last BB_SCs and BB_SCr, sent and
+	// acked count over the whole trace for the simulation
+	frames, rrdys int
+	sent, acked   int
+}
+
+// NewAnalyzer returns an Analyzer for a link without known credit.
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{
+		pending: [2]map[uint16]interface{}{{}, {}},
+	}
+}
+
+// SetCredit sets the BB_Credit and BB_SC_N of direction d, for traces which
+// do not contain the login.
+func (a *Analyzer) SetCredit(d Direction, credit, bbscn int) {
+	a.dirs[d].BBCredit = credit
+	a.dirs[d].BBSCN = bbscn
+}
+
+// Add feeds an event to the analyzer.
+func (a *Analyzer) Add(e Event) error {
+	if e.Time.Before(a.last) {
+		return errOrder
+	}
+	if a.first.IsZero() {
+		a.first = e.Time
+	}
+	a.last = e.Time
+	if e.Frame != nil {
+		a.frame(e)
+		return nil
+	}
+	switch e.Primitive.Type {
+	case primitive.TypeRRDY:
+		a.rrdy(e.Time, e.Direction.Reverse())
+	case primitive.TypeBBSCs:
+		a.bbscs(e.Direction)
+	case primitive.TypeBBSCr:
+		a.bbscr(e.Time, e.Direction.Reverse())
+	}
+	return nil
+}
+
+// Report returns the statistics so far. A period without credit still
+// going on is counted up to the last event.
+func (a *Analyzer) Report() *Report {
+	r := &Report{Duration: a.last.Sub(a.first)}
+	for i := range a.dirs {
+		d := a.dirs[i]
+		if d.zero {
+			d.endZero(a.last)
+		}
+		r.Directions[i] = d.Stats
+	}
+	return r
+}
+
+func (a *Analyzer) frame(e Event) {
+	a.learn(e)
+	d := &a.dirs[e.Direction]
+	d.Frames++
+	d.frames++
+	d.sent++
+	if d.BBCredit > 0 && d.outstanding >= d.BBCredit {
+		d.Violations++
+	}
+	d.outstanding++
+	if d.outstanding > d.MaxOutstanding {
+		d.MaxOutstanding = d.outstanding
+	}
+	if d.BBCredit > 0 && d.outstanding >= d.BBCredit && !d.zero {
+		d.zero = true
+		d.zeroSince = e.Time
+	}
+	if a.SimulateBBSC && d.BBSCN > 0 && d.sent%(1<<d.BBSCN) == 0 {
+		// A BB_SCs would be sent now, any credit for the windows
+		// before the last one still not returned has been lost
+		if lost := d.sent - 1<<d.BBSCN - d.acked; lost > 0 {
+			d.LostRRDYs += lost
+			d.recover(e.Time, lost)
+		}
+	}
+}
+
+// rrdy returns a credit to the transmitter of d.
+func (a *Analyzer) rrdy(t time.Time, dir Direction) {
+	d := &a.dirs[dir]
+	d.RRDYs++
+	d.rrdys++
+	if d.outstanding == 0 {
+		d.ExcessRRDYs++
+		return
+	}
+	d.recover(t, 1)
+}
+
+// bbscs checks the frames received in d against the BB_SC_N window. Frames
+// the receiver missed were never seen in the trace, but used up a credit
+// which the receiver returns with extra R_RDYs.
+func (a *Analyzer) bbscs(dir Direction) {
+	d := &a.dirs[dir]
+	if d.BBSCN == 0 {
+		return
+	}
+	if lost := 1<<d.BBSCN - d.frames; lost > 0 {
+		d.LostFrames += lost
+		d.outstanding += lost
+		d.sent += lost
+	}
+	d.frames = 0
+}
+
+// bbscr checks the R_RDYs returned for d against the BB_SC_N window, the
+// missing ones are recovered by the transmitter.
+func (a *Analyzer) bbscr(t time.Time, dir Direction) {
+	d := &a.dirs[dir]
+	if d.BBSCN == 0 {
+		return
+	}
+	if lost := 1<<d.BBSCN - d.rrdys; lost > 0 {
+		d.LostRRDYs += lost
+		if lost > d.outstanding {
+			lost = d.outstanding
+		}
+		d.recover(t, lost)
+	}
+	d.rrdys = 0
+}
+
+func (d *direction) recover(t time.Time, n int) {
+	d.outstanding -= n
+	d.acked += n
+	if d.zero && d.outstanding < d.BBCredit {
+		d.endZero(t)
+	}
+}
+
+func (d *direction) endZero(t time.Time) {
+	p := t.Sub(d.zeroSince)
+	d.zero = false
+	d.ZeroCredit++
+	d.ZeroCreditTime += p
+	if p > d.LongestZeroCredit {
+		d.LongestZeroCredit = p
+	}
+}
+
+// learn picks up the credit advertised in logins. A login or its accept sent
+// in one direction advertises the credit of the sender's receive buffers,
+// which is what the other direction is given. PLOGI only sets the credit of
+// a point-to-point link, on a fabric the credit is that of the FLOGI.
+func (a *Analyzer) learn(e Event) {
+	f := e.Frame
+	switch p := f.Payload.(type) {
+	case *els.Frame:
+		switch r := p.Payload.(type) {
+		case *els.FLOGI:
+			a.pending[e.Direction][f.OXID] = r
+			a.advertise(e.Direction, r.CommonSvcParams)
+		case *els.PLOGI:
+			if a.fabric {
+				return
+			}
+			a.pending[e.Direction][f.OXID] = r
+			a.advertise(e.Direction, r.CommonSvcParams)
+		case *els.LSACC:
+			req, ok := a.pending[e.Direction.Reverse()][f.OXID]
+			if !ok {
+				return
+			}
+			delete(a.pending[e.Direction.Reverse()], f.OXID)
+			acc := &els.PLOGI{}
+			if _, err := acc.ReadFrom(bytes.NewReader(r.Data)); err != nil {
+				return
+			}
+			switch req.(type) {
+			case *els.FLOGI:
+				a.fabric = acc.CommonSvcParams.NorFPort
+			case *els.PLOGI:
+				if a.fabric {
+					return
+				}
+			}
+			a.advertise(e.Direction, acc.CommonSvcParams)
+		}
+	case *swils.Frame:
+		switch p.Command {
+		case swils.CmdELP:
+			if elp, ok := p.Payload.(*swils.ELP); ok {
+				a.pending[e.Direction][f.OXID] = elp
+				a.advertiseELP(e.Direction, elp)
+			}
+		case swils.CmdSWACC:
+			if _, ok := a.pending[e.Direction.Reverse()][f.OXID].(*swils.ELP); !ok {
+				return
+			}
+			delete(a.pending[e.Direction.Reverse()], f.OXID)
+			if err := p.DecodeAccept(swils.CmdELP); err != nil {
+				return
+			}
+			if elp, ok := p.Payload.(*swils.ELP); ok {
+				a.advertiseELP(e.Direction, elp)
+			}
+		}
+	}
+}
+
+func (a *Analyzer) advertise(dir Direction, p els.PLOGICommonSvcParams) {
+	a.set(dir, &login{credit: p.B2BCredits, bbscn: p.BBSCN})
+}
+
+func (a *Analyzer) advertiseELP(dir Direction, elp *swils.ELP) {
+	l := &login{bbscn: int(elp.BBSCN)}
+	switch p := elp.FlowControl.Params.(type) {
+	case *swils.RRDYFlowControl:
+		l.credit = int(p.BBCredit)
+	case *swils.VCRDYFlowControl:
+		l.credit = int(p.BBCredit)
+	}
+	a.set(dir, l)
+}
+
+// set records what the sender of dir advertised. BB_SC_N is only used if
+// both ports support credit recovery, with the larger of the two values.
+func (a *Analyzer) set(dir Direction, l *login) {
+	a.advertised[dir] = l
+	a.dirs[dir.Reverse()].BBCredit = l.credit
+	o := a.advertised[dir.Reverse()]
+	bbscn := 0
+	if o != nil && o.bbscn > 0 && l.bbscn > 0 {
+		bbscn = l.bbscn
+		if o.bbscn > bbscn {
+			bbscn = o.bbscn
+		}
+	}
+	a.dirs[AToB].BBSCN = bbscn
+	a.dirs[BToA].BBSCN = bbscn
+}
+
+// ZeroCreditRatio returns the share of the trace the transmitter spent
+// without credit. A high ratio in one direction points at a receiver
+// draining its buffers slowly.
+func (s *Stats) ZeroCreditRatio(d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(s.ZeroCreditTime) / float64(d)
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	for i, s := range r.Directions {
+		fmt.Fprintf(&b, "%v: BB_Credit %d, BB_SC_N %d, %d frames, %d R_RDYs, max %d outstanding\n",
+			Direction(i), s.BBCredit, s.BBSCN, s.Frames, s.RRDYs, s.MaxOutstanding)
+		fmt.Fprintf(&b, "  zero credit %d times for %v (%.1f%%), longest %v\n",
+			s.ZeroCredit, s.ZeroCreditTime, 100*s.ZeroCreditRatio(r.Duration), s.LongestZeroCredit)
+		fmt.Fprintf(&b, "  %d violations, %d excess R_RDYs, %d lost frames, %d lost R_RDYs\n",
+			s.Violations, s.ExcessRRDYs, s.LostFrames, s.LostRRDYs)
+	}
+	return b.String()
+}
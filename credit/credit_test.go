@@ -0,0 +1,246 @@
+package credit
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/els"
+	"github.com/bluecmd/fibrechannel/primitive"
+	"github.com/bluecmd/fibrechannel/swils"
+)
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// accept turns a request into its accept by replacing the command byte.
+func accept(t *testing.T, req io.WriterTo, cmd byte, acc io.ReaderFrom) {
+	var b bytes.Buffer
+	if _, err := req.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	raw := b.Bytes()
+	raw[0] = cmd
+	if _, err := acc.ReadFrom(bytes.NewReader(raw)); err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+}
+
+func plogi(credit, bbscn int) *els.PLOGI {
+	p := &els.PLOGI{}
+	p.CommonSvcParams.B2BCredits = credit
+	p.CommonSvcParams.BBSCN = bbscn
+	return p
+}
+
+// trace feeds events one millisecond apart, lower case sent by A and upper
+// case by B: "f" a frame, "r" R_RDY, "s" BB_SCs, "c" BB_SCr and "." nothing.
+type trace struct {
+	t *testing.T
+	a *Analyzer
+	n int
+}
+
+func (tr *trace) add(e Event) {
+	e.Time = t0.Add(time.Duration(tr.n) * time.Millisecond)
+	tr.n++
+	if err := tr.a.Add(e); err != nil {
+		tr.t.Fatalf("Add: %v", err)
+	}
+}
+
+func (tr *trace) run(s string) {
+	for _, c := range s {
+		e := Event{Direction: AToB}
+		if c >= 'A' && c <= 'Z' {
+			e.Direction = BToA
+			c += 'a' - 'A'
+		}
+		switch c {
+		case 'f':
+			e.Frame = &fc.Frame{}
+		case 'r':
+			e.Primitive.Type = primitive.TypeRRDY
+		case 's':
+			e.Primitive.Type = primitive.TypeBBSCs
+		case 'c':
+			e.Primitive.Type = primitive.TypeBBSCr
+		}
+		tr.add(e)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	a := NewAnalyzer()
+	tr := &trace{t: t, a: a}
+	tr.add(Event{Direction: AToB, Frame: &fc.Frame{OXID: 1, Payload: &els.Frame{Payload: (*els.FLOGI)(plogi(8, 2))}}})
+	acc := &els.Frame{}
+	accept(t, &els.Frame{Payload: plogi(3, 1)}, els.CmdLSACC, acc)
+	tr.add(Event{Direction: BToA, Frame: &fc.Frame{OXID: 1, Payload: acc}})
+
+	r := a.Report()
+	if got := r.Directions[AToB]; got.BBCredit != 3 || got.BBSCN != 2 {
+		t.Errorf("A->B got BB_Credit %d BB_SC_N %d, wanted 3 and 2", got.BBCredit, got.BBSCN)
+	}
+	if got := r.Directions[BToA]; got.BBCredit != 8 || got.BBSCN != 2 {
+		t.Errorf("B->A got BB_Credit %d BB_SC_N %d, wanted 8 and 2", got.BBCredit, got.BBSCN)
+	}
+}
+
+func TestFabricPLOGI(t *testing.T) {
+	login := func(tr *trace, oxid uint16, did common.FCID, req interface{}, acc *els.PLOGI) {
+		tr.add(Event{Direction: AToB, Frame: &fc.Frame{OXID: oxid, DestinationID: did, Payload: &els.Frame{Payload: req}}})
+		f := &els.Frame{}
+		accept(t, &els.Frame{Payload: acc}, els.CmdLSACC, f)
+		tr.add(Event{Direction: BToA, Frame: &fc.Frame{OXID: oxid, SourceID: did, Payload: f}})
+	}
+	fport := plogi(3, 1)
+	fport.CommonSvcParams.NorFPort = true
+
+	// PLOGIs to the name server and to a remote N_Port leave the credit
+	// given by the F_Port alone
+	a := NewAnalyzer()
+	tr := &trace{t: t, a: a}
+	login(tr, 1, common.FPortController, (*els.FLOGI)(plogi(8, 2)), fport)
+	login(tr, 2, common.DirectoryServer, plogi(40, 0), plogi(50, 0))
+	login(tr, 3, common.FCID{0x01, 0x02, 0x00}, plogi(60, 0), plogi(70, 0))
+	r := a.Report()
+	if got := r.Directions[AToB]; got.BBCredit != 3 || got.BBSCN != 2 {
+		t.Errorf("A->B got BB_Credit %d BB_SC_N %d, wanted 3 and 2", got.BBCredit, got.BBSCN)
+	}
+	if got := r.Directions[BToA]; got.BBCredit != 8 || got.BBSCN != 2 {
+		t.Errorf("B->A got BB_Credit %d BB_SC_N %d, wanted 8 and 2", got.BBCredit, got.BBSCN)
+	}
+
+	// On a point-to-point link the PLOGI sets the credit
+	a = NewAnalyzer()
+	tr = &trace{t: t, a: a}
+	login(tr, 1, common.FCID{0x00, 0x00, 0x01}, (*els.FLOGI)(plogi(8, 2)), plogi(3, 1))
+	login(tr, 2, common.FCID{0x00, 0x00, 0x01}, plogi(60, 0), plogi(70, 0))
+	r = a.Report()
+	if got := r.Directions[AToB].BBCredit; got != 70 {
+		t.Errorf("A->B got BB_Credit %d, wanted 70", got)
+	}
+	if got := r.Directions[BToA].BBCredit; got != 60 {
+		t.Errorf("B->A got BB_Credit %d, wanted 60", got)
+	}
+}
+
+func TestELP(t *testing.T) {
+	elp := func(credit uint32) *swils.Frame {
+		return &swils.Frame{Command: swils.CmdELP, Payload: &swils.ELP{
+			Revision:    3,
+			FlowControl: swils.FlowControl{Mode: 2, Params: &swils.RRDYFlowControl{BBCredit: credit}},
+		}}
+	}
+	a := NewAnalyzer()
+	tr := &trace{t: t, a: a}
+	tr.add(Event{Direction: BToA, Frame: &fc.Frame{OXID: 7, Payload: elp(16)}})
+	acc := &swils.Frame{}
+	accept(t, elp(20), swils.CmdSWACC, acc)
+	tr.add(Event{Direction: AToB, Frame: &fc.Frame{OXID: 7, Payload: acc}})
+
+	r := a.Report()
+	if got := r.Directions[AToB].BBCredit; got != 16 {
+		t.Errorf("A->B got BB_Credit %d, wanted 16", got)
+	}
+	if got := r.Directions[BToA].BBCredit; got != 20 {
+		t.Errorf("B->A got BB_Credit %d, wanted 20", got)
+	}
+}
+
+func TestAnalyzer(t *testing.T) {
+	var tests = []struct {
+		desc     string
+		credit   int
+		bbscn    int
+		simulate bool
+		trace    string
+		want     Stats
+	}{
+		{
+			desc:   "credit returned in time",
+			credit: 2,
+			trace:  "fRfRfR",
+			want:   Stats{Frames: 3, RRDYs: 3, MaxOutstanding: 1},
+		},
+		{
+			desc:   "zero credit",
+			credit: 2,
+			trace:  "ff...RfR",
+			want: Stats{Frames: 3, RRDYs: 2, MaxOutstanding: 2,
+				ZeroCredit: 2, ZeroCreditTime: 5 * time.Millisecond, LongestZeroCredit: 4 * time.Millisecond},
+		},
+		{
+			desc:   "violation and excess R_RDY",
+			credit: 1,
+			trace:  "ffRRR",
+			want: Stats{Frames: 2, RRDYs: 3, MaxOutstanding: 2,
+				ZeroCredit: 1, ZeroCreditTime: 3 * time.Millisecond, LongestZeroCredit: 3 * time.Millisecond,
+				Violations: 1, ExcessRRDYs: 1},
+		},
+		{
+			desc:   "zero credit at the end",
+			credit: 1,
+			trace:  "f..",
+			want: Stats{Frames: 1, MaxOutstanding: 1,
+				ZeroCredit: 1, ZeroCreditTime: 2 * time.Millisecond, LongestZeroCredit: 2 * time.Millisecond},
+		},
+		{
+			desc:   "lost R_RDY recovered by BB_SCr",
+			credit: 2,
+			bbscn:  1,
+			trace:  "ffRCf",
+			want: Stats{Frames: 3, RRDYs: 1, MaxOutstanding: 2, LostRRDYs: 1,
+				ZeroCredit: 1, ZeroCreditTime: 1 * time.Millisecond, LongestZeroCredit: 1 * time.Millisecond},
+		},
+		{
+			desc:   "lost frame reported by BB_SCs",
+			credit: 4,
+			bbscn:  1,
+			trace:  "fsRR",
+			want:   Stats{Frames: 1, RRDYs: 2, MaxOutstanding: 1, LostFrames: 1},
+		},
+		{
+			desc:     "simulated recovery",
+			credit:   4,
+			bbscn:    1,
+			simulate: true,
+			trace:    "ffRff",
+			want:     Stats{Frames: 4, RRDYs: 1, MaxOutstanding: 3, LostRRDYs: 1},
+		},
+		{
+			desc:   "no recovery without simulation",
+			credit: 4,
+			bbscn:  1,
+			trace:  "ffRff",
+			want:   Stats{Frames: 4, RRDYs: 1, MaxOutstanding: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			a := NewAnalyzer()
+			a.SimulateBBSC = tt.simulate
+			a.SetCredit(AToB, tt.credit, tt.bbscn)
+			(&trace{t: t, a: a}).run(tt.trace)
+			got := a.Report().Directions[AToB]
+			tt.want.BBCredit = tt.credit
+			tt.want.BBSCN = tt.bbscn
+			if got != tt.want {
+				t.Fatalf("unexpected Stats:\n- want: %+v\n-  got: %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOrder(t *testing.T) {
+	a := NewAnalyzer()
+	if err := a.Add(Event{Time: t0}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := a.Add(Event{Time: t0.Add(-time.Second)}); err != errOrder {
+		t.Fatalf("got unexpected error %v, wanted %v", err, errOrder)
+	}
+}
@@ -0,0 +1,186 @@
+// Package ct implements the Common Transport of FC-GS used to talk to the
+// Generic Services of a fabric, such as the name server of the Directory
+// Service at FFFFFCh.
+package ct
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+// Command is the Command/Response code of a CT_IU.
+type Command uint16
+
+const (
+	Revision = 0x01
+
+	GSTypeAlias      = 0xf8 // Alias Service
+	GSTypeManagement = 0xfa // Management Service
+	GSTypeTime       = 0xfb // Time Service
+	GSTypeDirectory  = 0xfc // Directory Service
+
+	GSSubtypeNameServer = 0x02 // Name server of the Directory Service
+
+	CmdFSRJT Command = 0x8001 // Reject Response
+	CmdFSACC Command = 0x8002 // Accept Response
+
+	ReasonInvalidCommand      = 0x01 // Invalid command code
+	ReasonInvalidVersion      = 0x02 // Invalid version level
+	ReasonLogicalError        = 0x03 // Logical error
+	ReasonInvalidIUSize       = 0x04 // Invalid CT_IU size
+	ReasonLogicalBusy         = 0x05 // Logical busy
+	ReasonProtocolError       = 0x07 // Protocol error
+	ReasonUnableToPerform     = 0x09 // Unable to perform command request
+	ReasonCommandNotSupported = 0x0b // Command not supported
+	ReasonVendorSpecific      = 0xff // Vendor specific error, see VendorSpecific
+)
+
+// Frame is a CT_IU, the CT preamble followed by the payload of the command
+// or response.
+//
+// RawPayload holds the whole payload if the command is not decoded, otherwise
+// any trailing bytes not consumed by Payload. Requests to the name server
+// are decoded by their command code, use DecodeAccept for the FS_ACC.
+type Frame struct {
+	Revision       uint8       `fc:"@0"`
+	INID           common.FCID `fc:"@1"`
+	GSType         uint8       `fc:"@4"`
+	GSSubtype      uint8       `fc:"@5"`
+	Options        uint8       `fc:"@6"`
+	Command        Command     `fc:"@8"`
+	MaxSize        uint16      `fc:"@10"`
+	FragmentID     uint8       `fc:"@12"`
+	Reason         uint8       `fc:"@13"`
+	Explanation    uint8       `fc:"@14"`
+	VendorSpecific uint8       `fc:"@15"`
+	RawPayload     []byte      `fc:"@16"`
+	Payload        interface{}
+}
+
+// NameServerRequest returns a request to the name server carrying p.
+func NameServerRequest(cmd Command, p interface{}) *Frame {
+	return &Frame{
+		Revision:  Revision,
+		GSType:    GSTypeDirectory,
+		GSSubtype: GSSubtypeNameServer,
+		Command:   cmd,
+		Payload:   p,
+	}
+}
+
+// Accept returns an FS_ACC to the request f carrying p, which may be nil.
+func (f *Frame) Accept(p interface{}) *Frame {
+	return &Frame{
+		Revision:  Revision,
+		GSType:    f.GSType,
+		GSSubtype: f.GSSubtype,
+		Command:   CmdFSACC,
+		Payload:   p,
+	}
+}
+
+// Reject returns an FS_RJT to the request f.
+func (f *Frame) Reject(reason, explanation uint8) *Frame {
+	return &Frame{
+		Revision:    Revision,
+		GSType:      f.GSType,
+		GSSubtype:   f.GSSubtype,
+		Command:     CmdFSRJT,
+		Reason:      reason,
+		Explanation: explanation,
+	}
+}
+
+func (f *Frame) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFromAndPost(r, f)
+}
+
+func (f *Frame) PostUnmarshal() error {
+	if f.GSType != GSTypeDirectory || f.GSSubtype != GSSubtypeNameServer {
+		return nil
+	}
+	var sf io.ReaderFrom
+	switch f.Command {
+	case CmdGIDFT:
+		sf = &GIDFT{}
+	case CmdGPNID, CmdGNNID, CmdGFTID, CmdDAID:
+		sf = &PortIdentifier{}
+	case CmdGIDPN:
+		sf = &NameIdentifier{}
+	case CmdRPNID:
+		sf = &RPNID{}
+	case CmdRNNID:
+		sf = &RNNID{}
+	case CmdRFTID:
+		sf = &RFTID{}
+	}
+	return f.decode(sf)
+}
+
+// DecodeAccept decodes the payload of an FS_ACC. The layout of an accept
+// depends on the request it answers, so the command of the originating
+// request has to be supplied by the caller.
+func (f *Frame) DecodeAccept(req Command) error {
+	if f.Command != CmdFSACC {
+		return fmt.Errorf("not an FS_ACC: command 0x%04x", uint16(f.Command))
+	}
+	if f.Payload != nil {
+		return nil
+	}
+	var sf io.ReaderFrom
+	switch req {
+	case CmdGIDFT:
+		sf = &PortIDList{}
+	case CmdGPNID, CmdGNNID:
+		sf = &NameIdentifier{}
+	case CmdGFTID:
+		sf = &FC4Types{}
+	case CmdGIDPN:
+		sf = &PortIdentifier{}
+	}
+	return f.decode(sf)
+}
+
+func (f *Frame) decode(sf io.ReaderFrom) error {
+	if sf == nil {
+		return nil
+	}
+
+	r := bytes.NewReader(f.RawPayload)
+	_, err := sf.ReadFrom(r)
+	if err != nil {
+		return err
+	}
+	f.Payload = sf
+	f.RawPayload = nil
+	if r.Len() > 0 {
+		f.RawPayload = make([]byte, r.Len())
+		r.Read(f.RawPayload)
+	}
+	return nil
+}
+
+func (f *Frame) PreMarshal() error {
+	if f.Payload == nil {
+		return nil
+	}
+	b := new(bytes.Buffer)
+	if _, err := f.Payload.(io.WriterTo).WriteTo(b); err != nil {
+		return err
+	}
+	b.Write(f.RawPayload)
+	f.RawPayload = b.Bytes()
+	return nil
+}
+
+func (f *Frame) WriteTo(w io.Writer) (int64, error) {
+	// PreMarshal prepends the encoded Payload to RawPayload, restore it
+	// afterwards so that the frame can be written more than once
+	raw := f.RawPayload
+	defer func() { f.RawPayload = raw }()
+	return encoding.WriteToAndPre(w, f)
+}
@@ -0,0 +1,83 @@
+package ct
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+func TestFrameFiles(t *testing.T) {
+	common.TestFrameFiles(t, func() common.SerDes { return &Frame{} })
+}
+
+func TestDecodeAccept(t *testing.T) {
+	preamble := []byte{0x01, 0x00, 0x00, 0x00, 0xfc, 0x02, 0x00, 0x00, 0x80, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	var tests = []struct {
+		desc string
+		req  Command
+		b    []byte
+		want interface{}
+	}{
+		{
+			desc: "GID_FT accept",
+			req:  CmdGIDFT,
+			b:    []byte{0x00, 0x01, 0x02, 0x00, 0x80, 0x01, 0x04, 0x00},
+			want: &PortIDList{{0x01, 0x02, 0x00}, {0x01, 0x04, 0x00}},
+		},
+		{
+			desc: "GPN_ID accept",
+			req:  CmdGPNID,
+			b:    []byte{0x21, 0x00, 0x00, 0x24, 0xff, 0x7e, 0xa4, 0x55},
+			want: &NameIdentifier{Name: common.WWN{0x21, 0x00, 0x00, 0x24, 0xff, 0x7e, 0xa4, 0x55}},
+		},
+		{
+			desc: "GID_PN accept",
+			req:  CmdGIDPN,
+			b:    []byte{0x00, 0x01, 0x02, 0x00},
+			want: &PortIdentifier{PortID: common.FCID{0x01, 0x02, 0x00}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			d := append(append([]byte{}, preamble...), tt.b...)
+			f := &Frame{}
+			if _, err := f.ReadFrom(bytes.NewReader(d)); err != nil {
+				t.Fatalf("ReadFrom: %v", err)
+			}
+			if f.Payload != nil {
+				t.Fatalf("FS_ACC decoded without request: %v", f.Payload)
+			}
+			if err := f.DecodeAccept(tt.req); err != nil {
+				t.Fatalf("DecodeAccept: %v", err)
+			}
+			if want, got := tt.want, f.Payload; !reflect.DeepEqual(want, got) {
+				t.Fatalf("unexpected payload:\n- want: %v\n-  got: %v", want, got)
+			}
+			b := new(bytes.Buffer)
+			if _, err := f.WriteTo(b); err != nil {
+				t.Fatalf("WriteTo: %v", err)
+			}
+			if want, got := d, b.Bytes(); !bytes.Equal(want, got) {
+				t.Fatalf("unexpected bytes:\n- want: %v\n-  got: %v", want, got)
+			}
+		})
+	}
+}
+
+func TestFC4Types(t *testing.T) {
+	var ft FC4Types
+	ft.Set(0x08)
+	ft.Set(0x28)
+	if ft[2] != 0x01 || ft[6] != 0x01 {
+		t.Fatalf("unexpected bitmap % x", ft[:8])
+	}
+	if want, got := []uint8{0x08, 0x28}, ft.List(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("got TYPEs %v, wanted %v", got, want)
+	}
+	if ft.Has(0x20) {
+		t.Fatalf("TYPE 0x20 not set but reported")
+	}
+}
@@ -0,0 +1,191 @@
+package ct
+
+import (
+	"io"
+
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+const (
+	CmdGIDFT Command = 0x0171 // Get Port Identifiers - FC-4 TYPE
+	CmdGPNID Command = 0x0112 // Get Port Name - Port Identifier
+	CmdGNNID Command = 0x0113 // Get Node Name - Port Identifier
+	CmdGFTID Command = 0x0117 // Get FC-4 TYPEs - Port Identifier
+	CmdGIDPN Command = 0x0121 // Get Port Identifier - Port Name
+	CmdRPNID Command = 0x0212 // Register Port Name - Port Identifier
+	CmdRNNID Command = 0x0213 // Register Node Name - Port Identifier
+	CmdRFTID Command = 0x0217 // Register FC-4 TYPEs - Port Identifier
+	CmdDAID  Command = 0x0300 // Deregister all - Port Identifier
+
+	ExplNone                  = 0x00 // No additional explanation
+	ExplPortIDNotRegistered   = 0x01 // Port Identifier not registered
+	ExplPortNameNotRegistered = 0x02 // Port Name not registered
+	ExplNodeNameNotRegistered = 0x03 // Node Name not registered
+	ExplFC4TypeNotRegistered  = 0x07 // FC-4 TYPEs not registered
+	ExplAccessDenied          = 0x10 // Access denied
+	ExplInvalidPortID         = 0x11 // Unacceptable Port Identifier
+	ExplDatabaseEmpty         = 0x12 // Data base empty
+
+	// lastEntry marks the last entry of a PortIDList
+	lastEntry = 0x80
+)
+
+// FC4Types is the FC-4 TYPEs bitmap, bit t%32 of word t/32 set if the
+// TYPE t is supported.
+type FC4Types [32]byte
+
+// PortIdentifier is the request of the queries by Port Identifier and of
+// DA_ID, and the accept of GID_PN.
+type PortIdentifier struct {
+	PortID common.FCID `fc:"@1"`
+}
+
+// NameIdentifier is the request of GID_PN and the accept of GPN_ID and
+// GNN_ID.
+type NameIdentifier struct {
+	Name common.WWN `fc:"@0"`
+}
+
+// GIDFT queries the Port Identifiers of the ports registered for an FC-4
+// TYPE. A DomainScope or AreaScope other than zero limits the query to that
+// domain or area.
+type GIDFT struct {
+	DomainScope uint8 `fc:"@1"`
+	AreaScope   uint8 `fc:"@2"`
+	Type        uint8 `fc:"@3"`
+}
+
+// PortIDList is the accept of GID_FT, a list of Port Identifiers.
+type PortIDList []common.FCID
+
+// RPNID registers the Port Name of a port.
+type RPNID struct {
+	PortID   common.FCID `fc:"@1"`
+	PortName common.WWN  `fc:"@4"`
+}
+
+// RNNID registers the Node Name of a port.
+type RNNID struct {
+	PortID   common.FCID `fc:"@1"`
+	NodeName common.WWN  `fc:"@4"`
+}
+
+// RFTID registers the FC-4 TYPEs supported by a port.
+type RFTID struct {
+	PortID common.FCID `fc:"@1"`
+	Types  FC4Types    `fc:"@4"`
+}
+
+// Set marks the TYPE t as supported.
+func (o *FC4Types) Set(t uint8) {
+	o[t/32*4+3-t%32/8] |= 1 << (t % 8)
+}
+
+// Has reports whether the TYPE t is supported.
+func (o *FC4Types) Has(t uint8) bool {
+	return o[t/32*4+3-t%32/8]&(1<<(t%8)) != 0
+}
+
+// List returns the supported TYPEs in order.
+func (o *FC4Types) List() []uint8 {
+	var r []uint8
+	for t := 0; t < 256; t++ {
+		if o.Has(uint8(t)) {
+			r = append(r, uint8(t))
+		}
+	}
+	return r
+}
+
+func (o *FC4Types) ReadFrom(r io.Reader) (int64, error) {
+	n, err := io.ReadFull(r, o[:])
+	return int64(n), err
+}
+
+func (o *FC4Types) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(o[:])
+	return int64(n), err
+}
+
+func (o *PortIdentifier) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *PortIdentifier) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
+
+func (o *NameIdentifier) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *NameIdentifier) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
+
+func (o *GIDFT) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *GIDFT) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
+
+func (o *RPNID) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *RPNID) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
+
+func (o *RNNID) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *RNNID) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
+
+func (o *RFTID) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *RFTID) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
+
+// ReadFrom reads entries up to the one marked as the last.
+func (o *PortIDList) ReadFrom(r io.Reader) (int64, error) {
+	*o = PortIDList{}
+	var n int64
+	for {
+		var b [4]byte
+		m, err := io.ReadFull(r, b[:])
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+		*o = append(*o, common.FCID{b[1], b[2], b[3]})
+		if b[0]&lastEntry != 0 {
+			return n, nil
+		}
+	}
+}
+
+func (o *PortIDList) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for i, id := range *o {
+		b := []byte{0, id[0], id[1], id[2]}
+		if i == len(*o)-1 {
+			b[0] = lastEntry
+		}
+		m, err := w.Write(b)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
@@ -9,54 +9,57 @@ import (
 
 func main() {
 	fc := e.NewStruct("Frame")
+	// ReadFrom and WriteTo in frame.go handle the Extended_Headers and the
+	// CRC trailer around the generated code
+	fc.Wrapped = true
 
 	sof := &e.Enum{
 		Name: "SOF",
 		Size: 1 * e.Bytes,
 		Values: map[string]e.Constant{
-			"SOFf":  {Value: 0x1, Comment: "TODO"},
-			"SOFi4": {Value: 0x1, Comment: "TODO"},
-			"SOFi2": {Value: 0x1, Comment: "TODO"},
-			"SOFi3": {Value: 0x1, Comment: "TODO"},
-			"SOFn4": {Value: 0x1, Comment: "TODO"},
-			"SOFn2": {Value: 0x1, Comment: "TODO"},
-			"SOFn3": {Value: 0x1, Comment: "TODO"},
-			"SOFc4": {Value: 0x1, Comment: "TODO"},
+			"SOFf":  {Value: 0x1, Comment: "SOF Fabric (Class F)"},
+			"SOFi4": {Value: 0x2, Comment: "SOF Initiate Class 4"},
+			"SOFi2": {Value: 0x3, Comment: "SOF Initiate Class 2"},
+			"SOFi3": {Value: 0x4, Comment: "SOF Initiate Class 3"},
+			"SOFn4": {Value: 0x5, Comment: "SOF Normal Class 4"},
+			"SOFn2": {Value: 0x6, Comment: "SOF Normal Class 2"},
+			"SOFn3": {Value: 0x7, Comment: "SOF Normal Class 3"},
+			"SOFc4": {Value: 0x8, Comment: "SOF Activate Class 4"},
 		}}
 
 	eof := &e.Enum{
 		Name: "EOF",
 		Size: 1 * e.Bytes,
 		Values: map[string]e.Constant{
-			"EOFn":   {Value: 0x1, Comment: "TODO"},
-			"EOFt":   {Value: 0x1, Comment: "TODO"},
-			"EOFrt":  {Value: 0x1, Comment: "TODO"},
-			"EOFdt":  {Value: 0x1, Comment: "TODO"},
-			"EOFni":  {Value: 0x1, Comment: "TODO"},
-			"EOFdti": {Value: 0x1, Comment: "TODO"},
-			"EOFrti": {Value: 0x1, Comment: "TODO"},
-			"EOFa":   {Value: 0x1, Comment: "TODO"},
+			"EOFn":   {Value: 0x1, Comment: "EOF Normal"},
+			"EOFt":   {Value: 0x2, Comment: "EOF Terminate"},
+			"EOFrt":  {Value: 0x3, Comment: "EOF Remove Terminate (Class 4)"},
+			"EOFdt":  {Value: 0x4, Comment: "EOF Disconnect-Terminate"},
+			"EOFni":  {Value: 0x5, Comment: "EOF Normal-Invalid"},
+			"EOFdti": {Value: 0x6, Comment: "EOF Disconnect-Terminate-Invalid"},
+			"EOFrti": {Value: 0x7, Comment: "EOF Remove Terminate-Invalid (Class 4)"},
+			"EOFa":   {Value: 0x8, Comment: "EOF Abort"},
 		}}
 
 	t := &e.Enum{
 		Name: "Type",
 		Size: 1 * e.Bytes,
 		Values: map[string]e.Constant{
-			"TypeBLS":      {Value: 0x1, Comment: "TODO"},
+			"TypeBLS":      {Value: 0x0, Comment: "TODO"},
 			"TypeELS":      {Value: 0x1, Comment: "TODO"},
-			"TypeLLCSNAP":  {Value: 0x1, Comment: "TODO"},
-			"TypeIP":       {Value: 0x1, Comment: "TODO"},
-			"TypeFCP":      {Value: 0x1, Comment: "TODO"},
-			"TypeGPP":      {Value: 0x1, Comment: "TODO"},
-			"TypeSBToCU":   {Value: 0x1B, Comment: "FICON / FC-SB-3: Control Unit -> Channel"},
-			"TypeSBFromCU": {Value: 0x1C, Comment: "FICON / FC-SB-3: Channel -> Control Unit"},
-			"TypeFCCT":     {Value: 0x1, Comment: "TODO"},
-			"TypeSWILS":    {Value: 0x1, Comment: "TODO"},
-			"TypeAL":       {Value: 0x1, Comment: "TODO"},
-			"TypeSNMP":     {Value: 0x1, Comment: "TODO"},
-			"TypeNVME":     {Value: 0x1, Comment: "TODO"},
-			"TypeSPINFAB":  {Value: 0x1, Comment: "TODO"},
-			"TypeDIAG":     {Value: 0x1, Comment: "TODO"},
+			"TypeLLCSNAP":  {Value: 0x4, Comment: "TODO"},
+			"TypeIP":       {Value: 0x5, Comment: "TODO"},
+			"TypeFCP":      {Value: 0x8, Comment: "TODO"},
+			"TypeGPP":      {Value: 0x9, Comment: "TODO"},
+			"TypeSBToCU":   {Value: 0x1B, Comment: "FICON / FC-SB-3: Channel -> Control Unit"},
+			"TypeSBFromCU": {Value: 0x1C, Comment: "FICON / FC-SB-3: Control Unit -> Channel"},
+			"TypeFCCT":     {Value: 0x20, Comment: "TODO"},
+			"TypeSWILS":    {Value: 0x22, Comment: "TODO"},
+			"TypeAL":       {Value: 0x23, Comment: "TODO"},
+			"TypeSNMP":     {Value: 0x24, Comment: "TODO"},
+			"TypeNVME":     {Value: 0x28, Comment: "TODO"},
+			"TypeSPINFAB":  {Value: 0xEE, Comment: "TODO"},
+			"TypeDIAG":     {Value: 0xEF, Comment: "TODO"},
 		}}
 
 	fctl := e.NewBitStruct("FrameControl")
@@ -64,6 +67,12 @@ func main() {
 	prioen := fctl.BoolBit("PriorityEnable")
 	fctl.IntField("TODO2", 17)
 
+	// Extended_Headers (VFT, IFR and Enc) in front of the Frame_Header are
+	// not expressible here, they are handled in exthdr.go
+	fc.Field("Encapsulation", &e.External{Name: "*EncapsulationHeader"})
+	fc.Field("IFR", &e.External{Name: "*IFRHeader"})
+	fc.Field("VFT", &e.External{Name: "*VFTHeader"})
+
 	fc.Field("RCtl", e.Uint8)
 	// Address for source/destination Nx_Ports
 	// Each Nx_Port shall have a native N_Port_ID that is unique within the
@@ -71,7 +80,7 @@ func main() {
 	// an Nx_Port is unidentified. When a PN_Port completes Link Initialization,
 	// it shall be unidentified (i.e., it shall have a single Nx_Port for which
 	// the N_Port_ID is 00 00 00h).
-	fc.Field("DestinationID", &e.ByteArray{Count: 3})
+	fc.Field("DestinationID", &e.ByteArray{Count: 3, Name: "common.FCID"})
 
 	csctl := e.NewStruct("CSCtl")
 	csctl.Field("Data", e.Uint8)
@@ -88,7 +97,7 @@ func main() {
 		}}
 	fc.Field("CsctlPriority", csctlPrio)
 
-	fc.Field("SourceID", &e.ByteArray{Count: 3})
+	fc.Field("SourceID", &e.ByteArray{Count: 3, Name: "common.FCID"})
 
 	ftype := fc.Field("fcType", t)
 
@@ -117,17 +126,34 @@ func main() {
 
 	fc.Field("Parameters", &e.ByteArray{Count: 4})
 
+	// TypeNVME and FICON payloads are selected by R_CTL as well as TYPE, and
+	// payloads of other types are kept as []byte, neither of which a
+	// SwitchedType can express, see payload.go
 	payload := &e.SwitchedType{
 		Name:       "Payload",
 		Size:       e.RemainingBytes,
 		SwitchedOn: ftype,
 		Cases: map[string]e.Type{
-			"TypeELS": &e.Object{Class: "els.Frame"},
-		}}
+			"TypeELS":   &e.Object{Class: "els.Frame"},
+			"TypeSWILS": &e.Object{Class: "swils.Frame"},
+			"TypeFCCT":  &e.Object{Class: "ct.Frame"},
+		},
+		Fallback: true,
+	}
 	fc.Field("Payload", payload)
 
+	// The optional CRC trailer is handled in crc.go
+	fc.Field("CRCTrailer", &e.External{Name: "bool"}).Comment =
+		"CRCTrailer makes ReadFrom expect, and WriteTo append, the CRC after\n" +
+			"the data field, like in frames captured by analyzers. CRC holds the\n" +
+			"CRC read or written."
+	fc.Field("CRC", &e.External{Name: "uint32"})
+
 	imports := []string{
+		"github.com/bluecmd/fibrechannel/common",
+		"github.com/bluecmd/fibrechannel/ct",
 		"github.com/bluecmd/fibrechannel/els",
+		"github.com/bluecmd/fibrechannel/swils",
 	}
 	b, err := e.Generate("fibrechannel", imports, fc, sof, eof)
 	if err != nil {
@@ -0,0 +1,92 @@
+package fibrechannel
+
+// Class returns the class of service started by the SOF, 0 for Class F.
+func (o SOF) Class() int {
+	switch o {
+	case SOFi2, SOFn2:
+		return 2
+	case SOFi3, SOFn3:
+		return 3
+	case SOFi4, SOFn4, SOFc4:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// Initiate reports whether the SOF starts a new Sequence. SOFf is used for
+// all Class F frames and does not tell.
+func (o SOF) Initiate() bool {
+	return o == SOFi2 || o == SOFi3 || o == SOFi4
+}
+
+// Terminate reports whether the EOF ends the Sequence.
+func (o EOF) Terminate() bool {
+	switch o {
+	case EOFt, EOFdt, EOFrt, EOFdti, EOFrti:
+		return true
+	default:
+		return false
+	}
+}
+
+// Invalid reports whether the EOF marks the frame content as invalid, this
+// includes frames aborted by the sender.
+func (o EOF) Invalid() bool {
+	return o == EOFni || o == EOFdti || o == EOFrti || o == EOFa
+}
+
+// sofCodes and eofCodes map the delimiters to the codes they are encoded as
+// in the frame encapsulations of FC-BB and RFC 3643, such as FCoE and FCIP.
+var (
+	sofCodes = map[SOF]uint8{
+		SOFf:  0x28,
+		SOFi4: 0x29,
+		SOFi2: 0x2D,
+		SOFi3: 0x2E,
+		SOFn4: 0x31,
+		SOFn2: 0x35,
+		SOFn3: 0x36,
+		SOFc4: 0x39,
+	}
+	eofCodes = map[EOF]uint8{
+		EOFn:   0x41,
+		EOFt:   0x42,
+		EOFrt:  0x44,
+		EOFdt:  0x46,
+		EOFni:  0x49,
+		EOFdti: 0x4E,
+		EOFrti: 0x4F,
+		EOFa:   0x50,
+	}
+)
+
+// Code returns the encapsulation code of the SOF, 0 if it has none.
+func (o SOF) Code() uint8 {
+	return sofCodes[o]
+}
+
+// Code returns the encapsulation code of the EOF, 0 if it has none.
+func (o EOF) Code() uint8 {
+	return eofCodes[o]
+}
+
+// SOFFromCode returns the SOF encoded as c in a frame encapsulation.
+func SOFFromCode(c uint8) (SOF, bool) {
+	for k, v := range sofCodes {
+		if v == c {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// EOFFromCode returns the EOF encoded as c in a frame encapsulation.
+func EOFFromCode(c uint8) (EOF, bool) {
+	for k, v := range eofCodes {
+		if v == c {
+			return k, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,26 @@
+package els
+
+import (
+	"io"
+
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+// ADISC exchanges the addresses and names of two logged in N_Ports, to
+// check that a login is still valid. The LS_ACC of an ADISC has the same
+// layout and can be decoded by reading the LS_ACC Data into an ADISC.
+type ADISC struct {
+	HardAddress common.FCID `fc:"@4"`
+	PortName    common.WWN  `fc:"@7"`
+	NodeName    common.WWN  `fc:"@15"`
+	PortID      common.FCID `fc:"@24"`
+}
+
+func (o *ADISC) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *ADISC) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
@@ -78,9 +78,9 @@ func main() {
 		Name: "Route",
 		Size: 1 * Bytes,
 		Values: map[string]Constant{
-			"RouteSolicited": {Value: 0x1, Comment: "Solicited ELS"},
-			"RouteRequest":   {Value: 0x1, Comment: "ELS Request"},
-			"RouteReply":     {Value: 0x1, Comment: "ELS Reply"},
+			"RouteSolicited": {Value: 0x21, Comment: "Solicited ELS"},
+			"RouteRequest":   {Value: 0x22, Comment: "ELS Request"},
+			"RouteReply":     {Value: 0x23, Comment: "ELS Reply"},
 		}}
 
 	cmd := &Enum{
@@ -88,78 +88,95 @@ func main() {
 		Size: 1 * Bytes,
 		Values: map[string]Constant{
 			"CmdLSRJT":     {Value: 0x1, Comment: "ESL reject"},
-			"CmdLSACC":     {Value: 0x1, Comment: "ESL Accept"},
-			"CmdPLOGI":     {Value: 0x1, Comment: "N_Port login"},
-			"CmdFLOGI":     {Value: 0x1, Comment: "F_Port login"},
-			"CmdLOGO":      {Value: 0x1, Comment: "Logout"},
-			"CmdABTX":      {Value: 0x1, Comment: "Abort exchange - obsolete"},
-			"CmdRCS":       {Value: 0x1, Comment: "read connection status"},
-			"CmdRES":       {Value: 0x1, Comment: "read exchange status block"},
-			"CmdRSS":       {Value: 0x1, Comment: "read sequence status block"},
-			"CmdRSI":       {Value: 0x1, Comment: "read sequence initiative"},
-			"CmdESTS":      {Value: 0x1, Comment: "establish streaming"},
-			"CmdESTC":      {Value: 0x1, Comment: "estimate credit"},
-			"CmdADVC":      {Value: 0x1, Comment: "advise credit"},
-			"CmdRTV":       {Value: 0x1, Comment: "read timeout value"},
-			"CmdRLS":       {Value: 0x1, Comment: "read link error status block"},
-			"CmdEcho":      {Value: 0x1, Comment: "echo"},
-			"CmdTest":      {Value: 0x1, Comment: "test"},
-			"CmdRRQ":       {Value: 0x1, Comment: "reinstate recovery qualifier"},
-			"CmdREC":       {Value: 0x1, Comment: "read exchange concise"},
-			"CmdSRR":       {Value: 0x1, Comment: "sequence retransmission request"},
-			"CmdPRLI":      {Value: 0x1, Comment: "process login"},
-			"CmdPRLO":      {Value: 0x1, Comment: "process logout"},
-			"CmdSCN":       {Value: 0x1, Comment: "state change notification"},
-			"CmdTPLS":      {Value: 0x1, Comment: "test process login state"},
-			"CmdTPRLO":     {Value: 0x1, Comment: "third party process logout"},
-			"CmdLCLM":      {Value: 0x1, Comment: "login control list mgmt (obs)"},
-			"CmdGAID":      {Value: 0x1, Comment: "get alias_ID"},
-			"CmdFACT":      {Value: 0x1, Comment: "fabric activate alias_id"},
-			"CmdFDACDT":    {Value: 0x1, Comment: "fabric deactivate alias_id"},
-			"CmdNACT":      {Value: 0x1, Comment: "N-port activate alias_id"},
-			"CmdNDACT":     {Value: 0x1, Comment: "N-port deactivate alias_id"},
-			"CmdQOSR":      {Value: 0x1, Comment: "quality of service request"},
-			"CmdRVCS":      {Value: 0x1, Comment: "read virtual circuit status"},
-			"CmdPDISC":     {Value: 0x1, Comment: "discover N_port service params"},
-			"CmdFDISC":     {Value: 0x1, Comment: "discover F_port service params"},
-			"CmdADISC":     {Value: 0x1, Comment: "discover address"},
-			"CmdRNC":       {Value: 0x1, Comment: "report node cap (obs)"},
-			"CmdFARPReq":   {Value: 0x1, Comment: "FC ARP request"},
-			"CmdFARPReply": {Value: 0x1, Comment: "FC ARP reply"},
-			"CmdRPS":       {Value: 0x1, Comment: "read port status block"},
-			"CmdRPL":       {Value: 0x1, Comment: "read port list"},
-			"CmdRPBC":      {Value: 0x1, Comment: "read port buffer condition"},
-			"CmdFAN":       {Value: 0x1, Comment: "fabric address notification"},
-			"CmdRSCN":      {Value: 0x1, Comment: "registered state change notification"},
-			"CmdSCR":       {Value: 0x1, Comment: "state change registration"},
-			"CmdRNFT":      {Value: 0x1, Comment: "report node FC-4 types"},
-			"CmdCSR":       {Value: 0x1, Comment: "clock synch. request"},
-			"CmdCSU":       {Value: 0x1, Comment: "clock synch. update"},
-			"CmdLInit":     {Value: 0x1, Comment: "loop initialize"},
-			"CmdLSTS":      {Value: 0x1, Comment: "loop status"},
-			"CmdRNID":      {Value: 0x1, Comment: "request node ID data"},
-			"CmdRLIR":      {Value: 0x1, Comment: "registered link incident report"},
-			"CmdLIRR":      {Value: 0x1, Comment: "link incident record registration"},
-			"CmdSRL":       {Value: 0x1, Comment: "scan remote loop"},
-			"CmdSBRP":      {Value: 0x1, Comment: "set bit-error reporting params"},
-			"CmdRPSC":      {Value: 0x1, Comment: "report speed capabilities"},
-			"CmdQSA":       {Value: 0x1, Comment: "query security attributes"},
-			"CmdEVFP":      {Value: 0x1, Comment: "exchange virt. fabrics params"},
-			"CmdLKA":       {Value: 0x1, Comment: "link keep-alive"},
-			"CmdAuthELS":   {Value: 0x1, Comment: "authentication ELS"},
+			"CmdLSACC":     {Value: 0x2, Comment: "ESL Accept"},
+			"CmdPLOGI":     {Value: 0x3, Comment: "N_Port login"},
+			"CmdFLOGI":     {Value: 0x4, Comment: "F_Port login"},
+			"CmdLOGO":      {Value: 0x5, Comment: "Logout"},
+			"CmdABTX":      {Value: 0x6, Comment: "Abort exchange - obsolete"},
+			"CmdRCS":       {Value: 0x7, Comment: "read connection status"},
+			"CmdRES":       {Value: 0x8, Comment: "read exchange status block"},
+			"CmdRSS":       {Value: 0x9, Comment: "read sequence status block"},
+			"CmdRSI":       {Value: 0xA, Comment: "read sequence initiative"},
+			"CmdESTS":      {Value: 0xB, Comment: "establish streaming"},
+			"CmdESTC":      {Value: 0xC, Comment: "estimate credit"},
+			"CmdADVC":      {Value: 0xD, Comment: "advise credit"},
+			"CmdRTV":       {Value: 0xE, Comment: "read timeout value"},
+			"CmdRLS":       {Value: 0xF, Comment: "read link error status block"},
+			"CmdEcho":      {Value: 0x10, Comment: "echo"},
+			"CmdTest":      {Value: 0x11, Comment: "test, loop initialization in FC-AL"},
+			"CmdRRQ":       {Value: 0x12, Comment: "reinstate recovery qualifier"},
+			"CmdREC":       {Value: 0x13, Comment: "read exchange concise"},
+			"CmdSRR":       {Value: 0x14, Comment: "sequence retransmission request"},
+			"CmdPRLI":      {Value: 0x20, Comment: "process login"},
+			"CmdPRLO":      {Value: 0x21, Comment: "process logout"},
+			"CmdSCN":       {Value: 0x22, Comment: "state change notification"},
+			"CmdTPLS":      {Value: 0x23, Comment: "test process login state"},
+			"CmdTPRLO":     {Value: 0x24, Comment: "third party process logout"},
+			"CmdLCLM":      {Value: 0x25, Comment: "login control list mgmt (obs)"},
+			"CmdGAID":      {Value: 0x30, Comment: "get alias_ID"},
+			"CmdFACT":      {Value: 0x31, Comment: "fabric activate alias_id"},
+			"CmdFDACDT":    {Value: 0x32, Comment: "fabric deactivate alias_id"},
+			"CmdNACT":      {Value: 0x33, Comment: "N-port activate alias_id"},
+			"CmdNDACT":     {Value: 0x34, Comment: "N-port deactivate alias_id"},
+			"CmdQOSR":      {Value: 0x40, Comment: "quality of service request"},
+			"CmdRVCS":      {Value: 0x41, Comment: "read virtual circuit status"},
+			"CmdPDISC":     {Value: 0x50, Comment: "discover N_port service params"},
+			"CmdFDISC":     {Value: 0x51, Comment: "discover F_port service params"},
+			"CmdADISC":     {Value: 0x52, Comment: "discover address"},
+			"CmdRNC":       {Value: 0x53, Comment: "report node cap (obs)"},
+			"CmdFARPReq":   {Value: 0x54, Comment: "FC ARP request"},
+			"CmdFARPReply": {Value: 0x55, Comment: "FC ARP reply"},
+			"CmdRPS":       {Value: 0x56, Comment: "read port status block"},
+			"CmdRPL":       {Value: 0x57, Comment: "read port list"},
+			"CmdRPBC":      {Value: 0x58, Comment: "read port buffer condition"},
+			"CmdFAN":       {Value: 0x60, Comment: "fabric address notification"},
+			"CmdRSCN":      {Value: 0x61, Comment: "registered state change notification"},
+			"CmdSCR":       {Value: 0x62, Comment: "state change registration"},
+			"CmdRNFT":      {Value: 0x63, Comment: "report node FC-4 types"},
+			"CmdCSR":       {Value: 0x68, Comment: "clock synch. request"},
+			"CmdCSU":       {Value: 0x69, Comment: "clock synch. update"},
+			"CmdLInit":     {Value: 0x70, Comment: "loop initialize"},
+			"CmdLSTS":      {Value: 0x72, Comment: "loop status"},
+			"CmdRNID":      {Value: 0x78, Comment: "request node ID data"},
+			"CmdRLIR":      {Value: 0x79, Comment: "registered link incident report"},
+			"CmdLIRR":      {Value: 0x7A, Comment: "link incident record registration"},
+			"CmdSRL":       {Value: 0x7B, Comment: "scan remote loop"},
+			"CmdSBRP":      {Value: 0x7C, Comment: "set bit-error reporting params"},
+			"CmdRPSC":      {Value: 0x7D, Comment: "report speed capabilities"},
+			"CmdQSA":       {Value: 0x7E, Comment: "query security attributes"},
+			"CmdEVFP":      {Value: 0x7F, Comment: "exchange virt. fabrics params"},
+			"CmdLKA":       {Value: 0x80, Comment: "link keep-alive"},
+			"CmdAuthELS":   {Value: 0x90, Comment: "authentication ELS"},
 		}}
 
 	plogi := defPLOGI()
 
 	fcmd := els.Field("cmd", cmd)
 
+	// CmdTest is a TEST or a loop initialization sequence depending on
+	// the first byte of the payload, which a SwitchedType cannot express,
+	// see frame.go
 	var payload = &SwitchedType{
 		Name:       "Payload",
 		Size:       RemainingBytes,
 		SwitchedOn: fcmd,
 		Cases: map[string]Type{
 			"CmdPLOGI": plogi,
+			"CmdFLOGI": &Object{Class: "FLOGI"},
+			"CmdFDISC": &Object{Class: "FDISC"},
+			"CmdLOGO":  &Object{Class: "LOGO"},
+			"CmdLSACC": &Object{Class: "LSACC"},
+			"CmdLSRJT": &Object{Class: "LSRJT"},
+			"CmdEVFP":  &Object{Class: "common.EVFP"},
+			"CmdEcho":  &Object{Class: "Echo"},
+			"CmdADISC": &Object{Class: "ADISC"},
+			"CmdRNID":  &Object{Class: "RNID"},
+			"CmdSCR":   &Object{Class: "SCR"},
+			"CmdPRLI":  &Object{Class: "PRLI"},
+			"CmdPRLO":  &Object{Class: "PRLO"},
+			"CmdRSCN":  &Object{Class: "RSCN"},
 		},
+		Fallback: true,
 	}
 	els.Field("Payload", payload)
 
@@ -0,0 +1,21 @@
+package els
+
+import (
+	"io"
+
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+// Echo carries data to be returned unchanged in the LS_ACC, whose Data then
+// starts with three reserved bytes followed by the echoed data.
+type Echo struct {
+	Data []byte `fc:"@3"`
+}
+
+func (o *Echo) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *Echo) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
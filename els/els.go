@@ -31,7 +31,7 @@ const (
 	CmdRTV       = 0xe  // read timeout value
 	CmdRLS       = 0xf  // read link error status block
 	CmdEcho      = 0x10 // echo
-	CmdTest      = 0x11 // test
+	CmdTest      = 0x11 // test, loop initialization in FC-AL
 	CmdRRQ       = 0x12 // reinstate recovery qualifier
 	CmdREC       = 0x13 // read exchange concise
 	CmdSRR       = 0x14 // sequence retransmission request
@@ -170,7 +170,7 @@ func (o *Command) String() string {
 	case 0x10:
 		return "CmdEcho <0x10> (echo)"
 	case 0x11:
-		return "CmdTest <0x11> (test)"
+		return "CmdTest <0x11> (test, loop initialization in FC-AL)"
 	case 0x12:
 		return "CmdRRQ <0x12> (reinstate recovery qualifier)"
 	case 0x13:
@@ -270,10 +270,123 @@ func (o *Frame) ReadFrom(r io.Reader) (int64, error) {
 		return _io.Pos, _io.Error
 	}
 	switch o.cmd {
+	case CmdADISC:
+		i := &ADISC{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	case CmdEVFP:
+		i := &common.EVFP{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	case CmdEcho:
+		i := &Echo{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	case CmdFDISC:
+		i := &FDISC{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	case CmdFLOGI:
+		i := &FLOGI{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	case CmdLOGO:
+		i := &LOGO{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	case CmdLSACC:
+		i := &LSACC{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	case CmdLSRJT:
+		i := &LSRJT{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
 	case CmdPLOGI:
 		i := &PLOGI{}
-		if n, err := i.ReadFrom(&_io); err != nil {
-			return n, err
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	case CmdPRLI:
+		i := &PRLI{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	case CmdPRLO:
+		i := &PRLO{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	case CmdRNID:
+		i := &RNID{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	case CmdRSCN:
+		i := &RSCN{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	case CmdSCR:
+		i := &SCR{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	default:
+		i, n, err := o.readPayload(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
 		}
 		o.Payload = i
 	}
@@ -292,8 +405,36 @@ func (o *Frame) ReadFrom(r io.Reader) (int64, error) {
 func (o *Frame) WriteTo(w io.Writer) (int64, error) {
 	_io := encoding.Writer{W: w}
 	switch o.Payload.(type) {
-	case PLOGI:
+	case ADISC, *ADISC:
+		o.cmd = CmdADISC
+	case common.EVFP, *common.EVFP:
+		o.cmd = CmdEVFP
+	case Echo, *Echo:
+		o.cmd = CmdEcho
+	case FDISC, *FDISC:
+		o.cmd = CmdFDISC
+	case FLOGI, *FLOGI:
+		o.cmd = CmdFLOGI
+	case LOGO, *LOGO:
+		o.cmd = CmdLOGO
+	case LSACC, *LSACC:
+		o.cmd = CmdLSACC
+	case LSRJT, *LSRJT:
+		o.cmd = CmdLSRJT
+	case PLOGI, *PLOGI:
 		o.cmd = CmdPLOGI
+	case PRLI, *PRLI:
+		o.cmd = CmdPRLI
+	case PRLO, *PRLO:
+		o.cmd = CmdPRLO
+	case RNID, *RNID:
+		o.cmd = CmdRNID
+	case RSCN, *RSCN:
+		o.cmd = CmdRSCN
+	case SCR, *SCR:
+		o.cmd = CmdSCR
+	default:
+		o.switchPayload()
 	}
 
 	_io.WriteObject(o.cmd)
@@ -301,12 +442,66 @@ func (o *Frame) WriteTo(w io.Writer) (int64, error) {
 		return _io.Pos, _io.Error
 	}
 	switch i := o.Payload.(type) {
+	case *ADISC:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
+	case *common.EVFP:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
+	case *Echo:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
+	case *FDISC:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
+	case *FLOGI:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
+	case *LOGO:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
+	case *LSACC:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
+	case *LSRJT:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
 	case *PLOGI:
 		if n, err := i.WriteTo(&_io); err != nil {
 			return n, err
 		}
+	case *PRLI:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
+	case *PRLO:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
+	case *RNID:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
+	case *RSCN:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
+	case *SCR:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
 	default:
-		return _io.Pos, fmt.Errorf("Unsupported type %v", i)
+		if err := o.writePayload(&_io); err != nil {
+			return _io.Pos, err
+		}
 	}
 
 	if _io.Error != nil {
@@ -3,6 +3,7 @@ package els
 import (
 	"bytes"
 	"io"
+	"reflect"
 	"testing"
 
 	"github.com/bluecmd/fibrechannel/common"
@@ -31,3 +32,20 @@ func TestUnsupportedCmd(t *testing.T) {
 func TestFrameFiles(t *testing.T) {
 	common.TestFrameFiles(t, func() common.SerDes { return &Frame{} })
 }
+
+func TestWriteToUnchanged(t *testing.T) {
+	var tests = []func() io.WriterTo{
+		func() io.WriterTo { return &RSCN{Pages: RSCNPages{{Address: common.FCID{1, 2, 3}}}} },
+		func() io.WriterTo { return &PRLI{Pages: PRLIPages{{Type: FC4TypeFCP}}} },
+		func() io.WriterTo { return &RNIDAccept{Format: RNIDFormatCommon, Specific: []byte{1, 2, 3, 4}} },
+	}
+	for _, tt := range tests {
+		o := tt()
+		if _, err := o.WriteTo(new(bytes.Buffer)); err != nil {
+			t.Fatalf("WriteTo: %v", err)
+		}
+		if want := tt(); !reflect.DeepEqual(o, want) {
+			t.Errorf("WriteTo changed %T to %+v, wanted %+v", o, o, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package els
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/loop"
+)
+
+// Command returns the ELS command code of the frame as last read or
+// written.
+func (o *Frame) Command() Command {
+	return o.cmd
+}
+
+// readPayload decodes the payloads of the commands without a case in the
+// generated ReadFrom. Command code 0x11 is a TEST or, in FC-AL, a loop
+// initialization sequence. The payloads of other commands are not decoded.
+func (o *Frame) readPayload(r io.Reader) (interface{}, int64, error) {
+	if o.cmd == CmdTest {
+		return readTest(r)
+	}
+	return nil, 0, nil
+}
+
+// switchPayload sets the command code for the payloads without a case in
+// the generated WriteTo.
+func (o *Frame) switchPayload() {
+	switch o.Payload.(type) {
+	case loop.Init, *loop.Init, Test, *Test:
+		o.cmd = CmdTest
+	}
+}
+
+// writePayload encodes the payloads without a case in the generated
+// WriteTo.
+func (o *Frame) writePayload(w io.Writer) error {
+	switch i := o.Payload.(type) {
+	case *loop.Init:
+		_, err := i.WriteTo(w)
+		return err
+	case *Test:
+		_, err := i.WriteTo(w)
+		return err
+	}
+	return fmt.Errorf("Unsupported type %v", o.Payload)
+}
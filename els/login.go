@@ -0,0 +1,68 @@
+package els
+
+import (
+	"io"
+
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+const (
+	ClassValid              = 0x8000 // Class Service Parameters valid
+	ClassSequentialDelivery = 0x0800 // Class Service Parameters: sequential delivery
+
+	DefaultReceiveSize = 2048 // Receive data field size of DefaultParams
+	DefaultEDTOV       = 2000 // E_D_TOV of DefaultParams in ms
+)
+
+// FLOGI shares its layout with PLOGI, only the meaning of some of the
+// service parameters differ.
+type FLOGI PLOGI
+
+// FDISC shares its layout with PLOGI, it is used by additional N_Ports
+// behind an N_Port that has already completed FLOGI.
+type FDISC PLOGI
+
+// LOGO requests the removal of the login of the N_Port identified by
+// PortID and PortName.
+type LOGO struct {
+	PortID   common.FCID `fc:"@4"`
+	PortName common.WWN  `fc:"@7"`
+}
+
+// DefaultParams returns the service parameters of a port supporting Class 3
+// with sequential delivery, for a FLOGI, FDISC, PLOGI or their LS_ACC. The
+// BB_Credit is left to the caller.
+func DefaultParams(portName, nodeName common.WWN) *PLOGI {
+	o := &PLOGI{PortName: portName, NodeName: nodeName}
+	o.CommonSvcParams.FCPHVersion = 0x2020
+	o.CommonSvcParams.B2BRecvDataFieldSize = DefaultReceiveSize
+	o.CommonSvcParams.EDTOV = DefaultEDTOV
+	o.ClassSvcParams[2].Service = ClassValid | ClassSequentialDelivery
+	o.ClassSvcParams[2].ReceiveDataFieldSize = DefaultReceiveSize
+	return o
+}
+
+func (o *FLOGI) ReadFrom(r io.Reader) (int64, error) {
+	return (*PLOGI)(o).ReadFrom(r)
+}
+
+func (o *FLOGI) WriteTo(w io.Writer) (int64, error) {
+	return (*PLOGI)(o).WriteTo(w)
+}
+
+func (o *FDISC) ReadFrom(r io.Reader) (int64, error) {
+	return (*PLOGI)(o).ReadFrom(r)
+}
+
+func (o *FDISC) WriteTo(w io.Writer) (int64, error) {
+	return (*PLOGI)(o).WriteTo(w)
+}
+
+func (o *LOGO) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *LOGO) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
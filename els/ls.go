@@ -0,0 +1,84 @@
+package els
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+const (
+	ReasonInvalidCommand      = 0x01 // Invalid ELS command code
+	ReasonLogicalError        = 0x03 // Logical error
+	ReasonLogicalBusy         = 0x05 // Logical busy
+	ReasonProtocolError       = 0x07 // Protocol error
+	ReasonUnableToPerform     = 0x09 // Unable to perform command request
+	ReasonCommandNotSupported = 0x0b // Command not supported
+	ReasonCommandInProgress   = 0x0e // Command already in progress
+	ReasonVendorSpecific      = 0xff // Vendor specific error, see VendorSpecific
+
+	ExplNone                  = 0x00 // No additional explanation
+	ExplServiceParamOptions   = 0x01 // Service Parm error - Options
+	ExplServiceParamInitCtl   = 0x03 // Service Parm error - Initiator Ctl
+	ExplServiceParamRecipCtl  = 0x05 // Service Parm error - Recipient Ctl
+	ExplServiceParamRcvSize   = 0x07 // Service Parm error - Rec Data Field Size
+	ExplServiceParamConcSeq   = 0x09 // Service Parm error - Concurrent Seq
+	ExplServiceParamCredit    = 0x0b // Service Parm error - Credit
+	ExplInvalidPortName       = 0x0d // Invalid N_Port/F_Port_Name
+	ExplInvalidNodeName       = 0x0e // Invalid Node/Fabric Name
+	ExplInvalidCommonParams   = 0x0f // Invalid Common Service Parameters
+	ExplInsufficientResources = 0x29 // Insufficient resources
+	ExplUnableToSupplyData    = 0x2a // Unable to supply requested data
+	ExplRequestNotSupported   = 0x2c // Request not supported
+	ExplNoLogin               = 0x1e // N_Port login required
+)
+
+// LSACC is the payload of an LS_ACC. Its layout depends on the request it
+// accepts, so it is kept as is. Data starts right after the command code,
+// the LS_ACC of a FLOGI, FDISC or PLOGI can be decoded by reading Data into
+// the respective type.
+type LSACC struct {
+	Data []byte `fc:"@0"`
+}
+
+// LSRJT is the payload of an LS_RJT.
+type LSRJT struct {
+	Reason         uint8 `fc:"@4"`
+	Explanation    uint8 `fc:"@5"`
+	VendorSpecific uint8 `fc:"@6"`
+}
+
+func (o *LSACC) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *LSACC) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
+
+func (o *LSRJT) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *LSRJT) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
+
+// Accept returns an LS_ACC carrying the encoded o, or just the reserved
+// bytes after the command code if o is nil.
+func Accept(o io.WriterTo) *Frame {
+	acc := &LSACC{Data: []byte{0, 0, 0}}
+	if o != nil {
+		b := new(bytes.Buffer)
+		if _, err := o.WriteTo(b); err != nil {
+			b.Reset()
+		}
+		acc.Data = b.Bytes()
+	}
+	return &Frame{Payload: acc}
+}
+
+// Reject returns an LS_RJT with the reason and its explanation.
+func Reject(reason, explanation uint8) *Frame {
+	return &Frame{Payload: &LSRJT{Reason: reason, Explanation: explanation}}
+}
@@ -0,0 +1,126 @@
+package els
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+const (
+	PRLIPageLength = 16
+
+	PRLIOriginatorPAValid = 0x80 // Originator Process_Associator valid
+	PRLIResponderPAValid  = 0x40 // Responder Process_Associator valid
+	PRLIImagePair         = 0x20 // Establish image pair, or image pair established in the LS_ACC
+	PRLIResponseMask      = 0x0f // Accept response code of the LS_ACC
+
+	PRLIRequestExecuted = 0x1 // Request executed
+	PRLINoResources     = 0x2 // The recipient has no resources available
+	PRLIInitIncomplete  = 0x3 // Initialization not complete
+	PRLINoSuchImage     = 0x4 // The target image does not exist
+	PRLIPreconditions   = 0x5 // Predefined preconditions not met
+	PRLIConditional     = 0x6 // Request executed with conditions
+	PRLINotSupported    = 0x8 // Multiple service parameter pages not supported
+
+	FC4TypeFCP  = 0x08 // SCSI FCP
+	FC4TypeNVMe = 0x28 // FC-NVMe
+)
+
+// PRLI establishes process logins, one per service parameter page. The
+// LS_ACC of a PRLI has the same layout and can be decoded by reading the
+// LS_ACC Data into a PRLI. PageLength and PayloadLength are filled in when
+// written if zero.
+type PRLI struct {
+	PageLength    uint8     `fc:"@0"`
+	PayloadLength uint16    `fc:"@1"`
+	Pages         PRLIPages `fc:"@3"`
+}
+
+// PRLO removes process logins, it shares its layout with PRLI.
+type PRLO PRLI
+
+// PRLIPages are the service parameter pages of a PRLI or PRLO.
+type PRLIPages []PRLIPage
+
+// PRLIPage is a service parameter page. Params holds the FC-4 specific
+// service parameters.
+type PRLIPage struct {
+	Type          uint8
+	TypeExtension uint8
+	Flags         uint8
+	OriginatorPA  uint32
+	ResponderPA   uint32
+	Params        uint32
+}
+
+// Response returns the accept response code of a page from an LS_ACC.
+func (p *PRLIPage) Response() uint8 {
+	return p.Flags & PRLIResponseMask
+}
+
+func (o *PRLI) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *PRLI) WriteTo(w io.Writer) (int64, error) {
+	c := *o
+	if c.PageLength == 0 {
+		c.PageLength = PRLIPageLength
+	}
+	if c.PayloadLength == 0 {
+		c.PayloadLength = uint16(4 + PRLIPageLength*len(c.Pages))
+	}
+	return encoding.WriteTo(w, &c)
+}
+
+func (o *PRLO) ReadFrom(r io.Reader) (int64, error) {
+	return (*PRLI)(o).ReadFrom(r)
+}
+
+func (o *PRLO) WriteTo(w io.Writer) (int64, error) {
+	return (*PRLI)(o).WriteTo(w)
+}
+
+func (p *PRLIPages) ReadFrom(r io.Reader) (int64, error) {
+	*p = PRLIPages{}
+	var n int64
+	for {
+		var b [PRLIPageLength]byte
+		m, err := io.ReadFull(r, b[:])
+		n += int64(m)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		*p = append(*p, PRLIPage{
+			Type:          b[0],
+			TypeExtension: b[1],
+			Flags:         b[2],
+			OriginatorPA:  binary.BigEndian.Uint32(b[4:]),
+			ResponderPA:   binary.BigEndian.Uint32(b[8:]),
+			Params:        binary.BigEndian.Uint32(b[12:]),
+		})
+	}
+}
+
+func (p *PRLIPages) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for _, pg := range *p {
+		var b [PRLIPageLength]byte
+		b[0] = pg.Type
+		b[1] = pg.TypeExtension
+		b[2] = pg.Flags
+		binary.BigEndian.PutUint32(b[4:], pg.OriginatorPA)
+		binary.BigEndian.PutUint32(b[8:], pg.ResponderPA)
+		binary.BigEndian.PutUint32(b[12:], pg.Params)
+		m, err := w.Write(b[:])
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,56 @@
+package els
+
+import (
+	"io"
+
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+const (
+	RNIDFormatCommon   = 0x00 // Common Identification Data only
+	RNIDFormatTopology = 0xdf // General Topology Discovery format
+
+	RNIDCommonLength = 16
+)
+
+// RNID requests the identification data of a node in Format.
+type RNID struct {
+	Format uint8 `fc:"@3"`
+}
+
+// RNIDAccept is the LS_ACC of an RNID, decoded by reading the LS_ACC Data
+// into an RNIDAccept. The Common Identification Data, the port and node
+// names, is always expected. CommonLength and SpecificLength are filled in
+// when written if zero.
+type RNIDAccept struct {
+	Format         uint8      `fc:"@3"`
+	CommonLength   uint8      `fc:"@4"`
+	SpecificLength uint8      `fc:"@6"`
+	PortName       common.WWN `fc:"@7"`
+	NodeName       common.WWN `fc:"@15"`
+	Specific       []byte     `fc:"@23"`
+}
+
+func (o *RNID) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *RNID) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
+
+func (o *RNIDAccept) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *RNIDAccept) WriteTo(w io.Writer) (int64, error) {
+	c := *o
+	if c.CommonLength == 0 {
+		c.CommonLength = RNIDCommonLength
+	}
+	if c.SpecificLength == 0 {
+		c.SpecificLength = uint8(len(c.Specific))
+	}
+	return encoding.WriteTo(w, &c)
+}
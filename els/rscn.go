@@ -0,0 +1,99 @@
+package els
+
+import (
+	"io"
+
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+const (
+	RSCNPageLength = 4
+
+	RSCNAddressPort   = 0x0 // Port address, all three bytes are significant
+	RSCNAddressArea   = 0x1 // Area address, the Port_ID is ignored
+	RSCNAddressDomain = 0x2 // Domain address, only the Domain_ID is significant
+	RSCNAddressFabric = 0x3 // Fabric address, the address is ignored
+)
+
+// RSCN notifies about state changes of the ports at the affected
+// addresses. PageLength and PayloadLength are filled in when written if
+// zero.
+type RSCN struct {
+	PageLength    uint8     `fc:"@0"`
+	PayloadLength uint16    `fc:"@1"`
+	Pages         RSCNPages `fc:"@3"`
+}
+
+// RSCNPages are the affected N_Port_ID pages of an RSCN.
+type RSCNPages []RSCNPage
+
+// RSCNPage is an affected address. Flags holds the event qualifier in bits
+// 5-2 and the address format in bits 1-0.
+type RSCNPage struct {
+	Flags   uint8
+	Address common.FCID
+}
+
+// Format returns the address format of the page.
+func (p *RSCNPage) Format() uint8 {
+	return p.Flags & 0x3
+}
+
+// Affects reports whether id is covered by the affected address.
+func (p *RSCNPage) Affects(id common.FCID) bool {
+	switch p.Format() {
+	case RSCNAddressPort:
+		return id == p.Address
+	case RSCNAddressArea:
+		return id[0] == p.Address[0] && id[1] == p.Address[1]
+	case RSCNAddressDomain:
+		return id[0] == p.Address[0]
+	default:
+		return true
+	}
+}
+
+func (o *RSCN) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *RSCN) WriteTo(w io.Writer) (int64, error) {
+	c := *o
+	if c.PageLength == 0 {
+		c.PageLength = RSCNPageLength
+	}
+	if c.PayloadLength == 0 {
+		c.PayloadLength = uint16(4 + RSCNPageLength*len(c.Pages))
+	}
+	return encoding.WriteTo(w, &c)
+}
+
+func (p *RSCNPages) ReadFrom(r io.Reader) (int64, error) {
+	*p = RSCNPages{}
+	var n int64
+	for {
+		var b [RSCNPageLength]byte
+		m, err := io.ReadFull(r, b[:])
+		n += int64(m)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		*p = append(*p, RSCNPage{Flags: b[0], Address: common.FCID{b[1], b[2], b[3]}})
+	}
+}
+
+func (p *RSCNPages) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for _, pg := range *p {
+		m, err := w.Write([]byte{pg.Flags, pg.Address[0], pg.Address[1], pg.Address[2]})
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,28 @@
+package els
+
+import (
+	"io"
+
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+const (
+	SCRFabricDetected = 0x01 // Fabric detected registration
+	SCRNPortDetected  = 0x02 // N_Port detected registration
+	SCRFull           = 0x03 // Full registration
+	SCRClear          = 0xff // Clear registration
+)
+
+// SCR registers the N_Port with the Fabric Controller to receive RSCNs for
+// the events selected by Function.
+type SCR struct {
+	Function uint8 `fc:"@6"`
+}
+
+func (o *SCR) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *SCR) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
@@ -0,0 +1,43 @@
+package els
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/encoding"
+	"github.com/bluecmd/fibrechannel/loop"
+)
+
+// Test is the TEST ELS, carrying test data which is not responded to.
+// FC-AL uses the same command code for the loop initialization sequences,
+// which are decoded as *loop.Init instead.
+type Test struct {
+	Data []byte `fc:"@3"`
+}
+
+func (o *Test) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, o)
+}
+
+func (o *Test) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, o)
+}
+
+// readTest decodes the payload of command code 0x11 as a *loop.Init if it
+// starts with the identifier of a loop initialization sequence, and as a
+// *Test otherwise.
+func readTest(r io.Reader) (interface{}, int64, error) {
+	var id [1]byte
+	if _, err := io.ReadFull(r, id[:]); err != nil {
+		return nil, 0, err
+	}
+	r = io.MultiReader(bytes.NewReader(id[:]), r)
+	if s := loop.Sequence(id[0]); s >= loop.LISM && s <= loop.LILP {
+		i := &loop.Init{}
+		n, err := i.ReadFrom(r)
+		return i, n, err
+	}
+	i := &Test{}
+	n, err := i.ReadFrom(r)
+	return i, n, err
+}
@@ -44,15 +44,22 @@ type Context interface {
 	FindReference(interface{}) string
 }
 
+// Field is a field of a Struct. Comment, if set, is put above the field in
+// the struct declaration.
 type Field struct {
-	Name   string
-	Type   Type
-	Parent *Struct
+	Name    string
+	Type    Type
+	Parent  *Struct
+	Comment string
 }
 
+// Struct is a structure read and written field by field. If Wrapped is set
+// the generated methods are named readFrom and writeTo instead, for
+// hand-written ReadFrom and WriteTo methods to wrap them.
 type Struct struct {
-	Name   string
-	Fields []*Field
+	Name    string
+	Fields  []*Field
+	Wrapped bool
 }
 
 func (t *Struct) TypeName() string {
@@ -60,7 +67,7 @@ func (t *Struct) TypeName() string {
 }
 
 func (t *Struct) Field(n string, ty Type) *Field {
-	f := &Field{n, ty, t}
+	f := &Field{Name: n, Type: ty, Parent: t}
 	t.Fields = append(t.Fields, f)
 	return f
 }
@@ -86,8 +93,12 @@ func (t *Struct) FindReference(needle interface{}) string {
 }
 
 func (t *Struct) Functions() []Function {
+	readFrom, writeTo := "ReadFrom", "WriteTo"
+	if t.Wrapped {
+		readFrom, writeTo = "readFrom", "writeTo"
+	}
 	rf, wt, err := func() (string, string, error) {
-		rf := fmt.Sprintf("func (o *%s) ReadFrom(r io.Reader) (int64, error) {\n", t.Name)
+		rf := fmt.Sprintf("func (o *%s) %s(r io.Reader) (int64, error) {\n", t.Name, readFrom)
 		rf += "_io := encoding.Reader{R: r}\n"
 		rf += "fixup := []func()(int64,error){}\n"
 		ds, err := t.Deser(t, "o")
@@ -101,7 +112,7 @@ func (t *Struct) Functions() []Function {
 		rf += "for _, f := range fixup { if _, err := f(); err != nil { return _io.Pos, err } }\n"
 		rf += "return _io.Pos, nil }"
 
-		wt := fmt.Sprintf("func (o *%s) WriteTo(w io.Writer) (int64, error) {\n", t.Name)
+		wt := fmt.Sprintf("func (o *%s) %s(w io.Writer) (int64, error) {\n", t.Name, writeTo)
 		wt += "_io := encoding.Writer{W: w}\n"
 		ds, err = t.PreSer(t, "o")
 		if err != nil {
@@ -150,9 +161,15 @@ func (t *Struct) TypeDefs() []TypeDef {
 	for _, f := range t.Fields {
 		td = append(td, f.Type.TypeDefs()...)
 		tn := f.Type.TypeName()
-		if tn != "" {
-			mine += fmt.Sprintf("%s %s\n", f.Name, tn)
+		if tn == "" {
+			continue
+		}
+		for _, l := range strings.Split(f.Comment, "\n") {
+			if l != "" {
+				mine += "// " + l + "\n"
+			}
 		}
+		mine += fmt.Sprintf("%s %s\n", f.Name, tn)
 	}
 	mine += " }"
 	return append(td, TypeDef(mine))
@@ -212,7 +229,12 @@ func (t *Enum) Consts() []NamedConstant {
 	for k, v := range t.Values {
 		n = append(n, NamedConstant{k, t.Name, v})
 	}
-	sort.Slice(n, func(i, j int) bool { return n[i].Value < n[j].Value })
+	sort.Slice(n, func(i, j int) bool {
+		if n[i].Value != n[j].Value {
+			return n[i].Value < n[j].Value
+		}
+		return n[i].Name < n[j].Name
+	})
 	return n
 }
 
@@ -242,11 +264,31 @@ func (t *Enum) Ser(p Context, m string) ([]Statement, error) {
 	return []Statement{Statement(fmt.Sprintf("_io.WriteObject(%s)", m))}, nil
 }
 
+// SwitchedType is a field whose type depends on the value of the field
+// SwitchedOn. Cases maps the values to the types.
+//
+// If Fallback is set, values and types not in Cases are handled by the
+// hand-written methods read<Name>, switch<Name> and write<Name> of the
+// struct. read<Name>(io.Reader) (interface{}, int64, error) decodes the
+// field, switch<Name>() sets SwitchedOn for the type of the field and
+// write<Name>(io.Writer) error encodes it.
 type SwitchedType struct {
 	Name       string
 	Size       Size
 	SwitchedOn interface{}
 	Cases      map[string]Type
+	Fallback   bool
+}
+
+// keys returns the cases in order, so that the generated code is the same
+// every time.
+func (t *SwitchedType) keys() []string {
+	keys := make([]string, 0, len(t.Cases))
+	for k := range t.Cases {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func (t *SwitchedType) TypeName() string {
@@ -259,24 +301,24 @@ func (t *SwitchedType) FindReference(interface{}) string {
 
 func (t *SwitchedType) Consts() []NamedConstant {
 	nc := []NamedConstant{}
-	for _, c := range t.Cases {
-		nc = append(nc, c.Consts()...)
+	for _, k := range t.keys() {
+		nc = append(nc, t.Cases[k].Consts()...)
 	}
 	return nc
 }
 
 func (t *SwitchedType) Functions() []Function {
 	fcts := []Function{}
-	for _, f := range t.Cases {
-		fcts = append(fcts, f.Functions()...)
+	for _, k := range t.keys() {
+		fcts = append(fcts, t.Cases[k].Functions()...)
 	}
 	return fcts
 }
 
 func (t *SwitchedType) TypeDefs() []TypeDef {
 	td := []TypeDef{}
-	for _, f := range t.Cases {
-		td = append(td, f.TypeDefs()...)
+	for _, k := range t.keys() {
+		td = append(td, t.Cases[k].TypeDefs()...)
 	}
 	return td
 }
@@ -298,10 +340,20 @@ func (t *SwitchedType) Deser(p Context, m string) ([]Statement, error) {
 		stmt += "_io := encoding.Reader{R: bytes.NewReader(" + bname + "[:])}\n"
 	}
 	stmt += fmt.Sprintf("switch o.%s {\n", rp)
-	for k, c := range t.Cases {
+	for _, k := range t.keys() {
+		c := t.Cases[k]
 		stmt += fmt.Sprintf("case %s:\n", k)
 		stmt += fmt.Sprintf("i := &%s{}\n", c.TypeName())
-		stmt += "if n, err := i.ReadFrom(&_io); err != nil { return n, err }\n"
+		stmt += "n, err := i.ReadFrom(_io.R)\n"
+		stmt += "_io.Pos += n\n"
+		stmt += "if err != nil { return _io.Pos, err }\n"
+		stmt += fmt.Sprintf("%s = i\n", m)
+	}
+	if t.Fallback {
+		stmt += "default:\n"
+		stmt += fmt.Sprintf("i, n, err := o.read%s(_io.R)\n", t.Name)
+		stmt += "_io.Pos += n\n"
+		stmt += "if err != nil { return _io.Pos, err }\n"
 		stmt += fmt.Sprintf("%s = i\n", m)
 	}
 	stmt += "}\n"
@@ -318,10 +370,15 @@ func (t *SwitchedType) PreSer(p Context, m string) ([]Statement, error) {
 		return []Statement{}, fmt.Errorf("Context not available for %s", t.Name)
 	}
 	stmt := fmt.Sprintf("switch %s.(type) {\n", m)
-	for k, c := range t.Cases {
-		stmt += fmt.Sprintf("case %s:\n", c.TypeName())
+	for _, k := range t.keys() {
+		c := t.Cases[k]
+		stmt += fmt.Sprintf("case %s, *%s:\n", c.TypeName(), c.TypeName())
 		stmt += fmt.Sprintf("o.%s = %s\n", rp, k)
 	}
+	if t.Fallback {
+		stmt += "default:\n"
+		stmt += fmt.Sprintf("o.switch%s()\n", t.Name)
+	}
 	stmt += "}\n"
 
 	return []Statement{Statement(stmt)}, nil
@@ -333,17 +390,59 @@ func (t *SwitchedType) Ser(p Context, m string) ([]Statement, error) {
 		return []Statement{}, fmt.Errorf("Context not available for %s", t.Name)
 	}
 	stmt := fmt.Sprintf("switch i := %s.(type) {\n", m)
-	for _, c := range t.Cases {
-		stmt += fmt.Sprintf("case *%s:\n", c.TypeName())
+	for _, k := range t.keys() {
+		stmt += fmt.Sprintf("case *%s:\n", t.Cases[k].TypeName())
 		stmt += "if n, err := i.WriteTo(&_io); err != nil { return n, err }\n"
 	}
 	stmt += "default:\n"
-	stmt += "  return _io.Pos, fmt.Errorf(\"Unsupported type %v\", i)\n"
+	if t.Fallback {
+		stmt += fmt.Sprintf("if err := o.write%s(&_io); err != nil { return _io.Pos, err }\n", t.Name)
+	} else {
+		stmt += "  return _io.Pos, fmt.Errorf(\"Unsupported type %v\", i)\n"
+	}
 	stmt += "}\n"
 
 	return []Statement{Statement(stmt)}, nil
 }
 
+// External is a field declared as Name but neither read nor written by the
+// generated code, for fields handled by hand-written code.
+type External struct {
+	Name string
+}
+
+func (t *External) TypeName() string {
+	return t.Name
+}
+
+func (t *External) Consts() []NamedConstant {
+	return []NamedConstant{}
+}
+
+func (t *External) Functions() []Function {
+	return []Function{}
+}
+
+func (t *External) TypeDefs() []TypeDef {
+	return []TypeDef{}
+}
+
+func (t *External) FindReference(interface{}) string {
+	return ""
+}
+
+func (t *External) Deser(p Context, m string) ([]Statement, error) {
+	return []Statement{}, nil
+}
+
+func (t *External) PreSer(p Context, m string) ([]Statement, error) {
+	return []Statement{}, nil
+}
+
+func (t *External) Ser(p Context, m string) ([]Statement, error) {
+	return []Statement{}, nil
+}
+
 type Unsigned struct {
 	Size Size
 }
@@ -388,11 +487,16 @@ func (t *Unsigned) Ser(p Context, m string) ([]Statement, error) {
 	return []Statement{Statement(fmt.Sprintf("_io.WriteObject(%s)", m))}, nil
 }
 
+// ByteArray is a fixed size byte array, declared as Name if set.
 type ByteArray struct {
 	Count int
+	Name  string
 }
 
 func (t *ByteArray) TypeName() string {
+	if t.Name != "" {
+		return t.Name
+	}
 	return fmt.Sprintf("[%d]byte", t.Count)
 }
 
@@ -589,7 +693,10 @@ func Generate(pkg string, imports []string, ts ...Type) ([]byte, error) {
 		if consts[i].Domain != consts[j].Domain {
 			return consts[i].Domain < consts[j].Domain
 		}
-		return consts[i].Value < consts[j].Value
+		if consts[i].Value != consts[j].Value {
+			return consts[i].Value < consts[j].Value
+		}
+		return consts[i].Name < consts[j].Name
 	})
 	sort.Slice(typedefs, func(i, j int) bool { return typedefs[i] < typedefs[j] })
 	sort.Slice(funcs, func(i, j int) bool { return funcs[i] < funcs[j] })
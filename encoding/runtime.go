@@ -18,13 +18,12 @@ type Writer struct {
 }
 
 func (r *Reader) Read(b []byte) (int, error) {
-	if n, err := r.R.Read(b); err != nil {
-		r.Pos += int64(n)
+	n, err := r.R.Read(b)
+	r.Pos += int64(n)
+	if err != nil {
 		r.Error = err
-		return n, err
 	}
-	r.Pos += int64(len(b))
-	return len(b), nil
+	return n, err
 }
 
 func (r *Reader) Skip(n int) {
@@ -0,0 +1,391 @@
+// Package exchange groups captured frames into Exchanges and Sequences.
+// Frames are fed in the order they were captured, Sequences are reassembled
+// from their frames and every Exchange is handed over once its last
+// Sequence has ended. Frames out of order, missing or duplicated are
+// reported as anomalies.
+package exchange
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+// Kind is the kind of an Anomaly.
+type Kind int
+
+const (
+	KindDuplicate  Kind = iota // A frame was seen twice
+	KindOutOfOrder             // A frame arrived after one with a higher SEQ_CNT
+	KindMissing                // Frames of a Sequence were never seen
+	KindInitiative             // A Sequence was started without Sequence Initiative
+	KindNoStart                // The first Sequence of an Exchange was not seen
+)
+
+var kindNames = map[Kind]string{
+	KindDuplicate:  "duplicate",
+	KindOutOfOrder: "out of order",
+	KindMissing:    "missing",
+	KindInitiative: "no sequence initiative",
+	KindNoStart:    "exchange start not seen",
+}
+
+// Key identifies an Exchange by its Originator, Responder and OX_ID. The
+// RX_ID is assigned by the Responder during the Exchange and is kept in
+// Exchange instead.
+type Key struct {
+	Originator common.FCID
+	Responder  common.FCID
+	OXID       uint16
+}
+
+// Anomaly is a problem found in the frame stream. For KindMissing SeqCount
+// is the first SEQ_CNT missing and Count the number of frames missing.
+type Anomaly struct {
+	Kind     Kind
+	Key      Key
+	Time     time.Time
+	SeqID    uint8
+	SeqCount uint16
+	Count    int
+}
+
+// Sequence is a Sequence of an Exchange. Frames are ordered by SEQ_CNT and
+// Data is the reassembled payload. Initiative is set if the Sequence
+// transferred Sequence Initiative to the recipient.
+type Sequence struct {
+	ID         uint8
+	Initiator  common.FCID
+	Frames     []*fc.Frame
+	Data       []byte
+	Start, End time.Time
+	Initiative bool
+	// Complete is set once the last frame has been seen without any frame
+	// missing
+	Complete bool
+
+	ended  bool
+	counts map[uint16]bool
+	// high is the highest SEQ_CNT seen, last the one of the End_Sequence
+	// frame
+	low, high, last uint16
+}
+
+// Exchange is an Exchange and its Sequences in the order they were
+// started. Complete is set if the Exchange ended with its Last Sequence
+// instead of being flushed.
+type Exchange struct {
+	Key
+	RXID       uint16
+	Sequences  []*Sequence
+	Start, End time.Time
+	Complete   bool
+
+	open map[uint8]*Sequence
+	// initiative is the port holding Sequence Initiative
+	initiative common.FCID
+	last       bool
+}
+
+// recentExchanges is the number of ended Exchanges kept to recognize
+// frames repeated after their Exchange has ended.
+const recentExchanges = 1024
+
+// Tracker follows the Exchanges in a stream of frames. OnExchange is
+// called for every Exchange once it has ended, OnAnomaly for everything
+// wrong with the stream.
+type Tracker struct {
+	OnExchange func(*Exchange)
+	OnAnomaly  func(Anomaly)
+
+	exchanges map[Key]*Exchange
+	// recent holds the last ended Exchanges, ring in the order they ended
+	recent map[Key]*Exchange
+	ring   []*Exchange
+	next   int
+}
+
+// NewTracker returns a Tracker without any Exchanges.
+func NewTracker() *Tracker {
+	return &Tracker{
+		exchanges: map[Key]*Exchange{},
+		recent:    map[Key]*Exchange{},
+		ring:      make([]*Exchange, recentExchanges),
+	}
+}
+
+func (k Kind) String() string {
+	if n, ok := kindNames[k]; ok {
+		return n
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+func (k Key) String() string {
+	return fmt.Sprintf("%v->%v OX_ID 0x%04x", k.Originator, k.Responder, k.OXID)
+}
+
+func (a Anomaly) String() string {
+	return fmt.Sprintf("%v: %v SEQ_ID 0x%02x SEQ_CNT %d", a.Kind, a.Key, a.SeqID, a.SeqCount)
+}
+
+// Duration returns the time from the first to the last frame.
+func (x *Exchange) Duration() time.Duration {
+	return x.End.Sub(x.Start)
+}
+
+// KeyOf returns the key of the Exchange a frame belongs to.
+func KeyOf(f *fc.Frame) Key {
+	if f.FCtl.Has(fc.FCtlExchangeContext) {
+		return Key{Originator: f.DestinationID, Responder: f.SourceID, OXID: f.OXID}
+	}
+	return Key{Originator: f.SourceID, Responder: f.DestinationID, OXID: f.OXID}
+}
+
+// Add feeds the next captured frame.
+func (t *Tracker) Add(ts time.Time, f *fc.Frame) {
+	k := KeyOf(f)
+	x, ok := t.exchanges[k]
+	if !ok {
+		if r, ok := t.recent[k]; ok {
+			if r.duplicate(f) {
+				t.report(Anomaly{Kind: KindDuplicate, Key: k, Time: ts, SeqID: f.SeqID, SeqCount: f.SeqCount})
+				return
+			}
+			// The OX_ID has been reused for a new Exchange
+			delete(t.recent, k)
+		}
+		x = &Exchange{
+			Key:        k,
+			RXID:       0xffff,
+			Start:      ts,
+			open:       map[uint8]*Sequence{},
+			initiative: k.Originator,
+		}
+		t.exchanges[k] = x
+		if !f.FCtl.Has(fc.FCtlFirstSequence) {
+			t.report(Anomaly{Kind: KindNoStart, Key: k, Time: ts, SeqID: f.SeqID, SeqCount: f.SeqCount})
+		}
+	}
+	x.End = ts
+	if f.RXID != 0xffff {
+		x.RXID = f.RXID
+	}
+
+	s, ok := x.open[f.SeqID]
+	if !ok || s.Initiator != f.SourceID {
+		if x.duplicate(f) {
+			t.report(Anomaly{Kind: KindDuplicate, Key: k, Time: ts, SeqID: f.SeqID, SeqCount: f.SeqCount})
+			return
+		}
+		if ok {
+			t.close(x, s)
+		}
+		s = &Sequence{
+			ID:        f.SeqID,
+			Initiator: f.SourceID,
+			Start:     ts,
+			counts:    map[uint16]bool{},
+			low:       f.SeqCount,
+			high:      f.SeqCount,
+		}
+		if f.SourceID != x.initiative {
+			t.report(Anomaly{Kind: KindInitiative, Key: k, Time: ts, SeqID: f.SeqID, SeqCount: f.SeqCount})
+		}
+		x.open[f.SeqID] = s
+		x.Sequences = append(x.Sequences, s)
+	}
+	t.frame(x, s, ts, f)
+}
+
+func (t *Tracker) frame(x *Exchange, s *Sequence, ts time.Time, f *fc.Frame) {
+	a := Anomaly{Key: x.Key, Time: ts, SeqID: f.SeqID, SeqCount: f.SeqCount}
+	if s.counts[f.SeqCount] {
+		a.Kind = KindDuplicate
+		t.report(a)
+		return
+	}
+	if len(s.counts) > 0 && before(f.SeqCount, s.high) {
+		a.Kind = KindOutOfOrder
+		t.report(a)
+	}
+	s.counts[f.SeqCount] = true
+	s.Frames = append(s.Frames, f)
+	s.End = ts
+	if before(f.SeqCount, s.low) {
+		s.low = f.SeqCount
+	}
+	if before(s.high, f.SeqCount) {
+		s.high = f.SeqCount
+	}
+	if f.FCtl.Has(fc.FCtlEndSequence) {
+		s.ended = true
+		s.last = f.SeqCount
+		if f.FCtl.Has(fc.FCtlSequenceInitiative) {
+			s.Initiative = true
+			x.initiative = x.other(f.SourceID)
+		}
+		if f.FCtl.Has(fc.FCtlLastSequence) {
+			x.last = true
+		}
+	}
+	if s.ended && len(s.counts) == int(s.last-s.low)+1 {
+		t.close(x, s)
+	}
+	if x.last && len(x.open) == 0 {
+		x.Complete = true
+		t.finish(x)
+	}
+}
+
+// Flush ends all Exchanges still open, at the end of a capture, reporting
+// the frames missing from their Sequences.
+func (t *Tracker) Flush() {
+	keys := make([]Key, 0, len(t.exchanges))
+	for k := range t.exchanges {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return t.exchanges[keys[i]].Start.Before(t.exchanges[keys[j]].Start)
+	})
+	for _, k := range keys {
+		x := t.exchanges[k]
+		for _, s := range x.Sequences {
+			if x.open[s.ID] == s {
+				t.close(x, s)
+			}
+		}
+		t.finish(x)
+	}
+}
+
+// close ends a Sequence, reporting any gaps, and reassembles its payload.
+func (t *Tracker) close(x *Exchange, s *Sequence) {
+	delete(x.open, s.ID)
+	sort.Slice(s.Frames, func(i, j int) bool {
+		return before(s.Frames[i].SeqCount, s.Frames[j].SeqCount)
+	})
+	end := s.high
+	if s.ended {
+		end = s.last
+	}
+	missing := false
+	for c := s.low; before(c, end+1); c++ {
+		if s.counts[c] {
+			continue
+		}
+		a := Anomaly{Kind: KindMissing, Key: x.Key, Time: s.End, SeqID: s.ID, SeqCount: c}
+		for ; before(c, end+1) && !s.counts[c]; c++ {
+			a.Count++
+		}
+		t.report(a)
+		missing = true
+	}
+	if !s.ended {
+		// The frames after the last one seen are missing, but how many
+		// is not known
+		t.report(Anomaly{Kind: KindMissing, Key: x.Key, Time: s.End, SeqID: s.ID, SeqCount: end + 1})
+		missing = true
+	}
+	s.Complete = !missing
+	s.Data = reassemble(s.Frames)
+}
+
+func (t *Tracker) finish(x *Exchange) {
+	delete(t.exchanges, x.Key)
+	if old := t.ring[t.next]; old != nil && t.recent[old.Key] == old {
+		delete(t.recent, old.Key)
+	}
+	t.ring[t.next] = x
+	t.next = (t.next + 1) % len(t.ring)
+	t.recent[x.Key] = x
+	if t.OnExchange != nil {
+		t.OnExchange(x)
+	}
+}
+
+func (t *Tracker) report(a Anomaly) {
+	if t.OnAnomaly != nil {
+		t.OnAnomaly(a)
+	}
+}
+
+// other returns the other port of the Exchange.
+func (x *Exchange) other(p common.FCID) common.FCID {
+	if p == x.Originator {
+		return x.Responder
+	}
+	return x.Originator
+}
+
+// duplicate reports whether f repeats a frame of a Sequence of the
+// Exchange which has already been closed.
+func (x *Exchange) duplicate(f *fc.Frame) bool {
+	for _, s := range x.Sequences {
+		if s.ID != f.SeqID || s.Initiator != f.SourceID || x.open[s.ID] == s || !s.counts[f.SeqCount] {
+			continue
+		}
+		for _, g := range s.Frames {
+			if g.SeqCount == f.SeqCount && sameFrame(g, f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameFrame reports whether two frames with the same SEQ_ID and SEQ_CNT
+// also carry the same F_CTL, parameter and payload, as a SEQ_ID may be
+// reused once its Sequence has ended.
+func sameFrame(a, b *fc.Frame) bool {
+	return a.FCtl.Value() == b.FCtl.Value() && a.Parameters == b.Parameters &&
+		bytes.Equal(payload(a), payload(b))
+}
+
+// reassemble returns the payload of a Sequence. Frames with a relative
+// offset are placed there, the others are appended in SEQ_CNT order.
+func reassemble(frames []*fc.Frame) []byte {
+	var b []byte
+	for _, f := range frames {
+		p := payload(f)
+		if n := f.FCtl.FillBytes(); n <= len(p) {
+			p = p[:len(p)-n]
+		}
+		off := len(b)
+		if f.FCtl.Has(fc.FCtlRelativeOffset) {
+			off = int(uint32(f.Parameters[0])<<24 | uint32(f.Parameters[1])<<16 |
+				uint32(f.Parameters[2])<<8 | uint32(f.Parameters[3]))
+		}
+		if n := off + len(p); n > len(b) {
+			b = append(b, make([]byte, n-len(b))...)
+		}
+		copy(b[off:], p)
+	}
+	return b
+}
+
+// payload returns the data field of a frame, re-encoding decoded payloads.
+func payload(f *fc.Frame) []byte {
+	switch p := f.Payload.(type) {
+	case []byte:
+		return p
+	case io.WriterTo:
+		b := new(bytes.Buffer)
+		if _, err := p.WriteTo(b); err != nil {
+			return nil
+		}
+		return b.Bytes()
+	default:
+		return nil
+	}
+}
+
+// before compares SEQ_CNTs, which wrap around.
+func before(a, b uint16) bool {
+	return int16(a-b) < 0
+}
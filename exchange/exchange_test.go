@@ -0,0 +1,205 @@
+package exchange
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+var (
+	t0        = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	initiator = common.FCID{0x01, 0x02, 0x03}
+	target    = common.FCID{0x01, 0x04, 0x00}
+	key       = Key{Originator: initiator, Responder: target, OXID: 0x1234}
+)
+
+// frame returns a frame of the test exchange. Frames from the responder get
+// the Exchange Context bit and the RX_ID.
+func frame(responder bool, seqID uint8, seqCnt uint16, fctl uint32, data string) *fc.Frame {
+	f := &fc.Frame{
+		SourceID:      initiator,
+		DestinationID: target,
+		SeqID:         seqID,
+		SeqCount:      seqCnt,
+		OXID:          0x1234,
+		RXID:          0xffff,
+		Payload:       []byte(data),
+	}
+	if responder {
+		f.SourceID, f.DestinationID = target, initiator
+		f.RXID = 0x0042
+		fctl |= fc.FCtlExchangeContext
+	}
+	f.FCtl.SetValue(fctl)
+	return f
+}
+
+// withOffset sets the relative offset of a frame.
+func withOffset(f *fc.Frame, off uint32) *fc.Frame {
+	f.FCtl.SetValue(f.FCtl.Value() | fc.FCtlRelativeOffset)
+	f.Parameters = [4]byte{byte(off >> 24), byte(off >> 16), byte(off >> 8), byte(off)}
+	return f
+}
+
+type result struct {
+	exchanges []*Exchange
+	anomalies []Anomaly
+}
+
+func run(frames []*fc.Frame, flush bool) *result {
+	r := &result{}
+	t := NewTracker()
+	t.OnExchange = func(x *Exchange) { r.exchanges = append(r.exchanges, x) }
+	t.OnAnomaly = func(a Anomaly) { r.anomalies = append(r.anomalies, a) }
+	for i, f := range frames {
+		t.Add(t0.Add(time.Duration(i)*time.Millisecond), f)
+	}
+	if flush {
+		t.Flush()
+	}
+	return r
+}
+
+func TestRequestReply(t *testing.T) {
+	r := run([]*fc.Frame{
+		frame(false, 1, 0, fc.FCtlFirstSequence|fc.FCtlEndSequence|fc.FCtlSequenceInitiative, "request"),
+		frame(true, 2, 0, fc.FCtlLastSequence|fc.FCtlEndSequence, "reply"),
+	}, false)
+	if len(r.anomalies) != 0 {
+		t.Fatalf("unexpected anomalies %v", r.anomalies)
+	}
+	if len(r.exchanges) != 1 {
+		t.Fatalf("got %d exchanges, wanted 1", len(r.exchanges))
+	}
+	x := r.exchanges[0]
+	if x.Key != key || x.RXID != 0x0042 || !x.Complete || x.Duration() != time.Millisecond {
+		t.Fatalf("unexpected exchange %+v", x)
+	}
+	if len(x.Sequences) != 2 {
+		t.Fatalf("got %d sequences, wanted 2", len(x.Sequences))
+	}
+	req, rep := x.Sequences[0], x.Sequences[1]
+	if req.Initiator != initiator || !req.Initiative || !req.Complete || string(req.Data) != "request" {
+		t.Errorf("unexpected request sequence %+v", req)
+	}
+	if rep.Initiator != target || rep.Initiative || !rep.Complete || string(rep.Data) != "reply" {
+		t.Errorf("unexpected reply sequence %+v", rep)
+	}
+}
+
+func TestReassembly(t *testing.T) {
+	r := run([]*fc.Frame{
+		frame(false, 1, 0, fc.FCtlFirstSequence|fc.FCtlEndSequence|fc.FCtlSequenceInitiative, "read"),
+		withOffset(frame(true, 5, 0, 0, "0123"), 0),
+		withOffset(frame(true, 5, 2, 0, "89ab"), 8),
+		withOffset(frame(true, 5, 1, 0, "4567"), 4),
+		withOffset(frame(true, 5, 1, 0, "4567"), 4),
+		withOffset(frame(true, 5, 3, fc.FCtlEndSequence|2, "cd\x00\x00"), 12),
+		frame(true, 6, 0, fc.FCtlLastSequence|fc.FCtlEndSequence, "status"),
+	}, false)
+	want := []Anomaly{
+		{Kind: KindOutOfOrder, Key: key, Time: t0.Add(3 * time.Millisecond), SeqID: 5, SeqCount: 1},
+		{Kind: KindDuplicate, Key: key, Time: t0.Add(4 * time.Millisecond), SeqID: 5, SeqCount: 1},
+	}
+	if !reflect.DeepEqual(r.anomalies, want) {
+		t.Fatalf("unexpected anomalies:\n- want: %v\n-  got: %v", want, r.anomalies)
+	}
+	if len(r.exchanges) != 1 || len(r.exchanges[0].Sequences) != 3 {
+		t.Fatalf("unexpected exchanges %+v", r.exchanges)
+	}
+	s := r.exchanges[0].Sequences[1]
+	if !bytes.Equal(s.Data, []byte("0123456789abcd")) {
+		t.Errorf("reassembled %q", s.Data)
+	}
+	if len(s.Frames) != 4 || s.Frames[1].SeqCount != 1 || !s.Complete {
+		t.Errorf("unexpected sequence %+v", s)
+	}
+}
+
+func TestMissing(t *testing.T) {
+	r := run([]*fc.Frame{
+		frame(false, 1, 0, fc.FCtlFirstSequence, "a"),
+		frame(false, 1, 3, fc.FCtlEndSequence|fc.FCtlSequenceInitiative, "d"),
+		frame(true, 2, 0, 0, "x"),
+	}, true)
+	want := []Anomaly{
+		{Kind: KindMissing, Key: key, Time: t0.Add(time.Millisecond), SeqID: 1, SeqCount: 1, Count: 2},
+		{Kind: KindMissing, Key: key, Time: t0.Add(2 * time.Millisecond), SeqID: 2, SeqCount: 1},
+	}
+	if !reflect.DeepEqual(r.anomalies, want) {
+		t.Fatalf("unexpected anomalies:\n- want: %v\n-  got: %v", want, r.anomalies)
+	}
+	if len(r.exchanges) != 1 {
+		t.Fatalf("got %d exchanges, wanted 1", len(r.exchanges))
+	}
+	x := r.exchanges[0]
+	if x.Complete || x.Sequences[0].Complete || x.Sequences[1].Complete {
+		t.Errorf("incomplete exchange reported as complete")
+	}
+}
+
+func TestNoStart(t *testing.T) {
+	r := run([]*fc.Frame{
+		frame(true, 2, 0, fc.FCtlLastSequence|fc.FCtlEndSequence, "reply"),
+	}, false)
+	want := []Anomaly{
+		{Kind: KindNoStart, Key: key, Time: t0, SeqID: 2},
+		{Kind: KindInitiative, Key: key, Time: t0, SeqID: 2},
+	}
+	if !reflect.DeepEqual(r.anomalies, want) {
+		t.Fatalf("unexpected anomalies:\n- want: %v\n-  got: %v", want, r.anomalies)
+	}
+	if len(r.exchanges) != 1 || !r.exchanges[0].Complete {
+		t.Fatalf("unexpected exchanges %+v", r.exchanges)
+	}
+}
+
+func TestDuplicateReply(t *testing.T) {
+	r := run([]*fc.Frame{
+		frame(false, 1, 0, fc.FCtlFirstSequence|fc.FCtlEndSequence|fc.FCtlSequenceInitiative, "request"),
+		frame(true, 2, 0, fc.FCtlLastSequence|fc.FCtlEndSequence, "reply"),
+		frame(true, 2, 0, fc.FCtlLastSequence|fc.FCtlEndSequence, "reply"),
+		// The OX_ID is reused for the next Exchange
+		frame(false, 1, 0, fc.FCtlFirstSequence|fc.FCtlEndSequence|fc.FCtlSequenceInitiative, "again"),
+		frame(true, 2, 0, fc.FCtlLastSequence|fc.FCtlEndSequence, "reply"),
+	}, false)
+	want := []Anomaly{
+		{Kind: KindDuplicate, Key: key, Time: t0.Add(2 * time.Millisecond), SeqID: 2},
+	}
+	if !reflect.DeepEqual(r.anomalies, want) {
+		t.Fatalf("unexpected anomalies:\n- want: %v\n-  got: %v", want, r.anomalies)
+	}
+	if len(r.exchanges) != 2 || !r.exchanges[0].Complete || !r.exchanges[1].Complete {
+		t.Fatalf("unexpected exchanges %+v", r.exchanges)
+	}
+	if s := r.exchanges[1].Sequences[0]; string(s.Data) != "again" {
+		t.Errorf("unexpected request sequence %+v", s)
+	}
+}
+
+func TestDuplicateRequest(t *testing.T) {
+	r := run([]*fc.Frame{
+		frame(false, 1, 0, fc.FCtlFirstSequence|fc.FCtlEndSequence|fc.FCtlSequenceInitiative, "request"),
+		frame(false, 1, 0, fc.FCtlFirstSequence|fc.FCtlEndSequence|fc.FCtlSequenceInitiative, "request"),
+		frame(true, 2, 0, fc.FCtlLastSequence|fc.FCtlEndSequence, "reply"),
+	}, false)
+	want := []Anomaly{
+		{Kind: KindDuplicate, Key: key, Time: t0.Add(time.Millisecond), SeqID: 1},
+	}
+	if !reflect.DeepEqual(r.anomalies, want) {
+		t.Fatalf("unexpected anomalies:\n- want: %v\n-  got: %v", want, r.anomalies)
+	}
+	if len(r.exchanges) != 1 || len(r.exchanges[0].Sequences) != 2 || !r.exchanges[0].Complete {
+		t.Fatalf("unexpected exchanges %+v", r.exchanges)
+	}
+}
+
+func TestBefore(t *testing.T) {
+	if !before(0xffff, 0) || before(0, 0xffff) || before(5, 5) {
+		t.Fatalf("SEQ_CNT comparison does not wrap")
+	}
+}
@@ -0,0 +1,185 @@
+package fibrechannel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	RCtlVFT   = 0x50 // Virtual Fabric Tagging Extended_Header
+	RCtlIFR   = 0x51 // Inter-Fabric Routing Extended_Header
+	RCtlEnc   = 0x52 // Encapsulation Extended_Header
+	RCtlESP   = 0x5f // ESP_Header (not an extended header, see FC-SP)
+	VFTLength = 8
+	IFRLength = 8
+	EncLength = 24
+
+	FrameHeaderLength = 24
+)
+
+// VFTHeader is the Virtual Fabric Tagging Extended_Header, it associates a
+// frame with a Virtual Fabric.
+type VFTHeader struct {
+	Version  uint8
+	Type     uint8
+	Priority uint8
+	VFID     uint16
+	HopCount uint8
+}
+
+// IFRHeader is the Inter-Fabric Routing Extended_Header used by
+// inter-fabric routers to route frames between fabrics.
+type IFRHeader struct {
+	Version             uint8
+	Priority            uint8
+	ExpirationTimeValid bool
+	HopCountValid       bool
+	DestinationFabricID uint16
+	SourceFabricID      uint16
+	ExpirationTime      uint8
+	HopCount            uint8
+}
+
+// EncapsulationHeader is the Encapsulation Extended_Header. It carries the
+// Frame_Header of the encapsulated frame, without its R_CTL byte.
+type EncapsulationHeader struct {
+	Header [EncLength - 1]byte
+}
+
+// HeaderLength returns the length of the Frame_Header including any
+// Extended_Headers present.
+func (o *Frame) HeaderLength() int {
+	l := FrameHeaderLength
+	if o.Encapsulation != nil {
+		l += EncLength
+	}
+	if o.IFR != nil {
+		l += IFRLength
+	}
+	if o.VFT != nil {
+		l += VFTLength
+	}
+	return l
+}
+
+// readExtendedHeaders consumes any Extended_Headers in front of the
+// Frame_Header. The returned reader yields the Frame_Header and onwards.
+func (o *Frame) readExtendedHeaders(r io.Reader) (io.Reader, int64, error) {
+	var n int64
+	o.VFT = nil
+	o.IFR = nil
+	o.Encapsulation = nil
+	for {
+		var rctl [1]byte
+		if _, err := io.ReadFull(r, rctl[:]); err != nil {
+			return r, n, err
+		}
+		var l int
+		switch rctl[0] {
+		case RCtlVFT:
+			l = VFTLength
+		case RCtlIFR:
+			l = IFRLength
+		case RCtlEnc:
+			l = EncLength
+		default:
+			return io.MultiReader(bytes.NewReader(rctl[:]), r), n, nil
+		}
+		b := make([]byte, l)
+		b[0] = rctl[0]
+		c, err := io.ReadFull(r, b[1:])
+		n += int64(1 + c)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return r, n, err
+		}
+		switch rctl[0] {
+		case RCtlVFT:
+			o.VFT = &VFTHeader{}
+			o.VFT.unmarshal(b)
+		case RCtlIFR:
+			o.IFR = &IFRHeader{}
+			o.IFR.unmarshal(b)
+		case RCtlEnc:
+			o.Encapsulation = &EncapsulationHeader{}
+			copy(o.Encapsulation.Header[:], b[1:])
+		}
+	}
+}
+
+// writeExtendedHeaders writes the Extended_Headers present, in the order
+// mandated by FC-FS: Enc_Header, IFR_Header and last VFT_Header.
+func (o *Frame) writeExtendedHeaders(w io.Writer) (int, error) {
+	b := []byte{}
+	if o.Encapsulation != nil {
+		b = append(b, RCtlEnc)
+		b = append(b, o.Encapsulation.Header[:]...)
+	}
+	if o.IFR != nil {
+		b = append(b, o.IFR.marshal()...)
+	}
+	if o.VFT != nil {
+		b = append(b, o.VFT.marshal()...)
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	return w.Write(b)
+}
+
+func (h *VFTHeader) unmarshal(b []byte) {
+	w := binary.BigEndian.Uint32(b)
+	h.Version = uint8(w>>22) & 0x3
+	h.Type = uint8(w>>18) & 0xf
+	h.Priority = uint8(w>>13) & 0x7
+	h.VFID = uint16(w>>1) & 0xfff
+	h.HopCount = b[4]
+}
+
+func (h *VFTHeader) marshal() []byte {
+	b := make([]byte, VFTLength)
+	w := uint32(RCtlVFT)<<24 |
+		uint32(h.Version&0x3)<<22 |
+		uint32(h.Type&0xf)<<18 |
+		uint32(h.Priority&0x7)<<13 |
+		uint32(h.VFID&0xfff)<<1
+	binary.BigEndian.PutUint32(b, w)
+	b[4] = h.HopCount
+	return b
+}
+
+func (h *IFRHeader) unmarshal(b []byte) {
+	w := binary.BigEndian.Uint32(b)
+	h.Version = uint8(w>>22) & 0x3
+	h.Priority = uint8(w>>19) & 0x7
+	h.ExpirationTimeValid = w&(1<<18) != 0
+	h.HopCountValid = w&(1<<17) != 0
+	h.DestinationFabricID = uint16(w) & 0xfff
+	w = binary.BigEndian.Uint32(b[4:])
+	h.ExpirationTime = uint8(w >> 24)
+	h.SourceFabricID = uint16(w>>12) & 0xfff
+	h.HopCount = uint8(w)
+}
+
+func (h *IFRHeader) marshal() []byte {
+	b := make([]byte, IFRLength)
+	w := uint32(RCtlIFR)<<24 |
+		uint32(h.Version&0x3)<<22 |
+		uint32(h.Priority&0x7)<<19 |
+		uint32(h.DestinationFabricID&0xfff)
+	if h.ExpirationTimeValid {
+		w |= 1 << 18
+	}
+	if h.HopCountValid {
+		w |= 1 << 17
+	}
+	binary.BigEndian.PutUint32(b, w)
+	w = uint32(h.ExpirationTime)<<24 |
+		uint32(h.SourceFabricID&0xfff)<<12 |
+		uint32(h.HopCount)
+	binary.BigEndian.PutUint32(b[4:], w)
+	return b
+}
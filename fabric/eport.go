@@ -0,0 +1,536 @@
+package fabric
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/els"
+	"github.com/bluecmd/fibrechannel/port"
+	"github.com/bluecmd/fibrechannel/swils"
+)
+
+const (
+	// DefaultPriority is the Principal Switch priority of a switch not
+	// configured otherwise.
+	DefaultPriority = 0xfe
+	// DefaultFSTOV is the F_S_TOV given to the Principal Switch selection.
+	DefaultFSTOV = 5 * time.Second
+
+	raTOV      = 10000
+	elpVersion = 3
+
+	maxDomain = 239
+)
+
+// state is the state of the fabric configuration of a switch.
+type state int
+
+const (
+	// stateStable is a switch with its Domain_ID, or alone without one
+	stateStable state = iota
+	// stateSelecting is a switch in the Principal Switch selection
+	stateSelecting
+	// stateAssigning is a switch waiting for the DIA of its upstream
+	// switch to request its Domain_ID
+	stateAssigning
+	// stateRequesting is a switch waiting for the SW_ACC of its RDI
+	stateRequesting
+)
+
+// principal identifies a Principal Switch by its priority and name.
+type principal struct {
+	priority uint8
+	name     common.WWN
+}
+
+// eport is the state of a port operating as an E_Port. up is set once the
+// ELP has been exchanged and cleared when the link goes down, an isolated
+// E_Port carries no traffic. inFabric is set once the neighbour is known to
+// be in the same fabric, from when on FSPF runs on the link.
+type eport struct {
+	portName   common.WWN
+	switchName common.WWN
+	up         bool
+	isolated   bool
+	inFabric   bool
+
+	// FSPF neighbour state. told is set once a HLO naming the Domain_ID
+	// of the neighbour has been sent.
+	domain   uint8
+	index    uint32
+	told     bool
+	adjacent bool
+}
+
+// request is an outstanding SW_ILS originated by the switch. relay is set
+// for an RDI forwarded upstream on behalf of a downstream switch, whose
+// OX_ID it holds.
+type request struct {
+	cmd   swils.Command
+	relay *relay
+}
+
+type relay struct {
+	fport *fport
+	oxid  uint16
+}
+
+// better reports whether p wins the Principal Switch selection over o.
+func (p principal) better(o principal) bool {
+	if p.priority != o.priority {
+		return p.priority < o.priority
+	}
+	return bytes.Compare(p.name[:], o.name[:]) < 0
+}
+
+// AttachISL adds an E_Port on t, a link to another switch, and starts the
+// exchange of link parameters with ELP. The number of the E_Port is
+// returned. A port added with Attach becomes an E_Port when it receives an
+// ELP instead.
+func (s *Switch) AttachISL(t port.Transport) int {
+	var q queue
+	s.mu.Lock()
+	fp := &fport{index: len(s.ports), t: t, e: &eport{}}
+	s.ports = append(s.ports, fp)
+	s.ils(&q, fp, swils.CmdELP, s.elp(fp))
+	s.mu.Unlock()
+	q.flush()
+	go s.serve(fp)
+	return fp.index
+}
+
+// Link connects a and b with an ISL, a originating the ELP. The numbers of
+// the E_Ports on a and b are returned.
+func Link(a, b *Switch) (int, int) {
+	ta, tb := port.Pipe()
+	j := b.Attach(tb)
+	return a.AttachISL(ta), j
+}
+
+// DomainID returns the Domain_ID of the switch, 0 while it has none.
+func (s *Switch) DomainID() uint8 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.domain
+}
+
+// Principal returns the name of the Principal Switch of the fabric.
+func (s *Switch) Principal() common.WWN {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.principal.name
+}
+
+// Domains returns the Domain_IDs of the fabric known to the switch.
+func (s *Switch) Domains() []uint8 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var l []uint8
+	for d := range s.domainList {
+		l = append(l, d)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+	return l
+}
+
+// Stable reports whether the switch is not taking part in a fabric
+// configuration.
+func (s *Switch) Stable() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state == stateStable
+}
+
+// Isolated reports whether the port is an isolated E_Port.
+func (s *Switch) Isolated(port int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return port < len(s.ports) && s.ports[port].e != nil && s.ports[port].e.isolated
+}
+
+func (s *Switch) self() principal {
+	return principal{priority: s.Priority, name: s.Name}
+}
+
+// eports returns the E_Ports that are up and not isolated, except skip.
+func (s *Switch) eports(skip *fport) []*fport {
+	var l []*fport
+	for _, fp := range s.ports {
+		if fp != skip && fp.e != nil && fp.e.up && !fp.e.isolated {
+			l = append(l, fp)
+		}
+	}
+	return l
+}
+
+func (s *Switch) elp(fp *fport) *swils.ELP {
+	return &swils.ELP{
+		Revision: elpVersion,
+		RATOV:    raTOV,
+		EDTOV:    els.DefaultEDTOV,
+		Port:     s.portName(fp),
+		Switch:   s.Name,
+		ClassFParameters: swils.ClassFParameters{
+			Valid:                true,
+			ReceiveDataFieldSize: els.DefaultReceiveSize,
+			ConcurrentSeq:        1,
+			OpenSeqPerExch:       1,
+		},
+		Class3Parameters: swils.ClassParameters{
+			Valid:                true,
+			SequentialDelivery:   true,
+			ReceiveDataFieldSize: els.DefaultReceiveSize,
+		},
+		FlowControl: swils.FlowControl{
+			Mode:   swils.FlowControlRRDY,
+			Params: &swils.RRDYFlowControl{BBCredit: bbCredit},
+		},
+	}
+}
+
+func (s *Switch) efp() *swils.EFP {
+	o := &swils.EFP{
+		PrincipalSwitchPriority: s.principal.priority,
+		PrincipalSwitchName:     s.principal.name,
+	}
+	for d := 1; d <= maxDomain; d++ {
+		if n, ok := s.domainList[uint8(d)]; ok {
+			o.Records = append(o.Records, swils.EFPRecord{Type: swils.EFPRecordDomainIDList, ID: uint8(d), SwitchName: n})
+		}
+	}
+	return o
+}
+
+// ils sends a SW_ILS request to the neighbour on fp.
+func (s *Switch) ils(q *queue, fp *fport, cmd swils.Command, payload interface{}) uint16 {
+	s.oxid++
+	f := fc.NewFrame(common.FabricController, common.FabricController, fc.RCtlUnsolicitedControl, &swils.Frame{Command: cmd, Payload: payload})
+	f.OXID = s.oxid
+	f.FCtl.SetValue(fc.FCtlRequest)
+	s.requests[s.oxid] = request{cmd: cmd}
+	q.add(fp.t, f)
+	return s.oxid
+}
+
+func (s *Switch) flood(q *queue, skip *fport, cmd swils.Command, payload func() interface{}) {
+	for _, fp := range s.eports(skip) {
+		s.ils(q, fp, cmd, payload())
+	}
+}
+
+func (s *Switch) swAccept(q *queue, fp *fport, f *fc.Frame, payload interface{}) {
+	s.reply(q, fp, f, fc.RCtlSolicitedControl, &swils.Frame{Command: swils.CmdSWACC, Payload: payload})
+}
+
+func (s *Switch) swReject(q *queue, fp *fport, f *fc.Frame, reason, explanation uint8) {
+	rjt := &swils.SWRJT{Reason: swils.ReasonCode(reason), Explanation: swils.ReasonExplanation(explanation)}
+	s.reply(q, fp, f, fc.RCtlSolicitedControl, &swils.Frame{Command: swils.CmdSWRJT, Payload: rjt})
+}
+
+// interSwitch handles a frame received on an E_Port.
+func (s *Switch) interSwitch(q *queue, fp *fport, f *fc.Frame) {
+	if fp.e.isolated {
+		return
+	}
+	sw, ok := f.Payload.(*swils.Frame)
+	if !ok || f.DestinationID != common.FabricController {
+		if fp.e.up && f.DestinationID.WellKnown() == common.AddrNone {
+			s.forward(q, f)
+		}
+		return
+	}
+	if f.FCtl.Has(fc.FCtlExchangeContext) {
+		s.ilsReply(q, fp, f, sw)
+		return
+	}
+	if _, ok := sw.Payload.(*swils.ELP); !ok && !fp.e.up {
+		s.swReject(q, fp, f, swils.ReasonUnableToPerform, swils.ExplLinkParamsNotEstablished)
+		return
+	}
+	switch r := sw.Payload.(type) {
+	case *swils.ELP:
+		if r.EDTOV != els.DefaultEDTOV || r.RATOV != raTOV {
+			s.swReject(q, fp, f, swils.ReasonUnableToPerform, swils.ExplTOVMismatch)
+			s.isolate(q, fp)
+			return
+		}
+		fp.e.portName, fp.e.switchName = r.Port, r.Switch
+		s.swAccept(q, fp, f, s.elp(fp))
+		s.linkUp(q, fp)
+	case *swils.EFP:
+		// The SW_ACC carries the view of the switch after the EFP
+		s.exchangeFabric(q, fp, r, func() { s.swAccept(q, fp, f, s.efp()) })
+	case *swils.BF:
+		s.swAccept(q, fp, f, nil)
+		s.build(q, fp)
+	case *swils.DIA:
+		s.swAccept(q, fp, f, nil)
+		s.assigned(q, fp)
+	case *swils.RDI:
+		s.requestDomain(q, fp, f, r)
+	case *swils.HLO:
+		s.hello(q, fp, r)
+	case *swils.LSU:
+		s.swAccept(q, fp, f, nil)
+		s.update(q, fp, r)
+	case *swils.LSA:
+		s.swAccept(q, fp, f, nil)
+	default:
+		s.swReject(q, fp, f, swils.ReasonCommandNotSupported, swils.ExplNone)
+	}
+}
+
+// ilsReply handles the reply to a SW_ILS originated by the switch.
+func (s *Switch) ilsReply(q *queue, fp *fport, f *fc.Frame, sw *swils.Frame) {
+	req, ok := s.requests[f.OXID]
+	if !ok {
+		return
+	}
+	delete(s.requests, f.OXID)
+	if req.relay != nil {
+		f.OXID = req.relay.oxid
+		q.add(req.relay.fport.t, f)
+		return
+	}
+	if sw.Command == swils.CmdSWRJT {
+		if req.cmd == swils.CmdELP {
+			s.isolate(q, fp)
+		}
+		return
+	}
+	if err := sw.DecodeAccept(req.cmd); err != nil {
+		return
+	}
+	switch r := sw.Payload.(type) {
+	case *swils.ELP:
+		if !fp.e.up {
+			fp.e.portName, fp.e.switchName = r.Port, r.Switch
+			s.linkUp(q, fp)
+		}
+	case *swils.EFP:
+		s.exchangeFabric(q, fp, r, func() {})
+	case *swils.RDI:
+		if s.state == stateRequesting && len(r.DomainIDs) > 0 {
+			s.configured(q, r.DomainIDs[0])
+		}
+	}
+}
+
+// linkUp starts the fabric configuration on an E_Port once the link
+// parameters are exchanged.
+func (s *Switch) linkUp(q *queue, fp *fport) {
+	fp.e.up = true
+	s.ils(q, fp, swils.CmdEFP, s.efp())
+}
+
+// exchangeFabric handles the fabric parameters of the neighbour on fp,
+// received in an EFP or its SW_ACC. During the Principal Switch selection
+// the better Principal Switch is taken over and passed on. Otherwise a
+// neighbour in another fabric either merges with this one through a Build
+// Fabric, or its E_Port is isolated if their Domain_IDs overlap. Within a
+// fabric the Domain_ID lists are merged and passed on. reply is called
+// once the view of the switch is updated.
+func (s *Switch) exchangeFabric(q *queue, fp *fport, r *swils.EFP, reply func()) {
+	p := principal{priority: r.PrincipalSwitchPriority, name: r.PrincipalSwitchName}
+	switch {
+	case s.state == stateSelecting:
+		changed := s.mergeDomains(r)
+		if p.better(s.principal) {
+			s.principal, s.upstream = p, fp
+			s.restartTimer()
+			changed = true
+		}
+		reply()
+		if changed {
+			s.flood(q, fp, swils.CmdEFP, func() interface{} { return s.efp() })
+		}
+	case p == s.principal:
+		changed := s.mergeDomains(r)
+		reply()
+		if changed {
+			s.flood(q, fp, swils.CmdEFP, func() interface{} { return s.efp() })
+		}
+		if s.state == stateStable && s.domain != 0 && !fp.e.inFabric {
+			fp.e.inFabric = true
+			s.sayHello(q, fp)
+		}
+	case s.state == stateStable:
+		for _, rec := range r.Records {
+			if _, ok := s.domainList[rec.ID]; ok {
+				reply()
+				s.isolate(q, fp)
+				return
+			}
+		}
+		reply()
+		s.build(q, nil)
+	default:
+		reply()
+	}
+}
+
+// mergeDomains adds the Domain_IDs of an EFP missing from the list of the
+// switch and reports whether there were any.
+func (s *Switch) mergeDomains(r *swils.EFP) bool {
+	changed := false
+	for _, rec := range r.Records {
+		if _, ok := s.domainList[rec.ID]; !ok && rec.Type == swils.EFPRecordDomainIDList {
+			s.domainList[rec.ID] = rec.SwitchName
+			changed = true
+		}
+	}
+	return changed
+}
+
+// build starts a non-disruptive Principal Switch selection, passing on the
+// BF to all E_Ports but the one it was received on, if any. The Domain_ID
+// lists of the fabrics are kept and merged, so that the switches get their
+// Domain_IDs again.
+func (s *Switch) build(q *queue, from *fport) {
+	if s.state == stateSelecting {
+		return
+	}
+	s.state = stateSelecting
+	s.principal, s.upstream = s.self(), nil
+	for _, fp := range s.eports(nil) {
+		fp.e.inFabric = false
+	}
+	s.flood(q, from, swils.CmdBF, func() interface{} { return &swils.BF{} })
+	s.flood(q, nil, swils.CmdEFP, func() interface{} { return s.efp() })
+	s.restartTimer()
+}
+
+// restartTimer (re)starts the F_S_TOV of the Principal Switch selection.
+func (s *Switch) restartTimer() {
+	s.generation++
+	g := s.generation
+	time.AfterFunc(s.FSTOV, func() {
+		var q queue
+		s.mu.Lock()
+		if s.generation == g && s.state == stateSelecting {
+			s.selected(&q)
+		}
+		s.mu.Unlock()
+		q.flush()
+	})
+}
+
+// selected ends the Principal Switch selection. The Principal Switch
+// assigns its own Domain_ID, the other switches wait for the DIA of their
+// upstream switch.
+func (s *Switch) selected(q *queue) {
+	s.generation++
+	if s.principal.name != s.Name {
+		s.state = stateAssigning
+		return
+	}
+	d := s.domain
+	if d == 0 {
+		d = s.Domain
+	}
+	if d == 0 {
+		d = 1
+	}
+	s.domainList[d] = s.Name
+	s.configured(q, d)
+}
+
+// assigned handles the DIA of a neighbour, requesting the Domain_ID from
+// the Principal Switch if the neighbour is upstream.
+func (s *Switch) assigned(q *queue, fp *fport) {
+	if fp != s.upstream || (s.state != stateSelecting && s.state != stateAssigning) {
+		return
+	}
+	// The DIA of the upstream switch ends the selection even if the
+	// F_S_TOV has not expired yet
+	s.generation++
+	s.state = stateRequesting
+	d := s.domain
+	if d == 0 {
+		d = s.Domain
+	}
+	req := &swils.RDI{SwitchName: s.Name}
+	if d != 0 {
+		req.DomainIDs = swils.DomainIDList{d}
+	}
+	s.ils(q, fp, swils.CmdRDI, req)
+}
+
+// requestDomain handles an RDI. The Principal Switch grants the Domain_ID
+// requested if it is free or already held by the requesting switch, or the
+// lowest free one otherwise. Other switches relay the RDI upstream.
+func (s *Switch) requestDomain(q *queue, fp *fport, f *fc.Frame, r *swils.RDI) {
+	if s.state != stateStable || s.domain == 0 {
+		s.swReject(q, fp, f, swils.ReasonLogicalBusy, swils.ExplNone)
+		return
+	}
+	if s.principal.name != s.Name {
+		if s.upstream == nil {
+			s.swReject(q, fp, f, swils.ReasonUnableToPerform, swils.ExplNone)
+			return
+		}
+		oxid := s.ils(q, s.upstream, swils.CmdRDI, r)
+		s.requests[oxid] = request{cmd: swils.CmdRDI, relay: &relay{fport: fp, oxid: f.OXID}}
+		return
+	}
+	var d uint8
+	for _, w := range r.DomainIDs {
+		if n, ok := s.domainList[w]; w != 0 && w <= maxDomain && (!ok || n == r.SwitchName) {
+			d = w
+			break
+		}
+	}
+	for w := 1; d == 0 && w <= maxDomain; w++ {
+		if _, ok := s.domainList[uint8(w)]; !ok {
+			d = uint8(w)
+		}
+	}
+	if d == 0 {
+		s.swReject(q, fp, f, swils.ReasonUnableToPerform, swils.ExplDomainIDsNotAvailable)
+		return
+	}
+	s.domainList[d] = r.SwitchName
+	s.swAccept(q, fp, f, &swils.RDI{SwitchName: s.Name, DomainIDs: swils.DomainIDList{d}})
+	s.flood(q, nil, swils.CmdEFP, func() interface{} { return s.efp() })
+}
+
+// configured completes the fabric configuration of the switch with its
+// Domain_ID d, passing the DIA downstream and bringing up FSPF.
+func (s *Switch) configured(q *queue, d uint8) {
+	if s.domain != d {
+		for _, fp := range s.ports {
+			for len(fp.devices) > 0 {
+				s.leave(q, fp.devices[0])
+			}
+		}
+		s.domain = d
+	}
+	s.state = stateStable
+	s.domainList[d] = s.Name
+	s.flood(q, s.upstream, swils.CmdDIA, func() interface{} { return &swils.DIA{SwitchName: s.Name} })
+	for _, fp := range s.eports(nil) {
+		fp.e.inFabric = true
+		s.sayHello(q, fp)
+	}
+	s.originate(q, nil)
+}
+
+// isolate stops all traffic on an E_Port, as after a failed ELP or when the
+// fabrics on both ends cannot merge.
+func (s *Switch) isolate(q *queue, fp *fport) {
+	fp.e.isolated = true
+	s.linkDown(q, fp)
+}
+
+// linkDown removes the FSPF adjacency on an E_Port no longer carrying
+// traffic.
+func (s *Switch) linkDown(q *queue, fp *fport) {
+	fp.e.inFabric, fp.e.told = false, false
+	if fp.e.adjacent {
+		fp.e.adjacent = false
+		s.originate(q, nil)
+	}
+}
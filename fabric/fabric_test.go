@@ -0,0 +1,305 @@
+package fabric
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/ct"
+	"github.com/bluecmd/fibrechannel/els"
+	"github.com/bluecmd/fibrechannel/port"
+	"github.com/bluecmd/fibrechannel/zone"
+)
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// attach returns a new N_Port connected to s and logged in with it.
+func attach(ctx context.Context, t *testing.T, s *Switch, n byte, types ...uint8) *port.NPort {
+	p := port.NewNPort(s.Connect(), common.WWN{0x10, 0, 0, 0, 0, 0, 0, n}, common.WWN{0x20, 0, 0, 0, 0, 0, 0, n})
+	p.Types = types
+	go p.Serve()
+	t.Cleanup(func() { p.Close() })
+	if err := p.FLOGI(ctx); err != nil {
+		t.Fatalf("FLOGI: %v", err)
+	}
+	if err := p.Register(ctx); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return p
+}
+
+// newSwitch returns a switch with a short F_S_TOV.
+func newSwitch(t *testing.T, n byte, domain uint8) *Switch {
+	s := NewSwitch(common.WWN{0x10, 0, 0, 0x05, 0x1e, 0, 0, n}, domain)
+	s.FSTOV = 50 * time.Millisecond
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// settle waits for cond to hold.
+func settle(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+	}
+}
+
+// built reports whether the switches form a fabric with the Principal
+// Switch p and routes between all of them.
+func built(p common.WWN, switches ...*Switch) func() bool {
+	return func() bool {
+		for _, s := range switches {
+			if !s.Stable() || s.Principal() != p || s.DomainID() == 0 {
+				return false
+			}
+			for _, o := range switches {
+				if _, ok := s.Route(o.DomainID()); o != s && !ok {
+					return false
+				}
+			}
+		}
+		return true
+	}
+}
+
+func TestSwitch(t *testing.T) {
+	ctx := testContext(t)
+	s := NewSwitch(common.WWN{0x10, 0, 0, 0x05, 0x1e, 0, 0, 0x0a}, 0x0a)
+	defer s.Close()
+	s.Zoning = &zone.ZoneSet{
+		Name: "cfg",
+		Zones: []zone.Zone{{
+			Name: "host_array",
+			Members: []zone.Member{
+				{Type: zone.MemberPortName, ID: common.WWN{0x10, 0, 0, 0, 0, 0, 0, 1}},
+				{Type: zone.MemberDomainPort, ID: zone.DomainPort{Domain: 0x0a, Port: 1}},
+			},
+		}},
+	}
+
+	host := attach(ctx, t, s, 1)
+	rscns := make(chan common.FCID, 8)
+	host.OnRSCN = func(r *els.RSCN) {
+		for _, p := range r.Pages {
+			rscns <- p.Address
+		}
+	}
+	if err := host.SCR(ctx); err != nil {
+		t.Fatalf("SCR: %v", err)
+	}
+	array := attach(ctx, t, s, 2)
+	tape := attach(ctx, t, s, 3)
+	if want, got := []common.FCID{{0x0a, 0x00, 0x00}, {0x0a, 0x01, 0x00}, {0x0a, 0x02, 0x00}}, s.LoggedIn(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("got N_Ports %v, wanted %v", got, want)
+	}
+	select {
+	case id := <-rscns:
+		if id != array.ID() {
+			t.Fatalf("got RSCN for %v, wanted %v", id, array.ID())
+		}
+	case <-ctx.Done():
+		t.Fatalf("no RSCN for the array joining")
+	}
+
+	// The tape is registered but not zoned with the host
+	if want, got := []common.FCID{host.ID(), array.ID(), tape.ID()}, s.Registered(els.FC4TypeFCP); !reflect.DeepEqual(want, got) {
+		t.Fatalf("got registered ports %v, wanted %v", got, want)
+	}
+	ids, err := host.Query(ctx, els.FC4TypeFCP)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if want := []common.FCID{host.ID(), array.ID()}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("got FCP ports %v, wanted %v", ids, want)
+	}
+	r, err := host.CT(ctx, common.DirectoryServer, ct.NameServerRequest(ct.CmdGPNID, &ct.PortIdentifier{PortID: array.ID()}))
+	if err != nil {
+		t.Fatalf("GPN_ID: %v", err)
+	}
+	if n, ok := r.Payload.(*ct.NameIdentifier); !ok || n.Name != array.PortName {
+		t.Fatalf("unexpected GPN_ID accept %+v", r.Payload)
+	}
+	_, err = host.CT(ctx, common.DirectoryServer, ct.NameServerRequest(ct.CmdGPNID, &ct.PortIdentifier{PortID: tape.ID()}))
+	var rjt *port.RejectError
+	if !errors.As(err, &rjt) || rjt.Explanation != ct.ExplPortIDNotRegistered {
+		t.Fatalf("got %v for GPN_ID of the tape, wanted FS_RJT", err)
+	}
+
+	if err := host.Login(ctx, array.ID()); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	short, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := host.Login(short, tape.ID()); err != context.DeadlineExceeded {
+		t.Fatalf("got %v for Login to the tape, wanted the frames dropped", err)
+	}
+
+	array.Close()
+	select {
+	case id := <-rscns:
+		if id != (common.FCID{0x0a, 0x01, 0x00}) {
+			t.Fatalf("got RSCN for %v, wanted the array", id)
+		}
+	case <-ctx.Done():
+		t.Fatalf("no RSCN for the array leaving")
+	}
+	if want, got := []common.FCID{host.ID(), tape.ID()}, s.LoggedIn(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("got N_Ports %v, wanted %v", got, want)
+	}
+}
+
+// login sends a request to the F_Port Controller and returns the reply.
+func login(t *testing.T, link port.Transport, req interface{}) *fc.Frame {
+	t.Helper()
+	f := &fc.Frame{
+		RCtl:          fc.RCtlELSRequest,
+		DestinationID: common.FPortController,
+		CsctlPriority: &fc.CSCtl{},
+		RXID:          fc.XIDUnassigned,
+		Payload:       &els.Frame{Payload: req},
+	}
+	f.FCtl.SetValue(fc.FCtlRequest)
+	if err := link.Send(f); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	r, err := link.Receive()
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	return r
+}
+
+func TestFDISC(t *testing.T) {
+	s := NewSwitch(common.WWN{0x10, 0, 0, 0x05, 0x1e, 0, 0, 0x01}, 0x01)
+	defer s.Close()
+	s.Connect()
+	link := s.Connect()
+
+	login := func(req interface{}) *fc.Frame {
+		return login(t, link, req)
+	}
+
+	r := login(&els.FDISC{PortName: common.WWN{0x10, 0, 0, 0, 0, 0, 0, 2}})
+	if _, ok := r.Payload.(*els.Frame).Payload.(*els.LSRJT); !ok {
+		t.Fatalf("got %+v for FDISC before FLOGI, wanted LS_RJT", r.Payload)
+	}
+	var tests = []struct {
+		req  interface{}
+		want common.FCID
+	}{
+		{&els.FLOGI{PortName: common.WWN{0x10, 0, 0, 0, 0, 0, 0, 1}}, common.FCID{0x01, 0x01, 0x00}},
+		{&els.FDISC{PortName: common.WWN{0x10, 0, 0, 0, 0, 0, 0, 2}}, common.FCID{0x01, 0x01, 0x01}},
+		{&els.FDISC{PortName: common.WWN{0x10, 0, 0, 0, 0, 0, 0, 3}}, common.FCID{0x01, 0x01, 0x02}},
+	}
+	for _, tt := range tests {
+		r := login(tt.req)
+		if _, ok := r.Payload.(*els.Frame).Payload.(*els.LSACC); !ok || r.DestinationID != tt.want {
+			t.Fatalf("got %v %+v for %T, wanted LS_ACC to %v", r.DestinationID, r.Payload, tt.req, tt.want)
+		}
+	}
+	if want, got := []common.FCID{{0x01, 0x01, 0x00}, {0x01, 0x01, 0x01}, {0x01, 0x01, 0x02}}, s.LoggedIn(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("got N_Ports %v, wanted %v", got, want)
+	}
+
+	// A new FLOGI on the F_Port logs out the N_Ports logged in before
+	login(&els.FLOGI{PortName: common.WWN{0x10, 0, 0, 0, 0, 0, 0, 4}})
+	if want, got := []common.FCID{{0x01, 0x01, 0x00}}, s.LoggedIn(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("got N_Ports %v after FLOGI, wanted %v", got, want)
+	}
+
+	// FDISC is rejected once the Port of the N_Port_ID runs out
+	for i := 1; i <= 0xff; i++ {
+		r := login(&els.FDISC{PortName: common.WWN{0x10, 0, 0, 0, 0, 0, 1, byte(i)}})
+		if want := (common.FCID{0x01, 0x01, byte(i)}); r.DestinationID != want {
+			t.Fatalf("got %v for FDISC %d, wanted %v", r.DestinationID, i, want)
+		}
+	}
+	r = login(&els.FDISC{PortName: common.WWN{0x10, 0, 0, 0, 0, 0, 2, 0}})
+	if _, ok := r.Payload.(*els.Frame).Payload.(*els.LSRJT); !ok {
+		t.Fatalf("got %+v for FDISC with all Ports in use, wanted LS_RJT", r.Payload)
+	}
+}
+
+func TestAreaExhausted(t *testing.T) {
+	s := NewSwitch(common.WWN{0x10, 0, 0, 0x05, 0x1e, 0, 0, 0x01}, 0x01)
+	defer s.Close()
+	for i := 0; i <= 0xff; i++ {
+		s.Connect()
+	}
+	r := login(t, s.Connect(), &els.FLOGI{PortName: common.WWN{0x10, 0, 0, 0, 0, 0, 0, 1}})
+	if _, ok := r.Payload.(*els.Frame).Payload.(*els.LSRJT); !ok {
+		t.Fatalf("got %+v for FLOGI on F_Port 256, wanted LS_RJT", r.Payload)
+	}
+}
+
+func TestFabricMerge(t *testing.T) {
+	ctx := testContext(t)
+	a, b, c := newSwitch(t, 0x0a, 1), newSwitch(t, 0x0b, 2), newSwitch(t, 0x0c, 0)
+	b.Priority = 0x01
+
+	// Two fabrics with disjoint Domain_IDs merge, keeping them
+	ab, _ := Link(a, b)
+	settle(t, "fabric of a and b", built(b.Name, a, b))
+	if want, got := []uint8{1, 2}, a.Domains(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("got Domain_IDs %v, wanted %v", got, want)
+	}
+	if a.DomainID() != 1 || b.DomainID() != 2 {
+		t.Fatalf("Domain_IDs changed in merge to %d and %d", a.DomainID(), b.DomainID())
+	}
+	if p, _ := a.Route(2); p != ab {
+		t.Fatalf("route to domain 2 over port %d, wanted %d", p, ab)
+	}
+
+	// A switch without a Domain_ID gets the lowest free one
+	Link(c, b)
+	settle(t, "fabric of a, b and c", built(b.Name, a, b, c))
+	if c.DomainID() != 3 {
+		t.Fatalf("got Domain_ID %d, wanted 3", c.DomainID())
+	}
+	if want, got := []uint8{1, 2, 3}, c.Domains(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("got Domain_IDs %v, wanted %v", got, want)
+	}
+	if p, _ := a.Route(3); p != ab {
+		t.Fatalf("route to domain 3 over port %d, wanted %d", p, ab)
+	}
+
+	// N_Ports on a and c log in with each other over two hops
+	host, array := attach(ctx, t, a, 1), attach(ctx, t, c, 2)
+	if host.ID().Domain() != 1 || array.ID().Domain() != 3 {
+		t.Fatalf("unexpected N_Port_IDs %v %v", host.ID(), array.ID())
+	}
+	if err := host.Login(ctx, array.ID()); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	var found bool
+	for _, l := range array.Logins() {
+		found = found || l.PortID == host.ID()
+	}
+	if !found {
+		t.Fatalf("host not logged in at array %+v", array.Logins())
+	}
+}
+
+func TestDomainOverlap(t *testing.T) {
+	a, b := newSwitch(t, 0x0a, 1), newSwitch(t, 0x0b, 1)
+	i, j := Link(a, b)
+	settle(t, "isolation", func() bool { return a.Isolated(i) && b.Isolated(j) })
+	for _, s := range []*Switch{a, b} {
+		if s.Principal() != s.Name || s.DomainID() != 1 {
+			t.Errorf("switch %v changed to principal %v, domain %d", s.Name, s.Principal(), s.DomainID())
+		}
+		if _, ok := s.Route(1); ok {
+			t.Errorf("switch %v has a route over the isolated E_Port", s.Name)
+		}
+	}
+}
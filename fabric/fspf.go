@@ -0,0 +1,200 @@
+package fabric
+
+import (
+	"github.com/bluecmd/fibrechannel/swils"
+)
+
+const (
+	helloInterval = 20 // seconds
+	deadInterval  = 80 // seconds
+	// linkCost is the FSPF cost of every ISL, that of a 2 Gbit/s link
+	linkCost = 500
+)
+
+// Route returns the E_Port of the route to the domain, false if the domain
+// is not reachable.
+func (s *Switch) Route(domain uint8) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fp, ok := s.routes[domain]
+	if !ok {
+		return 0, false
+	}
+	return fp.index, true
+}
+
+func (s *Switch) fspfHeader() swils.FSPFHeader {
+	return swils.FSPFHeader{Version: swils.FSPFVersion, OriginDomain: s.domain}
+}
+
+// sayHello sends a HLO to the neighbour on fp, naming its Domain_ID if
+// known.
+func (s *Switch) sayHello(q *queue, fp *fport) {
+	fp.e.told = fp.e.domain != 0
+	s.ils(q, fp, swils.CmdHLO, &swils.HLO{
+		Header:          s.fspfHeader(),
+		HelloInterval:   helloInterval,
+		DeadInterval:    deadInterval,
+		RecipientDomain: fp.e.domain,
+		PortIndex:       uint32(fp.index),
+	})
+}
+
+// hello handles the HLO of the neighbour on fp. The adjacency is up once
+// both switches have seen their Domain_ID in a HLO of the other, when the
+// link state database is sent to the neighbour.
+func (s *Switch) hello(q *queue, fp *fport, r *swils.HLO) {
+	if s.state != stateStable || s.domain == 0 {
+		return
+	}
+	fp.e.inFabric = true
+	fp.e.domain, fp.e.index = r.Header.OriginDomain, r.PortIndex
+	if !fp.e.told {
+		s.sayHello(q, fp)
+	}
+	if r.RecipientDomain == s.domain && !fp.e.adjacent {
+		fp.e.adjacent = true
+		s.originate(q, fp)
+	}
+}
+
+// originate installs a new Link State Record of the switch and floods it.
+// full, if set, is a new adjacency which is sent the whole database.
+func (s *Switch) originate(q *queue, full *fport) {
+	if s.domain == 0 {
+		return
+	}
+	s.incarnation++
+	lsr := swils.LSR{
+		Type:              swils.LSRTypeSwitchLink,
+		ID:                s.domain,
+		AdvertisingDomain: s.domain,
+		Incarnation:       s.incarnation,
+	}
+	for _, fp := range s.eports(nil) {
+		if fp.e.adjacent {
+			lsr.Links = append(lsr.Links, swils.Link{
+				Domain:            fp.e.domain,
+				PortIndex:         uint32(fp.index),
+				NeighborPortIndex: fp.e.index,
+				Type:              swils.LinkTypePointToPoint,
+				Cost:              linkCost,
+			})
+		}
+	}
+	s.lsdb[s.domain] = lsr
+	s.route()
+	for _, fp := range s.eports(nil) {
+		switch {
+		case fp == full:
+			s.ils(q, fp, swils.CmdLSU, &swils.LSU{Header: s.fspfHeader(), Records: s.database()})
+		case fp.e.adjacent:
+			s.ils(q, fp, swils.CmdLSU, &swils.LSU{Header: s.fspfHeader(), Records: swils.LSRList{lsr}})
+		}
+	}
+}
+
+// database returns the link state database in the order of Domain_IDs.
+func (s *Switch) database() swils.LSRList {
+	var l swils.LSRList
+	for d := 1; d <= maxDomain; d++ {
+		if lsr, ok := s.lsdb[uint8(d)]; ok {
+			l = append(l, lsr)
+		}
+	}
+	return l
+}
+
+// update handles the LSU of the neighbour on fp. Records newer than those
+// in the database are installed and flooded to the other adjacent
+// neighbours, and all records are acknowledged with an LSA. The database
+// is kept up to date during a non-disruptive fabric reconfiguration.
+func (s *Switch) update(q *queue, fp *fport, r *swils.LSU) {
+	if s.domain == 0 {
+		return
+	}
+	var acked swils.LSRHeaderList
+	var flood swils.LSRList
+	for _, lsr := range r.Records {
+		h := lsr
+		h.Links = nil
+		acked = append(acked, h)
+		// The length is filled in again when the record is flooded
+		lsr.Length = 0
+		if lsr.ID == s.domain {
+			// A stale record of the switch itself is replaced by a
+			// newer incarnation
+			if lsr.Incarnation >= s.incarnation {
+				s.incarnation = lsr.Incarnation
+				s.originate(q, nil)
+			}
+			continue
+		}
+		if cur, ok := s.lsdb[lsr.ID]; ok && cur.Incarnation >= lsr.Incarnation {
+			continue
+		}
+		s.lsdb[lsr.ID] = lsr
+		flood = append(flood, lsr)
+	}
+	s.ils(q, fp, swils.CmdLSA, &swils.LSA{Header: s.fspfHeader(), Records: acked})
+	if len(flood) == 0 {
+		return
+	}
+	s.route()
+	for _, o := range s.eports(fp) {
+		if o.e.adjacent {
+			s.ils(q, o, swils.CmdLSU, &swils.LSU{Header: s.fspfHeader(), Records: flood})
+		}
+	}
+}
+
+// route computes the shortest paths to all domains from the link state
+// database. Links are only used if both of their switches report them,
+// ties are broken by the lower Domain_ID and port.
+func (s *Switch) route() {
+	const infinite = int(^uint(0) >> 1)
+	dist := map[uint8]int{s.domain: 0}
+	// hop holds the E_Port of the first hop to a domain
+	hop := map[uint8]*fport{}
+	done := map[uint8]bool{}
+	for {
+		var u uint8
+		best := infinite
+		for d := 1; d <= maxDomain; d++ {
+			if c, ok := dist[uint8(d)]; ok && !done[uint8(d)] && c < best {
+				u, best = uint8(d), c
+			}
+		}
+		if best == infinite {
+			break
+		}
+		done[u] = true
+		for _, l := range s.lsdb[u].Links {
+			if !s.twoWay(l.Domain, u) {
+				continue
+			}
+			c := best + int(l.Cost)
+			if cur, ok := dist[l.Domain]; ok && cur <= c {
+				continue
+			}
+			dist[l.Domain] = c
+			if u == s.domain {
+				hop[l.Domain] = s.ports[l.PortIndex]
+			} else {
+				hop[l.Domain] = hop[u]
+			}
+		}
+	}
+	delete(hop, s.domain)
+	s.routes = hop
+}
+
+// twoWay reports whether the record of the domain a lists a link to b.
+func (s *Switch) twoWay(a, b uint8) bool {
+	for _, l := range s.lsdb[a].Links {
+		if l.Domain == b {
+			return true
+		}
+	}
+	return false
+}
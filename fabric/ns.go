@@ -0,0 +1,81 @@
+package fabric
+
+import (
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/ct"
+)
+
+// nameServer answers a request to the name server from the device d. Only
+// the devices zoned with d are visible to its queries, and d may only
+// register objects for its own N_Port_ID.
+func (s *Switch) nameServer(d *device, r *ct.Frame) *ct.Frame {
+	if r.GSType != ct.GSTypeDirectory || r.GSSubtype != ct.GSSubtypeNameServer {
+		return r.Reject(ct.ReasonCommandNotSupported, ct.ExplNone)
+	}
+	switch q := r.Payload.(type) {
+	case *ct.RFTID:
+		if q.PortID != d.id {
+			return r.Reject(ct.ReasonUnableToPerform, ct.ExplInvalidPortID)
+		}
+		d.types = q.Types
+		return r.Accept(nil)
+	case *ct.RPNID:
+		if q.PortID != d.id {
+			return r.Reject(ct.ReasonUnableToPerform, ct.ExplInvalidPortID)
+		}
+		d.portName = q.PortName
+		return r.Accept(nil)
+	case *ct.RNNID:
+		if q.PortID != d.id {
+			return r.Reject(ct.ReasonUnableToPerform, ct.ExplInvalidPortID)
+		}
+		d.nodeName = q.NodeName
+		return r.Accept(nil)
+	case *ct.GIDFT:
+		l := ct.PortIDList(s.sorted(func(o *device) bool {
+			return s.visible(d, o) && o.types.Has(q.Type) &&
+				(q.DomainScope == 0 || q.DomainScope == o.id.Domain()) &&
+				(q.AreaScope == 0 || q.AreaScope == o.id.Area())
+		}))
+		if len(l) == 0 {
+			return r.Reject(ct.ReasonUnableToPerform, ct.ExplFC4TypeNotRegistered)
+		}
+		return r.Accept(&l)
+	case *ct.NameIdentifier:
+		l := s.sorted(func(o *device) bool { return s.visible(d, o) && o.portName == q.Name })
+		if len(l) > 0 {
+			return r.Accept(&ct.PortIdentifier{PortID: l[0]})
+		}
+		return r.Reject(ct.ReasonUnableToPerform, ct.ExplPortNameNotRegistered)
+	case *ct.PortIdentifier:
+		if r.Command == ct.CmdDAID {
+			if q.PortID != d.id {
+				return r.Reject(ct.ReasonUnableToPerform, ct.ExplInvalidPortID)
+			}
+			d.types = ct.FC4Types{}
+			return r.Accept(nil)
+		}
+		o, ok := s.devices[q.PortID]
+		if !ok || !s.visible(d, o) {
+			return r.Reject(ct.ReasonUnableToPerform, ct.ExplPortIDNotRegistered)
+		}
+		switch r.Command {
+		case ct.CmdGPNID:
+			return r.Accept(&ct.NameIdentifier{Name: o.portName})
+		case ct.CmdGNNID:
+			return r.Accept(&ct.NameIdentifier{Name: o.nodeName})
+		case ct.CmdGFTID:
+			ft := o.types
+			return r.Accept(&ft)
+		}
+	}
+	return r.Reject(ct.ReasonCommandNotSupported, ct.ExplNone)
+}
+
+// Registered returns the N_Port_IDs registered with the name server for the
+// FC-4 TYPE t.
+func (s *Switch) Registered(t uint8) []common.FCID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sorted(func(d *device) bool { return d.types.Has(t) })
+}
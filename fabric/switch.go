@@ -0,0 +1,490 @@
+// Package fabric emulates a Fibre Channel switch for N_Ports on Transports,
+// such as the software N_Ports of package port. The switch logs in N_Ports
+// with FLOGI and FDISC and assigns their N_Port_IDs, runs the name server
+// of the Directory Service, delivers RSCNs to the ports registered with
+// SCR and forwards frames between the ports by D_ID, enforcing the active
+// zone set.
+//
+// Switches linked by ISLs build a fabric. They exchange link parameters
+// with ELP, select the Principal Switch with EFP and BF, get their
+// Domain_IDs assigned with RDI and DIA and route frames between the domains
+// over the paths computed by FSPF. Linking two fabrics merges them if
+// their Domain_IDs are disjoint, otherwise the E_Port is isolated.
+package fabric
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/ct"
+	"github.com/bluecmd/fibrechannel/els"
+	"github.com/bluecmd/fibrechannel/port"
+	"github.com/bluecmd/fibrechannel/swils"
+	"github.com/bluecmd/fibrechannel/zone"
+)
+
+const bbCredit = 64
+
+// Switch is an emulated switch. Every Transport attached is an F_Port,
+// whose number is also the Area of the N_Port_IDs assigned on it, unless it
+// is an ISL to another switch.
+//
+// Domain is the Domain_ID requested by the switch, a switch with a Domain
+// starts out as a fabric of its own. Priority and FSTOV are used in the
+// Principal Switch selection.
+//
+// Zoning is the active zone set, with nil all ports may talk to each
+// other. Aliases in the zone set are not resolved, and as the name server
+// only knows the N_Ports of its own switch, N_Ports of other switches are
+// only matched by Domain and Port or N_Port_ID.
+type Switch struct {
+	Name     common.WWN
+	Domain   uint8
+	Priority uint8
+	FSTOV    time.Duration
+	Zoning   *zone.ZoneSet
+
+	mu    sync.Mutex
+	ports []*fport
+	// devices holds the N_Ports logged in by N_Port_ID
+	devices map[common.FCID]*device
+	// scr holds the registration function of the N_Ports registered for
+	// RSCNs
+	scr  map[common.FCID]uint8
+	oxid uint16
+
+	// Fabric configuration. domain is the Domain_ID assigned, upstream
+	// the E_Port towards the Principal Switch and domainList the
+	// Domain_IDs of the fabric. generation invalidates running F_S_TOV
+	// timers.
+	domain     uint8
+	state      state
+	principal  principal
+	upstream   *fport
+	domainList map[uint8]common.WWN
+	generation int
+	// requests holds the SW_ILSs originated by the switch by OX_ID
+	requests map[uint16]request
+
+	// FSPF link state database by Domain_ID and the E_Port of the first
+	// hop to the other domains.
+	lsdb        map[uint8]swils.LSR
+	incarnation uint32
+	routes      map[uint8]*fport
+}
+
+// fport is a port of the switch, an F_Port unless e is set. next is the
+// Port of the N_Port_ID assigned by the next FDISC.
+type fport struct {
+	index   int
+	t       port.Transport
+	devices []*device
+	next    int
+	e       *eport
+}
+
+// maxAddress is the highest Area and Port of an N_Port_ID. The Area is the
+// number of the F_Port, FLOGI and FDISC are rejected once either runs out.
+const maxAddress = 0xff
+
+// device is an N_Port logged in with the fabric and its name server
+// registrations.
+type device struct {
+	fport    *fport
+	id       common.FCID
+	portName common.WWN
+	nodeName common.WWN
+	types    ct.FC4Types
+}
+
+// delivery is a frame to be sent once the switch is unlocked.
+type delivery struct {
+	t port.Transport
+	f *fc.Frame
+}
+
+type queue []delivery
+
+// NewSwitch returns a switch without any ports. With a domain other than 0
+// it is a fabric of its own with that Domain_ID, otherwise it gets its
+// Domain_ID once linked to other switches.
+func NewSwitch(name common.WWN, domain uint8) *Switch {
+	s := &Switch{
+		Name:       name,
+		Domain:     domain,
+		Priority:   DefaultPriority,
+		FSTOV:      DefaultFSTOV,
+		devices:    map[common.FCID]*device{},
+		scr:        map[common.FCID]uint8{},
+		domain:     domain,
+		domainList: map[uint8]common.WWN{},
+		requests:   map[uint16]request{},
+		lsdb:       map[uint8]swils.LSR{},
+	}
+	s.principal = s.self()
+	if domain != 0 {
+		s.domainList[domain] = name
+	}
+	return s
+}
+
+// Attach adds an F_Port on t and serves it until t is closed, when the
+// N_Ports logged in on it leave the fabric. The number of the port is
+// returned.
+func (s *Switch) Attach(t port.Transport) int {
+	s.mu.Lock()
+	fp := &fport{index: len(s.ports), t: t}
+	s.ports = append(s.ports, fp)
+	s.mu.Unlock()
+	go s.serve(fp)
+	return fp.index
+}
+
+// Connect attaches a new F_Port and returns the Transport of the N_Port at
+// the other end of the link.
+func (s *Switch) Connect() port.Transport {
+	a, b := port.Pipe()
+	s.Attach(a)
+	return b
+}
+
+// Close closes the Transports of all F_Ports.
+func (s *Switch) Close() error {
+	s.mu.Lock()
+	ports := append([]*fport{}, s.ports...)
+	s.mu.Unlock()
+	for _, fp := range ports {
+		fp.t.Close()
+	}
+	return nil
+}
+
+// LoggedIn returns the N_Port_IDs of the N_Ports logged in with the fabric.
+func (s *Switch) LoggedIn() []common.FCID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sorted(nil)
+}
+
+func (s *Switch) serve(fp *fport) {
+	for {
+		f, err := fp.t.Receive()
+		if err != nil {
+			break
+		}
+		var q queue
+		s.mu.Lock()
+		s.dispatch(&q, fp, f)
+		s.mu.Unlock()
+		q.flush()
+	}
+	var q queue
+	s.mu.Lock()
+	for len(fp.devices) > 0 {
+		s.leave(&q, fp.devices[0])
+	}
+	if fp.e != nil && fp.e.up {
+		fp.e.up = false
+		s.linkDown(&q, fp)
+	}
+	s.mu.Unlock()
+	q.flush()
+}
+
+func (q *queue) add(t port.Transport, f *fc.Frame) {
+	*q = append(*q, delivery{t: t, f: f})
+}
+
+func (q queue) flush() {
+	for _, d := range q {
+		d.t.Send(d.f)
+	}
+}
+
+// dispatch handles a frame received on a port, the caller holds the lock.
+func (s *Switch) dispatch(q *queue, fp *fport, f *fc.Frame) {
+	if fp.e == nil && len(fp.devices) == 0 {
+		// A port receiving an ELP is linked to another switch
+		if sw, ok := f.Payload.(*swils.Frame); ok && sw.Command == swils.CmdELP {
+			fp.e = &eport{}
+		}
+	}
+	if fp.e != nil {
+		s.interSwitch(q, fp, f)
+		return
+	}
+	if f.FCtl.Has(fc.FCtlExchangeContext) && f.DestinationID.WellKnown() != common.AddrNone {
+		// Replies to the RSCNs of the Fabric Controller
+		return
+	}
+	switch f.DestinationID {
+	case common.FPortController:
+		s.login(q, fp, f)
+		return
+	case common.FabricController:
+		s.fabricController(q, fp, f)
+		return
+	case common.DirectoryServer:
+		s.directory(q, fp, f)
+		return
+	}
+	src, ok := s.devices[f.SourceID]
+	if !ok || src.fport != fp {
+		return
+	}
+	if f.DestinationID.WellKnown() != common.AddrNone {
+		if _, ok := f.Payload.(*els.Frame); ok && !f.FCtl.Has(fc.FCtlExchangeContext) {
+			s.reject(q, fp, f, els.ReasonUnableToPerform, els.ExplNone)
+		}
+		return
+	}
+	s.forward(q, f)
+}
+
+// forward delivers a frame to its destination, an N_Port logged in with
+// the switch or the E_Port of the route to its domain.
+func (s *Switch) forward(q *queue, f *fc.Frame) {
+	if !s.permits(f.SourceID, f.DestinationID) {
+		return
+	}
+	if d := f.DestinationID.Domain(); d != s.domain {
+		if fp, ok := s.routes[d]; ok {
+			q.add(fp.t, f)
+		}
+		return
+	}
+	if dst, ok := s.devices[f.DestinationID]; ok {
+		q.add(dst.fport.t, f)
+	}
+}
+
+// login handles the requests to the F_Port Controller.
+func (s *Switch) login(q *queue, fp *fport, f *fc.Frame) {
+	e, ok := f.Payload.(*els.Frame)
+	if !ok {
+		return
+	}
+	var pn, nn common.WWN
+	var id common.FCID
+	switch r := e.Payload.(type) {
+	case *els.FLOGI:
+		// A FLOGI logs out everything logged in on the F_Port before
+		for len(fp.devices) > 0 {
+			s.leave(q, fp.devices[0])
+		}
+		if s.domain == 0 {
+			s.reject(q, fp, f, els.ReasonLogicalBusy, els.ExplNone)
+			return
+		}
+		if fp.index > maxAddress {
+			s.reject(q, fp, f, els.ReasonUnableToPerform, els.ExplInsufficientResources)
+			return
+		}
+		pn, nn = r.PortName, r.NodeName
+		id = common.FCID{s.domain, uint8(fp.index), 0}
+		fp.next = 1
+	case *els.FDISC:
+		if len(fp.devices) == 0 {
+			s.reject(q, fp, f, els.ReasonLogicalError, els.ExplNone)
+			return
+		}
+		if fp.next > maxAddress {
+			s.reject(q, fp, f, els.ReasonUnableToPerform, els.ExplInsufficientResources)
+			return
+		}
+		pn, nn = r.PortName, r.NodeName
+		id = common.FCID{s.domain, uint8(fp.index), uint8(fp.next)}
+		fp.next++
+	case *els.LOGO:
+		if d, ok := s.devices[f.SourceID]; ok && d.fport == fp {
+			s.leave(q, d)
+		}
+		s.accept(q, fp, f, f.SourceID, nil)
+		return
+	default:
+		s.reject(q, fp, f, els.ReasonCommandNotSupported, els.ExplNone)
+		return
+	}
+	d := &device{fport: fp, id: id, portName: pn, nodeName: nn}
+	s.accept(q, fp, f, id, (*els.FLOGI)(s.params(fp)))
+	s.join(q, d)
+}
+
+// fabricController handles the requests to the Fabric Controller.
+func (s *Switch) fabricController(q *queue, fp *fport, f *fc.Frame) {
+	e, ok := f.Payload.(*els.Frame)
+	if !ok {
+		return
+	}
+	r, ok := e.Payload.(*els.SCR)
+	if !ok {
+		s.reject(q, fp, f, els.ReasonCommandNotSupported, els.ExplNone)
+		return
+	}
+	if d, ok := s.devices[f.SourceID]; !ok || d.fport != fp {
+		s.reject(q, fp, f, els.ReasonUnableToPerform, els.ExplNoLogin)
+		return
+	}
+	if r.Function == els.SCRClear {
+		delete(s.scr, f.SourceID)
+	} else {
+		s.scr[f.SourceID] = r.Function
+	}
+	s.accept(q, fp, f, f.SourceID, nil)
+}
+
+// directory handles the requests to the Directory Service.
+func (s *Switch) directory(q *queue, fp *fport, f *fc.Frame) {
+	d, ok := s.devices[f.SourceID]
+	if !ok || d.fport != fp {
+		return
+	}
+	switch r := f.Payload.(type) {
+	case *els.Frame:
+		switch r.Payload.(type) {
+		case *els.PLOGI:
+			s.accept(q, fp, f, f.SourceID, s.params(fp))
+		case *els.LOGO:
+			s.accept(q, fp, f, f.SourceID, nil)
+		default:
+			s.reject(q, fp, f, els.ReasonCommandNotSupported, els.ExplNone)
+		}
+	case *ct.Frame:
+		s.reply(q, fp, f, fc.RCtlSolicitedControl, s.nameServer(d, r))
+	}
+}
+
+// join logs a device in with the fabric.
+func (s *Switch) join(q *queue, d *device) {
+	d.fport.devices = append(d.fport.devices, d)
+	s.devices[d.id] = d
+	s.notify(q, d)
+}
+
+// leave logs a device out from the fabric and drops its registrations.
+func (s *Switch) leave(q *queue, d *device) {
+	l := d.fport.devices[:0]
+	for _, o := range d.fport.devices {
+		if o != d {
+			l = append(l, o)
+		}
+	}
+	d.fport.devices = l
+	delete(s.devices, d.id)
+	delete(s.scr, d.id)
+	s.notify(q, d)
+}
+
+// notify sends an RSCN for the device to the N_Ports registered for them
+// and zoned with it.
+func (s *Switch) notify(q *queue, d *device) {
+	for _, id := range s.sorted(nil) {
+		o := s.devices[id]
+		if _, ok := s.scr[id]; !ok || o == d || !s.visible(o, d) {
+			continue
+		}
+		rscn := &els.RSCN{Pages: els.RSCNPages{{Flags: els.RSCNAddressPort, Address: d.id}}}
+		s.oxid++
+		f := fc.NewFrame(common.FabricController, id, fc.RCtlELSRequest, &els.Frame{Payload: rscn})
+		f.OXID = s.oxid
+		f.FCtl.SetValue(fc.FCtlRequest)
+		q.add(o.fport.t, f)
+	}
+}
+
+// visible reports whether two devices may talk to each other.
+func (s *Switch) visible(a, b *device) bool {
+	if s.Zoning == nil || a == b {
+		return true
+	}
+	return s.Zoning.Permits(s.zoneDevice(a), s.zoneDevice(b))
+}
+
+// permits reports whether the zoning allows frames from the N_Port_ID a to
+// b. Frames passing through the switch are not zoned.
+func (s *Switch) permits(a, b common.FCID) bool {
+	if s.Zoning == nil {
+		return true
+	}
+	da, aok := s.devices[a]
+	db, bok := s.devices[b]
+	switch {
+	case aok && bok:
+		return s.visible(da, db)
+	case aok:
+		return s.Zoning.Permits(s.zoneDevice(da), remoteDevice(b))
+	case bok:
+		return s.Zoning.Permits(remoteDevice(a), s.zoneDevice(db))
+	}
+	return true
+}
+
+func (s *Switch) zoneDevice(d *device) zone.Device {
+	return zone.Device{
+		PortName: d.portName,
+		NodeName: d.nodeName,
+		PortID:   d.id,
+		Domain:   s.domain,
+		Port:     uint16(d.fport.index),
+	}
+}
+
+// remoteDevice returns what is known of an N_Port of another switch, whose
+// Area is the number of its F_Port.
+func remoteDevice(id common.FCID) zone.Device {
+	return zone.Device{PortID: id, Domain: id.Domain(), Port: uint16(id.Area())}
+}
+
+// sorted returns the N_Port_IDs logged in for which keep returns true, or
+// all with a nil keep, in order.
+func (s *Switch) sorted(keep func(*device) bool) []common.FCID {
+	var r []common.FCID
+	for id, d := range s.devices {
+		if keep == nil || keep(d) {
+			r = append(r, id)
+		}
+	}
+	sort.Slice(r, func(i, j int) bool { return bytes.Compare(r[i][:], r[j][:]) < 0 })
+	return r
+}
+
+// portName returns the Port_Name of a port, the name of the switch with
+// the number of the port in the 12 bits after the NAA.
+func (s *Switch) portName(fp *fport) common.WWN {
+	n := s.Name
+	n[0] = n[0]&0xf0 | uint8(fp.index>>8)&0x0f
+	n[1] = uint8(fp.index)
+	return n
+}
+
+// params returns the service parameters of an F_Port for the LS_ACC of
+// FLOGI, FDISC and PLOGI.
+func (s *Switch) params(fp *fport) *els.PLOGI {
+	o := els.DefaultParams(s.portName(fp), s.Name)
+	o.CommonSvcParams.B2BCredits = bbCredit
+	o.CommonSvcParams.NorFPort = true
+	return o
+}
+
+// reply answers the request f received on fp, ending the Exchange.
+func (s *Switch) reply(q *queue, fp *fport, f *fc.Frame, rctl uint8, payload interface{}) {
+	q.add(fp.t, f.Reply(rctl, payload))
+}
+
+// accept answers an ELS request with an LS_ACC sent to d, see els.Accept.
+// d is the source of the request, except for FLOGI and FDISC where it is
+// the N_Port_ID just assigned.
+func (s *Switch) accept(q *queue, fp *fport, f *fc.Frame, d common.FCID, o io.WriterTo) {
+	r := f.Reply(fc.RCtlELSReply, els.Accept(o))
+	r.DestinationID = d
+	q.add(fp.t, r)
+}
+
+func (s *Switch) reject(q *queue, fp *fport, f *fc.Frame, reason, explanation uint8) {
+	s.reply(q, fp, f, fc.RCtlELSReply, els.Reject(reason, explanation))
+}
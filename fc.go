@@ -8,30 +8,33 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/ct"
 	"github.com/bluecmd/fibrechannel/els"
 	"github.com/bluecmd/fibrechannel/encoding"
+	"github.com/bluecmd/fibrechannel/swils"
 )
 
 var _ = bytes.NewReader
 
 const (
-	EOFn   = 0x1 // TODO
-	EOFt   = 0x2 // TODO
-	EOFrt  = 0x3 // TODO
-	EOFdt  = 0x4 // TODO
-	EOFni  = 0x5 // TODO
-	EOFdti = 0x6 // TODO
-	EOFrti = 0x7 // TODO
-	EOFa   = 0x8 // TODO
+	EOFn   = 0x1 // EOF Normal
+	EOFt   = 0x2 // EOF Terminate
+	EOFrt  = 0x3 // EOF Remove Terminate (Class 4)
+	EOFdt  = 0x4 // EOF Disconnect-Terminate
+	EOFni  = 0x5 // EOF Normal-Invalid
+	EOFdti = 0x6 // EOF Disconnect-Terminate-Invalid
+	EOFrti = 0x7 // EOF Remove Terminate-Invalid (Class 4)
+	EOFa   = 0x8 // EOF Abort
 
-	SOFf  = 0x1 // TODO
-	SOFi4 = 0x2 // TODO
-	SOFi2 = 0x3 // TODO
-	SOFi3 = 0x4 // TODO
-	SOFn4 = 0x5 // TODO
-	SOFn2 = 0x6 // TODO
-	SOFn3 = 0x7 // TODO
-	SOFc4 = 0x8 // TODO
+	SOFf  = 0x1 // SOF Fabric (Class F)
+	SOFi4 = 0x2 // SOF Initiate Class 4
+	SOFi2 = 0x3 // SOF Initiate Class 2
+	SOFi3 = 0x4 // SOF Initiate Class 3
+	SOFn4 = 0x5 // SOF Normal Class 4
+	SOFn2 = 0x6 // SOF Normal Class 2
+	SOFn3 = 0x7 // SOF Normal Class 3
+	SOFc4 = 0x8 // SOF Activate Class 4
 
 	TypeBLS      = 0x0  // TODO
 	TypeELS      = 0x1  // TODO
@@ -39,8 +42,8 @@ const (
 	TypeIP       = 0x5  // TODO
 	TypeFCP      = 0x8  // TODO
 	TypeGPP      = 0x9  // TODO
-	TypeSBToCU   = 0x1b // FICON / FC-SB-3: Control Unit -> Channel
-	TypeSBFromCU = 0x1c // FICON / FC-SB-3: Channel -> Control Unit
+	TypeSBToCU   = 0x1b // FICON / FC-SB-3: Channel -> Control Unit
+	TypeSBFromCU = 0x1c // FICON / FC-SB-3: Control Unit -> Channel
 	TypeFCCT     = 0x20 // TODO
 	TypeSWILS    = 0x22 // TODO
 	TypeAL       = 0x23 // TODO
@@ -57,10 +60,13 @@ type CSCtl struct {
 type EOF uint8
 
 type Frame struct {
+	Encapsulation *EncapsulationHeader
+	IFR           *IFRHeader
+	VFT           *VFTHeader
 	RCtl          uint8
-	DestinationID [3]byte
+	DestinationID common.FCID
 	CsctlPriority interface{}
-	SourceID      [3]byte
+	SourceID      common.FCID
 	fcType        Type
 	FCtl          FrameControl
 	SeqID         uint8
@@ -70,6 +76,11 @@ type Frame struct {
 	RXID          uint16
 	Parameters    [4]byte
 	Payload       interface{}
+	// CRCTrailer makes ReadFrom expect, and WriteTo append, the CRC after
+	// the data field, like in frames captured by analyzers. CRC holds the
+	// CRC read or written.
+	CRCTrailer bool
+	CRC        uint32
 }
 
 type FrameControl struct {
@@ -113,27 +124,27 @@ func (o *CSCtl) WriteTo(w io.Writer) (int64, error) {
 func (o *EOF) String() string {
 	switch *o {
 	case 0x1:
-		return "EOFn <0x1> (TODO)"
+		return "EOFn <0x1> (EOF Normal)"
 	case 0x2:
-		return "EOFt <0x2> (TODO)"
+		return "EOFt <0x2> (EOF Terminate)"
 	case 0x3:
-		return "EOFrt <0x3> (TODO)"
+		return "EOFrt <0x3> (EOF Remove Terminate (Class 4))"
 	case 0x4:
-		return "EOFdt <0x4> (TODO)"
+		return "EOFdt <0x4> (EOF Disconnect-Terminate)"
 	case 0x5:
-		return "EOFni <0x5> (TODO)"
+		return "EOFni <0x5> (EOF Normal-Invalid)"
 	case 0x6:
-		return "EOFdti <0x6> (TODO)"
+		return "EOFdti <0x6> (EOF Disconnect-Terminate-Invalid)"
 	case 0x7:
-		return "EOFrti <0x7> (TODO)"
+		return "EOFrti <0x7> (EOF Remove Terminate-Invalid (Class 4))"
 	case 0x8:
-		return "EOFa <0x8> (TODO)"
+		return "EOFa <0x8> (EOF Abort)"
 	default:
 		return fmt.Sprintf("--Invalid Enum Value-- <0x%x>", *o)
 	}
 }
 
-func (o *Frame) ReadFrom(r io.Reader) (int64, error) {
+func (o *Frame) readFrom(r io.Reader) (int64, error) {
 	_io := encoding.Reader{R: r}
 	fixup := []func() (int64, error){}
 	_io.ReadObject(&o.RCtl)
@@ -151,14 +162,18 @@ func (o *Frame) ReadFrom(r io.Reader) (int64, error) {
 		switch o.FCtl.PriorityEnable {
 		case false:
 			i := &CSCtl{}
-			if n, err := i.ReadFrom(&_io); err != nil {
-				return n, err
+			n, err := i.ReadFrom(_io.R)
+			_io.Pos += n
+			if err != nil {
+				return _io.Pos, err
 			}
 			o.CsctlPriority = i
 		case true:
 			i := &Prio{}
-			if n, err := i.ReadFrom(&_io); err != nil {
-				return n, err
+			n, err := i.ReadFrom(_io.R)
+			_io.Pos += n
+			if err != nil {
+				return _io.Pos, err
 			}
 			o.CsctlPriority = i
 		}
@@ -216,8 +231,33 @@ func (o *Frame) ReadFrom(r io.Reader) (int64, error) {
 	switch o.fcType {
 	case TypeELS:
 		i := &els.Frame{}
-		if n, err := i.ReadFrom(&_io); err != nil {
-			return n, err
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	case TypeFCCT:
+		i := &ct.Frame{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	case TypeSWILS:
+		i := &swils.Frame{}
+		n, err := i.ReadFrom(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
+		}
+		o.Payload = i
+	default:
+		i, n, err := o.readPayload(_io.R)
+		_io.Pos += n
+		if err != nil {
+			return _io.Pos, err
 		}
 		o.Payload = i
 	}
@@ -233,18 +273,24 @@ func (o *Frame) ReadFrom(r io.Reader) (int64, error) {
 	return _io.Pos, nil
 }
 
-func (o *Frame) WriteTo(w io.Writer) (int64, error) {
+func (o *Frame) writeTo(w io.Writer) (int64, error) {
 	_io := encoding.Writer{W: w}
 	switch o.CsctlPriority.(type) {
-	case CSCtl:
+	case CSCtl, *CSCtl:
 		o.FCtl.PriorityEnable = false
-	case Prio:
+	case Prio, *Prio:
 		o.FCtl.PriorityEnable = true
 	}
 
 	switch o.Payload.(type) {
-	case els.Frame:
+	case els.Frame, *els.Frame:
 		o.fcType = TypeELS
+	case ct.Frame, *ct.Frame:
+		o.fcType = TypeFCCT
+	case swils.Frame, *swils.Frame:
+		o.fcType = TypeSWILS
+	default:
+		o.switchPayload()
 	}
 
 	_io.WriteObject(o.RCtl)
@@ -256,11 +302,11 @@ func (o *Frame) WriteTo(w io.Writer) (int64, error) {
 		return _io.Pos, _io.Error
 	}
 	switch i := o.CsctlPriority.(type) {
-	case *Prio:
+	case *CSCtl:
 		if n, err := i.WriteTo(&_io); err != nil {
 			return n, err
 		}
-	case *CSCtl:
+	case *Prio:
 		if n, err := i.WriteTo(&_io); err != nil {
 			return n, err
 		}
@@ -324,8 +370,18 @@ func (o *Frame) WriteTo(w io.Writer) (int64, error) {
 		if n, err := i.WriteTo(&_io); err != nil {
 			return n, err
 		}
+	case *ct.Frame:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
+	case *swils.Frame:
+		if n, err := i.WriteTo(&_io); err != nil {
+			return n, err
+		}
 	default:
-		return _io.Pos, fmt.Errorf("Unsupported type %v", i)
+		if err := o.writePayload(&_io); err != nil {
+			return _io.Pos, err
+		}
 	}
 
 	if _io.Error != nil {
@@ -361,21 +417,21 @@ func (o *Prio) WriteTo(w io.Writer) (int64, error) {
 func (o *SOF) String() string {
 	switch *o {
 	case 0x1:
-		return "SOFf <0x1> (TODO)"
+		return "SOFf <0x1> (SOF Fabric (Class F))"
 	case 0x2:
-		return "SOFi4 <0x2> (TODO)"
+		return "SOFi4 <0x2> (SOF Initiate Class 4)"
 	case 0x3:
-		return "SOFi2 <0x3> (TODO)"
+		return "SOFi2 <0x3> (SOF Initiate Class 2)"
 	case 0x4:
-		return "SOFi3 <0x4> (TODO)"
+		return "SOFi3 <0x4> (SOF Initiate Class 3)"
 	case 0x5:
-		return "SOFn4 <0x5> (TODO)"
+		return "SOFn4 <0x5> (SOF Normal Class 4)"
 	case 0x6:
-		return "SOFn2 <0x6> (TODO)"
+		return "SOFn2 <0x6> (SOF Normal Class 2)"
 	case 0x7:
-		return "SOFn3 <0x7> (TODO)"
+		return "SOFn3 <0x7> (SOF Normal Class 3)"
 	case 0x8:
-		return "SOFc4 <0x8> (TODO)"
+		return "SOFc4 <0x8> (SOF Activate Class 4)"
 	default:
 		return fmt.Sprintf("--Invalid Enum Value-- <0x%x>", *o)
 	}
@@ -396,9 +452,9 @@ func (o *Type) String() string {
 	case 0x9:
 		return "TypeGPP <0x9> (TODO)"
 	case 0x1b:
-		return "TypeSBToCU <0x1b> (FICON / FC-SB-3: Control Unit -> Channel)"
+		return "TypeSBToCU <0x1b> (FICON / FC-SB-3: Channel -> Control Unit)"
 	case 0x1c:
-		return "TypeSBFromCU <0x1c> (FICON / FC-SB-3: Channel -> Control Unit)"
+		return "TypeSBFromCU <0x1c> (FICON / FC-SB-3: Control Unit -> Channel)"
 	case 0x20:
 		return "TypeFCCT <0x20> (TODO)"
 	case 0x22:
@@ -2,11 +2,15 @@ package fibrechannel
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"reflect"
 	"testing"
 
 	"github.com/bluecmd/fibrechannel/common"
 	"github.com/bluecmd/fibrechannel/els"
+	"github.com/bluecmd/fibrechannel/fcsb"
+	"github.com/bluecmd/fibrechannel/nvme"
 )
 
 func TestNilBuffer(t *testing.T) {
@@ -53,3 +57,137 @@ func BenchmarkMarshal(b *testing.B) {
 func TestFrameFiles(t *testing.T) {
 	common.TestFrameFiles(t, func() common.SerDes { return &Frame{} })
 }
+
+// scr is an SCR frame and its CRC as captured on an FCoE link
+var scr = []byte{
+	0x22, 0xff, 0xff, 0xfd, 0x00, 0xed, 0x01, 0x00, 0x01, 0x29, 0x00, 0x00,
+	0xf0, 0x00, 0x00, 0x00, 0x03, 0xf8, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00,
+	0x62, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03,
+	0x1b, 0x72, 0x6f, 0x69,
+}
+
+func TestCRC(t *testing.T) {
+	if got, want := CRC(scr[:len(scr)-CRCLength]), uint32(0x1b726f69); got != want {
+		t.Fatalf("got CRC 0x%08x, wanted 0x%08x", got, want)
+	}
+	if err := VerifyCRC(scr); err != nil {
+		t.Fatalf("VerifyCRC: %v", err)
+	}
+	b := append([]byte{}, scr...)
+	b[len(b)-1] ^= 0xff
+	want := &CRCError{Got: 0x1b726f96, Want: 0x1b726f69}
+	if err := VerifyCRC(b); !reflect.DeepEqual(err, want) {
+		t.Fatalf("got unexpected error %v, wanted %v", err, want)
+	}
+}
+
+func TestCRCTrailer(t *testing.T) {
+	b := append([]byte{}, scr...)
+	f := &Frame{CRCTrailer: true}
+	n, err := f.ReadFrom(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+	if n != int64(len(b)) {
+		t.Errorf("read %d bytes, wanted %d", n, len(b))
+	}
+	if f.CRC != 0x1b726f69 {
+		t.Errorf("got CRC 0x%08x, wanted 0x1b726f69", f.CRC)
+	}
+	buf := new(bytes.Buffer)
+	if _, err := f.WriteTo(buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Fatalf("re-serialized to %v, wanted %v", buf.Bytes(), b)
+	}
+
+	b[len(b)-1] ^= 0xff
+	f = &Frame{CRCTrailer: true}
+	if _, err := f.ReadFrom(bytes.NewReader(b)); !errors.As(err, new(*CRCError)) {
+		t.Fatalf("got unexpected error %v, wanted a CRC error", err)
+	}
+	if f.Payload == nil {
+		t.Errorf("frame with bad CRC was not decoded")
+	}
+	if _, err := (&Frame{CRCTrailer: true}).ReadFrom(bytes.NewReader(b[:2])); err != io.ErrUnexpectedEOF {
+		t.Fatalf("got unexpected error %v, wanted io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestDelimiters(t *testing.T) {
+	if SOF(SOFi3).Class() != 3 || !SOF(SOFi3).Initiate() || SOF(SOFn3).Initiate() {
+		t.Errorf("SOFi3 and SOFn3 misclassified")
+	}
+	if SOF(SOFf).Class() != 0 || SOF(SOFc4).Class() != 4 {
+		t.Errorf("SOFf and SOFc4 misclassified")
+	}
+	if !EOF(EOFt).Terminate() || EOF(EOFt).Invalid() || EOF(EOFn).Terminate() {
+		t.Errorf("EOFt and EOFn misclassified")
+	}
+	if !EOF(EOFa).Invalid() || !EOF(EOFni).Invalid() || EOF(EOFni).Terminate() {
+		t.Errorf("EOFa and EOFni misclassified")
+	}
+	if s, ok := SOFFromCode(SOF(SOFi3).Code()); !ok || s != SOFi3 || SOF(SOFi3).Code() != 0x2E {
+		t.Errorf("SOFi3 encoded as 0x%02x", SOF(SOFi3).Code())
+	}
+	if e, ok := EOFFromCode(EOF(EOFt).Code()); !ok || e != EOFt || EOF(EOFt).Code() != 0x42 {
+		t.Errorf("EOFt encoded as 0x%02x", EOF(EOFt).Code())
+	}
+	if _, ok := SOFFromCode(0); ok {
+		t.Errorf("decoded an invalid SOF code")
+	}
+}
+
+func TestFrameControl(t *testing.T) {
+	c := FrameControl{TODO1: 10, TODO2: 0x10000}
+	want := uint32(FCtlFirstSequence | FCtlEndSequence | FCtlSequenceInitiative)
+	if c.Value() != want {
+		t.Fatalf("got F_CTL 0x%06x, wanted 0x%06x", c.Value(), want)
+	}
+	var d FrameControl
+	d.SetValue(want | FCtlPriorityEnable | 3)
+	if !d.PriorityEnable || d.FillBytes() != 3 || !d.Has(FCtlFirstSequence|FCtlEndSequence) || d.Has(FCtlLastSequence) {
+		t.Fatalf("unexpected FrameControl %+v", d)
+	}
+}
+
+func TestPayloadType(t *testing.T) {
+	var tests = []struct {
+		rctl    uint8
+		payload interface{}
+		want    Type
+	}{
+		{0, &fcsb.IU{IUI: fcsb.IUTypeCommandHeader, DIB: &fcsb.CommandDIB{}}, TypeSBToCU},
+		{0, &fcsb.IU{FromControlUnit: true, IUI: fcsb.IUTypeStatus, DIB: &fcsb.StatusDIB{}}, TypeSBFromCU},
+		{fcsb.RCtlTransportCommand, &fcsb.TransportCommandIU{}, TypeSBToCU},
+		{fcsb.RCtlTransportData, &fcsb.TransportDataIU{Data: []byte{1, 2, 3, 4}}, TypeSBToCU},
+		{fcsb.RCtlTransportData, &fcsb.TransportDataIU{FromControlUnit: true, Data: []byte{1, 2, 3, 4}}, TypeSBFromCU},
+		{fcsb.RCtlTransportResponse, &fcsb.TransportResponseIU{}, TypeSBFromCU},
+		{nvme.RCtlCommand, &nvme.CmndIU{SCSIID: nvme.SCSIID, FCID: nvme.FCID}, TypeNVME},
+		{nvme.RCtlSolicitedData, &nvme.Data{1, 2, 3, 4}, TypeNVME},
+	}
+	for _, tt := range tests {
+		f := &Frame{RCtl: tt.rctl, CsctlPriority: &CSCtl{}, Payload: tt.payload}
+		buf := new(bytes.Buffer)
+		if _, err := f.WriteTo(buf); err != nil {
+			t.Fatalf("WriteTo: %v", err)
+		}
+		if got := Type(buf.Bytes()[8]); got != tt.want {
+			t.Errorf("got TYPE 0x%02x for %T, wanted 0x%02x", got, tt.payload, tt.want)
+		}
+		// The direction is kept when the frame is read back
+		b := buf.Bytes()
+		r := &Frame{}
+		if _, err := r.ReadFrom(bytes.NewReader(b)); err != nil {
+			t.Fatalf("ReadFrom: %v", err)
+		}
+		buf = new(bytes.Buffer)
+		if _, err := r.WriteTo(buf); err != nil {
+			t.Fatalf("WriteTo: %v", err)
+		}
+		if !bytes.Equal(buf.Bytes(), b) {
+			t.Errorf("re-serialized %T to %v, wanted %v", tt.payload, buf.Bytes(), b)
+		}
+	}
+}
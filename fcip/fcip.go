@@ -0,0 +1,321 @@
+// Package fcip implements Fibre Channel over TCP/IP (RFC 3821), which
+// carries FC frames in a TCP stream using the FC Frame Encapsulation of
+// RFC 3643.
+package fcip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+const (
+	Protocol = 1 // FC Frame Encapsulation protocol number of FCIP
+	Version  = 1
+
+	PFlagChanged      = 0x80 // Ch: Special Frame changed by the receiver
+	PFlagSpecialFrame = 0x01 // SF: FCIP Special Frame
+
+	FlagCRCValid = 0x01 // CRCV: the header CRC is valid, never set by FCIP
+
+	HeaderLength       = 28
+	SpecialFrameLength = 76
+
+	// MaxFrameLength is the largest encapsulated frame in bytes, the frame
+	// length is a 10 bit count of words
+	MaxFrameLength = 1023 * 4
+)
+
+const (
+	// sofLength and eofLength are the SOF and EOF words around the FC frame
+	sofLength = 4
+	eofLength = 4
+	crcLength = 4
+
+	// ntpEpoch is the start of the NTP era used by the timestamp
+	ntpEpoch = -2208988800
+)
+
+var (
+	errProtocol   = errors.New("not an FCIP encapsulation header")
+	errComplement = errors.New("ones complement mismatch in encapsulation header")
+	errDelimiter  = errors.New("invalid SOF or EOF word")
+	errLength     = errors.New("invalid encapsulated frame length")
+	errShortFrame = errors.New("payload shorter than an FC frame header")
+)
+
+// Header is the FC Frame Encapsulation header as used by FCIP. The ones
+// complement fields and the replicated protocol and version word are checked
+// when decoding and generated when encoding. FrameLength is the length of
+// the whole encapsulated frame in words, it is computed when a Frame or
+// SpecialFrame is marshalled. Protocol and Version are written as the FCIP
+// ones if zero.
+type Header struct {
+	Protocol          uint8
+	Version           uint8
+	PFlags            uint8
+	Flags             uint8
+	FrameLength       uint16
+	TimestampSeconds  uint32
+	TimestampFraction uint32
+	CRC               uint32
+}
+
+// Frame is an FC frame encapsulated by FCIP. Payload holds the FC frame
+// header and data field, but not the FC CRC which is kept in CRC32.
+//
+// MarshalBinary writes the CRC computed from Payload, or CRC32 if KeepCRC is
+// set.
+type Frame struct {
+	Header Header
+	SOF    fc.SOF
+
+	Payload []byte
+
+	EOF fc.EOF
+
+	CRC32   uint32
+	KeepCRC bool
+}
+
+// SpecialFrame is the FCIP Special Frame (FSF), sent as the first bytes of a
+// new TCP connection to identify the FCIP entity and echoed by the other
+// side. KATOV is the keep alive timeout in milliseconds.
+type SpecialFrame struct {
+	Header                Header
+	SourceFabricName      common.WWN
+	SourceEntityID        [8]byte
+	ConnectionNonce       [8]byte
+	ConnectionUsageFlags  uint8
+	ConnectionUsageCode   uint16
+	DestinationFabricName common.WWN
+	KATOV                 uint32
+}
+
+// CRCError is returned when the FC CRC of a frame does not match its
+// contents.
+type CRCError = fc.CRCError
+
+// Time returns the timestamp of the header, which is in NTP format.
+func (h *Header) Time() time.Time {
+	ns := (int64(h.TimestampFraction) * int64(time.Second)) >> 32
+	return time.Unix(int64(h.TimestampSeconds)+ntpEpoch, ns)
+}
+
+// SetTime sets the timestamp of the header to t.
+func (h *Header) SetTime(t time.Time) {
+	h.TimestampSeconds = uint32(t.Unix() - ntpEpoch)
+	h.TimestampFraction = uint32((int64(t.Nanosecond()) << 32) / int64(time.Second))
+}
+
+func (h *Header) UnmarshalBinary(b []byte) error {
+	if len(b) < HeaderLength {
+		return io.ErrUnexpectedEOF
+	}
+	if b[0] != Protocol {
+		return errProtocol
+	}
+	if b[0] != ^b[2] || b[1] != ^b[3] || b[8] != ^b[10] || b[9] != ^b[11] {
+		return errComplement
+	}
+	if !bytes.Equal(b[0:4], b[4:8]) {
+		return errComplement
+	}
+	w := binary.BigEndian.Uint16(b[12:])
+	if w != ^binary.BigEndian.Uint16(b[14:]) {
+		return errComplement
+	}
+	h.Protocol = b[0]
+	h.Version = b[1]
+	h.PFlags = b[8]
+	h.Flags = uint8(w >> 10)
+	h.FrameLength = w & 0x3ff
+	h.TimestampSeconds = binary.BigEndian.Uint32(b[16:])
+	h.TimestampFraction = binary.BigEndian.Uint32(b[20:])
+	h.CRC = binary.BigEndian.Uint32(b[24:])
+	return nil
+}
+
+func (h *Header) MarshalBinary() ([]byte, error) {
+	proto, ver := h.Protocol, h.Version
+	if proto == 0 {
+		proto = Protocol
+	}
+	if ver == 0 {
+		ver = Version
+	}
+	b := make([]byte, HeaderLength)
+	b[0] = proto
+	b[1] = ver
+	b[2] = ^proto
+	b[3] = ^ver
+	copy(b[4:], b[0:4])
+	b[8] = h.PFlags
+	b[10] = ^h.PFlags
+	b[11] = 0xff
+	w := uint16(h.Flags)<<10 | h.FrameLength&0x3ff
+	binary.BigEndian.PutUint16(b[12:], w)
+	binary.BigEndian.PutUint16(b[14:], ^w)
+	binary.BigEndian.PutUint32(b[16:], h.TimestampSeconds)
+	binary.BigEndian.PutUint32(b[20:], h.TimestampFraction)
+	binary.BigEndian.PutUint32(b[24:], h.CRC)
+	return b, nil
+}
+
+// UnmarshalBinary decodes an encapsulated frame. Any bytes after the frame
+// length given in the header are ignored. The FC CRC is not checked, use
+// VerifyCRC or Decode for that.
+func (f *Frame) UnmarshalBinary(b []byte) error {
+	if err := f.Header.UnmarshalBinary(b); err != nil {
+		return err
+	}
+	l := 4 * int(f.Header.FrameLength)
+	if l < HeaderLength+sofLength+crcLength+eofLength {
+		return errLength
+	}
+	if len(b) < l {
+		return io.ErrUnexpectedEOF
+	}
+	b = b[:l]
+	var ok bool
+	sof := b[HeaderLength:]
+	if sof[0] != sof[1] || sof[0] != ^sof[2] || sof[0] != ^sof[3] {
+		return errDelimiter
+	}
+	if f.SOF, ok = fc.SOFFromCode(sof[0]); !ok {
+		return errDelimiter
+	}
+	eof := b[l-eofLength:]
+	if eof[0] != eof[1] || eof[0] != ^eof[2] || eof[0] != ^eof[3] {
+		return errDelimiter
+	}
+	if f.EOF, ok = fc.EOFFromCode(eof[0]); !ok {
+		return errDelimiter
+	}
+	f.Payload = b[HeaderLength+sofLength : l-eofLength-crcLength]
+	f.CRC32 = binary.BigEndian.Uint32(b[l-eofLength-crcLength:])
+	return nil
+}
+
+// MarshalBinary encodes the encapsulated frame, the frame length in the
+// header is always computed. Payload must be word aligned.
+func (f *Frame) MarshalBinary() ([]byte, error) {
+	if len(f.Payload)%4 != 0 {
+		return nil, errLength
+	}
+	l := HeaderLength + sofLength + len(f.Payload) + crcLength + eofLength
+	if l > MaxFrameLength {
+		return nil, errLength
+	}
+	crc := f.CRC32
+	if !f.KeepCRC {
+		crc = f.Checksum()
+	}
+	hdr := f.Header
+	hdr.FrameLength = uint16(l / 4)
+	h, err := hdr.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 0, l)
+	b = append(b, h...)
+	b = append(b, delimiter(f.SOF.Code())...)
+	b = append(b, f.Payload...)
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-crcLength:], crc)
+	b = append(b, delimiter(f.EOF.Code())...)
+	return b, nil
+}
+
+// Checksum returns the FC CRC of the Payload.
+func (f *Frame) Checksum() uint32 {
+	return fc.CRC(f.Payload)
+}
+
+// VerifyCRC returns a *CRCError if CRC32 does not match the Payload.
+func (f *Frame) VerifyCRC() error {
+	if c := f.Checksum(); c != f.CRC32 {
+		return &CRCError{Got: f.CRC32, Want: c}
+	}
+	return nil
+}
+
+// Decode verifies the FC CRC and decodes the encapsulated FC frame.
+func (f *Frame) Decode() (*fc.Frame, error) {
+	if err := f.VerifyCRC(); err != nil {
+		return nil, err
+	}
+	ff := &fc.Frame{}
+	if _, err := ff.ReadFrom(bytes.NewReader(f.Payload)); err != nil {
+		return nil, err
+	}
+	return ff, nil
+}
+
+// Encode sets Payload to the serialized FC frame ff.
+func (f *Frame) Encode(ff *fc.Frame) error {
+	buf := new(bytes.Buffer)
+	if _, err := ff.WriteTo(buf); err != nil {
+		return err
+	}
+	if buf.Len() < fc.FrameHeaderLength {
+		return errShortFrame
+	}
+	f.Payload = buf.Bytes()
+	return nil
+}
+
+func (f *SpecialFrame) UnmarshalBinary(b []byte) error {
+	if err := f.Header.UnmarshalBinary(b); err != nil {
+		return err
+	}
+	if f.Header.PFlags&PFlagSpecialFrame == 0 || f.Header.FrameLength != SpecialFrameLength/4 {
+		return errLength
+	}
+	if len(b) < SpecialFrameLength {
+		return io.ErrUnexpectedEOF
+	}
+	b = b[HeaderLength:]
+	if binary.BigEndian.Uint16(b[44:]) != ^binary.BigEndian.Uint16(b[46:]) {
+		return errComplement
+	}
+	copy(f.SourceFabricName[:], b[0:])
+	copy(f.SourceEntityID[:], b[8:])
+	copy(f.ConnectionNonce[:], b[16:])
+	f.ConnectionUsageFlags = b[24]
+	f.ConnectionUsageCode = binary.BigEndian.Uint16(b[26:])
+	copy(f.DestinationFabricName[:], b[28:])
+	f.KATOV = binary.BigEndian.Uint32(b[36:])
+	return nil
+}
+
+func (f *SpecialFrame) MarshalBinary() ([]byte, error) {
+	hdr := f.Header
+	hdr.PFlags |= PFlagSpecialFrame
+	hdr.FrameLength = SpecialFrameLength / 4
+	h, err := hdr.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, SpecialFrameLength)
+	copy(b, h)
+	v := b[HeaderLength:]
+	copy(v[0:], f.SourceFabricName[:])
+	copy(v[8:], f.SourceEntityID[:])
+	copy(v[16:], f.ConnectionNonce[:])
+	v[24] = f.ConnectionUsageFlags
+	binary.BigEndian.PutUint16(v[26:], f.ConnectionUsageCode)
+	copy(v[28:], f.DestinationFabricName[:])
+	binary.BigEndian.PutUint32(v[36:], f.KATOV)
+	binary.BigEndian.PutUint16(v[46:], 0xffff)
+	return b, nil
+}
+
+func delimiter(c uint8) []byte {
+	return []byte{c, c, ^c, ^c}
+}
@@ -0,0 +1,255 @@
+package fcip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/els"
+)
+
+var logo = &fc.Frame{
+	RCtl:          0x22,
+	DestinationID: common.FPortController,
+	SourceID:      common.FCID{0x01, 0x02, 0x03},
+	CsctlPriority: &fc.CSCtl{},
+	FCtl:          fc.FrameControl{TODO1: 10, TODO2: 0x10000},
+	OXID:          0x1234,
+	RXID:          0xffff,
+	Payload: &els.Frame{Payload: &els.LOGO{
+		PortID:   common.FCID{0x01, 0x02, 0x03},
+		PortName: common.WWN{0x10, 0x00, 0x00, 0x00, 0xc9, 0x12, 0x34, 0x56},
+	}},
+}
+
+func TestHeader(t *testing.T) {
+	var tests = []struct {
+		desc string
+		b    []byte
+		h    *Header
+		err  error
+	}{
+		{
+			desc: "short buffer",
+			b:    make([]byte, HeaderLength-1),
+			err:  io.ErrUnexpectedEOF,
+		},
+		{
+			desc: "header",
+			b: []byte{
+				0x01, 0x01, 0xfe, 0xfe,
+				0x01, 0x01, 0xfe, 0xfe,
+				0x00, 0x00, 0xff, 0xff,
+				0x00, 0x13, 0xff, 0xec,
+				0xe9, 0x1b, 0x2c, 0x00,
+				0x80, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00,
+			},
+			h: &Header{
+				Protocol:          Protocol,
+				Version:           Version,
+				FrameLength:       19,
+				TimestampSeconds:  0xe91b2c00,
+				TimestampFraction: 0x80000000,
+			},
+		},
+		{
+			desc: "wrong protocol",
+			b: []byte{
+				0x02, 0x01, 0xfd, 0xfe,
+				0x02, 0x01, 0xfd, 0xfe,
+				0x00, 0x00, 0xff, 0xff,
+				0x00, 0x13, 0xff, 0xec,
+				0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			},
+			err: errProtocol,
+		},
+		{
+			desc: "bad complement",
+			b: []byte{
+				0x01, 0x01, 0xfe, 0xfe,
+				0x01, 0x01, 0xfe, 0xfe,
+				0x00, 0x00, 0xff, 0xff,
+				0x00, 0x13, 0xff, 0xed,
+				0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			},
+			err: errComplement,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			h := &Header{}
+			err := h.UnmarshalBinary(tt.b)
+			if err != tt.err {
+				t.Fatalf("unexpected error: %v != %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(h, tt.h) {
+				t.Fatalf("unexpected Header:\n- want: %+v\n-  got: %+v", tt.h, h)
+			}
+			b, err := h.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary: %v", err)
+			}
+			if !bytes.Equal(b, tt.b) {
+				t.Fatalf("re-serialized to %v, wanted %v", b, tt.b)
+			}
+		})
+	}
+}
+
+func TestTime(t *testing.T) {
+	want := time.Date(2023, 11, 14, 22, 13, 20, 500000000, time.UTC)
+	h := &Header{}
+	h.SetTime(want)
+	if h.TimestampFraction != 0x80000000 {
+		t.Errorf("got fraction 0x%x, wanted 0x80000000", h.TimestampFraction)
+	}
+	if got := h.Time(); !got.Equal(want) {
+		t.Fatalf("got %v, wanted %v", got, want)
+	}
+}
+
+func TestFrame(t *testing.T) {
+	f := &Frame{SOF: fc.SOFi3, EOF: fc.EOFt}
+	if err := f.Encode(logo); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	b, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	l := int(binary.BigEndian.Uint16(b[12:]) & 0x3ff)
+	if want := HeaderLength + 4 + len(f.Payload) + 8; len(b) != want || l*4 != want {
+		t.Fatalf("got %d bytes and frame length %d words, wanted %d bytes", len(b), l, want)
+	}
+	if f.Header.FrameLength != 0 || f.CRC32 != 0 {
+		t.Errorf("MarshalBinary changed the Frame %+v", f)
+	}
+	if !bytes.Equal(b[HeaderLength:HeaderLength+4], []byte{0x2e, 0x2e, 0xd1, 0xd1}) {
+		t.Errorf("unexpected SOF word %v", b[HeaderLength:HeaderLength+4])
+	}
+	if !bytes.Equal(b[len(b)-4:], []byte{0x42, 0x42, 0xbd, 0xbd}) {
+		t.Errorf("unexpected EOF word %v", b[len(b)-4:])
+	}
+
+	g := &Frame{}
+	if err := g.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	got, err := g.Decode()
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !reflect.DeepEqual(got, logo) {
+		t.Fatalf("unexpected Frame:\n- want: %+v\n-  got: %+v", logo, got)
+	}
+
+	g.CRC32 ^= 1
+	if _, err := g.Decode(); !errors.As(err, new(*CRCError)) {
+		t.Fatalf("Decode did not verify the CRC: %v", err)
+	}
+	b[HeaderLength+2] = 0
+	if err := g.UnmarshalBinary(b); err != errDelimiter {
+		t.Fatalf("unexpected error: %v != %v", errDelimiter, err)
+	}
+}
+
+func TestSpecialFrame(t *testing.T) {
+	s := &SpecialFrame{
+		SourceFabricName:      common.WWN{0x10, 0x00, 0x00, 0x05, 0x1e, 0x01, 0x02, 0x03},
+		SourceEntityID:        [8]byte{0, 0, 0, 0, 0, 0, 0, 1},
+		ConnectionNonce:       [8]byte{1, 2, 3, 4, 5, 6, 7, 8},
+		ConnectionUsageFlags:  0x80,
+		DestinationFabricName: common.WWN{0x10, 0x00, 0x00, 0x05, 0x1e, 0x04, 0x05, 0x06},
+		KATOV:                 10000,
+	}
+	b, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	if len(b) != SpecialFrameLength {
+		t.Fatalf("got %d bytes, wanted %d", len(b), SpecialFrameLength)
+	}
+	got := &SpecialFrame{}
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	want := *s
+	want.Header = Header{Protocol: Protocol, Version: Version, PFlags: PFlagSpecialFrame, FrameLength: SpecialFrameLength / 4}
+	if !reflect.DeepEqual(got, &want) {
+		t.Fatalf("unexpected SpecialFrame:\n- want: %+v\n-  got: %+v", &want, got)
+	}
+}
+
+func TestReader(t *testing.T) {
+	s := &SpecialFrame{KATOV: 10000}
+	sb, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	f := &Frame{SOF: fc.SOFi3, EOF: fc.EOFt}
+	if err := f.Encode(logo); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	fb, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	// Start in the middle of a frame, followed by the special frame and two
+	// frames split across reads
+	stream := append([]byte{}, fb[10:]...)
+	stream = append(stream, sb...)
+	stream = append(stream, fb...)
+	stream = append(stream, fb...)
+	r := NewReader(io.MultiReader(
+		bytes.NewReader(stream[:50]),
+		bytes.NewReader(stream[50:120]),
+		bytes.NewReader(stream[120:])))
+
+	for i := 0; i < 2; i++ {
+		got, sof, eof, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame %d: %v", i, err)
+		}
+		if sof != fc.SOFi3 || eof != fc.EOFt {
+			t.Errorf("got SOF %v and EOF %v, wanted SOFi3 and EOFt", sof, eof)
+		}
+		if !reflect.DeepEqual(got, logo) {
+			t.Fatalf("unexpected Frame:\n- want: %+v\n-  got: %+v", logo, got)
+		}
+	}
+	if _, _, _, err := r.ReadFrame(); err != io.EOF {
+		t.Fatalf("unexpected error: %v != %v", io.EOF, err)
+	}
+	if want := int64(len(fb) - 10); r.Skipped != want {
+		t.Errorf("skipped %d bytes, wanted %d", r.Skipped, want)
+	}
+	if r.Special == nil || r.Special.KATOV != 10000 {
+		t.Errorf("unexpected special frame %+v", r.Special)
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	f := &Frame{SOF: fc.SOFi3, EOF: fc.EOFt}
+	if err := f.Encode(logo); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	b, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	r := NewReader(bytes.NewReader(b[:len(b)-1]))
+	if _, err := r.Next(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("unexpected error: %v != %v", io.ErrUnexpectedEOF, err)
+	}
+}
@@ -0,0 +1,90 @@
+package fcip
+
+import (
+	"bufio"
+	"io"
+
+	fc "github.com/bluecmd/fibrechannel"
+)
+
+// Reader reassembles encapsulated frames from one direction of an FCIP TCP
+// connection. If the stream does not start at a frame boundary, or a bad
+// header is found, the Reader resynchronizes by scanning for the next valid
+// header, the bytes thrown away are counted in Skipped.
+type Reader struct {
+	r *bufio.Reader
+
+	// Special is the last FCIP Special Frame seen in the stream
+	Special *SpecialFrame
+	// Skipped is the number of bytes skipped to find a frame boundary
+	Skipped int64
+}
+
+// NewReader returns a Reader for the TCP byte stream r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, MaxFrameLength)}
+}
+
+// Next returns the next encapsulated frame. Special Frames are not returned
+// but kept in Special. At the end of the stream io.EOF is returned, or
+// io.ErrUnexpectedEOF if it ends within a frame.
+func (r *Reader) Next() (*Frame, error) {
+	for {
+		h, err := r.r.Peek(HeaderLength)
+		if err != nil {
+			return nil, eof(len(h), err)
+		}
+		hdr := &Header{}
+		if err := hdr.UnmarshalBinary(h); err != nil {
+			r.skip()
+			continue
+		}
+		b, err := r.r.Peek(4 * int(hdr.FrameLength))
+		if err != nil {
+			return nil, eof(len(b), err)
+		}
+		if hdr.PFlags&PFlagSpecialFrame != 0 {
+			s := &SpecialFrame{}
+			if err := s.UnmarshalBinary(b); err != nil {
+				r.skip()
+				continue
+			}
+			r.Special = s
+			r.r.Discard(len(b))
+			continue
+		}
+		f := &Frame{}
+		if err := f.UnmarshalBinary(b); err != nil {
+			r.skip()
+			continue
+		}
+		// The peeked bytes are only valid until the next read
+		f.Payload = append([]byte{}, f.Payload...)
+		r.r.Discard(len(b))
+		return f, nil
+	}
+}
+
+// ReadFrame returns the next FC frame in the stream together with its SOF
+// and EOF. A frame with a bad FC CRC is returned as a *CRCError, the stream
+// can still be read after that.
+func (r *Reader) ReadFrame() (*fc.Frame, fc.SOF, fc.EOF, error) {
+	f, err := r.Next()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	ff, err := f.Decode()
+	return ff, f.SOF, f.EOF, err
+}
+
+func (r *Reader) skip() {
+	r.r.Discard(1)
+	r.Skipped++
+}
+
+func eof(n int, err error) error {
+	if err == io.EOF && n > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
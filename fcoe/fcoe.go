@@ -1,37 +1,48 @@
 package fcoe
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
-	"hash/crc32"
+	"fmt"
 	"io"
+	"net"
 
 	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
 )
 
 var (
-	eofMap = map[uint8]fc.EOF{
-		0x41: fc.EOFn,
-		0x42: fc.EOFt,
-		0x44: fc.EOFrt,
-		0x46: fc.EOFdt,
-		0x49: fc.EOFni,
-		0x4E: fc.EOFdti,
-		0x4F: fc.EOFrti,
-		0x50: fc.EOFa,
-	}
-	sofMap = map[uint8]fc.SOF{
-		0x28: fc.SOFf,
-		0x29: fc.SOFi4,
-		0x2D: fc.SOFi2,
-		0x2E: fc.SOFi3,
-		0x31: fc.SOFn4,
-		0x35: fc.SOFn2,
-		0x36: fc.SOFn3,
-		0x39: fc.SOFc4,
-	}
+	errShortHeader = errors.New("payload shorter than an FC frame header")
+)
+
+const (
+	EtherType = 0x8906
+)
+
+var (
+	// DefaultFCMAP is the FC-MAP used by FCFs unless configured otherwise.
+	DefaultFCMAP = [3]byte{0x0e, 0xfc, 0x00}
 )
 
+const (
+	// headerLength is the version, the reserved bits and the SOF
+	headerLength = 14
+	// trailerLength is the FC CRC, the EOF and three reserved bytes
+	trailerLength = 8
+	// fcHeaderLength is the length of the FC frame header in Payload
+	fcHeaderLength = 24
+	// fillMask is the Fill Data Bytes field of F_CTL in the FC frame header
+	fillMask = 0x03
+)
+
+// Frame is an FCoE PDU as carried after the Ethernet header, an FC frame
+// encapsulated between an SOF and an EOF. Payload holds the FC frame header
+// and data field, but not the FC CRC which is kept in CRC32.
+//
+// MarshalBinary writes the CRC computed from Payload, or CRC32 if KeepCRC is
+// set, which allows sending frames with a deliberately bad CRC. It leaves
+// the Frame unchanged.
 type Frame struct {
 	Version int
 	SOF     fc.SOF
@@ -40,72 +51,145 @@ type Frame struct {
 
 	EOF fc.EOF
 
-	CRC32 uint32
+	CRC32   uint32
+	KeepCRC bool
 }
 
+// CRCError is returned when the FC CRC of a frame does not match its
+// contents.
+type CRCError = fc.CRCError
+
+// UnmarshalBinary decodes an FCoE PDU. The reserved bits are ignored and
+// the FC CRC is not checked, use VerifyCRC or Decode for that.
 func (f *Frame) UnmarshalBinary(b []byte) error {
 	var ok bool
-	if len(b) < 22 {
+	if len(b) < headerLength+trailerLength {
 		return io.ErrUnexpectedEOF
 	}
 
-	f.Version = int(b[0])
-	f.SOF, ok = sofMap[b[13]]
+	// Only the upper 4 bits are the version, the rest up to the SOF is
+	// reserved
+	f.Version = int(b[0] >> 4)
+	f.SOF, ok = fc.SOFFromCode(b[13])
 	if !ok {
 		return errors.New("invalid SOF")
 	}
-	f.Payload = b[14 : len(b)-8]
-	f.EOF, ok = eofMap[b[len(b)-4]]
+	f.Payload = b[headerLength : len(b)-trailerLength]
+	f.EOF, ok = fc.EOFFromCode(b[len(b)-4])
 	if !ok {
 		return errors.New("invalid EOF")
 	}
-	f.CRC32 = binary.BigEndian.Uint32(b[len(b)-8 : len(b)-4])
+	f.CRC32 = binary.BigEndian.Uint32(b[len(b)-trailerLength : len(b)-4])
 	return nil
 }
 
 func (f *Frame) Checksum() uint32 {
-	h := crc32.ChecksumIEEE(f.Payload)
-	// Change from big-endian to host encoding
-	b := [4]byte{
-		uint8(h >> 0),
-		uint8(h >> 8),
-		uint8(h >> 16),
-		uint8(h >> 24),
+	return fc.CRC(f.Payload)
+}
+
+// VerifyCRC returns a *CRCError if CRC32 does not match the Payload.
+func (f *Frame) VerifyCRC() error {
+	if c := f.Checksum(); c != f.CRC32 {
+		return &CRCError{Got: f.CRC32, Want: c}
 	}
-	return binary.BigEndian.Uint32(b[:])
+	return nil
 }
 
-func (f *Frame) MarshalBinary() ([]byte, error) {
-	b := make([]byte, f.length())
-	err := f.read(b)
-	return b, err
+// Decode verifies the FC CRC and decodes the encapsulated FC frame. The fill
+// bytes given in F_CTL are dropped from the data field.
+func (f *Frame) Decode() (*fc.Frame, error) {
+	if err := f.VerifyCRC(); err != nil {
+		return nil, err
+	}
+	ff := &fc.Frame{}
+	if _, err := ff.ReadFrom(bytes.NewReader(f.Payload)); err != nil {
+		return nil, err
+	}
+	if fill := ff.FCtl.FillBytes(); fill != 0 && fill <= len(f.Payload) {
+		ff = &fc.Frame{}
+		if _, err := ff.ReadFrom(bytes.NewReader(f.Payload[:len(f.Payload)-fill])); err != nil {
+			return nil, err
+		}
+	}
+	return ff, nil
 }
 
-func (f *Frame) read(b []byte) error {
-	// Version
-	b[0] = byte(f.Version)
+// Encode sets Payload to the serialized FC frame ff. The CRC is computed
+// when the frame is marshalled.
+func (f *Frame) Encode(ff *fc.Frame) error {
+	buf := new(bytes.Buffer)
+	if _, err := ff.WriteTo(buf); err != nil {
+		return err
+	}
+	f.Payload = buf.Bytes()
+	return nil
+}
 
-	// TODO(bluecmd): This can be made faster, but the map is so small so
-	// should be fine
-	for k, v := range sofMap {
-		if v == f.SOF {
-			b[13] = k
-			break
+// MarshalBinary encodes the FCoE PDU. The data field of the FC frame is
+// padded to a word with fill bytes, which are accounted for in F_CTL, as
+// the FC CRC has to be word aligned.
+func (f *Frame) MarshalBinary() ([]byte, error) {
+	p := f.Payload
+	if fill := (4 - len(p)%4) % 4; fill != 0 {
+		i := fillOffset(p)
+		if i < 0 {
+			return nil, errShortHeader
 		}
+		p = make([]byte, len(f.Payload)+fill)
+		copy(p, f.Payload)
+		p[i] = p[i]&^fillMask | uint8(fill)
+	}
+	crc := f.CRC32
+	if !f.KeepCRC {
+		crc = fc.CRC(p)
 	}
-	copy(b[14:], f.Payload)
 
-	binary.BigEndian.PutUint32(b[len(b)-8:len(b)-4], f.CRC32)
-	for k, v := range eofMap {
-		if v == f.EOF {
-			b[len(b)-4] = k
-			break
+	b := make([]byte, len(p)+headerLength+trailerLength)
+	// Version, the remaining bits up to the SOF are reserved and zero
+	b[0] = byte(f.Version << 4)
+	b[13] = f.SOF.Code()
+	copy(b[headerLength:], p)
+	binary.BigEndian.PutUint32(b[len(b)-trailerLength:len(b)-4], crc)
+	b[len(b)-4] = f.EOF.Code()
+	return b, nil
+}
+
+// fillOffset returns the offset in p of the F_CTL byte holding the fill
+// bytes, after any Extended_Headers, or -1 if p is too short.
+func fillOffset(p []byte) int {
+	i := 0
+	for i < len(p) {
+		switch p[i] {
+		case fc.RCtlVFT:
+			i += fc.VFTLength
+		case fc.RCtlIFR:
+			i += fc.IFRLength
+		case fc.RCtlEnc:
+			i += fc.EncLength
+		default:
+			if len(p)-i < fcHeaderLength {
+				return -1
+			}
+			return i + 11
 		}
 	}
+	return -1
+}
 
-	return nil
+// FPMA returns the Fabric Provided MAC Address of the VN_Port with the
+// N_Port_ID id, which is the FC-MAP followed by the N_Port_ID.
+func FPMA(fcmap [3]byte, id common.FCID) net.HardwareAddr {
+	return net.HardwareAddr{fcmap[0], fcmap[1], fcmap[2], id[0], id[1], id[2]}
 }
 
-func (f *Frame) length() int {
-	return len(f.Payload) + 22
+// ParseFPMA splits a Fabric Provided MAC Address into its FC-MAP and
+// N_Port_ID. Server Provided MAC Addresses can not be told apart from an
+// FPMA, compare the FC-MAP to the one in use on the fabric.
+func ParseFPMA(mac net.HardwareAddr) (fcmap [3]byte, id common.FCID, err error) {
+	if len(mac) != 6 {
+		return fcmap, id, fmt.Errorf("invalid MAC address length %d", len(mac))
+	}
+	copy(fcmap[:], mac[:3])
+	copy(id[:], mac[3:])
+	return fcmap, id, nil
 }
@@ -2,13 +2,21 @@ package fcoe
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"reflect"
 	"testing"
 
 	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/els"
 )
 
+var testPayload = []byte{
+	0x22, 0xff, 0xff, 0xfd, 0x00, 0xed, 0x01, 0x00, 0x01, 0x29, 0x00, 0x00,
+	0xf0, 0x00, 0x00, 0x00, 0x03, 0xf8, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00,
+	0x62, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03}
+
 func TestFrameMarshalBinary(t *testing.T) {
 	var tests = []struct {
 		desc string
@@ -18,18 +26,39 @@ func TestFrameMarshalBinary(t *testing.T) {
 	}{
 		{
 			desc: "Normal frame",
-			f:    &Frame{SOF: fc.SOFf, EOF: fc.EOFn, CRC32: 0x10},
+			f:    &Frame{SOF: fc.SOFf, EOF: fc.EOFn, CRC32: 0x10, KeepCRC: true},
 			b:    append(bytes.Repeat([]byte{0}, 13), 0x28, 0, 0, 0, 0x10, 0x41, 0, 0, 0),
 		},
+		{
+			desc: "Computed CRC",
+			f:    &Frame{Version: 1, SOF: fc.SOFi3, EOF: fc.EOFt, Payload: testPayload, CRC32: 0x10},
+			b: append(append([]byte{0x10, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x2e}, testPayload...),
+				0x1b, 0x72, 0x6f, 0x69, 0x42, 0, 0, 0),
+		},
+		{
+			desc: "Fill bytes",
+			f:    &Frame{SOF: fc.SOFi3, EOF: fc.EOFt, Payload: testPayload[:30], KeepCRC: true},
+			b: append(append(append(bytes.Repeat([]byte{0}, 13), 0x2e), testPayload[:11]...),
+				append(append([]byte{0x02}, testPayload[12:30]...), 0, 0, 0, 0, 0, 0, 0x42, 0, 0, 0)...),
+		},
+		{
+			desc: "Short header",
+			f:    &Frame{SOF: fc.SOFi3, EOF: fc.EOFt, Payload: testPayload[:10]},
+			err:  errShortHeader,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.desc, func(t *testing.T) {
+			orig := *tt.f
 			b, err := tt.f.MarshalBinary()
+			if want, got := tt.err, err; want != got {
+				t.Fatalf("unexpected error: %v != %v", want, got)
+			}
+			if !reflect.DeepEqual(&orig, tt.f) {
+				t.Fatalf("MarshalBinary modified the Frame:\n- want: %+v\n-  got: %+v", &orig, tt.f)
+			}
 			if err != nil {
-				if want, got := tt.err, err; want != got {
-					t.Fatalf("unexpected error: %v != %v", want, got)
-				}
 				return
 			}
 
@@ -73,6 +102,24 @@ func TestFrameUnmarshalBinary(t *testing.T) {
 				EOF:     fc.EOFn,
 			},
 		},
+		{
+			desc: "reserved bits",
+			b: []byte{
+				0x1f, 0xff,
+				0xff, 0xff, 0xff, 0xff,
+				0xff, 0xff, 0xff, 0xff,
+				0xff, 0xff, 0xff, 0x28,
+				0x12, 0x34, 0x56, 0x78,
+				0x41, 0xff, 0xff, 0xff,
+			},
+			f: &Frame{
+				Version: 1,
+				CRC32:   0x12345678,
+				SOF:     fc.SOFf,
+				Payload: []byte{},
+				EOF:     fc.EOFn,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -93,11 +140,7 @@ func TestFrameUnmarshalBinary(t *testing.T) {
 }
 
 func TestCRC32(t *testing.T) {
-	p := []byte{
-		0x22, 0xff, 0xff, 0xfd, 0x00, 0xed, 0x01, 0x00, 0x01, 0x29, 0x00, 0x00,
-		0xf0, 0x00, 0x00, 0x00, 0x03, 0xf8, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00,
-		0x62, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03}
-	f := &Frame{Payload: p}
+	f := &Frame{Payload: testPayload}
 	f.CRC32 = f.Checksum()
 	want := uint32(0x1b726f69)
 	got := f.CRC32
@@ -105,3 +148,70 @@ func TestCRC32(t *testing.T) {
 		t.Fatalf("CRC calculation failed: wanted %08x, got %08x", want, got)
 	}
 }
+
+func TestVerifyCRC(t *testing.T) {
+	f := &Frame{Payload: testPayload, CRC32: 0x1b726f69}
+	if err := f.VerifyCRC(); err != nil {
+		t.Fatalf("VerifyCRC: %v", err)
+	}
+	f.CRC32 = 0xdeadbeef
+	err := f.VerifyCRC()
+	if want := (&CRCError{Got: 0xdeadbeef, Want: 0x1b726f69}); !reflect.DeepEqual(err, want) {
+		t.Fatalf("unexpected error: %v != %v", want, err)
+	}
+	if _, err := f.Decode(); !errors.As(err, new(*CRCError)) {
+		t.Fatalf("Decode did not verify the CRC: %v", err)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	want := &fc.Frame{
+		RCtl:          0x22,
+		DestinationID: common.FPortController,
+		SourceID:      common.FCID{0x01, 0x02, 0x03},
+		CsctlPriority: &fc.CSCtl{},
+		FCtl:          fc.FrameControl{TODO1: 10, TODO2: 0x10000},
+		OXID:          0x1234,
+		RXID:          0xffff,
+		Payload: &els.Frame{Payload: &els.LOGO{
+			PortID:   common.FCID{0x01, 0x02, 0x03},
+			PortName: common.WWN{0x10, 0x00, 0x00, 0x00, 0xc9, 0x12, 0x34, 0x56},
+		}},
+	}
+	f := &Frame{Version: 1, SOF: fc.SOFi3, EOF: fc.EOFt}
+	if err := f.Encode(want); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	b, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	g := new(Frame)
+	if err := g.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	got, err := g.Decode()
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("unexpected Frame:\n- want: %+v\n-  got: %+v", want, got)
+	}
+}
+
+func TestFPMA(t *testing.T) {
+	mac := FPMA(DefaultFCMAP, common.FCID{0x01, 0x02, 0x03})
+	if want := "0e:fc:00:01:02:03"; mac.String() != want {
+		t.Fatalf("got %v, wanted %v", mac, want)
+	}
+	fcmap, id, err := ParseFPMA(mac)
+	if err != nil {
+		t.Fatalf("ParseFPMA: %v", err)
+	}
+	if fcmap != DefaultFCMAP || id != (common.FCID{0x01, 0x02, 0x03}) {
+		t.Fatalf("got FC-MAP %x and N_Port_ID %x", fcmap, id)
+	}
+	if _, _, err := ParseFPMA(mac[:4]); err == nil {
+		t.Fatalf("ParseFPMA accepted a short MAC address")
+	}
+}
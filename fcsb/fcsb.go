@@ -0,0 +1,272 @@
+// Package fcsb decodes FICON, the Single-Byte Command Code Sets mapping of
+// channel programs onto Fibre Channel. Command mode IUs (FC-SB-3) are decoded
+// by IU, transport mode IUs (FC-SB-4, also known as zHPF) by the Transport*
+// types.
+package fcsb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+const (
+	IUTypeData          = 0x0 // Data
+	IUTypeCommandHeader = 0x1 // Command Header
+	IUTypeStatus        = 0x2 // Status
+	IUTypeControl       = 0x3 // Control
+	IUTypeCommandData   = 0x4 // Command Header and Data
+	IUTypeLinkControl   = 0x5 // Link Control
+
+	IUIAddressSpecific = 0x20 // IUI: Address Specific
+	IUIEarlyStatus     = 0x10 // IUI: Early Status
+	IUITypeMask        = 0x07 // IUI: IU type
+
+	DHFlagEnd      = 0x80 // Device header: last IU of the exchange
+	DHFlagChaining = 0x10 // Device header: chaining to the next IU
+	DHFlagEarlyEnd = 0x08 // Device header: early end
+	DHFlagNoCRC    = 0x02 // Device header: no CRC present
+
+	HeaderLength = 16
+	DIBLength    = 16
+)
+
+const (
+	CCWFlagChainData    = 0x80 // CD: chain data
+	CCWFlagChainCommand = 0x40 // CC: chain command
+	CCWFlagSLI          = 0x20 // SLI: suppress length indication
+	CCWFlagCRR          = 0x08 // CRR: command retry request
+
+	CmdFlagDataUnavailable = 0x10 // DU: data unavailable
+	CmdFlagCOC             = 0x08 // COC: CCW overwrite control
+	CmdFlagSyncRequest     = 0x04 // SYR: synchronization request
+	CmdFlagRex             = 0x02 // REX: read exchange
+	CmdFlagSSS             = 0x01 // SSS: supplemental status sent
+
+	UnitStatusAttention      = 0x80 // Attention
+	UnitStatusStatusModifier = 0x40 // Status Modifier
+	UnitStatusCUEnd          = 0x20 // Control Unit End
+	UnitStatusBusy           = 0x10 // Busy
+	UnitStatusChannelEnd     = 0x08 // Channel End
+	UnitStatusDeviceEnd      = 0x04 // Device End
+	UnitStatusUnitCheck      = 0x02 // Unit Check
+	UnitStatusUnitException  = 0x01 // Unit Exception
+
+	StatusFlagFFCMask            = 0xe0 // FFC: function flags
+	StatusFlagCUInitiated        = 0x10 // CI: control unit initiated
+	StatusFlagCommandRetry       = 0x04 // CR: command retry
+	StatusFlagLastRequestedIU    = 0x02 // LRI: last requested IU
+	StatusFlagResidualCountValid = 0x01 // RV: residual count valid
+)
+
+const (
+	ControlEnd                  = 0x00 // Control End
+	ControlCommandResponse      = 0x01 // Command Response
+	ControlStackStatus          = 0x02 // Stack Status
+	ControlCancel               = 0x03 // Cancel
+	ControlSystemReset          = 0x04 // System Reset
+	ControlSelectiveReset       = 0x05 // Selective Reset
+	ControlRequestStatus        = 0x07 // Request Status
+	ControlDeviceLevelException = 0x08 // Device-Level Exception
+	ControlStatusAccepted       = 0x0a // Status Accepted
+	ControlDeviceLevelAck       = 0x0b // Device-Level Acknowledgment
+	ControlPurgePath            = 0x0c // Purge Path
+	ControlPurgePathResponse    = 0x0d // Purge Path Response
+)
+
+const (
+	LinkTestInitialization       = 0x09 // TIN: Test Initialization
+	LinkTestInitializationResult = 0x19 // TIR: Test Initialization Result
+	LinkEstablishLogicalPath     = 0x41 // ELP: Establish Logical Path
+	LinkRemoveLogicalPath        = 0x42 // RLP: Remove Logical Path
+	LinkLogicalPathEstablished   = 0x51 // LPE: Logical Path Established
+	LinkLogicalPathRemoved       = 0x52 // LPR: Logical Path Removed
+	LinkLevelReject              = 0x54 // LRJ: Link-Level Reject
+	LinkLevelBusy                = 0x55 // LBY: Link-Level Busy
+	LinkLevelAck                 = 0x80 // LACK: Link-Level Acknowledgment
+)
+
+var (
+	errShortIU = errors.New("IU shorter than its headers")
+)
+
+// IU is an FC-SB-3 command mode Information Unit. The SB-3 header addresses
+// the device, the IU header describes the IU. DIB holds the Device
+// Information Block, a *CommandDIB, *StatusDIB, *ControlDIB or
+// *LinkControlDIB depending on Type, and is nil for data IUs. Data holds
+// whatever follows, like data, sense data or the CRC. FromControlUnit is
+// the direction of the IU, it selects the TYPE of the frame carrying it.
+type IU struct {
+	FromControlUnit    bool
+	ChannelImageID     uint8
+	ControlUnitImageID uint8
+	DeviceAddress      uint16
+	IUI                uint8
+	DHFlags            uint8
+	CCWNumber          uint16
+	Token              [3]byte
+	DIB                interface{}
+	Data               []byte
+}
+
+type header struct {
+	ChannelImageID     uint8   `fc:"@1"`
+	ControlUnitImageID uint8   `fc:"@3"`
+	DeviceAddress      uint16  `fc:"@4"`
+	IUI                uint8   `fc:"@8"`
+	DHFlags            uint8   `fc:"@9"`
+	CCWNumber          uint16  `fc:"@10"`
+	Token              [3]byte `fc:"@13"`
+}
+
+// CommandDIB carries a CCW, it is used by command header IUs.
+type CommandDIB struct {
+	Command   uint8  `fc:"@0"`
+	CCWFlags  uint8  `fc:"@1"`
+	CCWCount  uint16 `fc:"@2"`
+	Priority  uint8  `fc:"@5"`
+	Flags     uint8  `fc:"@7"`
+	IUCount   uint8  `fc:"@9"`
+	DataCount uint16 `fc:"@10"`
+	LRC       uint32 `fc:"@12"`
+}
+
+// StatusDIB presents the ending status of a channel program. Flags are the
+// status flags (FFC, CI, CR, LRI and RV), UnitStatus the device status byte.
+// ResidualCount is only meaningful if StatusFlagResidualCountValid is set.
+type StatusDIB struct {
+	Flags         uint8  `fc:"@0"`
+	UnitStatus    uint8  `fc:"@1"`
+	ResidualCount uint16 `fc:"@2"`
+	IUPacing      uint8  `fc:"@4"`
+	DataCount     uint16 `fc:"@10"`
+	LRC           uint32 `fc:"@12"`
+}
+
+// ControlDIB carries a control function, like System Reset or Cancel.
+type ControlDIB struct {
+	Function  uint8   `fc:"@0"`
+	Parameter [3]byte `fc:"@1"`
+	IUCount   uint8   `fc:"@9"`
+	DataCount uint16  `fc:"@10"`
+	LRC       uint32  `fc:"@12"`
+}
+
+// LinkControlDIB carries a link-level function, used to establish and remove
+// logical paths between a channel image and a control unit image.
+type LinkControlDIB struct {
+	Function   uint8  `fc:"@0"`
+	Info       uint16 `fc:"@2"`
+	CTCCounter uint16 `fc:"@4"`
+	DataCount  uint16 `fc:"@10"`
+	LRC        uint32 `fc:"@12"`
+}
+
+// Type returns the IU type encoded in the IUI.
+func (s *IU) Type() uint8 {
+	return s.IUI & IUITypeMask
+}
+
+func (s *IU) ReadFrom(r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	n := int64(len(b))
+	if len(b) < HeaderLength {
+		return n, io.ErrUnexpectedEOF
+	}
+	h := &header{}
+	if _, err := encoding.ReadFrom(bytes.NewReader(b), h); err != nil {
+		return n, err
+	}
+	s.ChannelImageID = h.ChannelImageID
+	s.ControlUnitImageID = h.ControlUnitImageID
+	s.DeviceAddress = h.DeviceAddress
+	s.IUI = h.IUI
+	s.DHFlags = h.DHFlags
+	s.CCWNumber = h.CCWNumber
+	s.Token = h.Token
+	b = b[HeaderLength:]
+
+	switch s.Type() {
+	case IUTypeCommandHeader, IUTypeCommandData:
+		s.DIB = &CommandDIB{}
+	case IUTypeStatus:
+		s.DIB = &StatusDIB{}
+	case IUTypeControl:
+		s.DIB = &ControlDIB{}
+	case IUTypeLinkControl:
+		s.DIB = &LinkControlDIB{}
+	default:
+		s.DIB = nil
+	}
+	if s.DIB != nil {
+		if len(b) < DIBLength {
+			return n, errShortIU
+		}
+		if _, err := encoding.ReadFrom(bytes.NewReader(b[:DIBLength]), s.DIB); err != nil {
+			return n, err
+		}
+		b = b[DIBLength:]
+	}
+	s.Data = nil
+	if len(b) > 0 {
+		s.Data = make([]byte, len(b))
+		copy(s.Data, b)
+	}
+	return n, nil
+}
+
+func (s *IU) WriteTo(w io.Writer) (int64, error) {
+	h := &header{
+		ChannelImageID:     s.ChannelImageID,
+		ControlUnitImageID: s.ControlUnitImageID,
+		DeviceAddress:      s.DeviceAddress,
+		IUI:                s.IUI,
+		DHFlags:            s.DHFlags,
+		CCWNumber:          s.CCWNumber,
+		Token:              s.Token,
+	}
+	buf := new(bytes.Buffer)
+	if _, err := encoding.WriteTo(buf, h); err != nil {
+		return 0, err
+	}
+	buf.Write(make([]byte, HeaderLength-buf.Len()))
+	switch s.DIB.(type) {
+	case nil:
+	case *CommandDIB, *StatusDIB, *ControlDIB, *LinkControlDIB:
+		if _, err := encoding.WriteTo(buf, s.DIB); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("Unsupported DIB %v", s.DIB)
+	}
+	buf.Write(s.Data)
+	return buf.WriteTo(w)
+}
+
+// ReadPayload decodes the payload of a TYPE 0x1B or 0x1C frame. Transport
+// mode IUs are sent using the information categories of FCP, anything else
+// is a command mode IU.
+func ReadPayload(rctl uint8, r io.Reader) (interface{}, int64, error) {
+	var p interface {
+		io.ReaderFrom
+		io.WriterTo
+	}
+	switch rctl & 0x0f {
+	case RCtlTransportData:
+		p = &TransportDataIU{}
+	case RCtlTransportCommand:
+		p = &TransportCommandIU{}
+	case RCtlTransportResponse:
+		p = &TransportResponseIU{}
+	default:
+		p = &IU{}
+	}
+	n, err := p.ReadFrom(r)
+	return p, n, err
+}
@@ -0,0 +1,155 @@
+package fcsb
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+func TestNilBuffer(t *testing.T) {
+	c := &IU{}
+	_, err := c.ReadFrom(bytes.NewReader([]byte{}))
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("got unexpected error %v, wanted io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestShortDIB(t *testing.T) {
+	b := make([]byte, HeaderLength+4)
+	b[8] = IUTypeStatus
+	c := &IU{}
+	if _, err := c.ReadFrom(bytes.NewReader(b)); err != errShortIU {
+		t.Fatalf("got unexpected error %v, wanted %v", err, errShortIU)
+	}
+}
+
+func TestFrameFiles(t *testing.T) {
+	common.TestFrameFiles(t, func() common.SerDes { return &IU{} })
+}
+
+func TestReadPayload(t *testing.T) {
+	var tests = []struct {
+		desc string
+		rctl uint8
+		b    []byte
+		want interface{}
+	}{
+		{
+			desc: "transport command",
+			rctl: 0x06,
+			b: []byte{
+				0x00, 0x01, 0x00, 0x02, 0x00, 0x30, 0x00, 0x00,
+				0x00, TCHFlagRead, 0x00, 0x0d,
+				// TCA Header
+				0x7f, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2c,
+				0x1f, 0xfe, 0x00, 0x00,
+				// DCW: Define Extent with 16 bytes of control data
+				0x63, 0x40, 0x00, 0x10, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+				0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+				// DCW: Read Track Data of 4096 bytes
+				0x0c, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00,
+				// TCA Trailer
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00,
+			},
+			want: &TransportCommandIU{
+				ChannelImageID:     1,
+				ControlUnitImageID: 2,
+				DeviceAddress:      0x30,
+				Flags:              TCHFlagRead,
+				TCCBLength:         13,
+				TCCB: TCCB{
+					Format:        TCCBFormatDefault,
+					TCALength:     44,
+					ServiceAction: TCCBSACDefault,
+					DCWs: []DCW{
+						{
+							Command:     0x63,
+							Flags:       DCWFlagChainCommand,
+							ControlData: []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+						},
+						{
+							Command:     0x0c,
+							Count:       4096,
+							ControlData: []byte{},
+						},
+					},
+					TransportCount: 4096,
+				},
+			},
+		},
+		{
+			desc: "transport response",
+			rctl: 0x07,
+			b: []byte{
+				0x00, 0x01, 0x00, 0x02, 0x00, 0x30, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x0e, 0x00, 0x00, 0x00, 0x20,
+				0x10, 0x00,
+			},
+			want: &TransportResponseIU{
+				ChannelImageID:     1,
+				ControlUnitImageID: 2,
+				DeviceAddress:      0x30,
+				UnitStatus:         UnitStatusChannelEnd | UnitStatusDeviceEnd | UnitStatusUnitCheck,
+				ResidualCount:      32,
+				Sense:              []byte{0x10, 0x00},
+			},
+		},
+		{
+			desc: "command mode",
+			rctl: 0x00,
+			b: []byte{
+				0x00, 0x01, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00,
+				0x03, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+			want: &IU{
+				ChannelImageID: 1,
+				DeviceAddress:  0x10,
+				IUI:            IUTypeControl,
+				DHFlags:        DHFlagEnd,
+				DIB:            &ControlDIB{Function: ControlSystemReset},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, n, err := ReadPayload(tc.rctl, bytes.NewReader(tc.b))
+			if err != nil {
+				t.Fatalf("ReadPayload: %v", err)
+			}
+			if n != int64(len(tc.b)) {
+				t.Errorf("read %d bytes, wanted %d", n, len(tc.b))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %+v, wanted %+v", got, tc.want)
+			}
+			buf := new(bytes.Buffer)
+			if _, err := got.(io.WriterTo).WriteTo(buf); err != nil {
+				t.Fatalf("WriteTo: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), tc.b) {
+				t.Fatalf("re-serialized to %v, wanted %v", buf.Bytes(), tc.b)
+			}
+		})
+	}
+}
+
+func TestWriteToUnchanged(t *testing.T) {
+	o := &TransportCommandIU{TCCB: TCCB{DCWs: []DCW{{Command: 0x0c, Count: 4096}}}}
+	b := new(bytes.Buffer)
+	if _, err := o.WriteTo(b); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	// The lengths are in the frame but not stored in the IU
+	if b.Bytes()[11] != 7 || b.Bytes()[19] != 20 {
+		t.Errorf("got TCCB length %d and TCA length %d, wanted 7 and 20", b.Bytes()[11], b.Bytes()[19])
+	}
+	if o.TCCBLength != 0 || o.TCCB.TCALength != 0 {
+		t.Errorf("WriteTo changed %+v", o)
+	}
+}
@@ -0,0 +1,222 @@
+package fcsb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	RCtlTransportData     = 0x01 // Information category of transport data IUs
+	RCtlTransportCommand  = 0x06 // Information category of transport command IUs
+	RCtlTransportResponse = 0x07 // Information category of transport response IUs
+
+	TCCBFormatDefault  = 0x7f   // TCA Header format
+	TCCBSACDefault     = 0x1ffe // Service Action Code: default
+	TCCBSACInterrogate = 0x1ff2 // Service Action Code: interrogate
+
+	TCHFlagWrite = 0x01 // Transport command header: write data follows
+	TCHFlagRead  = 0x02 // Transport command header: read data expected
+
+	DCWFlagChainCommand = 0x40 // CC: chain to the next DCW
+
+	sb4HeaderLength = 8
+	tchLength       = 4
+	tcahLength      = 12
+	tcatLength      = 8
+	dcwLength       = 8
+)
+
+// TransportCommandIU starts an FC-SB-4 transport mode I/O operation. The
+// whole channel program is sent at once in the TCCB. TCCBLength is in words,
+// it is filled in if zero.
+type TransportCommandIU struct {
+	ChannelImageID     uint8
+	ControlUnitImageID uint8
+	DeviceAddress      uint16
+	Flags              uint8
+	TCCBLength         uint8
+	TCCB               TCCB
+}
+
+// TCCB is the Transport Command Control Block, a TCA Header, the Device
+// Command Words and a TCA Trailer. TCALength is filled in if zero.
+type TCCB struct {
+	Format         uint8
+	TCALength      uint8
+	ServiceAction  uint16
+	Priority       uint8
+	DCWs           []DCW
+	TransportCount uint32
+}
+
+// DCW is a Device Command Word, the transport mode counterpart of a CCW.
+// ControlData is sent inline in the TCCB, Count is the amount of data
+// transferred in transport data IUs.
+type DCW struct {
+	Command     uint8
+	Flags       uint8
+	Count       uint32
+	ControlData []byte
+}
+
+// TransportDataIU carries read or write data of a transport mode operation.
+// FromControlUnit is set for read data, it selects the TYPE of the frame
+// carrying the IU.
+type TransportDataIU struct {
+	FromControlUnit bool
+	Data            []byte
+}
+
+// TransportResponseIU ends a transport mode operation. Sense holds any
+// sense data following the status.
+type TransportResponseIU struct {
+	ChannelImageID     uint8
+	ControlUnitImageID uint8
+	DeviceAddress      uint16
+	Flags              uint8
+	UnitStatus         uint8
+	ResidualCount      uint32
+	Sense              []byte
+}
+
+func (s *TransportCommandIU) ReadFrom(r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	n := int64(len(b))
+	if len(b) < sb4HeaderLength+tchLength {
+		return n, io.ErrUnexpectedEOF
+	}
+	s.ChannelImageID = b[1]
+	s.ControlUnitImageID = b[3]
+	s.DeviceAddress = binary.BigEndian.Uint16(b[4:])
+	s.Flags = b[9]
+	s.TCCBLength = b[11]
+	if err := s.TCCB.unmarshal(b[sb4HeaderLength+tchLength:]); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+func (s *TransportCommandIU) WriteTo(w io.Writer) (int64, error) {
+	tccb := s.TCCB.marshal()
+	l := s.TCCBLength
+	if l == 0 {
+		l = uint8(len(tccb) / 4)
+	}
+	b := make([]byte, sb4HeaderLength+tchLength, sb4HeaderLength+tchLength+len(tccb))
+	b[1] = s.ChannelImageID
+	b[3] = s.ControlUnitImageID
+	binary.BigEndian.PutUint16(b[4:], s.DeviceAddress)
+	b[9] = s.Flags
+	b[11] = l
+	b = append(b, tccb...)
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+func (s *TCCB) unmarshal(b []byte) error {
+	if len(b) < tcahLength+tcatLength {
+		return io.ErrUnexpectedEOF
+	}
+	s.Format = b[0]
+	s.TCALength = b[7]
+	s.ServiceAction = binary.BigEndian.Uint16(b[8:])
+	s.Priority = b[11]
+	tca := b[tcahLength : len(b)-tcatLength]
+	s.DCWs = []DCW{}
+	for len(tca) >= dcwLength {
+		d := DCW{
+			Command: tca[0],
+			Flags:   tca[1],
+			Count:   binary.BigEndian.Uint32(tca[4:]),
+		}
+		cd := int(tca[3])
+		l := dcwLength + (cd+3)&^3
+		if len(tca) < l {
+			return errShortIU
+		}
+		d.ControlData = append([]byte{}, tca[dcwLength:dcwLength+cd]...)
+		s.DCWs = append(s.DCWs, d)
+		tca = tca[l:]
+	}
+	s.TransportCount = binary.BigEndian.Uint32(b[len(b)-4:])
+	return nil
+}
+
+func (s *TCCB) marshal() []byte {
+	buf := new(bytes.Buffer)
+	for _, d := range s.DCWs {
+		var h [dcwLength]byte
+		h[0] = d.Command
+		h[1] = d.Flags
+		h[3] = uint8(len(d.ControlData))
+		binary.BigEndian.PutUint32(h[4:], d.Count)
+		buf.Write(h[:])
+		buf.Write(d.ControlData)
+		buf.Write(make([]byte, (4-len(d.ControlData)%4)%4))
+	}
+	l := s.TCALength
+	if l == 0 {
+		l = uint8(tcahLength + buf.Len())
+	}
+	b := make([]byte, tcahLength, tcahLength+buf.Len()+tcatLength)
+	b[0] = s.Format
+	b[7] = l
+	binary.BigEndian.PutUint16(b[8:], s.ServiceAction)
+	b[11] = s.Priority
+	b = append(b, buf.Bytes()...)
+	var t [tcatLength]byte
+	binary.BigEndian.PutUint32(t[4:], s.TransportCount)
+	return append(b, t[:]...)
+}
+
+func (s *TransportDataIU) ReadFrom(r io.Reader) (int64, error) {
+	buf := new(bytes.Buffer)
+	n, err := buf.ReadFrom(r)
+	s.Data = buf.Bytes()
+	return n, err
+}
+
+func (s *TransportDataIU) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(s.Data)
+	return int64(n), err
+}
+
+func (s *TransportResponseIU) ReadFrom(r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	n := int64(len(b))
+	if len(b) < sb4HeaderLength+8 {
+		return n, io.ErrUnexpectedEOF
+	}
+	s.ChannelImageID = b[1]
+	s.ControlUnitImageID = b[3]
+	s.DeviceAddress = binary.BigEndian.Uint16(b[4:])
+	s.Flags = b[9]
+	s.UnitStatus = b[11]
+	s.ResidualCount = binary.BigEndian.Uint32(b[12:])
+	s.Sense = nil
+	if len(b) > 16 {
+		s.Sense = make([]byte, len(b)-16)
+		copy(s.Sense, b[16:])
+	}
+	return n, nil
+}
+
+func (s *TransportResponseIU) WriteTo(w io.Writer) (int64, error) {
+	b := make([]byte, sb4HeaderLength+8, sb4HeaderLength+8+len(s.Sense))
+	b[1] = s.ChannelImageID
+	b[3] = s.ControlUnitImageID
+	binary.BigEndian.PutUint16(b[4:], s.DeviceAddress)
+	b[9] = s.Flags
+	b[11] = s.UnitStatus
+	binary.BigEndian.PutUint32(b[12:], s.ResidualCount)
+	b = append(b, s.Sense...)
+	n, err := w.Write(b)
+	return int64(n), err
+}
@@ -0,0 +1,49 @@
+package fibrechannel
+
+// F_CTL bits, as used with FrameControl.Value.
+const (
+	FCtlExchangeContext       = 1 << 23 // Frame sent by the Exchange Responder
+	FCtlSequenceContext       = 1 << 22 // Frame sent by the Sequence Recipient
+	FCtlFirstSequence         = 1 << 21 // First Sequence of the Exchange
+	FCtlLastSequence          = 1 << 20 // Last Sequence of the Exchange
+	FCtlEndSequence           = 1 << 19 // Last frame of the Sequence
+	FCtlPriorityEnable        = 1 << 17 // CS_CTL/Priority holds a priority
+	FCtlSequenceInitiative    = 1 << 16 // Sequence Initiative transferred
+	FCtlRetransmittedSequence = 1 << 9  // Retransmitted Sequence
+	FCtlRelativeOffset        = 1 << 3  // Parameter holds the relative offset
+	FCtlFillBytes             = 0x3     // Number of fill bytes in the last word
+)
+
+// Value returns the 24 bits of F_CTL.
+func (c FrameControl) Value() uint32 {
+	v := uint32(c.TODO1)<<18 | uint32(c.TODO2)&0x1ffff
+	if c.PriorityEnable {
+		v |= FCtlPriorityEnable
+	}
+	return v
+}
+
+// SetValue sets F_CTL from its 24 bits.
+func (c *FrameControl) SetValue(v uint32) {
+	c.TODO1 = int(v>>18) & 0x3f
+	c.PriorityEnable = v&FCtlPriorityEnable != 0
+	c.TODO2 = int(v & 0x1ffff)
+}
+
+// Has reports whether all of bits are set.
+func (c FrameControl) Has(bits uint32) bool {
+	return c.Value()&bits == bits
+}
+
+// FillBytes returns the number of fill bytes at the end of the payload.
+func (c FrameControl) FillBytes() int {
+	return int(c.Value() & FCtlFillBytes)
+}
+
+// F_CTL of the single frame Sequences of a request and its reply.
+const (
+	// FCtlRequest is the F_CTL of a single frame request
+	FCtlRequest = FCtlFirstSequence | FCtlEndSequence | FCtlSequenceInitiative
+	// FCtlReply is the F_CTL of a single frame reply ending the Exchange
+	FCtlReply = FCtlExchangeContext | FCtlLastSequence | FCtlEndSequence
+)
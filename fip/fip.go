@@ -0,0 +1,352 @@
+// Package fip implements the FCoE Initialization Protocol (FIP), used by
+// ENodes and FCFs to discover each other, to log in to the fabric and to
+// keep the resulting virtual links alive.
+package fip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+const (
+	EtherType = 0x8914
+
+	Version = 1
+)
+
+const (
+	OpDiscovery   = 0x0001 // Discovery
+	OpLinkService = 0x0002 // Virtual link instantiation
+	OpControl     = 0x0003 // Keep alive and Clear Virtual Links
+	OpVLAN        = 0x0004 // VLAN discovery
+
+	SubcodeSolicitation  = 0x01 // OpDiscovery: Discovery Solicitation
+	SubcodeAdvertisement = 0x02 // OpDiscovery: Discovery Advertisement
+	SubcodeRequest       = 0x01 // OpLinkService: request
+	SubcodeReply         = 0x02 // OpLinkService: reply
+	SubcodeKeepAlive     = 0x01 // OpControl: Keep Alive
+	SubcodeClearLinks    = 0x02 // OpControl: Clear Virtual Links
+	SubcodeVLANRequest   = 0x01 // OpVLAN: VLAN Request
+	SubcodeVLANNotify    = 0x02 // OpVLAN: VLAN Notification
+)
+
+const (
+	FlagFPMA      = 0x8000 // FP: Fabric Provided MAC Address
+	FlagSPMA      = 0x4000 // SP: Server Provided MAC Address
+	FlagRecommend = 0x0008 // REC: recommend the use of the FCF
+	FlagAvailable = 0x0004 // A: available for logins
+	FlagSolicited = 0x0002 // S: solicited advertisement
+	FlagFPort     = 0x0001 // F: FCF is an FC switch
+)
+
+const (
+	DescPriority     = 1  // Priority
+	DescMAC          = 2  // MAC address
+	DescFCMAP        = 3  // FC-MAP
+	DescName         = 4  // Name identifier
+	DescFabric       = 5  // Fabric
+	DescMaxFCoESize  = 6  // Max FCoE size
+	DescFLOGI        = 7  // FLOGI
+	DescFDISC        = 8  // FDISC
+	DescLOGO         = 9  // LOGO
+	DescELP          = 10 // ELP
+	DescVNPortID     = 11 // VX_Port identification
+	DescFKAAdvPeriod = 12 // FKA_ADV_Period
+	DescVendorID     = 13 // Vendor ID
+	DescVLAN         = 14 // VLAN
+
+	FKADisable = 0x01 // D: do not send keep alives
+)
+
+const (
+	headerLength = 10
+)
+
+var (
+	errShortDescriptor = errors.New("descriptor exceeds descriptor list")
+	errZeroLength      = errors.New("descriptor has zero length")
+)
+
+// Message is a FIP message as carried after the Ethernet header. Each
+// descriptor is one of the *Descriptor types in this package.
+type Message struct {
+	Version     uint8
+	Op          uint16
+	Subcode     uint8
+	Flags       uint16
+	Descriptors []interface{}
+}
+
+type PriorityDescriptor struct {
+	Priority uint8
+}
+
+type MACDescriptor struct {
+	MAC [6]byte
+}
+
+type FCMAPDescriptor struct {
+	FCMAP [3]byte
+}
+
+type NameDescriptor struct {
+	Name common.WWN
+}
+
+type FabricDescriptor struct {
+	VFID       uint16
+	FCMAP      [3]byte
+	FabricName common.WWN
+}
+
+type MaxFCoESizeDescriptor struct {
+	Size uint16
+}
+
+// ELSDescriptor encapsulates a FLOGI, FDISC, LOGO or ELP request or reply.
+// Type is the descriptor type, Frame the encapsulated frame without SOF, EOF
+// and CRC. The ELS itself is in Frame.Payload as an *els.Frame.
+type ELSDescriptor struct {
+	Type  uint8
+	Frame *fc.Frame
+}
+
+// VNPortIDDescriptor identifies a VN_Port by its MAC address, N_Port_ID and
+// N_Port_Name, it is used in keep alives and Clear Virtual Links.
+type VNPortIDDescriptor struct {
+	MAC      [6]byte
+	PortID   common.FCID
+	PortName common.WWN
+}
+
+// FKAAdvPeriodDescriptor holds the FIP Keep Alive period in milliseconds.
+type FKAAdvPeriodDescriptor struct {
+	Flags  uint8
+	Period uint32
+}
+
+type VendorIDDescriptor struct {
+	Vendor [8]byte
+	Data   []byte
+}
+
+type VLANDescriptor struct {
+	VLAN uint16
+}
+
+// RawDescriptor is a descriptor of a type this package does not know, kept
+// verbatim without its type and length.
+type RawDescriptor struct {
+	Type uint8
+	Data []byte
+}
+
+func (m *Message) UnmarshalBinary(b []byte) error {
+	if len(b) < headerLength {
+		return io.ErrUnexpectedEOF
+	}
+	m.Version = b[0] >> 4
+	m.Op = binary.BigEndian.Uint16(b[2:])
+	m.Subcode = b[5]
+	l := 4 * int(binary.BigEndian.Uint16(b[6:]))
+	m.Flags = binary.BigEndian.Uint16(b[8:])
+	b = b[headerLength:]
+	if len(b) < l {
+		return errShortDescriptor
+	}
+	// Anything after the descriptor list is Ethernet padding
+	b = b[:l]
+	m.Descriptors = []interface{}{}
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return errShortDescriptor
+		}
+		dl := 4 * int(b[1])
+		if dl == 0 {
+			return errZeroLength
+		}
+		if len(b) < dl {
+			return errShortDescriptor
+		}
+		d, err := unmarshalDescriptor(b[0], b[2:dl])
+		if err != nil {
+			return err
+		}
+		m.Descriptors = append(m.Descriptors, d)
+		b = b[dl:]
+	}
+	return nil
+}
+
+func (m *Message) MarshalBinary() ([]byte, error) {
+	list := new(bytes.Buffer)
+	for _, d := range m.Descriptors {
+		t, v, err := marshalDescriptor(d)
+		if err != nil {
+			return nil, err
+		}
+		if (len(v)+2)%4 != 0 {
+			v = append(v, make([]byte, 4-(len(v)+2)%4)...)
+		}
+		list.WriteByte(t)
+		list.WriteByte(uint8((len(v) + 2) / 4))
+		list.Write(v)
+	}
+	b := make([]byte, headerLength, headerLength+list.Len())
+	b[0] = m.Version << 4
+	binary.BigEndian.PutUint16(b[2:], m.Op)
+	b[5] = m.Subcode
+	binary.BigEndian.PutUint16(b[6:], uint16(list.Len()/4))
+	binary.BigEndian.PutUint16(b[8:], m.Flags)
+	return append(b, list.Bytes()...), nil
+}
+
+// unmarshalDescriptor decodes the value v of a descriptor, v starts after
+// the type and length bytes.
+func unmarshalDescriptor(t uint8, v []byte) (interface{}, error) {
+	short := func(l int) bool { return len(v) < l }
+	switch t {
+	case DescPriority:
+		if short(2) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return &PriorityDescriptor{Priority: v[1]}, nil
+	case DescMAC:
+		if short(6) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		d := &MACDescriptor{}
+		copy(d.MAC[:], v)
+		return d, nil
+	case DescFCMAP:
+		if short(6) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		d := &FCMAPDescriptor{}
+		copy(d.FCMAP[:], v[3:])
+		return d, nil
+	case DescName:
+		if short(10) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		d := &NameDescriptor{}
+		copy(d.Name[:], v[2:])
+		return d, nil
+	case DescFabric:
+		if short(14) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		d := &FabricDescriptor{VFID: binary.BigEndian.Uint16(v)}
+		copy(d.FCMAP[:], v[3:6])
+		copy(d.FabricName[:], v[6:])
+		return d, nil
+	case DescMaxFCoESize:
+		if short(2) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return &MaxFCoESizeDescriptor{Size: binary.BigEndian.Uint16(v)}, nil
+	case DescFLOGI, DescFDISC, DescLOGO, DescELP:
+		if short(2) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		f := &fc.Frame{}
+		if _, err := f.ReadFrom(bytes.NewReader(v[2:])); err != nil {
+			return nil, err
+		}
+		return &ELSDescriptor{Type: t, Frame: f}, nil
+	case DescVNPortID:
+		if short(18) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		d := &VNPortIDDescriptor{}
+		copy(d.MAC[:], v)
+		copy(d.PortID[:], v[7:10])
+		copy(d.PortName[:], v[10:])
+		return d, nil
+	case DescFKAAdvPeriod:
+		if short(6) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return &FKAAdvPeriodDescriptor{
+			Flags:  v[1],
+			Period: binary.BigEndian.Uint32(v[2:]),
+		}, nil
+	case DescVendorID:
+		if short(10) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		d := &VendorIDDescriptor{}
+		copy(d.Vendor[:], v[2:])
+		if len(v) > 10 {
+			d.Data = append([]byte{}, v[10:]...)
+		}
+		return d, nil
+	case DescVLAN:
+		if short(2) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return &VLANDescriptor{VLAN: binary.BigEndian.Uint16(v) & 0x0fff}, nil
+	default:
+		return &RawDescriptor{Type: t, Data: append([]byte{}, v...)}, nil
+	}
+}
+
+// marshalDescriptor returns the type and value of a descriptor, the value
+// is padded to a word by the caller.
+func marshalDescriptor(d interface{}) (uint8, []byte, error) {
+	switch d := d.(type) {
+	case *PriorityDescriptor:
+		return DescPriority, []byte{0, d.Priority}, nil
+	case *MACDescriptor:
+		return DescMAC, d.MAC[:], nil
+	case *FCMAPDescriptor:
+		return DescFCMAP, append([]byte{0, 0, 0}, d.FCMAP[:]...), nil
+	case *NameDescriptor:
+		return DescName, append([]byte{0, 0}, d.Name[:]...), nil
+	case *FabricDescriptor:
+		v := make([]byte, 6, 14)
+		binary.BigEndian.PutUint16(v, d.VFID)
+		copy(v[3:], d.FCMAP[:])
+		return DescFabric, append(v, d.FabricName[:]...), nil
+	case *MaxFCoESizeDescriptor:
+		v := make([]byte, 2)
+		binary.BigEndian.PutUint16(v, d.Size)
+		return DescMaxFCoESize, v, nil
+	case *ELSDescriptor:
+		if d.Frame == nil {
+			return 0, nil, fmt.Errorf("ELS descriptor %d without frame", d.Type)
+		}
+		buf := bytes.NewBuffer([]byte{0, 0})
+		if _, err := d.Frame.WriteTo(buf); err != nil {
+			return 0, nil, err
+		}
+		return d.Type, buf.Bytes(), nil
+	case *VNPortIDDescriptor:
+		v := make([]byte, 10, 18)
+		copy(v, d.MAC[:])
+		copy(v[7:], d.PortID[:])
+		return DescVNPortID, append(v, d.PortName[:]...), nil
+	case *FKAAdvPeriodDescriptor:
+		v := make([]byte, 6)
+		v[1] = d.Flags
+		binary.BigEndian.PutUint32(v[2:], d.Period)
+		return DescFKAAdvPeriod, v, nil
+	case *VendorIDDescriptor:
+		v := append([]byte{0, 0}, d.Vendor[:]...)
+		return DescVendorID, append(v, d.Data...), nil
+	case *VLANDescriptor:
+		v := make([]byte, 2)
+		binary.BigEndian.PutUint16(v, d.VLAN&0x0fff)
+		return DescVLAN, v, nil
+	case *RawDescriptor:
+		return d.Type, d.Data, nil
+	default:
+		return 0, nil, fmt.Errorf("Unsupported descriptor %v", d)
+	}
+}
@@ -0,0 +1,219 @@
+package fip
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/els"
+)
+
+var (
+	enodeMAC = [6]byte{0x00, 0x1b, 0x21, 0x12, 0x34, 0x56}
+	fcfMAC   = [6]byte{0x00, 0x05, 0x73, 0xab, 0xcd, 0xef}
+	nodeName = common.WWN{0x20, 0x00, 0x00, 0x1b, 0x21, 0x12, 0x34, 0x56}
+	portName = common.WWN{0x21, 0x00, 0x00, 0x1b, 0x21, 0x12, 0x34, 0x56}
+	fcMAP    = [3]byte{0x0e, 0xfc, 0x00}
+)
+
+func TestMessageUnmarshalBinary(t *testing.T) {
+	var tests = []struct {
+		desc string
+		b    []byte
+		m    *Message
+		err  error
+	}{
+		{
+			desc: "nil buffer",
+			err:  io.ErrUnexpectedEOF,
+		},
+		{
+			desc: "descriptor list exceeds message",
+			b:    []byte{0x10, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x02, 0x80, 0x00, 0x02, 0x02},
+			err:  errShortDescriptor,
+		},
+		{
+			desc: "zero length descriptor",
+			b:    []byte{0x10, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x01, 0x80, 0x00, 0x01, 0x00, 0x00, 0x00},
+			err:  errZeroLength,
+		},
+		{
+			desc: "solicitation with Ethernet padding",
+			b: []byte{
+				0x10, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x06, 0xc0, 0x00,
+				0x02, 0x02, 0x00, 0x1b, 0x21, 0x12, 0x34, 0x56,
+				0x04, 0x03, 0x00, 0x00, 0x20, 0x00, 0x00, 0x1b, 0x21, 0x12, 0x34, 0x56,
+				0x06, 0x01, 0x08, 0x76,
+				0x00, 0x00, 0x00, 0x00,
+			},
+			m: &Message{
+				Version: Version,
+				Op:      OpDiscovery,
+				Subcode: SubcodeSolicitation,
+				Flags:   FlagFPMA | FlagSPMA,
+				Descriptors: []interface{}{
+					&MACDescriptor{MAC: enodeMAC},
+					&NameDescriptor{Name: nodeName},
+					&MaxFCoESizeDescriptor{Size: 2166},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			m := &Message{}
+			err := m.UnmarshalBinary(tt.b)
+			if want, got := tt.err, err; want != got {
+				t.Fatalf("unexpected error: %v != %v", want, got)
+			}
+			if err != nil {
+				return
+			}
+			if want, got := tt.m, m; !reflect.DeepEqual(want, got) {
+				t.Fatalf("unexpected Message:\n- want: %+v\n-  got: %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	flogi := &fc.Frame{
+		RCtl:          0x22,
+		DestinationID: common.FPortController,
+		CsctlPriority: &fc.CSCtl{},
+		FCtl:          fc.FrameControl{TODO1: 10, TODO2: 0x10000},
+		OXID:          0x1234,
+		RXID:          0xffff,
+		Payload:       &els.Frame{Payload: &els.FLOGI{PortName: portName, NodeName: nodeName}},
+	}
+	var tests = []struct {
+		desc string
+		m    *Message
+	}{
+		{
+			desc: "advertisement",
+			m: &Message{
+				Version: Version,
+				Op:      OpDiscovery,
+				Subcode: SubcodeAdvertisement,
+				Flags:   FlagFPMA | FlagAvailable | FlagSolicited | FlagFPort,
+				Descriptors: []interface{}{
+					&PriorityDescriptor{Priority: 128},
+					&MACDescriptor{MAC: fcfMAC},
+					&NameDescriptor{Name: common.WWN{0x20, 0x01, 0x00, 0x05, 0x73, 0xab, 0xcd, 0xef}},
+					&FabricDescriptor{VFID: 1, FCMAP: fcMAP, FabricName: common.WWN{0x20, 0x01, 0x00, 0x05, 0x73, 0x00, 0x00, 0x01}},
+					&FKAAdvPeriodDescriptor{Period: 8000},
+				},
+			},
+		},
+		{
+			desc: "FLOGI request",
+			m: &Message{
+				Version: Version,
+				Op:      OpLinkService,
+				Subcode: SubcodeRequest,
+				Flags:   FlagFPMA,
+				Descriptors: []interface{}{
+					&ELSDescriptor{Type: DescFLOGI, Frame: flogi},
+					&MACDescriptor{MAC: enodeMAC},
+				},
+			},
+		},
+		{
+			desc: "keep alive",
+			m: &Message{
+				Version: Version,
+				Op:      OpControl,
+				Subcode: SubcodeKeepAlive,
+				Descriptors: []interface{}{
+					&MACDescriptor{MAC: enodeMAC},
+				},
+			},
+		},
+		{
+			desc: "clear virtual links",
+			m: &Message{
+				Version: Version,
+				Op:      OpControl,
+				Subcode: SubcodeClearLinks,
+				Descriptors: []interface{}{
+					&MACDescriptor{MAC: fcfMAC},
+					&NameDescriptor{Name: nodeName},
+					&VNPortIDDescriptor{
+						MAC:      [6]byte{0x0e, 0xfc, 0x00, 0x01, 0x02, 0x03},
+						PortID:   common.FCID{0x01, 0x02, 0x03},
+						PortName: portName,
+					},
+				},
+			},
+		},
+		{
+			desc: "VLAN request",
+			m: &Message{
+				Version: Version,
+				Op:      OpVLAN,
+				Subcode: SubcodeVLANRequest,
+				Descriptors: []interface{}{
+					&MACDescriptor{MAC: enodeMAC},
+					&NameDescriptor{Name: nodeName},
+				},
+			},
+		},
+		{
+			desc: "VLAN notification",
+			m: &Message{
+				Version: Version,
+				Op:      OpVLAN,
+				Subcode: SubcodeVLANNotify,
+				Descriptors: []interface{}{
+					&MACDescriptor{MAC: fcfMAC},
+					&VLANDescriptor{VLAN: 1002},
+					&VLANDescriptor{VLAN: 1003},
+				},
+			},
+		},
+		{
+			desc: "vendor and unknown descriptors",
+			m: &Message{
+				Version: Version,
+				Op:      OpDiscovery,
+				Subcode: SubcodeAdvertisement,
+				Descriptors: []interface{}{
+					&VendorIDDescriptor{Vendor: [8]byte{'C', 'i', 's', 'c', 'o', 0, 0, 0}, Data: []byte{1, 2, 3, 4}},
+					&FCMAPDescriptor{FCMAP: fcMAP},
+					&RawDescriptor{Type: 0xf0, Data: []byte{0, 0, 0xde, 0xad, 0xbe, 0xef}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			b, err := tt.m.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary: %v", err)
+			}
+			if l := 4 * (int(b[6])<<8 | int(b[7])); l != len(b)-headerLength {
+				t.Fatalf("descriptor list length %d, wanted %d", l, len(b)-headerLength)
+			}
+			m := &Message{}
+			if err := m.UnmarshalBinary(b); err != nil {
+				t.Fatalf("UnmarshalBinary: %v", err)
+			}
+			if want, got := tt.m, m; !reflect.DeepEqual(want, got) {
+				t.Fatalf("unexpected Message:\n- want: %+v\n-  got: %+v", want, got)
+			}
+			b2, err := m.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary: %v", err)
+			}
+			if !bytes.Equal(b, b2) {
+				t.Fatalf("unexpected re-encoding:\n- want: %v\n-  got: %v", b, b2)
+			}
+		})
+	}
+}
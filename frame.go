@@ -0,0 +1,67 @@
+package fibrechannel
+
+import (
+	"io"
+
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+// XIDUnassigned is the OX_ID or RX_ID of an Exchange without one.
+const XIDUnassigned = 0xffff
+
+// NewFrame returns a frame from s to d carrying payload, with a CS_CTL and
+// the RX_ID unassigned.
+func NewFrame(s, d common.FCID, rctl uint8, payload interface{}) *Frame {
+	return &Frame{
+		RCtl:          rctl,
+		DestinationID: d,
+		CsctlPriority: &CSCtl{},
+		SourceID:      s,
+		RXID:          XIDUnassigned,
+		Payload:       payload,
+	}
+}
+
+// Reply returns the single frame reply to the request f, ending the
+// Exchange.
+func (f *Frame) Reply(rctl uint8, payload interface{}) *Frame {
+	r := NewFrame(f.DestinationID, f.SourceID, rctl, payload)
+	r.OXID = f.OXID
+	r.SeqID = f.SeqID + 1
+	r.FCtl.SetValue(FCtlReply)
+	return r
+}
+
+// ReadFrom decodes a frame, starting with any Extended_Headers. If
+// CRCTrailer is set the CRC following the frame is read and checked as
+// well.
+func (o *Frame) ReadFrom(r io.Reader) (int64, error) {
+	if o.CRCTrailer {
+		return o.readWithCRC(r)
+	}
+	r, ext, err := o.readExtendedHeaders(r)
+	if err != nil {
+		return ext, err
+	}
+	n, err := o.readFrom(r)
+	return ext + n, err
+}
+
+// WriteTo encodes a frame, starting with any Extended_Headers. If
+// CRCTrailer is set the CRC is appended.
+func (o *Frame) WriteTo(w io.Writer) (int64, error) {
+	if o.CRCTrailer {
+		return o.writeWithCRC(w)
+	}
+	ext, err := o.writeExtendedHeaders(w)
+	if err != nil {
+		return int64(ext), err
+	}
+	n, err := o.writeTo(w)
+	return int64(ext) + n, err
+}
+
+// Type returns the TYPE of the frame as last read or written.
+func (o *Frame) Type() Type {
+	return o.fcType
+}
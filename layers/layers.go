@@ -0,0 +1,234 @@
+// Package layers provides gopacket layers for Fibre Channel and FCoE.
+// Importing it registers FCoE and FIP with the gopacket Ethernet decoder, so
+// that gopacket.NewPacket on an Ethernet capture decodes Ethernet, Dot1Q,
+// FCoE, FibreChannel and the FC-4 layers.
+package layers
+
+import (
+	"bytes"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/els"
+	"github.com/bluecmd/fibrechannel/fcoe"
+	"github.com/bluecmd/fibrechannel/fip"
+)
+
+const (
+	EthernetTypeFCoE layers.EthernetType = fcoe.EtherType
+	EthernetTypeFIP  layers.EthernetType = fip.EtherType
+)
+
+var (
+	LayerTypeFCoE = gopacket.RegisterLayerType(1800, gopacket.LayerTypeMetadata{
+		Name: "FCoE", Decoder: gopacket.DecodeFunc(decodeFCoE)})
+	LayerTypeFIP = gopacket.RegisterLayerType(1801, gopacket.LayerTypeMetadata{
+		Name: "FIP", Decoder: gopacket.DecodeFunc(decodeFIP)})
+	LayerTypeFibreChannel = gopacket.RegisterLayerType(1802, gopacket.LayerTypeMetadata{
+		Name: "FibreChannel", Decoder: gopacket.DecodeFunc(decodeFibreChannel)})
+	LayerTypeELS = gopacket.RegisterLayerType(1803, gopacket.LayerTypeMetadata{
+		Name: "ELS", Decoder: gopacket.DecodeFunc(decodeELS)})
+	LayerTypeFCP = gopacket.RegisterLayerType(1804, gopacket.LayerTypeMetadata{
+		Name: "FCP", Decoder: gopacket.DecodeFunc(decodeFCP)})
+)
+
+func init() {
+	layers.EthernetTypeMetadata[EthernetTypeFCoE] = layers.EnumMetadata{
+		DecodeWith: LayerTypeFCoE, Name: "FCoE", LayerType: LayerTypeFCoE}
+	layers.EthernetTypeMetadata[EthernetTypeFIP] = layers.EnumMetadata{
+		DecodeWith: LayerTypeFIP, Name: "FIP", LayerType: LayerTypeFIP}
+}
+
+// FCoE is an FCoE PDU. Its payload is the encapsulated FC frame without the
+// CRC, which together with the SOF and EOF is kept in Frame.
+type FCoE struct {
+	layers.BaseLayer
+	Frame fcoe.Frame
+}
+
+func (l *FCoE) LayerType() gopacket.LayerType     { return LayerTypeFCoE }
+func (l *FCoE) CanDecode() gopacket.LayerClass    { return LayerTypeFCoE }
+func (l *FCoE) NextLayerType() gopacket.LayerType { return LayerTypeFibreChannel }
+
+func (l *FCoE) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if err := l.Frame.UnmarshalBinary(data); err != nil {
+		df.SetTruncated()
+		return err
+	}
+	l.BaseLayer = layers.BaseLayer{Contents: data[:14], Payload: l.Frame.Payload}
+	return nil
+}
+
+// SerializeTo encapsulates the bytes already in b, the FC CRC is computed
+// unless Frame.KeepCRC is set.
+func (l *FCoE) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	f := l.Frame
+	f.Payload = b.Bytes()
+	d, err := f.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return replace(b, d)
+}
+
+// FIP is a FCoE Initialization Protocol message.
+type FIP struct {
+	layers.BaseLayer
+	Message fip.Message
+}
+
+func (l *FIP) LayerType() gopacket.LayerType     { return LayerTypeFIP }
+func (l *FIP) CanDecode() gopacket.LayerClass    { return LayerTypeFIP }
+func (l *FIP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+func (l *FIP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if err := l.Message.UnmarshalBinary(data); err != nil {
+		df.SetTruncated()
+		return err
+	}
+	l.BaseLayer = layers.BaseLayer{Contents: data}
+	return nil
+}
+
+func (l *FIP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	d, err := l.Message.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return replace(b, d)
+}
+
+// FibreChannel is an FC frame. Frame holds the decoded frame including its
+// payload, the layer payload is the data field as is.
+type FibreChannel struct {
+	layers.BaseLayer
+	Frame fc.Frame
+}
+
+func (l *FibreChannel) LayerType() gopacket.LayerType  { return LayerTypeFibreChannel }
+func (l *FibreChannel) CanDecode() gopacket.LayerClass { return LayerTypeFibreChannel }
+
+func (l *FibreChannel) NextLayerType() gopacket.LayerType {
+	switch l.Frame.Type() {
+	case fc.TypeELS:
+		return LayerTypeELS
+	case fc.TypeFCP:
+		return LayerTypeFCP
+	default:
+		return gopacket.LayerTypePayload
+	}
+}
+
+func (l *FibreChannel) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if _, err := l.Frame.ReadFrom(bytes.NewReader(data)); err != nil {
+		df.SetTruncated()
+		return err
+	}
+	h := l.Frame.HeaderLength()
+	l.BaseLayer = layers.BaseLayer{Contents: data[:h], Payload: data[h:]}
+	return nil
+}
+
+// SerializeTo writes the whole frame including Frame.Payload, it replaces
+// any bytes already in b.
+func (l *FibreChannel) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	buf := new(bytes.Buffer)
+	if _, err := l.Frame.WriteTo(buf); err != nil {
+		return err
+	}
+	return replace(b, buf.Bytes())
+}
+
+// ELS is an Extended Link Service request or reply.
+type ELS struct {
+	layers.BaseLayer
+	Frame els.Frame
+}
+
+func (l *ELS) LayerType() gopacket.LayerType     { return LayerTypeELS }
+func (l *ELS) CanDecode() gopacket.LayerClass    { return LayerTypeELS }
+func (l *ELS) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+func (l *ELS) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if _, err := l.Frame.ReadFrom(bytes.NewReader(data)); err != nil {
+		df.SetTruncated()
+		return err
+	}
+	l.BaseLayer = layers.BaseLayer{Contents: data}
+	return nil
+}
+
+func (l *ELS) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	buf := new(bytes.Buffer)
+	if _, err := l.Frame.WriteTo(buf); err != nil {
+		return err
+	}
+	return replace(b, buf.Bytes())
+}
+
+// FCP is a SCSI over Fibre Channel information unit. The IU is not decoded,
+// which IU it is depends on the R_CTL of the FibreChannel layer.
+type FCP struct {
+	layers.BaseLayer
+}
+
+func (l *FCP) LayerType() gopacket.LayerType     { return LayerTypeFCP }
+func (l *FCP) CanDecode() gopacket.LayerClass    { return LayerTypeFCP }
+func (l *FCP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+func (l *FCP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	l.BaseLayer = layers.BaseLayer{Contents: data}
+	return nil
+}
+
+// replace sets the contents of b to d.
+func replace(b gopacket.SerializeBuffer, d []byte) error {
+	if err := b.Clear(); err != nil {
+		return err
+	}
+	p, err := b.PrependBytes(len(d))
+	if err != nil {
+		return err
+	}
+	copy(p, d)
+	return nil
+}
+
+type decodingLayer interface {
+	gopacket.DecodingLayer
+	gopacket.Layer
+}
+
+func decode(l decodingLayer, data []byte, p gopacket.PacketBuilder) error {
+	if err := l.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(l)
+	next := l.NextLayerType()
+	if next == gopacket.LayerTypeZero {
+		return nil
+	}
+	return p.NextDecoder(next)
+}
+
+func decodeFCoE(data []byte, p gopacket.PacketBuilder) error {
+	return decode(&FCoE{}, data, p)
+}
+
+func decodeFIP(data []byte, p gopacket.PacketBuilder) error {
+	return decode(&FIP{}, data, p)
+}
+
+func decodeFibreChannel(data []byte, p gopacket.PacketBuilder) error {
+	return decode(&FibreChannel{}, data, p)
+}
+
+func decodeELS(data []byte, p gopacket.PacketBuilder) error {
+	return decode(&ELS{}, data, p)
+}
+
+func decodeFCP(data []byte, p gopacket.PacketBuilder) error {
+	return decode(&FCP{}, data, p)
+}
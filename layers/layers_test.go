@@ -0,0 +1,149 @@
+package layers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/els"
+	"github.com/bluecmd/fibrechannel/fcoe"
+	"github.com/bluecmd/fibrechannel/fip"
+)
+
+var (
+	enodeMAC = net.HardwareAddr{0x00, 0x1b, 0x21, 0x12, 0x34, 0x56}
+	fcfMAC   = net.HardwareAddr{0x00, 0x05, 0x73, 0xab, 0xcd, 0xef}
+	portID   = common.FCID{0x01, 0x02, 0x03}
+)
+
+func serialize(t *testing.T, l ...gopacket.SerializableLayer) []byte {
+	t.Helper()
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, l...); err != nil {
+		t.Fatalf("SerializeLayers: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func layerTypes(p gopacket.Packet) []gopacket.LayerType {
+	var lt []gopacket.LayerType
+	for _, l := range p.Layers() {
+		lt = append(lt, l.LayerType())
+	}
+	return lt
+}
+
+func TestFCoE(t *testing.T) {
+	logo := &els.LOGO{
+		PortID:   portID,
+		PortName: common.WWN{0x10, 0x00, 0x00, 0x1b, 0x21, 0x12, 0x34, 0x56},
+	}
+	b := serialize(t,
+		&layers.Ethernet{
+			SrcMAC:       fcoe.FPMA(fcoe.DefaultFCMAP, portID),
+			DstMAC:       fcfMAC,
+			EthernetType: layers.EthernetTypeDot1Q,
+		},
+		&layers.Dot1Q{VLANIdentifier: 1002, Type: EthernetTypeFCoE},
+		&FCoE{Frame: fcoe.Frame{SOF: fc.SOFi3, EOF: fc.EOFt}},
+		&FibreChannel{Frame: fc.Frame{
+			RCtl:          0x22,
+			DestinationID: common.FPortController,
+			SourceID:      portID,
+			CsctlPriority: &fc.CSCtl{},
+			FCtl:          fc.FrameControl{TODO1: 10, TODO2: 0x10000},
+			OXID:          0x1234,
+			RXID:          0xffff,
+			Payload:       &els.Frame{Payload: logo},
+		}},
+	)
+
+	p := gopacket.NewPacket(b, layers.LayerTypeEthernet, gopacket.Default)
+	if err := p.ErrorLayer(); err != nil {
+		t.Fatalf("decode: %v", err.Error())
+	}
+	want := []gopacket.LayerType{
+		layers.LayerTypeEthernet, layers.LayerTypeDot1Q,
+		LayerTypeFCoE, LayerTypeFibreChannel, LayerTypeELS,
+	}
+	if got := layerTypes(p); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got layers %v, wanted %v", got, want)
+	}
+	f := p.Layer(LayerTypeFCoE).(*FCoE)
+	if err := f.Frame.VerifyCRC(); err != nil {
+		t.Errorf("VerifyCRC: %v", err)
+	}
+	if f.Frame.SOF != fc.SOFi3 || f.Frame.EOF != fc.EOFt {
+		t.Errorf("got SOF %v and EOF %v, wanted SOFi3 and EOFt", f.Frame.SOF, f.Frame.EOF)
+	}
+	if l := len(p.Layer(LayerTypeFibreChannel).LayerContents()); l != fc.FrameHeaderLength {
+		t.Errorf("got FC header of %d bytes, wanted %d", l, fc.FrameHeaderLength)
+	}
+	e := p.Layer(LayerTypeELS).(*ELS)
+	if got, ok := e.Frame.Payload.(*els.LOGO); !ok || !reflect.DeepEqual(got, logo) {
+		t.Fatalf("got ELS %+v, wanted %+v", e.Frame.Payload, logo)
+	}
+}
+
+func TestFCP(t *testing.T) {
+	// An FCP_CMND frame with a zeroed 32 byte IU
+	fr := make([]byte, fc.FrameHeaderLength+32)
+	fr[0] = 0x06
+	fr[8] = fc.TypeFCP
+	b := serialize(t,
+		&layers.Ethernet{SrcMAC: enodeMAC, DstMAC: fcfMAC, EthernetType: EthernetTypeFCoE},
+		&FCoE{Frame: fcoe.Frame{SOF: fc.SOFi3, EOF: fc.EOFt}},
+		gopacket.Payload(fr),
+	)
+
+	var (
+		eth layers.Ethernet
+		fco FCoE
+		fcl FibreChannel
+		fcp FCP
+	)
+	parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, &eth, &fco, &fcl, &fcp)
+	decoded := []gopacket.LayerType{}
+	if err := parser.DecodeLayers(b, &decoded); err != nil {
+		t.Fatalf("DecodeLayers: %v", err)
+	}
+	want := []gopacket.LayerType{layers.LayerTypeEthernet, LayerTypeFCoE, LayerTypeFibreChannel, LayerTypeFCP}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Fatalf("got layers %v, wanted %v", decoded, want)
+	}
+	if len(fcp.LayerContents()) != 32 {
+		t.Errorf("got FCP IU of %d bytes, wanted 32", len(fcp.LayerContents()))
+	}
+}
+
+func TestFIP(t *testing.T) {
+	m := fip.Message{
+		Version: fip.Version,
+		Op:      fip.OpVLAN,
+		Subcode: fip.SubcodeVLANRequest,
+		Descriptors: []interface{}{
+			&fip.MACDescriptor{MAC: [6]byte{0x00, 0x1b, 0x21, 0x12, 0x34, 0x56}},
+		},
+	}
+	b := serialize(t,
+		&layers.Ethernet{SrcMAC: enodeMAC, DstMAC: fcfMAC, EthernetType: EthernetTypeFIP},
+		&FIP{Message: m},
+	)
+	p := gopacket.NewPacket(b, layers.LayerTypeEthernet, gopacket.Default)
+	if err := p.ErrorLayer(); err != nil {
+		t.Fatalf("decode: %v", err.Error())
+	}
+	l, ok := p.Layer(LayerTypeFIP).(*FIP)
+	if !ok {
+		t.Fatalf("no FIP layer in %v", layerTypes(p))
+	}
+	if !reflect.DeepEqual(l.Message, m) {
+		t.Fatalf("got %+v, wanted %+v", l.Message, m)
+	}
+}
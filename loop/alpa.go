@@ -0,0 +1,52 @@
+package loop
+
+// alpas holds the 127 valid AL_PAs, the neutral disparity characters, by
+// loop ID. Loop ID 0 has the lowest priority, loop ID 126 is AL_PA 0x00 of
+// the FL_Port.
+var alpas = [Ports]uint8{
+	0xef, 0xe8, 0xe4, 0xe2, 0xe1, 0xe0, 0xdc, 0xda, 0xd9, 0xd6, 0xd5, 0xd4, 0xd3, 0xd2, 0xd1, 0xce,
+	0xcd, 0xcc, 0xcb, 0xca, 0xc9, 0xc7, 0xc6, 0xc5, 0xc3, 0xbc, 0xba, 0xb9, 0xb6, 0xb5, 0xb4, 0xb3,
+	0xb2, 0xb1, 0xae, 0xad, 0xac, 0xab, 0xaa, 0xa9, 0xa7, 0xa6, 0xa5, 0xa3, 0x9f, 0x9e, 0x9d, 0x9b,
+	0x98, 0x97, 0x90, 0x8f, 0x88, 0x84, 0x82, 0x81, 0x80, 0x7c, 0x7a, 0x79, 0x76, 0x75, 0x74, 0x73,
+	0x72, 0x71, 0x6e, 0x6d, 0x6c, 0x6b, 0x6a, 0x69, 0x67, 0x66, 0x65, 0x63, 0x5c, 0x5a, 0x59, 0x56,
+	0x55, 0x54, 0x53, 0x52, 0x51, 0x4e, 0x4d, 0x4c, 0x4b, 0x4a, 0x49, 0x47, 0x46, 0x45, 0x43, 0x3c,
+	0x3a, 0x39, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x2e, 0x2d, 0x2c, 0x2b, 0x2a, 0x29, 0x27, 0x26,
+	0x25, 0x23, 0x1f, 0x1e, 0x1d, 0x1b, 0x18, 0x17, 0x10, 0x0f, 0x08, 0x04, 0x02, 0x01, 0x00,
+}
+
+// loopIDs maps an AL_PA back to its loop ID, -1 for invalid AL_PAs
+var loopIDs [256]int
+
+func init() {
+	for i := range loopIDs {
+		loopIDs[i] = -1
+	}
+	for id, a := range alpas {
+		loopIDs[a] = id
+	}
+}
+
+// ALPA returns the AL_PA of a loop ID.
+func ALPA(loopID int) (uint8, bool) {
+	if loopID < 0 || loopID >= Ports {
+		return 0, false
+	}
+	return alpas[loopID], true
+}
+
+// LoopID returns the loop ID of an AL_PA.
+func LoopID(alpa uint8) (int, bool) {
+	id := loopIDs[alpa]
+	return id, id >= 0
+}
+
+// ValidALPA reports whether alpa is one of the 127 AL_PAs usable on a loop.
+func ValidALPA(alpa uint8) bool {
+	return loopIDs[alpa] >= 0
+}
+
+// bit returns the position of an AL_PA in the AL_PA bitmap. Position 0 is
+// the L_bit, followed by the AL_PAs in ascending order.
+func bit(alpa uint8) int {
+	return Ports - loopIDs[alpa]
+}
@@ -0,0 +1,237 @@
+// Package loop implements the FC-AL loop initialization frames and the
+// AL_PA addressing of an Arbitrated Loop. Loop initialization frames are
+// sent as ELS frames with command code 0x11, els decodes their payload as
+// Init.
+package loop
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+// Ports is the number of AL_PAs on a loop, including the FL_Port.
+const Ports = 127
+
+// Loop initialization sequences, the second byte of the payload.
+const (
+	LISM Sequence = 0x01 // Select Master
+	LIFA Sequence = 0x02 // Fabric Assigned AL_PA
+	LIPA Sequence = 0x03 // Previously Acquired AL_PA
+	LIHA Sequence = 0x04 // Hard Assigned AL_PA
+	LISA Sequence = 0x05 // Soft Assigned AL_PA
+	LIRP Sequence = 0x06 // Report Position
+	LILP Sequence = 0x07 // Loop Position
+)
+
+var (
+	errSequence   = errors.New("unknown loop initialization sequence")
+	errPositions  = errors.New("position map exceeds 127 AL_PAs")
+	errIncomplete = errors.New("loop initialization did not reach LISA")
+
+	sequenceNames = map[Sequence]string{
+		LISM: "LISM",
+		LIFA: "LIFA",
+		LIPA: "LIPA",
+		LIHA: "LIHA",
+		LISA: "LISA",
+		LIRP: "LIRP",
+		LILP: "LILP",
+	}
+)
+
+// Sequence identifies a loop initialization sequence.
+type Sequence uint8
+
+// Bitmap is the AL_PA bitmap carried by LIFA, LIPA, LIHA and LISA, with a
+// bit set for every AL_PA claimed.
+type Bitmap [16]byte
+
+// Init is the payload of a loop initialization frame, following the ELS
+// command code. PortName is only used by LISM, Bitmap by LIFA, LIPA, LIHA
+// and LISA, and Positions by LIRP and LILP, where it holds the AL_PAs in
+// loop order starting with the loop master.
+type Init struct {
+	Sequence  Sequence
+	PortName  common.WWN
+	Bitmap    Bitmap
+	Positions []uint8
+}
+
+func (s Sequence) String() string {
+	if n, ok := sequenceNames[s]; ok {
+		return n
+	}
+	return fmt.Sprintf("Sequence(0x%02x)", uint8(s))
+}
+
+// Has reports whether alpa is claimed.
+func (b *Bitmap) Has(alpa uint8) bool {
+	if !ValidALPA(alpa) {
+		return false
+	}
+	i := bit(alpa)
+	return b[i/8]&(0x80>>uint(i%8)) != 0
+}
+
+// Set claims alpa.
+func (b *Bitmap) Set(alpa uint8) {
+	if !ValidALPA(alpa) {
+		return
+	}
+	i := bit(alpa)
+	b[i/8] |= 0x80 >> uint(i%8)
+}
+
+// LBit reports whether the L_bit is set, which a port sets in LISA if it
+// could not acquire an AL_PA and is non-participating.
+func (b *Bitmap) LBit() bool {
+	return b[0]&0x80 != 0
+}
+
+// SetLBit sets the L_bit.
+func (b *Bitmap) SetLBit() {
+	b[0] |= 0x80
+}
+
+// ALPAs returns the AL_PAs claimed in ascending order, which is the order of
+// decreasing priority.
+func (b *Bitmap) ALPAs() []uint8 {
+	r := []uint8{}
+	for id := Ports - 1; id >= 0; id-- {
+		if a := alpas[id]; b.Has(a) {
+			r = append(r, a)
+		}
+	}
+	return r
+}
+
+// Claim claims the first free AL_PA, as done in LISA by a port without a
+// previous or hard assigned address. AL_PA 0x00 is left to the FL_Port.
+func (b *Bitmap) Claim() (uint8, bool) {
+	for id := Ports - 2; id >= 0; id-- {
+		if a := alpas[id]; !b.Has(a) {
+			b.Set(a)
+			return a, true
+		}
+	}
+	return 0, false
+}
+
+func (o *Init) ReadFrom(r io.Reader) (int64, error) {
+	var hdr [3]byte
+	n, err := io.ReadFull(r, hdr[:])
+	if err != nil {
+		return int64(n), err
+	}
+	o.Sequence = Sequence(hdr[0])
+	var m int
+	switch o.Sequence {
+	case LISM:
+		m, err = io.ReadFull(r, o.PortName[:])
+	case LIFA, LIPA, LIHA, LISA:
+		m, err = io.ReadFull(r, o.Bitmap[:])
+	case LIRP, LILP:
+		var pm [Ports + 1]byte
+		m, err = io.ReadFull(r, pm[:])
+		if err == nil {
+			if pm[0] > Ports {
+				return int64(n + m), errPositions
+			}
+			o.Positions = append([]uint8{}, pm[1:1+pm[0]]...)
+		}
+	default:
+		return int64(n), errSequence
+	}
+	return int64(n + m), err
+}
+
+func (o *Init) WriteTo(w io.Writer) (int64, error) {
+	b := []byte{byte(o.Sequence), 0, 0}
+	switch o.Sequence {
+	case LISM:
+		b = append(b, o.PortName[:]...)
+	case LIFA, LIPA, LIHA, LISA:
+		b = append(b, o.Bitmap[:]...)
+	case LIRP, LILP:
+		if len(o.Positions) > Ports {
+			return 0, errPositions
+		}
+		var pm [Ports + 1]byte
+		for i := range pm {
+			pm[i] = 0xff
+		}
+		pm[0] = byte(len(o.Positions))
+		copy(pm[1:], o.Positions)
+		b = append(b, pm[:]...)
+	default:
+		return 0, errSequence
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// Map is the outcome of a loop initialization. Fabric, Previous, Hard and
+// Soft hold the AL_PAs claimed in LIFA, LIPA, LIHA and LISA respectively.
+// Positions is the loop order as reported in LILP, or LIRP if the LILP was
+// not captured, and nil if the loop master did not map positions.
+type Map struct {
+	Master    common.WWN
+	Fabric    []uint8
+	Previous  []uint8
+	Hard      []uint8
+	Soft      []uint8
+	Positions []uint8
+	// NonParticipating is set if the L_bit of the LISA was set by a port
+	// which could not claim an AL_PA
+	NonParticipating bool
+}
+
+// Reconstruct returns the loop map from the loop initialization frames
+// captured during a LIP, in the order they were seen. Each sequence is
+// forwarded around the loop, the last frame of a sequence holds what all
+// ports claimed.
+func Reconstruct(frames []*Init) (*Map, error) {
+	last := map[Sequence]*Init{}
+	for _, f := range frames {
+		last[f.Sequence] = f
+	}
+	if last[LISA] == nil {
+		return nil, errIncomplete
+	}
+	m := &Map{}
+	if f := last[LISM]; f != nil {
+		m.Master = f.PortName
+	}
+	var prev Bitmap
+	for _, s := range []struct {
+		seq Sequence
+		r   *[]uint8
+	}{
+		{LIFA, &m.Fabric},
+		{LIPA, &m.Previous},
+		{LIHA, &m.Hard},
+		{LISA, &m.Soft},
+	} {
+		f := last[s.seq]
+		if f == nil {
+			continue
+		}
+		*s.r = []uint8{}
+		for _, a := range f.Bitmap.ALPAs() {
+			if !prev.Has(a) {
+				*s.r = append(*s.r, a)
+			}
+		}
+		prev = f.Bitmap
+	}
+	m.NonParticipating = last[LISA].Bitmap.LBit()
+	if f := last[LILP]; f != nil {
+		m.Positions = f.Positions
+	} else if f := last[LIRP]; f != nil {
+		m.Positions = f.Positions
+	}
+	return m, nil
+}
@@ -0,0 +1,167 @@
+package loop
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+func TestLoopID(t *testing.T) {
+	var tests = []struct {
+		id   int
+		alpa uint8
+	}{
+		{0, 0xef},
+		{1, 0xe8},
+		{125, 0x01},
+		{126, 0x00},
+	}
+	for _, tt := range tests {
+		if a, ok := ALPA(tt.id); !ok || a != tt.alpa {
+			t.Errorf("ALPA(%d) = 0x%02x, wanted 0x%02x", tt.id, a, tt.alpa)
+		}
+		if id, ok := LoopID(tt.alpa); !ok || id != tt.id {
+			t.Errorf("LoopID(0x%02x) = %d, wanted %d", tt.alpa, id, tt.id)
+		}
+	}
+	if _, ok := ALPA(Ports); ok {
+		t.Errorf("ALPA(%d) is valid", Ports)
+	}
+	if ValidALPA(0x03) || ValidALPA(0xf0) {
+		t.Errorf("invalid AL_PAs reported as valid")
+	}
+	seen := map[uint8]bool{}
+	for id := 0; id < Ports; id++ {
+		a, _ := ALPA(id)
+		if seen[a] {
+			t.Fatalf("AL_PA 0x%02x is duplicated", a)
+		}
+		seen[a] = true
+	}
+}
+
+func TestBitmap(t *testing.T) {
+	var b Bitmap
+	b.Set(0x00)
+	b.Set(0xef)
+	b.Set(0x03)
+	if want := (Bitmap{0x40, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01}); b != want {
+		t.Fatalf("got bitmap %x, wanted %x", b, want)
+	}
+	if a, ok := b.Claim(); !ok || a != 0x01 {
+		t.Fatalf("Claim got 0x%02x, wanted 0x01", a)
+	}
+	if got, want := b.ALPAs(), []uint8{0x00, 0x01, 0xef}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got AL_PAs %v, wanted %v", got, want)
+	}
+	if b.LBit() {
+		t.Fatalf("L_bit set")
+	}
+	b.SetLBit()
+	if !b.LBit() || !b.Has(0x00) {
+		t.Fatalf("SetLBit changed the AL_PAs")
+	}
+
+	var full Bitmap
+	for id := 0; id < Ports; id++ {
+		a, _ := ALPA(id)
+		full.Set(a)
+	}
+	if _, ok := full.Claim(); ok {
+		t.Fatalf("Claim succeeded on a full loop")
+	}
+}
+
+func TestInitRoundTrip(t *testing.T) {
+	var bm Bitmap
+	bm.Set(0x01)
+	var tests = []struct {
+		init *Init
+		len  int
+	}{
+		{&Init{Sequence: LISM, PortName: common.WWN{0x20, 0, 0, 0x11, 0x22, 0x33, 0x44, 0x55}}, 11},
+		{&Init{Sequence: LIHA, Bitmap: bm}, 19},
+		{&Init{Sequence: LILP, Positions: []uint8{0x01, 0xe8, 0x00}}, 131},
+	}
+	for _, tt := range tests {
+		t.Run(tt.init.Sequence.String(), func(t *testing.T) {
+			b := new(bytes.Buffer)
+			if _, err := tt.init.WriteTo(b); err != nil {
+				t.Fatalf("WriteTo: %v", err)
+			}
+			if b.Len() != tt.len {
+				t.Fatalf("got %d bytes, wanted %d", b.Len(), tt.len)
+			}
+			got := &Init{}
+			if _, err := got.ReadFrom(bytes.NewReader(b.Bytes())); err != nil {
+				t.Fatalf("ReadFrom: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.init) {
+				t.Fatalf("unexpected Init:\n- want: %+v\n-  got: %+v", tt.init, got)
+			}
+		})
+	}
+}
+
+func TestInitInvalid(t *testing.T) {
+	if _, err := (&Init{}).ReadFrom(bytes.NewReader([]byte{0x09, 0, 0})); err != errSequence {
+		t.Errorf("got unexpected error %v, wanted %v", err, errSequence)
+	}
+	if _, err := (&Init{}).ReadFrom(bytes.NewReader([]byte{byte(LISA), 0, 0, 0})); err != io.ErrUnexpectedEOF {
+		t.Errorf("got unexpected error %v, wanted %v", err, io.ErrUnexpectedEOF)
+	}
+	pm := make([]byte, 3+Ports+1)
+	pm[0] = byte(LIRP)
+	pm[3] = Ports + 1
+	if _, err := (&Init{}).ReadFrom(bytes.NewReader(pm)); err != errPositions {
+		t.Errorf("got unexpected error %v, wanted %v", err, errPositions)
+	}
+}
+
+func TestReconstruct(t *testing.T) {
+	master := common.WWN{0x20, 0, 0, 0x11, 0x22, 0x33, 0x44, 0x55}
+	var fa, pa, ha, sa Bitmap
+	fa.Set(0x00)
+	pa = fa
+	pa.Set(0xe8)
+	ha = pa
+	ha.Set(0x02)
+	sa = ha
+	sa.Claim()
+
+	// The master's LIHA comes back with the hard address of the other port
+	// added, only the last frame of each sequence counts
+	frames := []*Init{
+		{Sequence: LISM, PortName: common.WWN{0x21}},
+		{Sequence: LISM, PortName: master},
+		{Sequence: LIFA, Bitmap: fa},
+		{Sequence: LIPA, Bitmap: pa},
+		{Sequence: LIHA, Bitmap: pa},
+		{Sequence: LIHA, Bitmap: ha},
+		{Sequence: LISA, Bitmap: sa},
+		{Sequence: LIRP, Positions: []uint8{0x01, 0x00, 0x02}},
+		{Sequence: LILP, Positions: []uint8{0x01, 0x00, 0x02, 0xe8}},
+	}
+	got, err := Reconstruct(frames)
+	if err != nil {
+		t.Fatalf("Reconstruct: %v", err)
+	}
+	want := &Map{
+		Master:    master,
+		Fabric:    []uint8{0x00},
+		Previous:  []uint8{0xe8},
+		Hard:      []uint8{0x02},
+		Soft:      []uint8{0x01},
+		Positions: []uint8{0x01, 0x00, 0x02, 0xe8},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected Map:\n- want: %+v\n-  got: %+v", want, got)
+	}
+
+	if _, err := Reconstruct(frames[:6]); err != errIncomplete {
+		t.Fatalf("got unexpected error %v, wanted %v", err, errIncomplete)
+	}
+}
@@ -0,0 +1,145 @@
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	StatusTypeGeneric         = 0x0 // Generic Command Status
+	StatusTypeCommandSpecific = 0x1 // Command Specific Status
+	StatusTypeMediaError      = 0x2 // Media and Data Integrity Errors
+	StatusTypePath            = 0x3 // Path Related Status
+	StatusTypeVendorSpecific  = 0x7 // Vendor Specific
+)
+
+// CQE is an NVMe Completion Queue Entry as carried in the ERSP IU. Unlike
+// the rest of Fibre Channel its fields are little-endian.
+type CQE [16]byte
+
+// Status is the Status Field of a completion, without the Phase Tag.
+type Status uint16
+
+type statusKey struct {
+	sct uint8
+	sc  uint8
+}
+
+var (
+	statusNames = map[statusKey]string{
+		{StatusTypeGeneric, 0x00}: "Success",
+		{StatusTypeGeneric, 0x01}: "Invalid Command Opcode",
+		{StatusTypeGeneric, 0x02}: "Invalid Field in Command",
+		{StatusTypeGeneric, 0x03}: "Command ID Conflict",
+		{StatusTypeGeneric, 0x04}: "Data Transfer Error",
+		{StatusTypeGeneric, 0x05}: "Commands Aborted due to Power Loss Notification",
+		{StatusTypeGeneric, 0x06}: "Internal Error",
+		{StatusTypeGeneric, 0x07}: "Command Abort Requested",
+		{StatusTypeGeneric, 0x08}: "Command Aborted due to SQ Deletion",
+		{StatusTypeGeneric, 0x09}: "Command Aborted due to Failed Fused Command",
+		{StatusTypeGeneric, 0x0a}: "Command Aborted due to Missing Fused Command",
+		{StatusTypeGeneric, 0x0b}: "Invalid Namespace or Format",
+		{StatusTypeGeneric, 0x0c}: "Command Sequence Error",
+		{StatusTypeGeneric, 0x0d}: "Invalid SGL Segment Descriptor",
+		{StatusTypeGeneric, 0x0e}: "Invalid Number of SGL Descriptors",
+		{StatusTypeGeneric, 0x0f}: "Data SGL Length Invalid",
+		{StatusTypeGeneric, 0x10}: "Metadata SGL Length Invalid",
+		{StatusTypeGeneric, 0x11}: "SGL Descriptor Type Invalid",
+		{StatusTypeGeneric, 0x16}: "SGL Offset Invalid",
+		{StatusTypeGeneric, 0x80}: "LBA Out of Range",
+		{StatusTypeGeneric, 0x81}: "Capacity Exceeded",
+		{StatusTypeGeneric, 0x82}: "Namespace Not Ready",
+		{StatusTypeGeneric, 0x83}: "Reservation Conflict",
+
+		{StatusTypeCommandSpecific, 0x01}: "Invalid Completion Queue",
+		{StatusTypeCommandSpecific, 0x02}: "Invalid Queue Identifier",
+		{StatusTypeCommandSpecific, 0x0d}: "Feature Identifier Not Saveable",
+		{StatusTypeCommandSpecific, 0x0e}: "Feature Not Changeable",
+		{StatusTypeCommandSpecific, 0x0f}: "Feature Not Namespace Specific",
+
+		{StatusTypeMediaError, 0x80}: "Write Fault",
+		{StatusTypeMediaError, 0x81}: "Unrecovered Read Error",
+		{StatusTypeMediaError, 0x82}: "End-to-end Guard Check Error",
+		{StatusTypeMediaError, 0x83}: "End-to-end Application Tag Check Error",
+		{StatusTypeMediaError, 0x84}: "End-to-end Reference Tag Check Error",
+		{StatusTypeMediaError, 0x85}: "Compare Failure",
+		{StatusTypeMediaError, 0x86}: "Access Denied",
+		{StatusTypeMediaError, 0x87}: "Deallocated or Unwritten Logical Block",
+
+		{StatusTypePath, 0x00}: "Internal Path Error",
+		{StatusTypePath, 0x01}: "Asymmetric Access Persistent Loss",
+		{StatusTypePath, 0x02}: "Asymmetric Access Inaccessible",
+		{StatusTypePath, 0x03}: "Asymmetric Access Transition",
+		{StatusTypePath, 0x60}: "Controller Pathing Error",
+		{StatusTypePath, 0x70}: "Host Pathing Error",
+		{StatusTypePath, 0x71}: "Command Aborted By Host",
+	}
+	// Command specific status codes of the Fabrics Connect command.
+	connectStatusNames = map[uint8]string{
+		0x80: "Incompatible Format",
+		0x81: "Controller Busy",
+		0x82: "Connect Invalid Parameters",
+		0x83: "Connect Restart Discovery",
+		0x84: "Connect Invalid Host",
+	}
+)
+
+// Result is Dword 0 of the completion, its meaning is command specific.
+func (c *CQE) Result() uint32 {
+	return binary.LittleEndian.Uint32(c[0:])
+}
+
+func (c *CQE) SQHead() uint16 {
+	return binary.LittleEndian.Uint16(c[8:])
+}
+
+func (c *CQE) SQID() uint16 {
+	return binary.LittleEndian.Uint16(c[10:])
+}
+
+func (c *CQE) CommandID() uint16 {
+	return binary.LittleEndian.Uint16(c[12:])
+}
+
+func (c *CQE) Phase() bool {
+	return c[14]&0x1 != 0
+}
+
+func (c *CQE) Status() Status {
+	return Status(binary.LittleEndian.Uint16(c[14:]) >> 1)
+}
+
+// Type is the Status Code Type (SCT).
+func (s Status) Type() uint8 {
+	return uint8(s>>8) & 0x7
+}
+
+// Code is the Status Code (SC), interpreted according to Type.
+func (s Status) Code() uint8 {
+	return uint8(s)
+}
+
+// More is set if more status information is available in the Error
+// Information log page.
+func (s Status) More() bool {
+	return s&0x2000 != 0
+}
+
+// DoNotRetry is set if the command is expected to fail if resubmitted.
+func (s Status) DoNotRetry() bool {
+	return s&0x4000 != 0
+}
+
+func (s Status) Success() bool {
+	return s.Type() == StatusTypeGeneric && s.Code() == 0
+}
+
+func (s Status) String() string {
+	if n, ok := statusNames[statusKey{s.Type(), s.Code()}]; ok {
+		return n
+	}
+	if s.Type() == StatusTypeVendorSpecific {
+		return fmt.Sprintf("Vendor Specific <0x%x>", s.Code())
+	}
+	return fmt.Sprintf("--Unknown Status-- <sct=%d sc=0x%x>", s.Type(), s.Code())
+}
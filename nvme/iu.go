@@ -0,0 +1,105 @@
+package nvme
+
+import (
+	"io"
+
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+const (
+	SCSIID = 0xFD // SCSI_ID of every FC-NVMe CMND IU
+	FCID   = 0x28 // FC_ID of every FC-NVMe CMND IU
+
+	CmndIULength    = 96
+	ERSPIULength    = 32
+	XferRdyIULength = 12
+
+	FlagWrite = 0x01 // CMND IU: data is transferred to the controller
+	FlagRead  = 0x02 // CMND IU: data is transferred to the host
+)
+
+// CmndIU carries an NVMe Submission Queue Entry from the host to the
+// controller. IULength is in words, it is filled in if zero.
+type CmndIU struct {
+	SCSIID                uint8  `fc:"@0"`
+	FCID                  uint8  `fc:"@1"`
+	IULength              uint16 `fc:"@2"`
+	Category              uint8  `fc:"@6"`
+	Flags                 uint8  `fc:"@7"`
+	ConnectionID          uint64 `fc:"@8"`
+	CommandSequenceNumber uint32 `fc:"@16"`
+	DataLength            uint32 `fc:"@20"`
+	SQE                   SQE    `fc:"@24"`
+}
+
+// ERSPIU is the Extended Response IU, it carries the NVMe Completion Queue
+// Entry back to the host. IULength is in words, it is filled in if zero.
+type ERSPIU struct {
+	Status                 uint8  `fc:"@0"`
+	IULength               uint16 `fc:"@2"`
+	ResponseSequenceNumber uint32 `fc:"@4"`
+	TransferredLength      uint32 `fc:"@8"`
+	CQE                    CQE    `fc:"@16"`
+}
+
+// XferRdyIU is sent by the controller when it is ready to receive the next
+// burst of write data.
+type XferRdyIU struct {
+	RelativeOffset uint32 `fc:"@0"`
+	BurstLength    uint32 `fc:"@4"`
+}
+
+func (s *CmndIU) ReadFrom(r io.Reader) (int64, error) {
+	return readPadded(r, s, CmndIULength)
+}
+
+func (s *CmndIU) WriteTo(w io.Writer) (int64, error) {
+	o := *s
+	if o.IULength == 0 {
+		o.IULength = CmndIULength / 4
+	}
+	return writePadded(w, &o, CmndIULength)
+}
+
+func (s *ERSPIU) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *ERSPIU) WriteTo(w io.Writer) (int64, error) {
+	o := *s
+	if o.IULength == 0 {
+		o.IULength = ERSPIULength / 4
+	}
+	return encoding.WriteTo(w, &o)
+}
+
+func (s *XferRdyIU) ReadFrom(r io.Reader) (int64, error) {
+	return readPadded(r, s, XferRdyIULength)
+}
+
+func (s *XferRdyIU) WriteTo(w io.Writer) (int64, error) {
+	return writePadded(w, s, XferRdyIULength)
+}
+
+// readPadded reads an IU ending in reserved bytes, which are skipped.
+func readPadded(r io.Reader, s interface{}, l int64) (int64, error) {
+	n, err := encoding.ReadFrom(r, s)
+	if err != nil {
+		return n, err
+	}
+	m, err := io.CopyN(io.Discard, r, l-n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n + m, err
+}
+
+// writePadded writes an IU ending in reserved bytes, which are zeroed.
+func writePadded(w io.Writer, s interface{}, l int64) (int64, error) {
+	n, err := encoding.WriteTo(w, s)
+	if err != nil {
+		return n, err
+	}
+	m, err := w.Write(make([]byte, l-n))
+	return n + int64(m), err
+}
@@ -0,0 +1,363 @@
+package nvme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type LSCommand uint8
+
+type RejectReason uint8
+
+type RejectExplanation uint8
+
+const (
+	LSCmdRJT                   = 0x01 // Link Service Reject
+	LSCmdACC                   = 0x02 // Link Service Accept
+	LSCmdCreateAssociation     = 0x03 // Create Association
+	LSCmdCreateConnection      = 0x04 // Create I/O Connection
+	LSCmdDisconnectAssociation = 0x05 // Disconnect Association
+	LSCmdDisconnectConnection  = 0x06 // Disconnect I/O Connection
+)
+
+const (
+	DescRequest           = 0x01 // Link Service Request Information
+	DescReject            = 0x02 // Reject reason
+	DescCreateAssociation = 0x03 // Create Association command
+	DescCreateConnection  = 0x04 // Create I/O Connection command
+	DescDisconnect        = 0x05 // Disconnect command
+	DescConnectionID      = 0x06 // Connection Identifier
+	DescAssociationID     = 0x07 // Association Identifier
+)
+
+const (
+	RejectInvalidCommand        = 0x01 // Invalid LS command code
+	RejectLogicalError          = 0x03 // Logical error
+	RejectUnableToPerform       = 0x09 // Unable to perform command request
+	RejectCommandNotSupported   = 0x0b // Command not supported
+	RejectInProgress            = 0x0e // Command already in progress
+	RejectInvalidAssociation    = 0x40 // Invalid Association_ID
+	RejectInvalidConnection     = 0x41 // Invalid Connection_ID
+	RejectInvalidParameters     = 0x42 // Invalid parameters
+	RejectInsufficientResources = 0x43 // Insufficient resources
+	RejectInvalidHost           = 0x44 // Invalid or rejected host
+	RejectVendorSpecific        = 0xff // Vendor specific error
+)
+
+const (
+	ExplNone               = 0x00 // No additional explanation
+	ExplInvalidOXIDRXID    = 0x17 // Invalid OX_ID-RX_ID combination
+	ExplUnableToSupplyData = 0x2a // Unable to supply requested data
+	ExplInvalidLength      = 0x2d // Invalid payload length
+	ExplInvalidERSPRatio   = 0x40 // Invalid ERSP_Ratio
+	ExplInvalidController  = 0x41 // Invalid controller ID
+	ExplInvalidQueueID     = 0x42 // Invalid queue ID
+	ExplInvalidSQSize      = 0x43 // Invalid submission queue size
+	ExplInvalidHostID      = 0x44 // Invalid or rejected host ID
+	ExplInvalidHostNQN     = 0x45 // Invalid or rejected host NQN
+	ExplInvalidSubsysNQN   = 0x46 // Invalid or rejected subsystem NQN
+)
+
+const (
+	createAssociationLength = 1016
+	createConnectionLength  = 48
+	nqnLength               = 256
+)
+
+var (
+	errShortDescriptor = errors.New("descriptor exceeds descriptor list")
+
+	lsCommandNames = map[LSCommand]string{
+		LSCmdRJT:                   "LS_RJT",
+		LSCmdACC:                   "LS_ACC",
+		LSCmdCreateAssociation:     "Create Association",
+		LSCmdCreateConnection:      "Create I/O Connection",
+		LSCmdDisconnectAssociation: "Disconnect Association",
+		LSCmdDisconnectConnection:  "Disconnect I/O Connection",
+	}
+	rejectNames = map[RejectReason]string{
+		RejectInvalidCommand:        "Invalid LS command code",
+		RejectLogicalError:          "Logical error",
+		RejectUnableToPerform:       "Unable to perform command request",
+		RejectCommandNotSupported:   "Command not supported",
+		RejectInProgress:            "Command already in progress",
+		RejectInvalidAssociation:    "Invalid Association_ID",
+		RejectInvalidConnection:     "Invalid Connection_ID",
+		RejectInvalidParameters:     "Invalid parameters",
+		RejectInsufficientResources: "Insufficient resources",
+		RejectInvalidHost:           "Invalid or rejected host",
+		RejectVendorSpecific:        "Vendor specific error",
+	}
+	explanationNames = map[RejectExplanation]string{
+		ExplNone:               "No additional explanation",
+		ExplInvalidOXIDRXID:    "Invalid OX_ID-RX_ID combination",
+		ExplUnableToSupplyData: "Unable to supply requested data",
+		ExplInvalidLength:      "Invalid payload length",
+		ExplInvalidERSPRatio:   "Invalid ERSP_Ratio",
+		ExplInvalidController:  "Invalid controller ID",
+		ExplInvalidQueueID:     "Invalid queue ID",
+		ExplInvalidSQSize:      "Invalid submission queue size",
+		ExplInvalidHostID:      "Invalid or rejected host ID",
+		ExplInvalidHostNQN:     "Invalid or rejected host NQN",
+		ExplInvalidSubsysNQN:   "Invalid or rejected subsystem NQN",
+	}
+)
+
+// LS is an FC-NVMe Link Service request or reply. Every FC-NVMe LS is a
+// command code followed by a list of descriptors, so requests, LS_ACC and
+// LS_RJT all share this type. Each descriptor is one of the *Descriptor,
+// *Cmd or *ID types in this package, or a *RawDescriptor.
+//
+// A Create Association request carries a CreateAssociationCmd, its LS_ACC a
+// RequestDescriptor, an AssociationID and a ConnectionID. A Create I/O
+// Connection request carries an AssociationID and a CreateConnectionCmd, its
+// LS_ACC a RequestDescriptor and a ConnectionID. A Disconnect request
+// carries an AssociationID and a DisconnectCmd. An LS_RJT carries a
+// RequestDescriptor and a RejectDescriptor.
+type LS struct {
+	Command     LSCommand
+	Descriptors []interface{}
+}
+
+// RequestDescriptor names the request an LS_ACC or LS_RJT answers.
+type RequestDescriptor struct {
+	Command LSCommand
+}
+
+// RejectDescriptor holds why a request was rejected.
+type RejectDescriptor struct {
+	Reason         RejectReason
+	Explanation    RejectExplanation
+	VendorSpecific uint8
+}
+
+// CreateAssociationCmd asks the controller to create an association and
+// its admin queue.
+type CreateAssociationCmd struct {
+	ERSPRatio    uint16
+	ControllerID uint16
+	SQSize       uint16
+	HostID       [16]byte
+	HostNQN      string
+	SubsystemNQN string
+}
+
+// CreateConnectionCmd asks the controller to create an I/O queue within an
+// existing association.
+type CreateConnectionCmd struct {
+	ERSPRatio uint16
+	QueueID   uint16
+	SQSize    uint16
+}
+
+// DisconnectCmd asks the controller to tear down the association named by
+// the accompanying AssociationID.
+type DisconnectCmd struct{}
+
+// ConnectionID identifies a queue, it is echoed in every CMND IU.
+type ConnectionID struct {
+	ID uint64
+}
+
+// AssociationID identifies an association between a host and a controller.
+type AssociationID struct {
+	ID uint64
+}
+
+// RawDescriptor is a descriptor of a tag this package does not know, kept
+// verbatim without its tag and length.
+type RawDescriptor struct {
+	Tag  uint32
+	Data []byte
+}
+
+func (o *LSCommand) String() string {
+	if n, ok := lsCommandNames[*o]; ok {
+		return fmt.Sprintf("%s <0x%x>", n, uint8(*o))
+	}
+	return fmt.Sprintf("--Invalid LS Command-- <0x%x>", uint8(*o))
+}
+
+func (o *RejectReason) String() string {
+	if n, ok := rejectNames[*o]; ok {
+		return fmt.Sprintf("%s <0x%x>", n, uint8(*o))
+	}
+	return fmt.Sprintf("--Invalid Reason Code-- <0x%x>", uint8(*o))
+}
+
+func (o *RejectExplanation) String() string {
+	if n, ok := explanationNames[*o]; ok {
+		return fmt.Sprintf("%s <0x%x>", n, uint8(*o))
+	}
+	return fmt.Sprintf("--Invalid Reason Explanation-- <0x%x>", uint8(*o))
+}
+
+func (s *LS) ReadFrom(r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	n := int64(len(b))
+	if len(b) < 8 {
+		return n, io.ErrUnexpectedEOF
+	}
+	s.Command = LSCommand(b[0])
+	l := int(binary.BigEndian.Uint32(b[4:]))
+	b = b[8:]
+	if len(b) < l {
+		return n, errShortDescriptor
+	}
+	b = b[:l]
+	s.Descriptors = []interface{}{}
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return n, errShortDescriptor
+		}
+		tag := binary.BigEndian.Uint32(b)
+		dl := int(binary.BigEndian.Uint32(b[4:]))
+		if len(b) < 8+dl {
+			return n, errShortDescriptor
+		}
+		d, err := unmarshalDescriptor(tag, b[8:8+dl])
+		if err != nil {
+			return n, err
+		}
+		s.Descriptors = append(s.Descriptors, d)
+		b = b[8+dl:]
+	}
+	return n, nil
+}
+
+func (s *LS) WriteTo(w io.Writer) (int64, error) {
+	list := new(bytes.Buffer)
+	for _, d := range s.Descriptors {
+		tag, body, err := marshalDescriptor(d)
+		if err != nil {
+			return 0, err
+		}
+		var hdr [8]byte
+		binary.BigEndian.PutUint32(hdr[0:], tag)
+		binary.BigEndian.PutUint32(hdr[4:], uint32(len(body)))
+		list.Write(hdr[:])
+		list.Write(body)
+	}
+	var hdr [8]byte
+	hdr[0] = uint8(s.Command)
+	binary.BigEndian.PutUint32(hdr[4:], uint32(list.Len()))
+	n, err := w.Write(hdr[:])
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := list.WriteTo(w)
+	return int64(n) + m, err
+}
+
+func unmarshalDescriptor(tag uint32, b []byte) (interface{}, error) {
+	switch tag {
+	case DescRequest:
+		if len(b) < 1 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return &RequestDescriptor{Command: LSCommand(b[0])}, nil
+	case DescReject:
+		if len(b) < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return &RejectDescriptor{
+			Reason:         RejectReason(b[1]),
+			Explanation:    RejectExplanation(b[2]),
+			VendorSpecific: b[3],
+		}, nil
+	case DescCreateAssociation:
+		if len(b) < createAssociationLength-nqnLength {
+			return nil, io.ErrUnexpectedEOF
+		}
+		c := &CreateAssociationCmd{
+			ERSPRatio:    binary.BigEndian.Uint16(b[0:]),
+			ControllerID: binary.BigEndian.Uint16(b[40:]),
+			SQSize:       binary.BigEndian.Uint16(b[42:]),
+			HostNQN:      nqn(b[64:320]),
+			SubsystemNQN: nqn(b[320:]),
+		}
+		copy(c.HostID[:], b[48:64])
+		return c, nil
+	case DescCreateConnection:
+		if len(b) < 44 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return &CreateConnectionCmd{
+			ERSPRatio: binary.BigEndian.Uint16(b[0:]),
+			QueueID:   binary.BigEndian.Uint16(b[40:]),
+			SQSize:    binary.BigEndian.Uint16(b[42:]),
+		}, nil
+	case DescDisconnect:
+		return &DisconnectCmd{}, nil
+	case DescConnectionID:
+		if len(b) < 8 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return &ConnectionID{ID: binary.BigEndian.Uint64(b)}, nil
+	case DescAssociationID:
+		if len(b) < 8 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return &AssociationID{ID: binary.BigEndian.Uint64(b)}, nil
+	default:
+		return &RawDescriptor{Tag: tag, Data: append([]byte{}, b...)}, nil
+	}
+}
+
+func marshalDescriptor(d interface{}) (uint32, []byte, error) {
+	switch d := d.(type) {
+	case *RequestDescriptor:
+		return DescRequest, []byte{uint8(d.Command), 0, 0, 0, 0, 0, 0, 0}, nil
+	case *RejectDescriptor:
+		return DescReject, []byte{0, uint8(d.Reason), uint8(d.Explanation), d.VendorSpecific, 0, 0, 0, 0}, nil
+	case *CreateAssociationCmd:
+		if len(d.HostNQN) >= nqnLength || len(d.SubsystemNQN) >= nqnLength {
+			return 0, nil, fmt.Errorf("NQN longer than %d bytes", nqnLength-1)
+		}
+		b := make([]byte, createAssociationLength)
+		binary.BigEndian.PutUint16(b[0:], d.ERSPRatio)
+		binary.BigEndian.PutUint16(b[40:], d.ControllerID)
+		binary.BigEndian.PutUint16(b[42:], d.SQSize)
+		copy(b[48:], d.HostID[:])
+		copy(b[64:], d.HostNQN)
+		copy(b[320:], d.SubsystemNQN)
+		return DescCreateAssociation, b, nil
+	case *CreateConnectionCmd:
+		b := make([]byte, createConnectionLength)
+		binary.BigEndian.PutUint16(b[0:], d.ERSPRatio)
+		binary.BigEndian.PutUint16(b[40:], d.QueueID)
+		binary.BigEndian.PutUint16(b[42:], d.SQSize)
+		return DescCreateConnection, b, nil
+	case *DisconnectCmd:
+		return DescDisconnect, make([]byte, 4), nil
+	case *ConnectionID:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, d.ID)
+		return DescConnectionID, b, nil
+	case *AssociationID:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, d.ID)
+		return DescAssociationID, b, nil
+	case *RawDescriptor:
+		return d.Tag, d.Data, nil
+	default:
+		return 0, nil, fmt.Errorf("Unsupported descriptor %v", d)
+	}
+}
+
+// nqn returns the NUL terminated NVMe Qualified Name in b.
+func nqn(b []byte) string {
+	if len(b) > nqnLength {
+		b = b[:nqnLength]
+	}
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
@@ -0,0 +1,67 @@
+// Package nvme decodes FC-NVMe, the mapping of NVMe over Fabrics onto Fibre
+// Channel. It covers the Information Units exchanged on an I/O queue and the
+// FC-NVMe Link Services used to create and tear down associations.
+package nvme
+
+import (
+	"bytes"
+	"io"
+)
+
+const (
+	RCtlSolicitedData  = 0x01 // Device_Data: solicited data
+	RCtlDataDescriptor = 0x05 // Device_Data: data descriptor (XFER_RDY IU)
+	RCtlCommand        = 0x06 // Device_Data: unsolicited command (CMND IU)
+	RCtlStatus         = 0x07 // Device_Data: command status (RSP or ERSP IU)
+	RCtlLSRequest      = 0x32 // FC-4 Link_Data: unsolicited control (LS request)
+	RCtlLSReply        = 0x33 // FC-4 Link_Data: solicited control (LS reply)
+)
+
+// Data is a payload this package does not decode, like solicited data or
+// the all-zero RSP IU. It is kept verbatim.
+type Data []byte
+
+func (d *Data) ReadFrom(r io.Reader) (int64, error) {
+	buf := new(bytes.Buffer)
+	n, err := buf.ReadFrom(r)
+	*d = buf.Bytes()
+	return n, err
+}
+
+func (d *Data) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(*d)
+	return int64(n), err
+}
+
+// ReadPayload decodes the payload of a TYPE 0x28 frame. The R_CTL of the
+// frame selects the Information Unit or Link Service carried.
+func ReadPayload(rctl uint8, r io.Reader) (interface{}, int64, error) {
+	var p interface {
+		io.ReaderFrom
+		io.WriterTo
+	}
+	switch rctl {
+	case RCtlCommand:
+		p = &CmndIU{}
+	case RCtlDataDescriptor:
+		p = &XferRdyIU{}
+	case RCtlStatus:
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, int64(len(b)), err
+		}
+		if len(b) < ERSPIULength {
+			d := Data(b)
+			return &d, int64(len(b)), nil
+		}
+		e := &ERSPIU{}
+		n, err := e.ReadFrom(bytes.NewReader(b))
+		return e, n, err
+	case RCtlLSRequest, RCtlLSReply:
+		p = &LS{}
+	default:
+		p = &Data{}
+	}
+	n, err := p.ReadFrom(r)
+	return p, n, err
+}
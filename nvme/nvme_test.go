@@ -0,0 +1,221 @@
+package nvme
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+func TestNilBuffer(t *testing.T) {
+	c := &LS{}
+	_, err := c.ReadFrom(bytes.NewReader([]byte{}))
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("got unexpected error %v, wanted io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestFrameFiles(t *testing.T) {
+	common.TestFrameFiles(t, func() common.SerDes { return &LS{} })
+}
+
+func TestReadPayload(t *testing.T) {
+	var tests = []struct {
+		desc string
+		rctl uint8
+		b    []byte
+		want interface{}
+	}{
+		{
+			desc: "ERSP IU",
+			rctl: RCtlStatus,
+			b: []byte{
+				0x00, 0x00, 0x00, 0x08,
+				0x00, 0x00, 0x00, 0x03,
+				0x00, 0x00, 0x10, 0x00,
+				0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x01, 0x00, 0x01, 0x00, 0x2a, 0x00, 0x00, 0x00,
+			},
+			want: &ERSPIU{
+				IULength:               8,
+				ResponseSequenceNumber: 3,
+				TransferredLength:      4096,
+				CQE:                    [16]byte{8: 0x01, 10: 0x01, 12: 0x2a},
+			},
+		},
+		{
+			desc: "RSP IU",
+			rctl: RCtlStatus,
+			b:    make([]byte, 12),
+			want: &Data{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			desc: "XFER_RDY IU",
+			rctl: RCtlDataDescriptor,
+			b: []byte{
+				0x00, 0x00, 0x20, 0x00,
+				0x00, 0x00, 0x10, 0x00,
+				0x00, 0x00, 0x00, 0x00,
+			},
+			want: &XferRdyIU{
+				RelativeOffset: 8192,
+				BurstLength:    4096,
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, n, err := ReadPayload(tc.rctl, bytes.NewReader(tc.b))
+			if err != nil {
+				t.Fatalf("ReadPayload: %v", err)
+			}
+			if n != int64(len(tc.b)) {
+				t.Errorf("read %d bytes, wanted %d", n, len(tc.b))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %+v, wanted %+v", got, tc.want)
+			}
+			buf := new(bytes.Buffer)
+			if _, err := got.(io.WriterTo).WriteTo(buf); err != nil {
+				t.Fatalf("WriteTo: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), tc.b) {
+				t.Fatalf("re-serialized to %v, wanted %v", buf.Bytes(), tc.b)
+			}
+		})
+	}
+}
+
+func TestShortCmndIU(t *testing.T) {
+	c := &CmndIU{}
+	_, err := c.ReadFrom(bytes.NewReader(make([]byte, CmndIULength-4)))
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("got unexpected error %v, wanted io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestWriteToUnchanged(t *testing.T) {
+	var tests = []struct {
+		o, want io.WriterTo
+		words   uint8
+	}{
+		{&CmndIU{}, &CmndIU{}, CmndIULength / 4},
+		{&ERSPIU{}, &ERSPIU{}, ERSPIULength / 4},
+	}
+	for _, tt := range tests {
+		b := new(bytes.Buffer)
+		if _, err := tt.o.WriteTo(b); err != nil {
+			t.Fatalf("WriteTo: %v", err)
+		}
+		if b.Bytes()[3] != tt.words {
+			t.Errorf("got IU_LENGTH %d for %T, wanted %d", b.Bytes()[3], tt.o, tt.words)
+		}
+		if !reflect.DeepEqual(tt.o, tt.want) {
+			t.Errorf("WriteTo changed %T to %+v", tt.o, tt.o)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	var tests = []struct {
+		desc  string
+		admin bool
+		sqe   SQE
+		cqe   *CQE
+		want  string
+	}{
+		{
+			desc: "write",
+			sqe: SQE{
+				0: OpWrite, 2: 0x07,
+				4:  0x01,
+				40: 0x00, 41: 0x08,
+				48: 0x07, 51: 0x40,
+			},
+			cqe:  &CQE{12: 0x07, 14: 0x01},
+			want: "Write nsid=1 slba=0x800 nlb=7 fua → Success",
+		},
+		{
+			desc: "read out of range",
+			sqe: SQE{
+				0: OpRead, 4: 0x02,
+				40: 0xff, 48: 0x0f,
+			},
+			cqe:  &CQE{14: 0x00, 15: 0x81},
+			want: "Read nsid=2 slba=0xff nlb=15 → LBA Out of Range",
+		},
+		{
+			desc:  "identify",
+			admin: true,
+			sqe:   SQE{0: OpIdentify, 40: 0x01},
+			want:  "Identify cns=0x01 nsid=0 cntid=0",
+		},
+		{
+			desc:  "set features",
+			admin: true,
+			sqe:   SQE{0: OpSetFeatures, 40: 0x07, 44: 0x03, 46: 0x03},
+			cqe:   &CQE{14: 0x1a, 15: 0x02},
+			want:  "Set Features fid=0x07 value=0x30003 → Feature Identifier Not Saveable",
+		},
+		{
+			desc:  "keep alive",
+			admin: true,
+			sqe:   SQE{0: OpKeepAlive},
+			cqe:   &CQE{},
+			want:  "Keep Alive → Success",
+		},
+		{
+			desc: "connect",
+			sqe: SQE{
+				0: OpFabrics, 4: FabricsConnect,
+				42: 0x01, 44: 0x7f,
+				48: 0x30, 49: 0x75,
+			},
+			cqe:  &CQE{14: 0x04, 15: 0x03},
+			want: "Connect qid=1 sqsize=127 kato=30000 → Connect Invalid Parameters",
+		},
+		{
+			desc: "property get",
+			sqe:  SQE{0: OpFabrics, 4: FabricsPropertyGet, 40: 0x01},
+			want: "Property Get offset=0x0",
+		},
+		{
+			desc: "property set",
+			sqe:  SQE{0: OpFabrics, 4: FabricsPropertySet, 44: 0x14, 48: 0x01, 50: 0x46},
+			cqe:  &CQE{},
+			want: "Property Set offset=0x14 value=0x460001 → Success",
+		},
+		{
+			desc: "unknown",
+			sqe:  SQE{0: 0x92, 4: 0x01},
+			want: "Opcode 0x92 nsid=1",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := tc.sqe.Summary(tc.admin, tc.cqe); got != tc.want {
+				t.Fatalf("got %q, wanted %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	c := &CQE{14: 0x0d, 15: 0xc5}
+	s := c.Status()
+	if !c.Phase() {
+		t.Errorf("phase tag not set")
+	}
+	if s.Type() != StatusTypeMediaError || s.Code() != 0x86 {
+		t.Errorf("got sct=%d sc=0x%x, wanted sct=2 sc=0x86", s.Type(), s.Code())
+	}
+	if !s.More() || !s.DoNotRetry() || s.Success() {
+		t.Errorf("unexpected flags in %v", s)
+	}
+	if got, want := s.String(), "Access Denied"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+}
@@ -0,0 +1,258 @@
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	OpFlush = 0x00 // I/O: Flush
+	OpWrite = 0x01 // I/O: Write
+	OpRead  = 0x02 // I/O: Read
+
+	OpIdentify    = 0x06 // Admin: Identify
+	OpSetFeatures = 0x09 // Admin: Set Features
+	OpGetFeatures = 0x0a // Admin: Get Features
+	OpKeepAlive   = 0x18 // Admin: Keep Alive
+
+	OpFabrics = 0x7f // Fabrics command, see FabricsType
+)
+
+const (
+	FabricsPropertySet = 0x00 // Property Set
+	FabricsConnect     = 0x01 // Connect
+	FabricsPropertyGet = 0x04 // Property Get
+)
+
+// SQE is an NVMe Submission Queue Entry as carried in the CMND IU. Unlike the
+// rest of Fibre Channel its fields are little-endian.
+type SQE [64]byte
+
+// ReadWriteCmd is a Read or Write command. NLB is the number of logical
+// blocks, it is a 0's based value.
+type ReadWriteCmd struct {
+	Opcode       uint8
+	NSID         uint32
+	SLBA         uint64
+	NLB          uint16
+	FUA          bool
+	LimitedRetry bool
+}
+
+// FlushCmd commits volatile data of a namespace to non-volatile media.
+type FlushCmd struct {
+	NSID uint32
+}
+
+// IdentifyCmd returns a data structure describing the controller or a
+// namespace, selected by CNS.
+type IdentifyCmd struct {
+	NSID         uint32
+	CNS          uint8
+	ControllerID uint16
+}
+
+// GetFeaturesCmd reads the attributes of a feature. Select picks the
+// current, default, saved or supported value.
+type GetFeaturesCmd struct {
+	NSID      uint32
+	FeatureID uint8
+	Select    uint8
+	Value     uint32
+}
+
+// SetFeaturesCmd sets the attributes of a feature, Value is Command Dword 11.
+type SetFeaturesCmd struct {
+	NSID      uint32
+	FeatureID uint8
+	Save      bool
+	Value     uint32
+}
+
+// KeepAliveCmd restarts the Keep Alive Timer of the controller.
+type KeepAliveCmd struct{}
+
+// ConnectCmd creates a queue on the controller. The host and subsystem
+// identities are sent as command data and are not part of the SQE.
+type ConnectCmd struct {
+	RecordFormat     uint16
+	QueueID          uint16
+	SQSize           uint16
+	Attributes       uint8
+	KeepAliveTimeout uint32
+}
+
+// PropertyGetCmd reads a controller property. Attributes bit 0 selects an
+// 8 byte rather than 4 byte property.
+type PropertyGetCmd struct {
+	Attributes uint8
+	Offset     uint32
+}
+
+// PropertySetCmd writes a controller property. Attributes bit 0 selects an
+// 8 byte rather than 4 byte property.
+type PropertySetCmd struct {
+	Attributes uint8
+	Offset     uint32
+	Value      uint64
+}
+
+func (s *SQE) Opcode() uint8 {
+	return s[0]
+}
+
+func (s *SQE) CommandID() uint16 {
+	return binary.LittleEndian.Uint16(s[2:])
+}
+
+func (s *SQE) NSID() uint32 {
+	return binary.LittleEndian.Uint32(s[4:])
+}
+
+// FabricsType is the command type of a Fabrics command.
+func (s *SQE) FabricsType() uint8 {
+	return s[4]
+}
+
+func (s *SQE) cdw(i int) uint32 {
+	return binary.LittleEndian.Uint32(s[4*i:])
+}
+
+// Decode returns the command in the SQE as one of the *Cmd types of this
+// package, or nil if the command is not known. Admin and I/O commands share
+// opcodes, admin selects which set to decode from.
+func (s *SQE) Decode(admin bool) interface{} {
+	if s.Opcode() == OpFabrics {
+		switch s.FabricsType() {
+		case FabricsPropertySet:
+			return &PropertySetCmd{
+				Attributes: s[40],
+				Offset:     s.cdw(11),
+				Value:      binary.LittleEndian.Uint64(s[48:]),
+			}
+		case FabricsConnect:
+			return &ConnectCmd{
+				RecordFormat:     binary.LittleEndian.Uint16(s[40:]),
+				QueueID:          binary.LittleEndian.Uint16(s[42:]),
+				SQSize:           binary.LittleEndian.Uint16(s[44:]),
+				Attributes:       s[46],
+				KeepAliveTimeout: s.cdw(12),
+			}
+		case FabricsPropertyGet:
+			return &PropertyGetCmd{
+				Attributes: s[40],
+				Offset:     s.cdw(11),
+			}
+		}
+		return nil
+	}
+	if admin {
+		switch s.Opcode() {
+		case OpIdentify:
+			return &IdentifyCmd{
+				NSID:         s.NSID(),
+				CNS:          s[40],
+				ControllerID: binary.LittleEndian.Uint16(s[42:]),
+			}
+		case OpGetFeatures:
+			return &GetFeaturesCmd{
+				NSID:      s.NSID(),
+				FeatureID: s[40],
+				Select:    s[41] & 0x7,
+				Value:     s.cdw(11),
+			}
+		case OpSetFeatures:
+			return &SetFeaturesCmd{
+				NSID:      s.NSID(),
+				FeatureID: s[40],
+				Save:      s[43]&0x80 != 0,
+				Value:     s.cdw(11),
+			}
+		case OpKeepAlive:
+			return &KeepAliveCmd{}
+		}
+		return nil
+	}
+	switch s.Opcode() {
+	case OpRead, OpWrite:
+		return &ReadWriteCmd{
+			Opcode:       s.Opcode(),
+			NSID:         s.NSID(),
+			SLBA:         binary.LittleEndian.Uint64(s[40:]),
+			NLB:          binary.LittleEndian.Uint16(s[48:]),
+			FUA:          s[51]&0x40 != 0,
+			LimitedRetry: s[51]&0x80 != 0,
+		}
+	case OpFlush:
+		return &FlushCmd{NSID: s.NSID()}
+	}
+	return nil
+}
+
+// Summary describes the command in the SQE and, if cqe is not nil, how it
+// completed, e.g. "Write nsid=1 slba=0x800 nlb=7 → Success".
+func (s *SQE) Summary(admin bool, cqe *CQE) string {
+	var d string
+	cmd := s.Decode(admin)
+	if c, ok := cmd.(fmt.Stringer); ok {
+		d = c.String()
+	} else if s.Opcode() == OpFabrics {
+		d = fmt.Sprintf("Fabrics fctype=0x%02x", s.FabricsType())
+	} else {
+		d = fmt.Sprintf("Opcode 0x%02x nsid=%d", s.Opcode(), s.NSID())
+	}
+	if cqe == nil {
+		return d
+	}
+	st := cqe.Status()
+	if _, ok := cmd.(*ConnectCmd); ok && st.Type() == StatusTypeCommandSpecific {
+		if n, ok := connectStatusNames[st.Code()]; ok {
+			return d + " → " + n
+		}
+	}
+	return d + " → " + st.String()
+}
+
+func (c *ReadWriteCmd) String() string {
+	op := "Read"
+	if c.Opcode == OpWrite {
+		op = "Write"
+	}
+	s := fmt.Sprintf("%s nsid=%d slba=0x%x nlb=%d", op, c.NSID, c.SLBA, c.NLB)
+	if c.FUA {
+		s += " fua"
+	}
+	return s
+}
+
+func (c *FlushCmd) String() string {
+	return fmt.Sprintf("Flush nsid=%d", c.NSID)
+}
+
+func (c *IdentifyCmd) String() string {
+	return fmt.Sprintf("Identify cns=0x%02x nsid=%d cntid=%d", c.CNS, c.NSID, c.ControllerID)
+}
+
+func (c *GetFeaturesCmd) String() string {
+	return fmt.Sprintf("Get Features fid=0x%02x sel=%d", c.FeatureID, c.Select)
+}
+
+func (c *SetFeaturesCmd) String() string {
+	return fmt.Sprintf("Set Features fid=0x%02x value=0x%x", c.FeatureID, c.Value)
+}
+
+func (c *KeepAliveCmd) String() string {
+	return "Keep Alive"
+}
+
+func (c *ConnectCmd) String() string {
+	return fmt.Sprintf("Connect qid=%d sqsize=%d kato=%d", c.QueueID, c.SQSize, c.KeepAliveTimeout)
+}
+
+func (c *PropertyGetCmd) String() string {
+	return fmt.Sprintf("Property Get offset=0x%x", c.Offset)
+}
+
+func (c *PropertySetCmd) String() string {
+	return fmt.Sprintf("Property Set offset=0x%x value=0x%x", c.Offset, c.Value)
+}
@@ -0,0 +1,75 @@
+package fibrechannel
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/fcsb"
+	"github.com/bluecmd/fibrechannel/nvme"
+)
+
+// readPayload decodes the payloads of the TYPEs without a case in the
+// generated ReadFrom. FC-NVMe and FC-SB payloads are told apart by R_CTL,
+// the payloads of other TYPEs are kept as []byte.
+func (o *Frame) readPayload(r io.Reader) (interface{}, int64, error) {
+	switch o.fcType {
+	case TypeNVME:
+		return nvme.ReadPayload(o.RCtl, r)
+	case TypeSBToCU, TypeSBFromCU:
+		i, n, err := fcsb.ReadPayload(o.RCtl, r)
+		if err != nil {
+			return i, n, err
+		}
+		switch p := i.(type) {
+		case *fcsb.IU:
+			p.FromControlUnit = o.fcType == TypeSBFromCU
+		case *fcsb.TransportDataIU:
+			p.FromControlUnit = o.fcType == TypeSBFromCU
+		}
+		return i, n, nil
+	}
+	b := new(bytes.Buffer)
+	n, err := b.ReadFrom(r)
+	return b.Bytes(), n, err
+}
+
+// switchPayload sets the TYPE for the payloads without a case in the
+// generated WriteTo. The TYPE of a []byte payload is left as is.
+func (o *Frame) switchPayload() {
+	switch i := o.Payload.(type) {
+	case *nvme.CmndIU, *nvme.ERSPIU, *nvme.XferRdyIU, *nvme.LS, *nvme.Data:
+		o.fcType = TypeNVME
+	case *fcsb.IU:
+		o.fcType = sbType(i.FromControlUnit)
+	case *fcsb.TransportDataIU:
+		o.fcType = sbType(i.FromControlUnit)
+	case *fcsb.TransportCommandIU:
+		o.fcType = TypeSBToCU
+	case *fcsb.TransportResponseIU:
+		o.fcType = TypeSBFromCU
+	}
+}
+
+// writePayload encodes the payloads without a case in the generated
+// WriteTo.
+func (o *Frame) writePayload(w io.Writer) error {
+	switch i := o.Payload.(type) {
+	case *nvme.CmndIU, *nvme.ERSPIU, *nvme.XferRdyIU, *nvme.LS, *nvme.Data,
+		*fcsb.IU, *fcsb.TransportCommandIU, *fcsb.TransportDataIU, *fcsb.TransportResponseIU:
+		_, err := i.(io.WriterTo).WriteTo(w)
+		return err
+	case []byte:
+		_, err := w.Write(i)
+		return err
+	}
+	return fmt.Errorf("Unsupported type %v", o.Payload)
+}
+
+// sbType returns the FC-SB TYPE of the direction of an IU.
+func sbType(fromCU bool) Type {
+	if fromCU {
+		return TypeSBFromCU
+	}
+	return TypeSBToCU
+}
@@ -0,0 +1,557 @@
+package port
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/ct"
+	"github.com/bluecmd/fibrechannel/els"
+)
+
+var (
+	errNoOXID = errors.New("no OX_ID available")
+)
+
+// RejectError is returned for a request answered with an LS_RJT or an
+// FS_RJT.
+type RejectError struct {
+	Reason      uint8
+	Explanation uint8
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("request rejected: reason 0x%02x, explanation 0x%02x", e.Reason, e.Explanation)
+}
+
+// Login is an N_Port login with another port. Types holds the FC-4 TYPEs
+// with an established image pair.
+type Login struct {
+	PortID   common.FCID
+	PortName common.WWN
+	NodeName common.WWN
+	Types    []uint8
+}
+
+// NPort is an N_Port implemented in software on top of a Transport. It
+// logs in with the fabric, registers with the name server and logs in with
+// other N_Ports, and it answers the ABTS and ELS requests it receives.
+// Only single frame Sequences are supported.
+//
+// Serve has to run for the requests to get their replies.
+type NPort struct {
+	PortName common.WWN
+	NodeName common.WWN
+	// Types are the FC-4 TYPEs registered with the name server and logged
+	// in with PRLI, FCP if empty.
+	Types []uint8
+	// Handler is called from Serve with every frame not handled by the
+	// port itself, such as FC-4 Information Units.
+	Handler func(*fc.Frame)
+	// OnRSCN is called from Serve with every RSCN received, after it has
+	// been accepted. Requests made from it have to be made from another
+	// goroutine, as their replies are received by Serve.
+	OnRSCN func(*els.RSCN)
+
+	t  Transport
+	mu sync.Mutex
+	id common.FCID
+	// oxid is the OX_ID last allocated
+	oxid  uint16
+	seqID uint8
+	// open holds the Exchanges originated by the port by OX_ID
+	open   map[uint16]*exchange
+	logins map[common.FCID]*Login
+}
+
+// exchange is an Exchange waiting for its reply. Aborted Exchanges wait for
+// the BA_ACC or BA_RJT instead.
+type exchange struct {
+	peer    common.FCID
+	reply   chan *fc.Frame
+	aborted bool
+}
+
+// NewNPort returns an N_Port on t, not yet logged in with the fabric.
+func NewNPort(t Transport, portName, nodeName common.WWN) *NPort {
+	return &NPort{
+		PortName: portName,
+		NodeName: nodeName,
+		t:        t,
+		open:     map[uint16]*exchange{},
+		logins:   map[common.FCID]*Login{},
+	}
+}
+
+// ID returns the N_Port_ID assigned by the fabric, 000000 before FLOGI.
+func (p *NPort) ID() common.FCID {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.id
+}
+
+// Logins returns the N_Port logins of the port.
+func (p *NPort) Logins() []Login {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r := make([]Login, 0, len(p.logins))
+	for _, l := range p.logins {
+		c := *l
+		c.Types = append([]uint8{}, l.Types...)
+		r = append(r, c)
+	}
+	return r
+}
+
+// Serve receives frames until the Transport is closed. It returns the
+// error of receiving a frame or of sending a reply.
+func (p *NPort) Serve() error {
+	for {
+		f, err := p.t.Receive()
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			err = p.handle(f)
+		}
+		if err == ErrClosed {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Close closes the Transport, which ends Serve.
+func (p *NPort) Close() error {
+	return p.t.Close()
+}
+
+func (p *NPort) types() []uint8 {
+	if len(p.Types) == 0 {
+		return []uint8{els.FC4TypeFCP}
+	}
+	return p.Types
+}
+
+// params returns the service parameters of the port for FLOGI and PLOGI.
+func (p *NPort) params() *els.PLOGI {
+	o := els.DefaultParams(p.PortName, p.NodeName)
+	o.CommonSvcParams.B2BCredits = pipeDepth
+	o.CommonSvcParams.NxPortTotalConcurrentSeq = 0xff
+	o.CommonSvcParams.RelOffsetInfoCat = 0x1f
+	o.ClassSvcParams[2].ConcurrentSeq = 0xff
+	o.ClassSvcParams[2].OpenSeqPerExch = 1
+	return o
+}
+
+// frame returns a frame from the port, the caller holds the lock.
+func (p *NPort) frame(d common.FCID, rctl uint8, payload interface{}) *fc.Frame {
+	p.seqID++
+	f := fc.NewFrame(p.id, d, rctl, payload)
+	f.SeqID = p.seqID
+	return f
+}
+
+// allocate returns an OX_ID not in use, the caller holds the lock.
+func (p *NPort) allocate() (uint16, error) {
+	for i := 0; i < fc.XIDUnassigned; i++ {
+		p.oxid++
+		if p.oxid == fc.XIDUnassigned {
+			p.oxid = 0
+		}
+		if _, ok := p.open[p.oxid]; !ok {
+			return p.oxid, nil
+		}
+	}
+	return 0, errNoOXID
+}
+
+// Request originates an Exchange by sending payload to d and returns the
+// reply. If ctx ends first the Exchange is aborted with an ABTS.
+func (p *NPort) Request(ctx context.Context, d common.FCID, rctl uint8, payload interface{}) (*fc.Frame, error) {
+	p.mu.Lock()
+	oxid, err := p.allocate()
+	if err != nil {
+		p.mu.Unlock()
+		return nil, err
+	}
+	x := &exchange{peer: d, reply: make(chan *fc.Frame, 1)}
+	p.open[oxid] = x
+	f := p.frame(d, rctl, payload)
+	f.OXID = oxid
+	f.FCtl.SetValue(fc.FCtlRequest)
+	p.mu.Unlock()
+
+	if err := p.t.Send(f); err != nil {
+		p.release(oxid)
+		return nil, err
+	}
+	select {
+	case r := <-x.reply:
+		return r, nil
+	case <-ctx.Done():
+		p.abort(oxid, x)
+		return nil, ctx.Err()
+	}
+}
+
+func (p *NPort) release(oxid uint16) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.open, oxid)
+}
+
+// abort sends an ABTS for an Exchange, the OX_ID is released once the
+// BA_ACC or BA_RJT arrives.
+func (p *NPort) abort(oxid uint16, x *exchange) {
+	p.mu.Lock()
+	x.aborted = true
+	f := p.frame(x.peer, fc.RCtlABTS, []byte{})
+	f.OXID = oxid
+	f.FCtl.SetValue(fc.FCtlEndSequence | fc.FCtlSequenceInitiative)
+	p.mu.Unlock()
+	if err := p.t.Send(f); err != nil {
+		p.release(oxid)
+	}
+}
+
+// reply answers the request f, ending the Exchange.
+func (p *NPort) reply(f *fc.Frame, rctl uint8, payload interface{}) error {
+	return p.t.Send(f.Reply(rctl, payload))
+}
+
+func (p *NPort) handle(f *fc.Frame) error {
+	if f.FCtl.Has(fc.FCtlExchangeContext) {
+		p.complete(f)
+		return nil
+	}
+	if f.RCtl == fc.RCtlABTS {
+		// Exchanges are answered right away, so there is never anything
+		// left to abort
+		acc := &fc.BAACC{SeqIDValidity: fc.SeqIDInvalid, OXID: f.OXID, RXID: f.RXID, HighSeqCount: 0xffff}
+		return p.reply(f, fc.RCtlBAACC, encode(acc))
+	}
+	if e, ok := f.Payload.(*els.Frame); ok {
+		return p.serveELS(f, e)
+	}
+	if p.Handler != nil {
+		p.Handler(f)
+	}
+	return nil
+}
+
+// complete hands a reply to the Exchange waiting for it.
+func (p *NPort) complete(f *fc.Frame) {
+	p.mu.Lock()
+	x, ok := p.open[f.OXID]
+	if !ok || x.peer != f.SourceID {
+		p.mu.Unlock()
+		if p.Handler != nil {
+			p.Handler(f)
+		}
+		return
+	}
+	bls := f.RCtl == fc.RCtlBAACC || f.RCtl == fc.RCtlBARJT
+	if x.aborted != bls {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.open, f.OXID)
+	p.mu.Unlock()
+	if !x.aborted {
+		x.reply <- f
+	}
+}
+
+func (p *NPort) serveELS(f *fc.Frame, e *els.Frame) error {
+	p.mu.Lock()
+	l, in := p.logins[f.SourceID]
+	p.mu.Unlock()
+
+	switch r := e.Payload.(type) {
+	case *els.PLOGI:
+		p.mu.Lock()
+		p.logins[f.SourceID] = &Login{PortID: f.SourceID, PortName: r.PortName, NodeName: r.NodeName}
+		p.mu.Unlock()
+		return p.accept(f, p.params())
+	case *els.LOGO:
+		p.mu.Lock()
+		delete(p.logins, f.SourceID)
+		p.mu.Unlock()
+		return p.accept(f, nil)
+	case *els.PRLI:
+		if !in {
+			return p.reject(f, els.ReasonUnableToPerform, els.ExplNoLogin)
+		}
+		acc := &els.PRLI{}
+		p.mu.Lock()
+		for _, pg := range r.Pages {
+			pg.Flags = els.PRLINoSuchImage
+			if contains(p.types(), pg.Type) {
+				pg.Flags = els.PRLIImagePair | els.PRLIRequestExecuted
+				if !contains(l.Types, pg.Type) {
+					l.Types = append(l.Types, pg.Type)
+				}
+			}
+			acc.Pages = append(acc.Pages, pg)
+		}
+		p.mu.Unlock()
+		return p.accept(f, acc)
+	case *els.PRLO:
+		if !in {
+			return p.reject(f, els.ReasonUnableToPerform, els.ExplNoLogin)
+		}
+		acc := &els.PRLO{}
+		p.mu.Lock()
+		for _, pg := range r.Pages {
+			l.Types = remove(l.Types, pg.Type)
+			pg.Flags = els.PRLIRequestExecuted
+			acc.Pages = append(acc.Pages, pg)
+		}
+		p.mu.Unlock()
+		return p.accept(f, acc)
+	case *els.Echo:
+		if !in {
+			return p.reject(f, els.ReasonUnableToPerform, els.ExplNoLogin)
+		}
+		return p.accept(f, r)
+	case *els.ADISC:
+		if !in {
+			return p.reject(f, els.ReasonUnableToPerform, els.ExplNoLogin)
+		}
+		return p.accept(f, &els.ADISC{PortName: p.PortName, NodeName: p.NodeName, PortID: f.DestinationID})
+	case *els.RNID:
+		if !in {
+			return p.reject(f, els.ReasonUnableToPerform, els.ExplNoLogin)
+		}
+		return p.accept(f, &els.RNIDAccept{Format: els.RNIDFormatCommon, PortName: p.PortName, NodeName: p.NodeName})
+	case *els.RSCN:
+		if err := p.accept(f, nil); err != nil {
+			return err
+		}
+		if p.OnRSCN != nil {
+			p.OnRSCN(r)
+		}
+	case *els.LSACC, *els.LSRJT:
+		// A reply without Exchange Context is a protocol error, ignore it
+		return nil
+	default:
+		if p.Handler != nil {
+			p.Handler(f)
+			return nil
+		}
+		return p.reject(f, els.ReasonCommandNotSupported, els.ExplNone)
+	}
+	return nil
+}
+
+// accept answers an ELS request with an LS_ACC, see els.Accept.
+func (p *NPort) accept(f *fc.Frame, o io.WriterTo) error {
+	return p.reply(f, fc.RCtlELSReply, els.Accept(o))
+}
+
+func (p *NPort) reject(f *fc.Frame, reason, explanation uint8) error {
+	return p.reply(f, fc.RCtlELSReply, els.Reject(reason, explanation))
+}
+
+// ELS sends an ELS request to d and returns the LS_ACC. An LS_RJT is
+// returned as a *RejectError.
+func (p *NPort) ELS(ctx context.Context, d common.FCID, req interface{}) (*els.LSACC, error) {
+	r, err := p.Request(ctx, d, fc.RCtlELSRequest, &els.Frame{Payload: req})
+	if err != nil {
+		return nil, err
+	}
+	e, ok := r.Payload.(*els.Frame)
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply %T to ELS", r.Payload)
+	}
+	switch a := e.Payload.(type) {
+	case *els.LSACC:
+		return a, nil
+	case *els.LSRJT:
+		return nil, &RejectError{Reason: a.Reason, Explanation: a.Explanation}
+	default:
+		return nil, fmt.Errorf("unexpected ELS reply %T", e.Payload)
+	}
+}
+
+// CT sends a CT request to the Generic Service at d and returns the FS_ACC
+// with its payload decoded. An FS_RJT is returned as a *RejectError.
+func (p *NPort) CT(ctx context.Context, d common.FCID, req *ct.Frame) (*ct.Frame, error) {
+	r, err := p.Request(ctx, d, fc.RCtlUnsolicitedControl, req)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := r.Payload.(*ct.Frame)
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply %T to CT request", r.Payload)
+	}
+	if c.Command == ct.CmdFSRJT {
+		return nil, &RejectError{Reason: c.Reason, Explanation: c.Explanation}
+	}
+	if err := c.DecodeAccept(req.Command); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// FLOGI logs the port in with the fabric and takes the N_Port_ID assigned.
+func (p *NPort) FLOGI(ctx context.Context) error {
+	r, err := p.Request(ctx, common.FPortController, fc.RCtlELSRequest, &els.Frame{Payload: (*els.FLOGI)(p.params())})
+	if err != nil {
+		return err
+	}
+	e, ok := r.Payload.(*els.Frame)
+	if !ok {
+		return fmt.Errorf("unexpected reply %T to FLOGI", r.Payload)
+	}
+	if rjt, ok := e.Payload.(*els.LSRJT); ok {
+		return &RejectError{Reason: rjt.Reason, Explanation: rjt.Explanation}
+	}
+	if _, ok := e.Payload.(*els.LSACC); !ok {
+		return fmt.Errorf("unexpected reply %T to FLOGI", e.Payload)
+	}
+	p.mu.Lock()
+	p.id = r.DestinationID
+	p.mu.Unlock()
+	return nil
+}
+
+// Register logs in with the name server and registers the FC-4 TYPEs of
+// the port.
+func (p *NPort) Register(ctx context.Context) error {
+	if err := p.PLOGI(ctx, common.DirectoryServer); err != nil {
+		return err
+	}
+	rft := &ct.RFTID{PortID: p.ID()}
+	for _, t := range p.types() {
+		rft.Types.Set(t)
+	}
+	_, err := p.CT(ctx, common.DirectoryServer, ct.NameServerRequest(ct.CmdRFTID, rft))
+	return err
+}
+
+// SCR registers the port with the Fabric Controller for RSCNs of all
+// events.
+func (p *NPort) SCR(ctx context.Context) error {
+	_, err := p.ELS(ctx, common.FabricController, &els.SCR{Function: els.SCRFull})
+	return err
+}
+
+// Query returns the ports registered with the name server for the FC-4
+// TYPE t.
+func (p *NPort) Query(ctx context.Context, t uint8) ([]common.FCID, error) {
+	r, err := p.CT(ctx, common.DirectoryServer, ct.NameServerRequest(ct.CmdGIDFT, &ct.GIDFT{Type: t}))
+	var rjt *RejectError
+	if errors.As(err, &rjt) && rjt.Explanation == ct.ExplFC4TypeNotRegistered {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	l, ok := r.Payload.(*ct.PortIDList)
+	if !ok {
+		return nil, nil
+	}
+	return *l, nil
+}
+
+// PLOGI logs the port in with the port d.
+func (p *NPort) PLOGI(ctx context.Context, d common.FCID) error {
+	acc, err := p.ELS(ctx, d, p.params())
+	if err != nil {
+		return err
+	}
+	r := &els.PLOGI{}
+	if _, err := r.ReadFrom(bytes.NewReader(acc.Data)); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.logins[d] = &Login{PortID: d, PortName: r.PortName, NodeName: r.NodeName}
+	p.mu.Unlock()
+	return nil
+}
+
+// PRLI establishes image pairs with the port d for the FC-4 TYPEs of the
+// port, and returns the TYPEs the port d accepted.
+func (p *NPort) PRLI(ctx context.Context, d common.FCID) ([]uint8, error) {
+	req := &els.PRLI{}
+	for _, t := range p.types() {
+		req.Pages = append(req.Pages, els.PRLIPage{Type: t, Flags: els.PRLIImagePair})
+	}
+	acc, err := p.ELS(ctx, d, req)
+	if err != nil {
+		return nil, err
+	}
+	r := &els.PRLI{}
+	if _, err := r.ReadFrom(bytes.NewReader(acc.Data)); err != nil {
+		return nil, err
+	}
+	var types []uint8
+	for _, pg := range r.Pages {
+		if pg.Flags&els.PRLIImagePair != 0 && pg.Response() == els.PRLIRequestExecuted {
+			types = append(types, pg.Type)
+		}
+	}
+	p.mu.Lock()
+	if l, ok := p.logins[d]; ok {
+		l.Types = types
+	}
+	p.mu.Unlock()
+	return types, nil
+}
+
+// Login logs the port in with the port d with PLOGI and PRLI.
+func (p *NPort) Login(ctx context.Context, d common.FCID) error {
+	if err := p.PLOGI(ctx, d); err != nil {
+		return err
+	}
+	_, err := p.PRLI(ctx, d)
+	return err
+}
+
+// Logout logs the port out from the port d with LOGO. The login is only
+// removed once the LOGO is accepted.
+func (p *NPort) Logout(ctx context.Context, d common.FCID) error {
+	if _, err := p.ELS(ctx, d, &els.LOGO{PortID: p.ID(), PortName: p.PortName}); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	delete(p.logins, d)
+	p.mu.Unlock()
+	return nil
+}
+
+func encode(o io.WriterTo) []byte {
+	b := new(bytes.Buffer)
+	if _, err := o.WriteTo(b); err != nil {
+		return nil
+	}
+	return b.Bytes()
+}
+
+func contains(l []uint8, t uint8) bool {
+	for _, v := range l {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(l []uint8, t uint8) []uint8 {
+	r := l[:0]
+	for _, v := range l {
+		if v != t {
+			r = append(r, v)
+		}
+	}
+	return r
+}
@@ -0,0 +1,371 @@
+package port
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/ct"
+	"github.com/bluecmd/fibrechannel/els"
+)
+
+// fabric is a minimal switch for the tests. It assigns N_Port_IDs on FLOGI,
+// keeps the FC-4 TYPEs registered with RFT_ID for GID_FT and forwards
+// everything else by D_ID.
+type fabric struct {
+	mu    sync.Mutex
+	ports map[common.FCID]Transport
+	types map[common.FCID]ct.FC4Types
+	next  byte
+}
+
+func newFabric() *fabric {
+	return &fabric{ports: map[common.FCID]Transport{}, types: map[common.FCID]ct.FC4Types{}}
+}
+
+// attach returns a new N_Port connected to the fabric.
+func (s *fabric) attach(t *testing.T, n byte) *NPort {
+	a, b := Pipe()
+	p := NewNPort(a, common.WWN{0x10, 0, 0, 0, 0, 0, 0, n}, common.WWN{0x20, 0, 0, 0, 0, 0, 0, n})
+	go s.serve(b)
+	go p.Serve()
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func (s *fabric) reply(t Transport, f *fc.Frame, payload interface{}) {
+	rctl := uint8(fc.RCtlELSReply)
+	if _, ok := payload.(*ct.Frame); ok {
+		rctl = fc.RCtlSolicitedControl
+	}
+	r := &fc.Frame{
+		RCtl:          rctl,
+		DestinationID: f.SourceID,
+		CsctlPriority: &fc.CSCtl{},
+		SourceID:      f.DestinationID,
+		OXID:          f.OXID,
+		RXID:          fc.XIDUnassigned,
+		Payload:       payload,
+	}
+	r.FCtl.SetValue(fc.FCtlReply)
+	t.Send(r)
+}
+
+func (s *fabric) serve(t Transport) {
+	for {
+		f, err := t.Receive()
+		if err != nil {
+			return
+		}
+		switch f.DestinationID {
+		case common.FPortController:
+			s.mu.Lock()
+			s.next++
+			f.SourceID = common.FCID{0x01, s.next, 0x00}
+			s.ports[f.SourceID] = t
+			s.mu.Unlock()
+			f.DestinationID = common.FPortController
+			s.reply(t, f, &els.Frame{Payload: &els.LSACC{Data: encode(&els.PLOGI{})}})
+		case common.DirectoryServer:
+			switch r := f.Payload.(type) {
+			case *els.Frame:
+				s.reply(t, f, &els.Frame{Payload: &els.LSACC{Data: encode(&els.PLOGI{})}})
+			case *ct.Frame:
+				s.mu.Lock()
+				switch q := r.Payload.(type) {
+				case *ct.RFTID:
+					s.types[q.PortID] = q.Types
+					s.reply(t, f, r.Accept(nil))
+				case *ct.GIDFT:
+					var l ct.PortIDList
+					for id, ft := range s.types {
+						if ft.Has(q.Type) {
+							l = append(l, id)
+						}
+					}
+					if len(l) == 0 {
+						s.reply(t, f, r.Reject(ct.ReasonUnableToPerform, ct.ExplFC4TypeNotRegistered))
+					} else {
+						s.reply(t, f, r.Accept(&l))
+					}
+				}
+				s.mu.Unlock()
+			}
+		default:
+			s.mu.Lock()
+			d, ok := s.ports[f.DestinationID]
+			s.mu.Unlock()
+			if ok {
+				d.Send(f)
+			}
+		}
+	}
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestNPortLogin(t *testing.T) {
+	ctx := testContext(t)
+	s := newFabric()
+	initiator, target := s.attach(t, 1), s.attach(t, 2)
+	target.Types = []uint8{els.FC4TypeFCP, els.FC4TypeNVMe}
+
+	for _, p := range []*NPort{initiator, target} {
+		if err := p.FLOGI(ctx); err != nil {
+			t.Fatalf("FLOGI: %v", err)
+		}
+		if err := p.Register(ctx); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+	if initiator.ID() != (common.FCID{0x01, 0x01, 0x00}) || target.ID() != (common.FCID{0x01, 0x02, 0x00}) {
+		t.Fatalf("unexpected N_Port_IDs %v %v", initiator.ID(), target.ID())
+	}
+
+	ids, err := initiator.Query(ctx, els.FC4TypeNVMe)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if want := []common.FCID{target.ID()}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("got NVMe ports %v, wanted %v", ids, want)
+	}
+	if ids, err := initiator.Query(ctx, 0x05); err != nil || len(ids) != 0 {
+		t.Fatalf("got IP ports %v, %v", ids, err)
+	}
+
+	if err := initiator.Login(ctx, target.ID()); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	var l *Login
+	logins := target.Logins()
+	for i := range logins {
+		if logins[i].PortID == initiator.ID() {
+			l = &logins[i]
+		}
+	}
+	if l == nil || l.PortName != initiator.PortName || !reflect.DeepEqual(l.Types, []uint8{els.FC4TypeFCP}) {
+		t.Fatalf("unexpected login at target %+v", target.Logins())
+	}
+
+	acc, err := initiator.ELS(ctx, target.ID(), &els.ADISC{PortID: initiator.ID()})
+	if err != nil {
+		t.Fatalf("ADISC: %v", err)
+	}
+	adisc := &els.ADISC{}
+	adisc.ReadFrom(bytes.NewReader(acc.Data))
+	if adisc.PortName != target.PortName || adisc.PortID != target.ID() {
+		t.Errorf("unexpected ADISC accept %+v", adisc)
+	}
+
+	acc, err = initiator.ELS(ctx, target.ID(), &els.Echo{Data: []byte("ping")})
+	if err != nil {
+		t.Fatalf("ECHO: %v", err)
+	}
+	if !bytes.Equal(acc.Data, []byte("\x00\x00\x00ping")) {
+		t.Errorf("unexpected ECHO accept % x", acc.Data)
+	}
+
+	acc, err = initiator.ELS(ctx, target.ID(), &els.RNID{Format: els.RNIDFormatCommon})
+	if err != nil {
+		t.Fatalf("RNID: %v", err)
+	}
+	rnid := &els.RNIDAccept{}
+	rnid.ReadFrom(bytes.NewReader(acc.Data))
+	if rnid.NodeName != target.NodeName {
+		t.Errorf("unexpected RNID accept %+v", rnid)
+	}
+
+	if err := initiator.Logout(ctx, target.ID()); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	_, err = initiator.ELS(ctx, target.ID(), &els.Echo{})
+	var rjt *RejectError
+	if !errors.As(err, &rjt) || rjt.Explanation != els.ExplNoLogin {
+		t.Fatalf("got %v for ECHO after LOGO, wanted LS_RJT", err)
+	}
+}
+
+func TestNPortAbort(t *testing.T) {
+	a, b := Pipe()
+	p := NewNPort(a, common.WWN{1}, common.WWN{2})
+	go p.Serve()
+	defer p.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() {
+		_, err := p.Request(ctx, common.FCID{0x01, 0x02, 0x00}, fc.RCtlELSRequest, &els.Frame{Payload: &els.Echo{}})
+		errc <- err
+	}()
+	req, err := b.Receive()
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("got %v, wanted context.Canceled", err)
+	}
+	abts, err := b.Receive()
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	if abts.RCtl != fc.RCtlABTS || abts.OXID != req.OXID {
+		t.Fatalf("unexpected frame after cancel %+v", abts)
+	}
+	p.mu.Lock()
+	_, open := p.open[req.OXID]
+	p.mu.Unlock()
+	if !open {
+		t.Fatalf("OX_ID released before BA_ACC")
+	}
+
+	abts.SourceID, abts.DestinationID = abts.DestinationID, abts.SourceID
+	abts.RCtl = fc.RCtlBAACC
+	abts.FCtl.SetValue(fc.FCtlReply)
+	abts.Payload = encode(&fc.BAACC{OXID: req.OXID, RXID: fc.XIDUnassigned})
+	b.Send(abts)
+	for i := 0; ; i++ {
+		p.mu.Lock()
+		_, open = p.open[req.OXID]
+		p.mu.Unlock()
+		if !open {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("OX_ID not released after BA_ACC")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// An ABTS received is answered with a BA_ACC
+	abts.RCtl = fc.RCtlABTS
+	abts.OXID = 0x4242
+	abts.FCtl.SetValue(fc.FCtlEndSequence)
+	abts.Payload = []byte{}
+	b.Send(abts)
+	r, err := b.Receive()
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	acc := &fc.BAACC{}
+	acc.ReadFrom(bytes.NewReader(r.Payload.([]byte)))
+	if r.RCtl != fc.RCtlBAACC || acc.OXID != 0x4242 {
+		t.Fatalf("unexpected reply to ABTS %+v %+v", r, acc)
+	}
+}
+
+func TestFCoETransport(t *testing.T) {
+	c1, c2 := net.Pipe()
+	m1, m2 := net.HardwareAddr{0x0e, 0xfc, 0x00, 0x01, 0x01, 0x00}, net.HardwareAddr{0x0e, 0xfc, 0x00, 0x01, 0x02, 0x00}
+	a, b := NewFCoETransport(c1, m1, m2), NewFCoETransport(c2, m2, m1)
+	defer a.Close()
+
+	f := &fc.Frame{
+		RCtl:          fc.RCtlELSRequest,
+		DestinationID: common.FCID{0x01, 0x02, 0x00},
+		CsctlPriority: &fc.CSCtl{},
+		SourceID:      common.FCID{0x01, 0x01, 0x00},
+		OXID:          1,
+		RXID:          fc.XIDUnassigned,
+		Payload:       &els.Frame{Payload: &els.Echo{Data: []byte("hello")}},
+	}
+	f.FCtl.SetValue(fc.FCtlRequest)
+	go a.Send(f)
+	r, err := b.Receive()
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	e, ok := r.Payload.(*els.Frame)
+	if !ok || r.SourceID != f.SourceID || r.OXID != 1 {
+		t.Fatalf("unexpected frame %+v", r)
+	}
+	// The data field was padded to a word with fill bytes on the link
+	if echo, ok := e.Payload.(*els.Echo); !ok || !bytes.Equal(echo.Data, []byte("hello")) || r.FCtl.FillBytes() != 3 {
+		t.Fatalf("unexpected payload %+v", e.Payload)
+	}
+
+	// The fill bytes are found after an Extended_Header as well
+	f.VFT = &fc.VFTHeader{VFID: 42}
+	go a.Send(f)
+	r, err = b.Receive()
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	if r.VFT == nil || r.VFT.VFID != 42 || r.FCtl.FillBytes() != 3 {
+		t.Fatalf("unexpected frame %+v", r)
+	}
+	if echo, ok := r.Payload.(*els.Frame).Payload.(*els.Echo); !ok || !bytes.Equal(echo.Data, []byte("hello")) {
+		t.Fatalf("unexpected payload %+v", r.Payload)
+	}
+}
+
+func TestNPortLogout(t *testing.T) {
+	a, b := Pipe()
+	p := NewNPort(a, common.WWN{1}, common.WWN{2})
+	go p.Serve()
+	defer p.Close()
+
+	ctx := testContext(t)
+	d := common.FCID{0x01, 0x02, 0x00}
+	p.logins[d] = &Login{PortID: d}
+	// A rejected LOGO keeps the login, an accepted one removes it
+	for _, tt := range []struct {
+		reply  *els.Frame
+		logins int
+	}{
+		{els.Reject(els.ReasonUnableToPerform, els.ExplNone), 1},
+		{els.Accept(nil), 0},
+	} {
+		errc := make(chan error)
+		go func() { errc <- p.Logout(ctx, d) }()
+		req, err := b.Receive()
+		if err != nil {
+			t.Fatalf("Receive: %v", err)
+		}
+		if len(p.Logins()) != 1 {
+			t.Fatalf("login removed before the LOGO was answered")
+		}
+		b.Send(req.Reply(fc.RCtlELSReply, tt.reply))
+		err = <-errc
+		if n := len(p.Logins()); (err == nil) != (tt.logins == 0) || n != tt.logins {
+			t.Fatalf("got %v and %d logins after %T, wanted %d logins", err, n, tt.reply.Payload, tt.logins)
+		}
+	}
+}
+
+// failingTransport fails to send any frame.
+type failingTransport struct {
+	Transport
+}
+
+var errSend = errors.New("send failed")
+
+func (t failingTransport) Send(*fc.Frame) error {
+	return errSend
+}
+
+func TestNPortServeSendError(t *testing.T) {
+	a, b := Pipe()
+	p := NewNPort(failingTransport{a}, common.WWN{1}, common.WWN{2})
+	defer p.Close()
+
+	errc := make(chan error)
+	go func() { errc <- p.Serve() }()
+	f := fc.NewFrame(common.FCID{0x01, 0x02, 0x00}, common.FCID{0x01, 0x01, 0x00}, fc.RCtlELSRequest, &els.Frame{Payload: &els.Echo{}})
+	f.FCtl.SetValue(fc.FCtlRequest)
+	b.Send(f)
+	if err := <-errc; err != errSend {
+		t.Fatalf("got %v from Serve, wanted %v", err, errSend)
+	}
+}
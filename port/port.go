@@ -0,0 +1,238 @@
+// Package port simulates the FC-FS Port State Machine of a link. The state
+// machine is driven by the ordered sets received, timeouts and requests from
+// the port itself, and reports every state transition. It can be used to
+// check traces from analyzers, or to let two ports initialize a link
+// against each other.
+//
+// NPort is an N_Port implemented in software on top of a Transport, which
+// lets fabric services be tested without an HBA. Pipe connects two
+// Transports in memory and FCoETransport carries the frames over Ethernet.
+package port
+
+import (
+	"fmt"
+
+	"github.com/bluecmd/fibrechannel/primitive"
+)
+
+// State is a state of the Port State Machine.
+type State int
+
+const (
+	StateActive State = iota // AC: Active
+	StateLR1                 // LR1: Link Recovery, LR Transmit
+	StateLR2                 // LR2: Link Recovery, LR Receive
+	StateLR3                 // LR3: Link Recovery, LRR Receive
+	StateLF1                 // LF1: Link Failure, NOS Receive
+	StateLF2                 // LF2: Link Failure, NOS Transmit
+	StateOL1                 // OL1: Offline, OLS Transmit
+	StateOL2                 // OL2: Offline, OLS Receive
+	StateOL3                 // OL3: Offline, Wait for OLS
+)
+
+// SequenceLength is the number of consecutive identical ordered sets which
+// make up a recognized primitive sequence.
+const SequenceLength = 3
+
+var (
+	stateNames = map[State]string{
+		StateActive: "AC",
+		StateLR1:    "LR1",
+		StateLR2:    "LR2",
+		StateLR3:    "LR3",
+		StateLF1:    "LF1",
+		StateLF2:    "LF2",
+		StateOL1:    "OL1",
+		StateOL2:    "OL2",
+		StateOL3:    "OL3",
+	}
+
+	// received holds the state entered when an ordered set is recognized
+	received = map[State]map[primitive.Type]State{
+		StateActive: {
+			primitive.TypeNOS: StateLF1,
+			primitive.TypeOLS: StateOL2,
+			primitive.TypeLR:  StateLR2,
+			primitive.TypeLRR: StateLR3,
+		},
+		StateLR1: {
+			primitive.TypeNOS: StateLF1,
+			primitive.TypeOLS: StateOL2,
+			primitive.TypeLR:  StateLR2,
+			primitive.TypeLRR: StateLR3,
+		},
+		StateLR2: {
+			primitive.TypeNOS:  StateLF1,
+			primitive.TypeOLS:  StateOL2,
+			primitive.TypeLRR:  StateLR3,
+			primitive.TypeIdle: StateActive,
+		},
+		StateLR3: {
+			primitive.TypeNOS:  StateLF1,
+			primitive.TypeOLS:  StateOL2,
+			primitive.TypeLR:   StateLR2,
+			primitive.TypeIdle: StateActive,
+			primitive.TypeRRDY: StateActive,
+		},
+		StateLF1: {
+			primitive.TypeOLS: StateOL2,
+			primitive.TypeLR:  StateLR2,
+			primitive.TypeLRR: StateLR3,
+		},
+		StateLF2: {
+			primitive.TypeNOS: StateLF1,
+			primitive.TypeOLS: StateOL2,
+			primitive.TypeLR:  StateLR2,
+			primitive.TypeLRR: StateLR3,
+		},
+		StateOL1: {
+			primitive.TypeNOS: StateLF1,
+			primitive.TypeOLS: StateOL2,
+			primitive.TypeLR:  StateLR2,
+			primitive.TypeLRR: StateLR3,
+		},
+		StateOL2: {
+			primitive.TypeNOS: StateLF1,
+			primitive.TypeLR:  StateLR2,
+			primitive.TypeLRR: StateLR3,
+		},
+		StateOL3: {
+			primitive.TypeNOS: StateLF1,
+			primitive.TypeOLS: StateOL2,
+		},
+	}
+
+	// timeouts holds the state entered when the timer of a state expires,
+	// R_T_TOV for the Link Recovery states and OL2, and the minimum OLS
+	// transmission time for OL1
+	timeouts = map[State]State{
+		StateLR1: StateLF2,
+		StateLR2: StateLF2,
+		StateLR3: StateLF2,
+		StateOL2: StateLF2,
+		StateOL1: StateOL3,
+	}
+
+	// transmits holds what a port transmits in each state
+	transmits = map[State]primitive.Type{
+		StateActive: primitive.TypeIdle,
+		StateLR1:    primitive.TypeLR,
+		StateLR2:    primitive.TypeLRR,
+		StateLR3:    primitive.TypeIdle,
+		StateLF1:    primitive.TypeOLS,
+		StateLF2:    primitive.TypeNOS,
+		StateOL1:    primitive.TypeOLS,
+		StateOL2:    primitive.TypeLR,
+		StateOL3:    primitive.TypeUnknown,
+	}
+)
+
+// Transition is a change of state and what caused it.
+type Transition struct {
+	From  State
+	To    State
+	Cause string
+}
+
+// Port is the Port State Machine of one port. OnTransition, if set, is
+// called for every transition.
+type Port struct {
+	State        State
+	OnTransition func(Transition)
+
+	// last is the last ordered set received and count how many times in a
+	// row it was received
+	last  primitive.Type
+	count int
+}
+
+// New returns a Port in state s.
+func New(s State) *Port {
+	return &Port{State: s}
+}
+
+func (s State) String() string {
+	if n, ok := stateNames[s]; ok {
+		return n
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+func (t Transition) String() string {
+	return fmt.Sprintf("%v -> %v (%s)", t.From, t.To, t.Cause)
+}
+
+// Transmit returns the ordered set the port transmits in its current state,
+// TypeUnknown in OL3 where the transmitter may be turned off. In the Active
+// state IDLE stands for IDLEs, R_RDYs and frames.
+func (p *Port) Transmit() primitive.Type {
+	return transmits[p.State]
+}
+
+// Receive feeds the port an ordered set. Primitive sequences are recognized
+// after SequenceLength consecutive occurrences, primitive signals at once.
+// SOF and EOF delimiters are part of frames and do not change the state.
+// The transition caused, if any, is returned.
+func (p *Port) Receive(pr primitive.Primitive) *Transition {
+	if pr.Type == p.last {
+		p.count++
+	} else {
+		p.last = pr.Type
+		p.count = 1
+	}
+	if pr.Type.Sequence() && p.count < SequenceLength {
+		return nil
+	}
+	to, ok := received[p.State][pr.Type]
+	if !ok {
+		return nil
+	}
+	return p.transition(to, pr.Type.String()+" recognized")
+}
+
+// Timeout signals that the timer of the current state expired.
+func (p *Port) Timeout() *Transition {
+	to, ok := timeouts[p.State]
+	if !ok {
+		return nil
+	}
+	return p.transition(to, "timeout")
+}
+
+// LinkFailure signals a loss of signal or a loss of synchronization longer
+// than R_T_TOV. It is ignored while offline or already in LF2.
+func (p *Port) LinkFailure() *Transition {
+	switch p.State {
+	case StateLF2, StateOL1, StateOL3:
+		return nil
+	}
+	return p.transition(StateLF2, "link failure")
+}
+
+// LinkReset starts Link Recovery from the Active state.
+func (p *Port) LinkReset() *Transition {
+	if p.State != StateActive {
+		return nil
+	}
+	return p.transition(StateLR1, "link reset")
+}
+
+// Offline takes the port offline.
+func (p *Port) Offline() *Transition {
+	if p.State == StateOL1 {
+		return nil
+	}
+	return p.transition(StateOL1, "offline")
+}
+
+func (p *Port) transition(to State, cause string) *Transition {
+	if to == p.State {
+		return nil
+	}
+	t := &Transition{From: p.State, To: to, Cause: cause}
+	p.State = to
+	if p.OnTransition != nil {
+		p.OnTransition(*t)
+	}
+	return t
+}
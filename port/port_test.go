@@ -0,0 +1,132 @@
+package port
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bluecmd/fibrechannel/primitive"
+)
+
+var (
+	idle = [4]byte{0xbc, 0x95, 0xb5, 0xb5}
+	rrdy = [4]byte{0xbc, 0x95, 0x4a, 0x4a}
+	nos  = [4]byte{0xbc, 0x55, 0xbf, 0x45}
+	ols  = [4]byte{0xbc, 0x35, 0x8a, 0x55}
+	lr   = [4]byte{0xbc, 0x49, 0xbf, 0x49}
+	lrr  = [4]byte{0xbc, 0x35, 0xbf, 0x49}
+)
+
+// event is a step of a script, either an ordered set or an event named by
+// one of the methods of Port
+type event struct {
+	os    [4]byte
+	event string
+}
+
+func TestScripts(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		start  State
+		script []event
+		want   []State
+	}{
+		{
+			desc:   "link reset by peer",
+			start:  StateActive,
+			script: []event{{os: lr}, {os: lr}, {os: lr}, {os: lr}, {os: idle}, {os: rrdy}},
+			want:   []State{StateLR2, StateActive},
+		},
+		{
+			desc:   "link reset by port",
+			start:  StateActive,
+			script: []event{{event: "reset"}, {os: lrr}, {os: lrr}, {os: lrr}, {os: idle}},
+			want:   []State{StateLR1, StateLR3, StateActive},
+		},
+		{
+			desc:   "interrupted sequence",
+			start:  StateActive,
+			script: []event{{os: lr}, {os: lr}, {os: idle}, {os: lr}, {os: lr}},
+		},
+		{
+			desc:   "link recovery timeout",
+			start:  StateActive,
+			script: []event{{event: "reset"}, {event: "timeout"}, {os: nos}, {os: nos}, {os: nos}},
+			want:   []State{StateLR1, StateLF2, StateLF1},
+		},
+		{
+			desc:  "link failure",
+			start: StateActive,
+			script: []event{
+				{event: "failure"}, {os: ols}, {os: ols}, {os: ols},
+				{os: lrr}, {os: lrr}, {os: lrr}, {os: idle},
+			},
+			want: []State{StateLF2, StateOL2, StateLR3, StateActive},
+		},
+		{
+			desc:   "offline",
+			start:  StateActive,
+			script: []event{{event: "offline"}, {event: "timeout"}, {os: lr}, {os: lr}, {os: lr}, {event: "failure"}},
+			want:   []State{StateOL1, StateOL3},
+		},
+		{
+			desc:   "frames do not change state",
+			start:  StateLR3,
+			script: []event{{os: [4]byte{0xbc, 0xb5, 0x56, 0x56}}, {os: [4]byte{0xbc, 0x95, 0x75, 0x75}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var got []State
+			p := New(tt.start)
+			p.OnTransition = func(tr Transition) { got = append(got, tr.To) }
+			for _, e := range tt.script {
+				switch e.event {
+				case "":
+					pr, err := primitive.Decode(e.os)
+					if err != nil {
+						t.Fatalf("Decode(%x): %v", e.os, err)
+					}
+					p.Receive(pr)
+				case "reset":
+					p.LinkReset()
+				case "timeout":
+					p.Timeout()
+				case "failure":
+					p.LinkFailure()
+				case "offline":
+					p.Offline()
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got transitions to %v, wanted %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkInitialization(t *testing.T) {
+	var trace []string
+	a := New(StateOL1)
+	b := New(StateLF2)
+	a.OnTransition = func(tr Transition) { trace = append(trace, "A "+tr.String()) }
+	b.OnTransition = func(tr Transition) { trace = append(trace, "B "+tr.String()) }
+	for i := 0; i < 20; i++ {
+		ta, tb := a.Transmit(), b.Transmit()
+		a.Receive(primitive.Primitive{Type: tb})
+		b.Receive(primitive.Primitive{Type: ta})
+	}
+	if a.State != StateActive || b.State != StateActive {
+		t.Fatalf("link did not come up: A in %v, B in %v", a.State, b.State)
+	}
+	want := []string{
+		"A OL1 -> LF1 (NOS recognized)",
+		"B LF2 -> OL2 (OLS recognized)",
+		"A LF1 -> LR2 (LR recognized)",
+		"B OL2 -> LR3 (LRR recognized)",
+		"A LR2 -> AC (IDLE recognized)",
+		"B LR3 -> AC (IDLE recognized)",
+	}
+	if !reflect.DeepEqual(trace, want) {
+		t.Fatalf("got trace %q, wanted %q", trace, want)
+	}
+}
@@ -0,0 +1,142 @@
+package port
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/fcoe"
+)
+
+// ErrClosed is returned by a Transport used after Close.
+var ErrClosed = errors.New("transport closed")
+
+// Transport carries frames to and from a port. Receive blocks until a frame
+// arrives and returns io.EOF once the transport is closed. Send and Receive
+// may be used from different goroutines.
+type Transport interface {
+	Send(f *fc.Frame) error
+	Receive() (*fc.Frame, error)
+	Close() error
+}
+
+const (
+	// pipeDepth is the number of frames a pipe buffers, like the
+	// BB_Credit of a link
+	pipeDepth = 64
+
+	ethernetHeaderLength = 14
+	// maxFCoELength covers the largest FCoE PDU, a 2112 byte data field
+	// with all Extended_Headers, in a baby jumbo frame
+	maxFCoELength = 2500
+)
+
+type pipe struct {
+	in, out chan []byte
+	// done is closed when either end is closed
+	done *sync.Once
+	quit chan struct{}
+}
+
+// Pipe returns the two ends of an in-memory link. Frames are serialized on
+// Send and parsed on Receive like on a real link, so the receiver gets its
+// own copy with decoded payloads.
+func Pipe() (Transport, Transport) {
+	a, b := make(chan []byte, pipeDepth), make(chan []byte, pipeDepth)
+	once, quit := &sync.Once{}, make(chan struct{})
+	return &pipe{in: a, out: b, done: once, quit: quit}, &pipe{in: b, out: a, done: once, quit: quit}
+}
+
+func (p *pipe) Send(f *fc.Frame) error {
+	b := new(bytes.Buffer)
+	if _, err := f.WriteTo(b); err != nil {
+		return err
+	}
+	select {
+	case p.out <- b.Bytes():
+		return nil
+	case <-p.quit:
+		return ErrClosed
+	}
+}
+
+func (p *pipe) Receive() (*fc.Frame, error) {
+	select {
+	case b := <-p.in:
+		f := &fc.Frame{}
+		if _, err := f.ReadFrom(bytes.NewReader(b)); err != nil {
+			return nil, err
+		}
+		return f, nil
+	case <-p.quit:
+		return nil, io.EOF
+	}
+}
+
+// Close closes both ends of the pipe.
+func (p *pipe) Close() error {
+	p.done.Do(func() { close(p.quit) })
+	return nil
+}
+
+// FCoETransport carries frames as FCoE PDUs in Ethernet frames over Link,
+// one Ethernet frame per Read and Write, such as a net.Pipe or a raw socket.
+// Frames are sent from Local to Peer and only frames addressed to Local are
+// received. The MAC addresses are fixed, FIP and Fabric Provided MAC
+// Addresses are left to the caller.
+type FCoETransport struct {
+	Link  io.ReadWriteCloser
+	Local net.HardwareAddr
+	Peer  net.HardwareAddr
+}
+
+// NewFCoETransport returns a FCoETransport over link.
+func NewFCoETransport(link io.ReadWriteCloser, local, peer net.HardwareAddr) *FCoETransport {
+	return &FCoETransport{Link: link, Local: local, Peer: peer}
+}
+
+// Send encapsulates f as a single frame Sequence of class 3.
+func (t *FCoETransport) Send(f *fc.Frame) error {
+	p := &fcoe.Frame{SOF: fc.SOFi3, EOF: fc.EOFt}
+	if err := p.Encode(f); err != nil {
+		return err
+	}
+	d, err := p.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	b := make([]byte, ethernetHeaderLength, ethernetHeaderLength+len(d))
+	copy(b[0:], t.Peer)
+	copy(b[6:], t.Local)
+	binary.BigEndian.PutUint16(b[12:], fcoe.EtherType)
+	_, err = t.Link.Write(append(b, d...))
+	return err
+}
+
+// Receive returns the next frame for Local, skipping other Ethernet frames.
+func (t *FCoETransport) Receive() (*fc.Frame, error) {
+	b := make([]byte, ethernetHeaderLength+maxFCoELength)
+	for {
+		n, err := t.Link.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		if n < ethernetHeaderLength || !bytes.Equal(b[:6], t.Local) ||
+			binary.BigEndian.Uint16(b[12:]) != fcoe.EtherType {
+			continue
+		}
+		p := &fcoe.Frame{}
+		if err := p.UnmarshalBinary(b[ethernetHeaderLength:n]); err != nil {
+			return nil, err
+		}
+		return p.Decode()
+	}
+}
+
+func (t *FCoETransport) Close() error {
+	return t.Link.Close()
+}
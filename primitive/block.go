@@ -0,0 +1,174 @@
+package primitive
+
+import (
+	"errors"
+)
+
+const (
+	SyncData    = 0x1 // Sync header of a data block
+	SyncControl = 0x2 // Sync header of a control block
+)
+
+// Block types of 64b/66b control blocks carrying FC words. Every block is
+// two transmission words, in which an ordered set is sent as its three data
+// characters with K28.5 replaced by the block type or an O code. IDLE is
+// sent as four idle control characters.
+const (
+	BlockIdle      = 0x1e // C0-C7: two IDLEs
+	BlockIdleOS    = 0x2d // C0-C3 O4 D5-D7: IDLE and an ordered set
+	BlockIdleStart = 0x33 // C0-C3 S4 D5-D7: IDLE and SOF
+	BlockOSStart   = 0x66 // O0 D1-D3 S4 D5-D7: ordered set and SOF
+	BlockOSOS      = 0x55 // O0 D1-D3 O4 D5-D7: two ordered sets
+	BlockStart     = 0x78 // S0 D1-D7: SOF and the first word of the frame
+	BlockOSIdle    = 0x4b // O0 D1-D3 C4-C7: ordered set and IDLE
+	BlockEOFIdle   = 0xb4 // D0-D2 T3 C4-C7: EOF and IDLE
+	BlockDataEOF   = 0xff // D0-D6 T7: the last word of the frame and EOF
+)
+
+var (
+	errSync      = errors.New("invalid 64b/66b sync header")
+	errBlockType = errors.New("unsupported 64b/66b block type")
+	errIdle      = errors.New("non-idle control characters")
+
+	idle = Primitive{Type: TypeIdle}
+)
+
+// Block is a 64b/66b block, Sync is the two bit sync header and Data[0] is
+// the block type of control blocks.
+type Block struct {
+	Sync uint8
+	Data [8]byte
+}
+
+// DecodeBlock returns the ordered sets in a 64b/66b block in transmission
+// order. Data blocks and frame data in control blocks do not yield anything.
+func DecodeBlock(b Block) ([]Primitive, error) {
+	switch b.Sync {
+	case SyncData:
+		return nil, nil
+	case SyncControl:
+	default:
+		return nil, errSync
+	}
+	d := b.Data
+	lo := [3]byte{d[1], d[2], d[3]}
+	hi := [3]byte{d[5], d[6], d[7]}
+	switch d[0] {
+	case BlockIdle:
+		if d != [8]byte{BlockIdle} {
+			return nil, errIdle
+		}
+		return []Primitive{idle, idle}, nil
+	case BlockIdleOS:
+		return decodeWords(nil, &hi)
+	case BlockIdleStart:
+		s, err := decodeDelimiter(hi, TypeSOF)
+		if err != nil {
+			return nil, err
+		}
+		return []Primitive{idle, s}, nil
+	case BlockOSStart:
+		p, err := decodeWords(&lo, nil)
+		if err != nil {
+			return nil, err
+		}
+		s, err := decodeDelimiter(hi, TypeSOF)
+		if err != nil {
+			return nil, err
+		}
+		return []Primitive{p[0], s}, nil
+	case BlockOSOS:
+		return decodeWords(&lo, &hi)
+	case BlockStart:
+		s, err := decodeDelimiter(lo, TypeSOF)
+		if err != nil {
+			return nil, err
+		}
+		return []Primitive{s}, nil
+	case BlockOSIdle:
+		return decodeWords(&lo, nil)
+	case BlockEOFIdle:
+		e, err := decodeDelimiter(lo, TypeEOF)
+		if err != nil {
+			return nil, err
+		}
+		return []Primitive{e, idle}, nil
+	case BlockDataEOF:
+		e, err := decodeDelimiter(hi, TypeEOF)
+		if err != nil {
+			return nil, err
+		}
+		return []Primitive{e}, nil
+	default:
+		return nil, errBlockType
+	}
+}
+
+// decodeWords decodes the ordered sets of the two words of a block, a nil
+// word is IDLE.
+func decodeWords(w0, w1 *[3]byte) ([]Primitive, error) {
+	r := []Primitive{}
+	for _, w := range []*[3]byte{w0, w1} {
+		if w == nil {
+			r = append(r, idle)
+			continue
+		}
+		p, err := decode(*w)
+		if err != nil {
+			return nil, err
+		}
+		if p.Type == TypeSOF || p.Type == TypeEOF {
+			return nil, errUnknown
+		}
+		r = append(r, p)
+	}
+	return r, nil
+}
+
+// decodeDelimiter decodes a word that has to be an SOF or EOF.
+func decodeDelimiter(w [3]byte, t Type) (Primitive, error) {
+	p, err := decode(w)
+	if err != nil {
+		return Primitive{}, err
+	}
+	if p.Type != t {
+		return Primitive{}, errUnknown
+	}
+	return p, nil
+}
+
+// EncodeBlock encodes two words without frame data as a 64b/66b control
+// block, each is IDLE or a primitive signal or sequence.
+func EncodeBlock(w0, w1 Primitive) (Block, error) {
+	b := Block{Sync: SyncControl}
+	for _, p := range []Primitive{w0, w1} {
+		if p.Type == TypeSOF || p.Type == TypeEOF {
+			return Block{}, errBlockType
+		}
+	}
+	c0, err := w0.encode(RDNegative)
+	if err != nil {
+		return Block{}, err
+	}
+	c1, err := w1.encode(RDNegative)
+	if err != nil {
+		return Block{}, err
+	}
+	i0 := w0.Type == TypeIdle
+	i1 := w1.Type == TypeIdle
+	switch {
+	case i0 && i1:
+		b.Data[0] = BlockIdle
+	case i0:
+		b.Data[0] = BlockIdleOS
+		copy(b.Data[5:], c1[:])
+	case i1:
+		b.Data[0] = BlockOSIdle
+		copy(b.Data[1:], c0[:])
+	default:
+		b.Data[0] = BlockOSOS
+		copy(b.Data[1:], c0[:])
+		copy(b.Data[5:], c1[:])
+	}
+	return b, nil
+}
@@ -0,0 +1,286 @@
+// Package primitive decodes and encodes FC-FS ordered sets: the frame
+// delimiters, primitive signals like IDLE and R_RDY, and primitive sequences
+// like NOS and LR. Ordered sets are handled as the four characters of an
+// 8b/10b transmission word, led by K28.5, or as 64b/66b blocks.
+package primitive
+
+import (
+	"errors"
+	"fmt"
+
+	fc "github.com/bluecmd/fibrechannel"
+)
+
+// K28_5 is the special character starting every ordered set.
+const K28_5 = 0xbc
+
+// Type is the kind of an ordered set.
+type Type int
+
+const (
+	TypeUnknown Type = iota
+	TypeSOF          // Start-of-Frame delimiter
+	TypeEOF          // End-of-Frame delimiter
+	TypeIdle         // IDLE: fill word
+	TypeRRDY         // R_RDY: Receiver_Ready, returns a buffer-to-buffer credit
+	TypeVCRDY        // VC_RDY: Virtual Circuit Ready
+	TypeBBSCs        // BB_SCs: buffer-to-buffer state change, frames
+	TypeBBSCr        // BB_SCr: buffer-to-buffer state change, R_RDYs
+	TypeNOS          // NOS: Not_Operational
+	TypeOLS          // OLS: Offline
+	TypeLR           // LR: Link Reset
+	TypeLRR          // LRR: Link Reset Response
+)
+
+// Disparity is the running disparity at the start of an ordered set. Only
+// EOFs depend on it, their second character is chosen so that the running
+// disparity is negative after the EOF.
+type Disparity int
+
+const (
+	RDNegative Disparity = iota
+	RDPositive
+)
+
+var (
+	errNotOrderedSet = errors.New("not an ordered set")
+	errUnknown       = errors.New("unknown ordered set")
+
+	typeNames = map[Type]string{
+		TypeUnknown: "Unknown",
+		TypeSOF:     "SOF",
+		TypeEOF:     "EOF",
+		TypeIdle:    "IDLE",
+		TypeRRDY:    "R_RDY",
+		TypeVCRDY:   "VC_RDY",
+		TypeBBSCs:   "BB_SCs",
+		TypeBBSCr:   "BB_SCr",
+		TypeNOS:     "NOS",
+		TypeOLS:     "OLS",
+		TypeLR:      "LR",
+		TypeLRR:     "LRR",
+	}
+
+	// signals are the three characters following K28.5 of the primitive
+	// signals and sequences
+	signals = map[Type][3]byte{
+		TypeIdle:  {d(21, 4), d(21, 5), d(21, 5)},
+		TypeRRDY:  {d(21, 4), d(10, 2), d(10, 2)},
+		TypeBBSCs: {d(21, 4), d(22, 4), d(22, 4)},
+		TypeBBSCr: {d(21, 4), d(22, 6), d(22, 6)},
+		TypeNOS:   {d(21, 2), d(31, 5), d(5, 2)},
+		TypeOLS:   {d(21, 1), d(10, 4), d(21, 2)},
+		TypeLR:    {d(9, 2), d(31, 5), d(9, 2)},
+		TypeLRR:   {d(21, 1), d(31, 5), d(9, 2)},
+	}
+
+	// sofs are the third and fourth character of the SOFs, the second is
+	// always D21.5
+	sofs = map[fc.SOF]byte{
+		fc.SOFf:  d(24, 2),
+		fc.SOFi2: d(21, 2),
+		fc.SOFn2: d(21, 1),
+		fc.SOFi3: d(22, 2),
+		fc.SOFn3: d(22, 1),
+		fc.SOFc4: d(25, 0),
+		fc.SOFi4: d(25, 2),
+		fc.SOFn4: d(25, 1),
+	}
+
+	// eofs are the third and fourth character of the EOFs. The second is
+	// D21.4 or D21.5 depending on disparity, or D10.4 and D10.5 for the
+	// invalid EOFs.
+	eofs = map[fc.EOF]byte{
+		fc.EOFn:   d(21, 6),
+		fc.EOFt:   d(21, 3),
+		fc.EOFdt:  d(21, 4),
+		fc.EOFa:   d(21, 7),
+		fc.EOFrt:  d(25, 4),
+		fc.EOFni:  d(21, 6),
+		fc.EOFdti: d(21, 4),
+		fc.EOFrti: d(25, 4),
+	}
+)
+
+// d returns the data character Dx.y.
+func d(x, y uint8) byte {
+	return y<<5 | x
+}
+
+// Primitive is a decoded ordered set. SOF, EOF and VCID are only set for
+// the types they belong to.
+type Primitive struct {
+	Type Type
+	SOF  fc.SOF
+	EOF  fc.EOF
+	VCID uint8
+}
+
+func (t Type) String() string {
+	if n, ok := typeNames[t]; ok {
+		return n
+	}
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
+// Sequence reports whether t is a primitive sequence, which is only
+// recognized after three consecutive occurrences.
+func (t Type) Sequence() bool {
+	return t == TypeNOS || t == TypeOLS || t == TypeLR || t == TypeLRR
+}
+
+// Signal reports whether t is a primitive signal.
+func (t Type) Signal() bool {
+	switch t {
+	case TypeIdle, TypeRRDY, TypeVCRDY, TypeBBSCs, TypeBBSCr:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p Primitive) String() string {
+	switch p.Type {
+	case TypeSOF:
+		return sofName(p.SOF)
+	case TypeEOF:
+		return eofName(p.EOF)
+	case TypeVCRDY:
+		return fmt.Sprintf("VC_RDY(%d)", p.VCID)
+	default:
+		return p.Type.String()
+	}
+}
+
+// Decode decodes an ordered set given as its four 8b/10b characters, the
+// first of which has to be K28.5.
+func Decode(os [4]byte) (Primitive, error) {
+	if os[0] != K28_5 {
+		return Primitive{}, errNotOrderedSet
+	}
+	return decode([3]byte{os[1], os[2], os[3]})
+}
+
+// decode decodes the three characters following K28.5.
+func decode(c [3]byte) (Primitive, error) {
+	for t, s := range signals {
+		if s == c {
+			return Primitive{Type: t}, nil
+		}
+	}
+	if c[0] == d(21, 7) && c[1] == c[2] {
+		return Primitive{Type: TypeVCRDY, VCID: c[1]}, nil
+	}
+	if c[1] != c[2] {
+		return Primitive{}, errUnknown
+	}
+	if c[0] == d(21, 5) {
+		for s, b := range sofs {
+			if b == c[1] {
+				return Primitive{Type: TypeSOF, SOF: s}, nil
+			}
+		}
+	}
+	valid := c[0] == d(21, 4) || c[0] == d(21, 5)
+	invalid := c[0] == d(10, 4) || c[0] == d(10, 5)
+	if valid || invalid {
+		for e, b := range eofs {
+			if b == c[1] && e.Invalid() == invalid && e != fc.EOFa {
+				return Primitive{Type: TypeEOF, EOF: e}, nil
+			}
+		}
+		if valid && c[1] == eofs[fc.EOFa] {
+			return Primitive{Type: TypeEOF, EOF: fc.EOFa}, nil
+		}
+	}
+	return Primitive{}, errUnknown
+}
+
+// Encode returns the four 8b/10b characters of the ordered set. rd is the
+// running disparity before the ordered set, it only matters for EOFs.
+func (p Primitive) Encode(rd Disparity) ([4]byte, error) {
+	c, err := p.encode(rd)
+	if err != nil {
+		return [4]byte{}, err
+	}
+	return [4]byte{K28_5, c[0], c[1], c[2]}, nil
+}
+
+// encode returns the three characters following K28.5.
+func (p Primitive) encode(rd Disparity) ([3]byte, error) {
+	switch p.Type {
+	case TypeSOF:
+		b, ok := sofs[p.SOF]
+		if !ok {
+			return [3]byte{}, errUnknown
+		}
+		return [3]byte{d(21, 5), b, b}, nil
+	case TypeEOF:
+		b, ok := eofs[p.EOF]
+		if !ok {
+			return [3]byte{}, errUnknown
+		}
+		first := d(21, 4)
+		if p.EOF.Invalid() && p.EOF != fc.EOFa {
+			first = d(10, 4)
+		}
+		if rd == RDPositive {
+			first |= 0x20
+		}
+		return [3]byte{first, b, b}, nil
+	case TypeVCRDY:
+		return [3]byte{d(21, 7), p.VCID, p.VCID}, nil
+	default:
+		s, ok := signals[p.Type]
+		if !ok {
+			return [3]byte{}, errUnknown
+		}
+		return s, nil
+	}
+}
+
+func sofName(s fc.SOF) string {
+	switch s {
+	case fc.SOFf:
+		return "SOFf"
+	case fc.SOFi2:
+		return "SOFi2"
+	case fc.SOFn2:
+		return "SOFn2"
+	case fc.SOFi3:
+		return "SOFi3"
+	case fc.SOFn3:
+		return "SOFn3"
+	case fc.SOFc4:
+		return "SOFc4"
+	case fc.SOFi4:
+		return "SOFi4"
+	case fc.SOFn4:
+		return "SOFn4"
+	default:
+		return fmt.Sprintf("SOF(%d)", int(s))
+	}
+}
+
+func eofName(e fc.EOF) string {
+	switch e {
+	case fc.EOFn:
+		return "EOFn"
+	case fc.EOFt:
+		return "EOFt"
+	case fc.EOFdt:
+		return "EOFdt"
+	case fc.EOFa:
+		return "EOFa"
+	case fc.EOFrt:
+		return "EOFrt"
+	case fc.EOFni:
+		return "EOFni"
+	case fc.EOFdti:
+		return "EOFdti"
+	case fc.EOFrti:
+		return "EOFrti"
+	default:
+		return fmt.Sprintf("EOF(%d)", int(e))
+	}
+}
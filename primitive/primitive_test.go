@@ -0,0 +1,181 @@
+package primitive
+
+import (
+	"reflect"
+	"testing"
+
+	fc "github.com/bluecmd/fibrechannel"
+)
+
+func TestDecode(t *testing.T) {
+	var tests = []struct {
+		os   [4]byte
+		want Primitive
+		name string
+	}{
+		{[4]byte{0xbc, 0x95, 0xb5, 0xb5}, Primitive{Type: TypeIdle}, "IDLE"},
+		{[4]byte{0xbc, 0x95, 0x4a, 0x4a}, Primitive{Type: TypeRRDY}, "R_RDY"},
+		{[4]byte{0xbc, 0xf5, 0x03, 0x03}, Primitive{Type: TypeVCRDY, VCID: 3}, "VC_RDY(3)"},
+		{[4]byte{0xbc, 0x95, 0x96, 0x96}, Primitive{Type: TypeBBSCs}, "BB_SCs"},
+		{[4]byte{0xbc, 0x95, 0xd6, 0xd6}, Primitive{Type: TypeBBSCr}, "BB_SCr"},
+		{[4]byte{0xbc, 0x55, 0xbf, 0x45}, Primitive{Type: TypeNOS}, "NOS"},
+		{[4]byte{0xbc, 0x35, 0x8a, 0x55}, Primitive{Type: TypeOLS}, "OLS"},
+		{[4]byte{0xbc, 0x49, 0xbf, 0x49}, Primitive{Type: TypeLR}, "LR"},
+		{[4]byte{0xbc, 0x35, 0xbf, 0x49}, Primitive{Type: TypeLRR}, "LRR"},
+		{[4]byte{0xbc, 0xb5, 0x56, 0x56}, Primitive{Type: TypeSOF, SOF: fc.SOFi3}, "SOFi3"},
+		{[4]byte{0xbc, 0xb5, 0x36, 0x36}, Primitive{Type: TypeSOF, SOF: fc.SOFn3}, "SOFn3"},
+		{[4]byte{0xbc, 0xb5, 0x58, 0x58}, Primitive{Type: TypeSOF, SOF: fc.SOFf}, "SOFf"},
+		{[4]byte{0xbc, 0x95, 0x75, 0x75}, Primitive{Type: TypeEOF, EOF: fc.EOFt}, "EOFt"},
+		{[4]byte{0xbc, 0xb5, 0x75, 0x75}, Primitive{Type: TypeEOF, EOF: fc.EOFt}, "EOFt"},
+		{[4]byte{0xbc, 0x95, 0xd5, 0xd5}, Primitive{Type: TypeEOF, EOF: fc.EOFn}, "EOFn"},
+		{[4]byte{0xbc, 0x8a, 0xd5, 0xd5}, Primitive{Type: TypeEOF, EOF: fc.EOFni}, "EOFni"},
+		{[4]byte{0xbc, 0x95, 0xf5, 0xf5}, Primitive{Type: TypeEOF, EOF: fc.EOFa}, "EOFa"},
+		{[4]byte{0xbc, 0xaa, 0x99, 0x99}, Primitive{Type: TypeEOF, EOF: fc.EOFrti}, "EOFrti"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.os)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, wanted %+v", got, tt.want)
+			}
+			if got.String() != tt.name {
+				t.Errorf("got name %q, wanted %q", got.String(), tt.name)
+			}
+			rd := RDNegative
+			if tt.os[1]&0x20 != 0 && got.Type == TypeEOF {
+				rd = RDPositive
+			}
+			os, err := got.Encode(rd)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if os != tt.os {
+				t.Fatalf("encoded to %x, wanted %x", os, tt.os)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	if _, err := Decode([4]byte{0x95, 0xb5, 0xb5, 0xbc}); err != errNotOrderedSet {
+		t.Errorf("got unexpected error %v, wanted %v", err, errNotOrderedSet)
+	}
+	if _, err := Decode([4]byte{0xbc, 0x00, 0x01, 0x02}); err != errUnknown {
+		t.Errorf("got unexpected error %v, wanted %v", err, errUnknown)
+	}
+	if _, err := (Primitive{}).Encode(RDNegative); err != errUnknown {
+		t.Errorf("got unexpected error %v, wanted %v", err, errUnknown)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	for _, ty := range []Type{TypeNOS, TypeOLS, TypeLR, TypeLRR} {
+		if !ty.Sequence() || ty.Signal() {
+			t.Errorf("%v is not a primitive sequence", ty)
+		}
+	}
+	for _, ty := range []Type{TypeIdle, TypeRRDY, TypeVCRDY} {
+		if ty.Sequence() || !ty.Signal() {
+			t.Errorf("%v is not a primitive signal", ty)
+		}
+	}
+}
+
+func TestDecodeBlock(t *testing.T) {
+	sofi3 := Primitive{Type: TypeSOF, SOF: fc.SOFi3}
+	eoft := Primitive{Type: TypeEOF, EOF: fc.EOFt}
+	rrdy := Primitive{Type: TypeRRDY}
+	lr := Primitive{Type: TypeLR}
+	var tests = []struct {
+		desc string
+		b    Block
+		want []Primitive
+		err  error
+	}{
+		{
+			desc: "data",
+			b:    Block{Sync: SyncData, Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		},
+		{
+			desc: "idle",
+			b:    Block{Sync: SyncControl, Data: [8]byte{BlockIdle}},
+			want: []Primitive{idle, idle},
+		},
+		{
+			desc: "R_RDY and IDLE",
+			b:    Block{Sync: SyncControl, Data: [8]byte{BlockOSIdle, 0x95, 0x4a, 0x4a}},
+			want: []Primitive{rrdy, idle},
+		},
+		{
+			desc: "IDLE and LR",
+			b:    Block{Sync: SyncControl, Data: [8]byte{BlockIdleOS, 0, 0, 0, 0, 0x49, 0xbf, 0x49}},
+			want: []Primitive{idle, lr},
+		},
+		{
+			desc: "R_RDY and SOF",
+			b:    Block{Sync: SyncControl, Data: [8]byte{BlockOSStart, 0x95, 0x4a, 0x4a, 0, 0xb5, 0x56, 0x56}},
+			want: []Primitive{rrdy, sofi3},
+		},
+		{
+			desc: "SOF",
+			b:    Block{Sync: SyncControl, Data: [8]byte{BlockStart, 0xb5, 0x56, 0x56, 0x22, 0xff, 0xff, 0xfd}},
+			want: []Primitive{sofi3},
+		},
+		{
+			desc: "EOF and IDLE",
+			b:    Block{Sync: SyncControl, Data: [8]byte{BlockEOFIdle, 0x95, 0x75, 0x75}},
+			want: []Primitive{eoft, idle},
+		},
+		{
+			desc: "data and EOF",
+			b:    Block{Sync: SyncControl, Data: [8]byte{BlockDataEOF, 1, 2, 3, 4, 0x95, 0x75, 0x75}},
+			want: []Primitive{eoft},
+		},
+		{
+			desc: "SOF in place of EOF",
+			b:    Block{Sync: SyncControl, Data: [8]byte{BlockEOFIdle, 0xb5, 0x56, 0x56}},
+			err:  errUnknown,
+		},
+		{
+			desc: "bad sync header",
+			b:    Block{Sync: 0x3},
+			err:  errSync,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := DecodeBlock(tt.b)
+			if err != tt.err {
+				t.Fatalf("got unexpected error %v, wanted %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, wanted %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeBlock(t *testing.T) {
+	words := []Primitive{idle, {Type: TypeRRDY}, {Type: TypeVCRDY, VCID: 7}, {Type: TypeNOS}}
+	for _, w0 := range words {
+		for _, w1 := range words {
+			b, err := EncodeBlock(w0, w1)
+			if err != nil {
+				t.Fatalf("EncodeBlock(%v, %v): %v", w0, w1, err)
+			}
+			got, err := DecodeBlock(b)
+			if err != nil {
+				t.Fatalf("DecodeBlock(%v): %v", b, err)
+			}
+			if want := []Primitive{w0, w1}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, wanted %v", got, want)
+			}
+		}
+	}
+	if _, err := EncodeBlock(Primitive{Type: TypeSOF, SOF: fc.SOFi3}, idle); err != errBlockType {
+		t.Fatalf("got unexpected error %v, wanted %v", err, errBlockType)
+	}
+}
@@ -0,0 +1,416 @@
+// Package session follows the logins between ports in a stream of captured
+// frames. It tracks the fabric logins made with FLOGI and FDISC, the N_Port
+// logins made with PLOGI and the process logins made with PRLI, and ends
+// them once a PRLO or LOGO is accepted as well as implicitly on a new FLOGI or PLOGI, on an
+// RSCN for the port and on an LS_RJT asking for a login. The resulting
+// table can be queried at any point and every change is reported as an
+// Event.
+package session
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/els"
+	"github.com/bluecmd/fibrechannel/exchange"
+)
+
+// Kind is the kind of an Event.
+type Kind int
+
+const (
+	KindFabricLogin   Kind = iota // An N_Port logged in with the fabric
+	KindFabricLogout              // An N_Port logged out from the fabric
+	KindPortLogin                 // Two N_Ports logged in with each other
+	KindPortLogout                // Two N_Ports logged out from each other
+	KindProcessLogin              // An image pair was established
+	KindProcessLogout             // An image pair was removed
+	KindRejected                  // A login was rejected
+)
+
+var kindNames = map[Kind]string{
+	KindFabricLogin:   "fabric login",
+	KindFabricLogout:  "fabric logout",
+	KindPortLogin:     "port login",
+	KindPortLogout:    "port logout",
+	KindProcessLogin:  "process login",
+	KindProcessLogout: "process logout",
+	KindRejected:      "login rejected",
+}
+
+// Event is a change of the login state. Port is the N_Port the change was
+// initiated by, if known, and Peer the other port. For fabric logins Peer
+// is the F_Port Controller. Type is the FC-4 TYPE of process logins and
+// Cause the ELS command that made the change.
+type Event struct {
+	Time  time.Time
+	Kind  Kind
+	Port  common.FCID
+	Peer  common.FCID
+	Type  uint8
+	Cause els.Command
+}
+
+// FabricLogin is an N_Port logged in with the fabric. Discovery is set if
+// the login was made with FDISC.
+type FabricLogin struct {
+	Port      common.FCID
+	PortName  common.WWN
+	NodeName  common.WWN
+	Since     time.Time
+	Discovery bool
+}
+
+// Session is an N_Port login between two ports. Processes holds the FC-4
+// TYPEs with an established image pair and the time they were established.
+type Session struct {
+	Originator     common.FCID
+	Responder      common.FCID
+	OriginatorName common.WWN
+	ResponderName  common.WWN
+	Since          time.Time
+	Processes      map[uint8]time.Time
+}
+
+// raTOV is the default R_A_TOV, after which a request not replied to is
+// forgotten.
+const raTOV = 10 * time.Second
+
+// Tracker follows the logins in a stream of frames. OnEvent is called for
+// every change of the login state.
+type Tracker struct {
+	OnEvent func(Event)
+
+	fabric   map[common.FCID]*FabricLogin
+	sessions map[pair]*Session
+	// pending holds the outstanding requests by their Exchange, expired
+	// is when they were last checked for R_A_TOV
+	pending map[exchange.Key]outstanding
+	expired time.Time
+}
+
+// outstanding is an ELS request and the time it was sent.
+type outstanding struct {
+	payload interface{}
+	time    time.Time
+}
+
+// pair is the key of a Session, the lower port first.
+type pair [2]common.FCID
+
+// NewTracker returns a Tracker without any logins.
+func NewTracker() *Tracker {
+	return &Tracker{
+		fabric:   map[common.FCID]*FabricLogin{},
+		sessions: map[pair]*Session{},
+		pending:  map[exchange.Key]outstanding{},
+	}
+}
+
+func (k Kind) String() string {
+	if n, ok := kindNames[k]; ok {
+		return n
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+func (e Event) String() string {
+	s := fmt.Sprintf("%v: %v<->%v", e.Kind, e.Port, e.Peer)
+	if e.Kind == KindProcessLogin || e.Kind == KindProcessLogout {
+		s += fmt.Sprintf(" TYPE 0x%02x", e.Type)
+	}
+	return s + " by " + e.Cause.String()
+}
+
+// HasProcess reports whether an image pair for the FC-4 TYPE t is
+// established.
+func (s *Session) HasProcess(t uint8) bool {
+	_, ok := s.Processes[t]
+	return ok
+}
+
+func key(a, b common.FCID) pair {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	return pair{a, b}
+}
+
+// Add feeds the next captured frame. Frames not carrying an ELS are
+// ignored, except for a BA_ACC ending an Exchange with a request
+// outstanding.
+func (t *Tracker) Add(ts time.Time, f *fc.Frame) {
+	t.expire(ts)
+	k := exchange.KeyOf(f)
+	if f.RCtl == fc.RCtlBAACC {
+		delete(t.pending, k)
+		return
+	}
+	p, ok := f.Payload.(*els.Frame)
+	if !ok {
+		return
+	}
+	switch r := p.Payload.(type) {
+	case *els.LSACC:
+		if req, ok := t.request(k); ok {
+			t.accept(ts, f, k, req, r)
+		}
+	case *els.LSRJT:
+		if req, ok := t.request(k); ok {
+			t.reject(ts, k, req, r)
+		}
+	case *els.RSCN:
+		for _, s := range t.Sessions() {
+			peer, ok := s.peer(f.DestinationID)
+			if !ok {
+				continue
+			}
+			for _, pg := range r.Pages {
+				if pg.Affects(peer) {
+					t.logout(ts, f.DestinationID, peer, els.CmdRSCN)
+					break
+				}
+			}
+		}
+	default:
+		t.pending[k] = outstanding{payload: r, time: ts}
+	}
+}
+
+// expire forgets the requests which have not been replied to within
+// R_A_TOV.
+func (t *Tracker) expire(ts time.Time) {
+	if ts.Sub(t.expired) < raTOV {
+		return
+	}
+	t.expired = ts
+	for k, req := range t.pending {
+		if ts.Sub(req.time) >= raTOV {
+			delete(t.pending, k)
+		}
+	}
+}
+
+// request returns and forgets the request a reply is for. The reply to a
+// FLOGI or FDISC is sent to the address just assigned instead of the
+// address 000000 the request came from.
+func (t *Tracker) request(k exchange.Key) (interface{}, bool) {
+	req, ok := t.pending[k]
+	if !ok && k.Responder == common.FPortController {
+		k.Originator = common.FCID{}
+		req, ok = t.pending[k]
+	}
+	delete(t.pending, k)
+	return req.payload, ok
+}
+
+func (t *Tracker) accept(ts time.Time, f *fc.Frame, k exchange.Key, req interface{}, acc *els.LSACC) {
+	switch r := req.(type) {
+	case *els.FLOGI:
+		t.fabricLogin(ts, f.DestinationID, r.PortName, r.NodeName, false, els.CmdFLOGI)
+	case *els.FDISC:
+		t.fabricLogin(ts, f.DestinationID, r.PortName, r.NodeName, true, els.CmdFDISC)
+	case *els.PLOGI:
+		t.logout(ts, k.Originator, k.Responder, els.CmdPLOGI)
+		s := &Session{
+			Originator:     k.Originator,
+			Responder:      k.Responder,
+			OriginatorName: r.PortName,
+			Since:          ts,
+			Processes:      map[uint8]time.Time{},
+		}
+		a := &els.PLOGI{}
+		if _, err := a.ReadFrom(bytes.NewReader(acc.Data)); err == nil {
+			s.ResponderName = a.PortName
+		}
+		t.sessions[key(k.Originator, k.Responder)] = s
+		t.emit(Event{Time: ts, Kind: KindPortLogin, Port: k.Originator, Peer: k.Responder, Cause: els.CmdPLOGI})
+	case *els.PRLI:
+		a := &els.PRLI{}
+		if _, err := a.ReadFrom(bytes.NewReader(acc.Data)); err != nil {
+			return
+		}
+		for _, pg := range a.Pages {
+			if pg.Flags&els.PRLIImagePair == 0 || pg.Response() != els.PRLIRequestExecuted {
+				continue
+			}
+			t.processLogin(ts, k.Originator, k.Responder, pg.Type)
+		}
+	case *els.PRLO:
+		for _, pg := range r.Pages {
+			t.processLogout(ts, k.Originator, k.Responder, pg.Type, els.CmdPRLO)
+		}
+	case *els.LOGO:
+		if k.Responder == common.FPortController {
+			t.fabricLogout(ts, k.Originator, els.CmdLOGO)
+		} else {
+			t.logout(ts, k.Originator, k.Responder, els.CmdLOGO)
+		}
+	}
+}
+
+// reject handles an LS_RJT. A request rejected because the N_Port login is
+// missing means the ports are logged out from each other.
+func (t *Tracker) reject(ts time.Time, k exchange.Key, req interface{}, rjt *els.LSRJT) {
+	if rjt.Explanation == els.ExplNoLogin {
+		t.logout(ts, k.Originator, k.Responder, els.CmdLSRJT)
+	}
+	switch req.(type) {
+	case *els.FLOGI, *els.FDISC, *els.PLOGI, *els.PRLI:
+		t.emit(Event{Time: ts, Kind: KindRejected, Port: k.Originator, Peer: k.Responder, Cause: els.CmdLSRJT})
+	}
+}
+
+// fabricLogin records a fabric login. A FLOGI implicitly logs the N_Port
+// out of everything it was logged in with before.
+func (t *Tracker) fabricLogin(ts time.Time, port common.FCID, pn, nn common.WWN, fdisc bool, cause els.Command) {
+	if !fdisc {
+		t.fabricLogout(ts, port, cause)
+	}
+	t.fabric[port] = &FabricLogin{Port: port, PortName: pn, NodeName: nn, Since: ts, Discovery: fdisc}
+	t.emit(Event{Time: ts, Kind: KindFabricLogin, Port: port, Peer: common.FPortController, Cause: cause})
+}
+
+// fabricLogout ends the fabric login of a port and all of its sessions.
+func (t *Tracker) fabricLogout(ts time.Time, port common.FCID, cause els.Command) {
+	for _, s := range t.Sessions() {
+		if peer, ok := s.peer(port); ok {
+			t.logout(ts, port, peer, cause)
+		}
+	}
+	if _, ok := t.fabric[port]; !ok {
+		return
+	}
+	delete(t.fabric, port)
+	t.emit(Event{Time: ts, Kind: KindFabricLogout, Port: port, Peer: common.FPortController, Cause: cause})
+}
+
+// logout ends the session between two ports and its process logins.
+func (t *Tracker) logout(ts time.Time, port, peer common.FCID, cause els.Command) {
+	s, ok := t.sessions[key(port, peer)]
+	if !ok {
+		return
+	}
+	for _, ty := range s.types() {
+		t.processLogout(ts, port, peer, ty, cause)
+	}
+	delete(t.sessions, key(port, peer))
+	t.emit(Event{Time: ts, Kind: KindPortLogout, Port: port, Peer: peer, Cause: cause})
+}
+
+// processLogin establishes an image pair. Captures starting after the
+// PLOGI lack the session, it is created as if logged in by the PRLI.
+func (t *Tracker) processLogin(ts time.Time, port, peer common.FCID, ty uint8) {
+	s, ok := t.sessions[key(port, peer)]
+	if !ok {
+		s = &Session{Originator: port, Responder: peer, Since: ts, Processes: map[uint8]time.Time{}}
+		t.sessions[key(port, peer)] = s
+		t.emit(Event{Time: ts, Kind: KindPortLogin, Port: port, Peer: peer, Cause: els.CmdPRLI})
+	}
+	if _, ok := s.Processes[ty]; ok {
+		return
+	}
+	s.Processes[ty] = ts
+	t.emit(Event{Time: ts, Kind: KindProcessLogin, Port: port, Peer: peer, Type: ty, Cause: els.CmdPRLI})
+}
+
+func (t *Tracker) processLogout(ts time.Time, port, peer common.FCID, ty uint8, cause els.Command) {
+	s, ok := t.sessions[key(port, peer)]
+	if !ok {
+		return
+	}
+	if _, ok := s.Processes[ty]; !ok {
+		return
+	}
+	delete(s.Processes, ty)
+	t.emit(Event{Time: ts, Kind: KindProcessLogout, Port: port, Peer: peer, Type: ty, Cause: cause})
+}
+
+func (t *Tracker) emit(e Event) {
+	if t.OnEvent != nil {
+		t.OnEvent(e)
+	}
+}
+
+// FabricLogins returns the N_Ports logged in with the fabric, ordered by
+// address.
+func (t *Tracker) FabricLogins() []FabricLogin {
+	r := make([]FabricLogin, 0, len(t.fabric))
+	for _, l := range t.fabric {
+		r = append(r, *l)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		return bytes.Compare(r[i].Port[:], r[j].Port[:]) < 0
+	})
+	return r
+}
+
+// LoggedIn reports whether the N_Port is logged in with the fabric.
+func (t *Tracker) LoggedIn(port common.FCID) bool {
+	_, ok := t.fabric[port]
+	return ok
+}
+
+// Sessions returns a copy of the N_Port logins, ordered by the addresses of
+// the ports.
+func (t *Tracker) Sessions() []Session {
+	keys := make([]pair, 0, len(t.sessions))
+	for k := range t.sessions {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if c := bytes.Compare(keys[i][0][:], keys[j][0][:]); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(keys[i][1][:], keys[j][1][:]) < 0
+	})
+	r := make([]Session, 0, len(keys))
+	for _, k := range keys {
+		r = append(r, t.sessions[k].copy())
+	}
+	return r
+}
+
+// Session returns a copy of the N_Port login between two ports, in either
+// direction.
+func (t *Tracker) Session(a, b common.FCID) (Session, bool) {
+	s, ok := t.sessions[key(a, b)]
+	if !ok {
+		return Session{}, false
+	}
+	return s.copy(), true
+}
+
+func (s *Session) copy() Session {
+	c := *s
+	c.Processes = make(map[uint8]time.Time, len(s.Processes))
+	for ty, ts := range s.Processes {
+		c.Processes[ty] = ts
+	}
+	return c
+}
+
+// peer returns the other port of the session if port is part of it.
+func (s *Session) peer(port common.FCID) (common.FCID, bool) {
+	switch port {
+	case s.Originator:
+		return s.Responder, true
+	case s.Responder:
+		return s.Originator, true
+	}
+	return common.FCID{}, false
+}
+
+// types returns the FC-4 TYPEs of the process logins in order.
+func (s *Session) types() []uint8 {
+	r := make([]uint8, 0, len(s.Processes))
+	for ty := range s.Processes {
+		r = append(r, ty)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i] < r[j] })
+	return r
+}
@@ -0,0 +1,264 @@
+package session
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	fc "github.com/bluecmd/fibrechannel"
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/els"
+)
+
+var (
+	t0        = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	initiator = common.FCID{0x01, 0x02, 0x00}
+	target    = common.FCID{0x01, 0x04, 0x00}
+	fabric    = common.FPortController
+)
+
+// request returns an ELS request frame.
+func request(src, dst common.FCID, oxid uint16, p interface{}) *fc.Frame {
+	return &fc.Frame{SourceID: src, DestinationID: dst, OXID: oxid, RXID: 0xffff, Payload: &els.Frame{Payload: p}}
+}
+
+// reply returns an ELS reply frame, sent by the Exchange Responder.
+func reply(src, dst common.FCID, oxid uint16, p interface{}) *fc.Frame {
+	f := request(src, dst, oxid, p)
+	f.FCtl.SetValue(fc.FCtlExchangeContext)
+	return f
+}
+
+func acc(p els.Frame) *els.LSACC {
+	b := new(bytes.Buffer)
+	if _, err := p.WriteTo(b); err != nil {
+		panic(err)
+	}
+	return &els.LSACC{Data: b.Bytes()[1:]}
+}
+
+func prli(flags uint8, types ...uint8) *els.PRLI {
+	p := &els.PRLI{}
+	for _, ty := range types {
+		p.Pages = append(p.Pages, els.PRLIPage{Type: ty, Flags: flags})
+	}
+	return p
+}
+
+func run(tr *Tracker, frames []*fc.Frame) []Event {
+	var r []Event
+	tr.OnEvent = func(e Event) { r = append(r, e) }
+	for i, f := range frames {
+		tr.Add(t0.Add(time.Duration(i)*time.Millisecond), f)
+	}
+	return r
+}
+
+func ms(n int) time.Time {
+	return t0.Add(time.Duration(n) * time.Millisecond)
+}
+
+// login returns the frames logging both ports in with the fabric and with
+// each other, and establishing an FCP image pair.
+func login() []*fc.Frame {
+	return []*fc.Frame{
+		request(common.FCID{}, fabric, 1, &els.FLOGI{PortName: common.WWN{0x10, 0, 0, 0, 0, 0, 0, 1}}),
+		reply(fabric, initiator, 1, acc(els.Frame{Payload: &els.FLOGI{}})),
+		request(common.FCID{}, fabric, 1, &els.FLOGI{PortName: common.WWN{0x10, 0, 0, 0, 0, 0, 0, 2}}),
+		reply(fabric, target, 1, acc(els.Frame{Payload: &els.FLOGI{}})),
+		request(initiator, target, 2, &els.PLOGI{PortName: common.WWN{0x10, 0, 0, 0, 0, 0, 0, 1}}),
+		reply(target, initiator, 2, acc(els.Frame{Payload: &els.PLOGI{PortName: common.WWN{0x10, 0, 0, 0, 0, 0, 0, 2}}})),
+		request(initiator, target, 3, prli(els.PRLIImagePair, els.FC4TypeFCP)),
+		reply(target, initiator, 3, acc(els.Frame{Payload: prli(els.PRLIImagePair|els.PRLIRequestExecuted, els.FC4TypeFCP)})),
+	}
+}
+
+func TestLogin(t *testing.T) {
+	tr := NewTracker()
+	got := run(tr, login())
+	want := []Event{
+		{Time: ms(1), Kind: KindFabricLogin, Port: initiator, Peer: fabric, Cause: els.CmdFLOGI},
+		{Time: ms(3), Kind: KindFabricLogin, Port: target, Peer: fabric, Cause: els.CmdFLOGI},
+		{Time: ms(5), Kind: KindPortLogin, Port: initiator, Peer: target, Cause: els.CmdPLOGI},
+		{Time: ms(7), Kind: KindProcessLogin, Port: initiator, Peer: target, Type: els.FC4TypeFCP, Cause: els.CmdPRLI},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected events:\n- want: %v\n-  got: %v", want, got)
+	}
+	if !tr.LoggedIn(initiator) || !tr.LoggedIn(target) || len(tr.FabricLogins()) != 2 {
+		t.Errorf("unexpected fabric logins %+v", tr.FabricLogins())
+	}
+	if tr.FabricLogins()[0].PortName != (common.WWN{0x10, 0, 0, 0, 0, 0, 0, 1}) {
+		t.Errorf("unexpected port name %v", tr.FabricLogins()[0].PortName)
+	}
+	s, ok := tr.Session(target, initiator)
+	if !ok || s.Originator != initiator || s.ResponderName != (common.WWN{0x10, 0, 0, 0, 0, 0, 0, 2}) {
+		t.Fatalf("unexpected session %+v", s)
+	}
+	if !s.HasProcess(els.FC4TypeFCP) || s.HasProcess(els.FC4TypeNVMe) {
+		t.Errorf("unexpected process logins %v", s.Processes)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		frames []*fc.Frame
+		want   []Event
+		fabric int
+	}{
+		{
+			name: "prlo",
+			frames: []*fc.Frame{
+				request(initiator, target, 4, &els.PRLO{Pages: prli(0, els.FC4TypeFCP).Pages}),
+				reply(target, initiator, 4, acc(els.Frame{Payload: &els.PRLO{Pages: prli(els.PRLIRequestExecuted, els.FC4TypeFCP).Pages}})),
+			},
+			want: []Event{
+				{Time: ms(9), Kind: KindProcessLogout, Port: initiator, Peer: target, Type: els.FC4TypeFCP, Cause: els.CmdPRLO},
+			},
+			fabric: 2,
+		},
+		{
+			name: "logo",
+			frames: []*fc.Frame{
+				request(target, initiator, 4, &els.LOGO{PortID: target}),
+				reply(initiator, target, 4, &els.LSACC{}),
+			},
+			want: []Event{
+				{Time: ms(9), Kind: KindProcessLogout, Port: target, Peer: initiator, Type: els.FC4TypeFCP, Cause: els.CmdLOGO},
+				{Time: ms(9), Kind: KindPortLogout, Port: target, Peer: initiator, Cause: els.CmdLOGO},
+			},
+			fabric: 2,
+		},
+		{
+			name: "fabric logo",
+			frames: []*fc.Frame{
+				request(initiator, fabric, 4, &els.LOGO{PortID: initiator}),
+				reply(fabric, initiator, 4, &els.LSACC{}),
+			},
+			want: []Event{
+				{Time: ms(9), Kind: KindProcessLogout, Port: initiator, Peer: target, Type: els.FC4TypeFCP, Cause: els.CmdLOGO},
+				{Time: ms(9), Kind: KindPortLogout, Port: initiator, Peer: target, Cause: els.CmdLOGO},
+				{Time: ms(9), Kind: KindFabricLogout, Port: initiator, Peer: fabric, Cause: els.CmdLOGO},
+			},
+			fabric: 1,
+		},
+		{
+			name: "logo rejected",
+			frames: []*fc.Frame{
+				request(target, initiator, 4, &els.LOGO{PortID: target}),
+				reply(initiator, target, 4, &els.LSRJT{Reason: els.ReasonUnableToPerform}),
+			},
+			fabric: 2,
+		},
+		{
+			name: "rscn",
+			frames: []*fc.Frame{
+				request(common.FabricController, initiator, 4, &els.RSCN{Pages: els.RSCNPages{
+					{Flags: els.RSCNAddressArea, Address: common.FCID{0x01, 0x04, 0x00}},
+				}}),
+			},
+			want: []Event{
+				{Time: ms(8), Kind: KindProcessLogout, Port: initiator, Peer: target, Type: els.FC4TypeFCP, Cause: els.CmdRSCN},
+				{Time: ms(8), Kind: KindPortLogout, Port: initiator, Peer: target, Cause: els.CmdRSCN},
+			},
+			fabric: 2,
+		},
+		{
+			name: "rscn elsewhere",
+			frames: []*fc.Frame{
+				request(common.FabricController, initiator, 4, &els.RSCN{Pages: els.RSCNPages{
+					{Flags: els.RSCNAddressDomain, Address: common.FCID{0x02, 0x00, 0x00}},
+				}}),
+			},
+			fabric: 2,
+		},
+		{
+			name: "ls_rjt",
+			frames: []*fc.Frame{
+				request(target, initiator, 4, &els.PRLI{}),
+				reply(initiator, target, 4, &els.LSRJT{Reason: els.ReasonUnableToPerform, Explanation: els.ExplNoLogin}),
+			},
+			want: []Event{
+				{Time: ms(9), Kind: KindProcessLogout, Port: target, Peer: initiator, Type: els.FC4TypeFCP, Cause: els.CmdLSRJT},
+				{Time: ms(9), Kind: KindPortLogout, Port: target, Peer: initiator, Cause: els.CmdLSRJT},
+				{Time: ms(9), Kind: KindRejected, Port: target, Peer: initiator, Cause: els.CmdLSRJT},
+			},
+			fabric: 2,
+		},
+		{
+			name: "flogi",
+			frames: []*fc.Frame{
+				request(common.FCID{}, fabric, 5, &els.FLOGI{}),
+				reply(fabric, target, 5, acc(els.Frame{Payload: &els.FLOGI{}})),
+			},
+			want: []Event{
+				{Time: ms(9), Kind: KindProcessLogout, Port: target, Peer: initiator, Type: els.FC4TypeFCP, Cause: els.CmdFLOGI},
+				{Time: ms(9), Kind: KindPortLogout, Port: target, Peer: initiator, Cause: els.CmdFLOGI},
+				{Time: ms(9), Kind: KindFabricLogout, Port: target, Peer: fabric, Cause: els.CmdFLOGI},
+				{Time: ms(9), Kind: KindFabricLogin, Port: target, Peer: fabric, Cause: els.CmdFLOGI},
+			},
+			fabric: 2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := NewTracker()
+			run(tr, login())
+			var got []Event
+			tr.OnEvent = func(e Event) { got = append(got, e) }
+			for i, f := range tc.frames {
+				tr.Add(ms(len(login())+i), f)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("unexpected events:\n- want: %v\n-  got: %v", tc.want, got)
+			}
+			if n := len(tr.FabricLogins()); n != tc.fabric {
+				t.Errorf("got %d fabric logins, wanted %d", n, tc.fabric)
+			}
+		})
+	}
+}
+
+func TestPendingExpired(t *testing.T) {
+	tr := NewTracker()
+	run(tr, login())
+	var got []Event
+	tr.OnEvent = func(e Event) { got = append(got, e) }
+	tr.Add(ms(8), request(target, initiator, 4, &els.LOGO{PortID: target}))
+	tr.Add(ms(9), request(initiator, target, 5, &els.LOGO{PortID: initiator}))
+	baacc := &fc.Frame{RCtl: fc.RCtlBAACC, SourceID: target, DestinationID: initiator, OXID: 5}
+	baacc.FCtl.SetValue(fc.FCtlExchangeContext)
+	tr.Add(ms(10), baacc)
+	if len(tr.pending) != 1 {
+		t.Fatalf("got %d pending requests after BA_ACC, wanted 1", len(tr.pending))
+	}
+	tr.Add(ms(8).Add(raTOV), reply(initiator, target, 4, &els.LSACC{}))
+	if len(got) != 0 || len(tr.pending) != 0 {
+		t.Fatalf("got events %v and %d pending requests after R_A_TOV", got, len(tr.pending))
+	}
+	if len(tr.Sessions()) != 1 {
+		t.Errorf("unexpected sessions %v", tr.Sessions())
+	}
+}
+
+func TestMidCapture(t *testing.T) {
+	tr := NewTracker()
+	got := run(tr, []*fc.Frame{
+		request(initiator, target, 3, prli(els.PRLIImagePair, els.FC4TypeFCP, els.FC4TypeNVMe)),
+		reply(target, initiator, 3, acc(els.Frame{Payload: &els.PRLI{Pages: els.PRLIPages{
+			{Type: els.FC4TypeFCP, Flags: els.PRLIImagePair | els.PRLINoResources},
+			{Type: els.FC4TypeNVMe, Flags: els.PRLIImagePair | els.PRLIRequestExecuted},
+		}}})),
+	})
+	want := []Event{
+		{Time: ms(1), Kind: KindPortLogin, Port: initiator, Peer: target, Cause: els.CmdPRLI},
+		{Time: ms(1), Kind: KindProcessLogin, Port: initiator, Peer: target, Type: els.FC4TypeNVMe, Cause: els.CmdPRLI},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected events:\n- want: %v\n-  got: %v", want, got)
+	}
+	if tr.LoggedIn(initiator) || len(tr.Sessions()) != 1 {
+		t.Errorf("unexpected table %v %v", tr.FabricLogins(), tr.Sessions())
+	}
+}
@@ -0,0 +1,50 @@
+package swils
+
+import (
+	"io"
+
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+// DIA is sent by a switch on its E_Ports once it has been assigned a
+// Domain_ID, allowing the switches downstream of it to request theirs.
+type DIA struct {
+	SwitchName common.WWN `fc:"@3"`
+}
+
+// BF requests a non-disruptive reconfiguration of the fabric, restarting
+// the Principal Switch selection while the switches keep their Domain_IDs
+// where possible. It has no payload besides the command.
+type BF struct{}
+
+// RCF requests a disruptive reconfiguration of the fabric, where all
+// Domain_IDs are given up. It has no payload besides the command.
+type RCF BF
+
+func (s *DIA) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *DIA) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, s)
+}
+
+func (s *BF) ReadFrom(r io.Reader) (int64, error) {
+	var bs [3]byte
+	n, err := io.ReadFull(r, bs[:])
+	return int64(n), err
+}
+
+func (s *BF) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write([]byte{0, 0, 0})
+	return int64(n), err
+}
+
+func (s *RCF) ReadFrom(r io.Reader) (int64, error) {
+	return (*BF)(s).ReadFrom(r)
+}
+
+func (s *RCF) WriteTo(w io.Writer) (int64, error) {
+	return (*BF)(s).WriteTo(w)
+}
@@ -0,0 +1,122 @@
+package swils
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+const (
+	EFPRecordDomainIDList  = 0x01 // Domain_ID_List record
+	EFPRecordMulticastList = 0x02 // Multicast_ID_List record
+)
+
+// EFP is used both for the EFP request and its SW_ACC, they share layout.
+type EFP struct {
+	PageLength              uint8         `fc:"@0"`
+	PayloadLength           uint16        `fc:"@1"`
+	PrincipalSwitchPriority uint8         `fc:"@6"`
+	PrincipalSwitchName     common.WWN    `fc:"@7"`
+	Records                 EFPRecordList `fc:"@15"`
+}
+
+// EFPRecord is a Domain_ID_List or Multicast_ID_List record. ID holds the
+// Domain_ID or Multicast_ID depending on Type, SwitchName is only valid for
+// Domain_ID_List records.
+type EFPRecord struct {
+	Type       uint8      `fc:"@0"`
+	ID         uint8      `fc:"@1"`
+	SwitchName common.WWN `fc:"@8"`
+}
+
+type EFPRecordList []EFPRecord
+
+func (s *EFP) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *EFP) WriteTo(w io.Writer) (int64, error) {
+	o := *s
+	if o.PageLength == 0 {
+		o.PageLength = 16
+	}
+	if o.PayloadLength == 0 {
+		o.PayloadLength = uint16(16 + 16*len(o.Records))
+	}
+	return encoding.WriteTo(w, &o)
+}
+
+func (s *EFPRecord) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *EFPRecord) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, s)
+}
+
+func (p *EFPRecordList) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	*p = EFPRecordList{}
+	for {
+		var b [16]byte
+		c, err := io.ReadFull(r, b[:])
+		n += int64(c)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		rec := EFPRecord{Type: b[0], ID: b[1]}
+		copy(rec.SwitchName[:], b[8:])
+		*p = append(*p, rec)
+	}
+}
+
+func (p *EFPRecordList) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for _, rec := range *p {
+		var b [16]byte
+		b[0] = rec.Type
+		b[1] = rec.ID
+		copy(b[8:], rec.SwitchName[:])
+		c, err := w.Write(b[:])
+		n += int64(c)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// DomainIDList is a list of Domain_IDs, each stored right-aligned in a word.
+type DomainIDList []uint8
+
+func (p *DomainIDList) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	*p = DomainIDList{}
+	for {
+		var w uint32
+		if err := binary.Read(r, binary.BigEndian, &w); err != nil {
+			if err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+		n += 4
+		*p = append(*p, uint8(w))
+	}
+}
+
+func (p *DomainIDList) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for _, d := range *p {
+		if err := binary.Write(w, binary.BigEndian, uint32(d)); err != nil {
+			return n, err
+		}
+		n += 4
+	}
+	return n, nil
+}
@@ -1,25 +1,102 @@
 package swils
 
 import (
+	"encoding/binary"
+	"fmt"
 	"io"
 
 	"github.com/bluecmd/fibrechannel/common"
 	"github.com/bluecmd/fibrechannel/encoding"
 )
 
+const (
+	FlowControlVendor = 0x0001 // Vendor unique
+	FlowControlRRDY   = 0x0002 // R_RDY flow control
+	FlowControlVCRDY  = 0x0003 // VC_RDY flow control
+)
+
+// ELP is used both for the ELP request and its SW_ACC, they share layout.
+// The Reserved fields, here and in the parameters, hold what is not decoded
+// so that an ELP is written back exactly as read.
 type ELP struct {
-	Revision           uint8                       `fc:"@0"`
-	Flags              uint16                      `fc:"@1"`
-	BBSCN              uint8                       `fc:"@3"`
-	RATOV              uint32                      `fc:"@4"`
-	EDTOV              uint32                      `fc:"@8"`
-	Port               common.WWN                  `fc:"@12"` // RequesterPortName
-	Switch             common.WWN                  `fc:"@20"` // RequesterSwitchName
-	ClassFParameters   [16]byte                    `fc:"@28"`
-	Class2Parameters   [4]byte                     `fc:"@48"`
-	Class3Parameters   [4]byte                     `fc:"@52"`
-	ISLFlowControlMode uint16                      `fc:"@76"`
-	FCParam            common.Uint16SizedByteArray `fc:"@78"`
+	Reserved1        [3]byte          `fc:"@0"`
+	Revision         uint8            `fc:"@3"`
+	Flags            ELPFlags         `fc:"@4"`
+	BBSCN            uint8            `fc:"@6"`
+	RATOV            uint32           `fc:"@7"`
+	EDTOV            uint32           `fc:"@11"`
+	Port             common.WWN       `fc:"@15"` // RequesterPortName
+	Switch           common.WWN       `fc:"@23"` // RequesterSwitchName
+	ClassFParameters ClassFParameters `fc:"@31"`
+	Class1Parameters ClassParameters  `fc:"@47"`
+	Class2Parameters ClassParameters  `fc:"@51"`
+	Class3Parameters ClassParameters  `fc:"@55"`
+	Reserved2        [20]byte         `fc:"@59"`
+	FlowControl      FlowControl      `fc:"@79"`
+}
+
+// ELPFlags are the flags of an ELP, Reserved holds the other bits.
+type ELPFlags struct {
+	BridgePort     bool
+	VirtualFabrics bool
+	Reserved       uint16
+}
+
+// ClassFParameters are the Class F parameters of an ELP. Reserved holds the
+// bits of the 16 bytes not decoded, the decoded ones are zero in it.
+type ClassFParameters struct {
+	Valid                bool
+	ReceiveDataFieldSize uint16
+	ConcurrentSeq        uint8
+	E2ECredits           uint16
+	OpenSeqPerExch       uint8
+	Reserved             [16]byte
+}
+
+// ClassParameters are the Class 1, 2 and 3 parameters of an ELP. Reserved
+// holds the bits of the word not decoded.
+type ClassParameters struct {
+	Valid                bool
+	SequentialDelivery   bool
+	ReceiveDataFieldSize uint16
+	Reserved             uint32
+}
+
+const (
+	elpFlagBridgePort     = 0x8000
+	elpFlagVirtualFabrics = 0x4000
+
+	classValid              = 0x80000000
+	classSequentialDelivery = 0x08000000
+	classReceiveSize        = 0x0000ffff
+)
+
+// classFMask has the bits of the Class F parameters which are decoded set.
+var classFMask = [16]byte{0: 0x80, 6: 0xff, 7: 0xff, 9: 0xff, 10: 0xff, 11: 0xff, 13: 0xff}
+
+// FlowControl holds the ISL flow control mode and the parameters for that
+// mode, one of *RRDYFlowControl, *VCRDYFlowControl or *VendorFlowControl.
+// Parameters of unknown modes are kept as *VendorFlowControl.
+type FlowControl struct {
+	Mode   uint16
+	Params interface{}
+}
+
+type RRDYFlowControl struct {
+	BBCredit      uint32
+	Compatibility [16]byte
+}
+
+// VCRDYFlowControl are R_RDY style parameters followed by the BB_Credit for
+// each of the virtual channels.
+type VCRDYFlowControl struct {
+	BBCredit      uint32
+	Compatibility [16]byte
+	VCCredits     []uint16
+}
+
+type VendorFlowControl struct {
+	Data []byte
 }
 
 func (s *ELP) ReadFrom(r io.Reader) (int64, error) {
@@ -29,3 +106,149 @@ func (s *ELP) ReadFrom(r io.Reader) (int64, error) {
 func (s *ELP) WriteTo(w io.Writer) (int64, error) {
 	return encoding.WriteTo(w, s)
 }
+
+func (s *ELPFlags) ReadFrom(r io.Reader) (int64, error) {
+	var bs [2]byte
+	if _, err := io.ReadFull(r, bs[:]); err != nil {
+		return 0, err
+	}
+	v := binary.BigEndian.Uint16(bs[:])
+	s.BridgePort = v&elpFlagBridgePort != 0
+	s.VirtualFabrics = v&elpFlagVirtualFabrics != 0
+	s.Reserved = v &^ (elpFlagBridgePort | elpFlagVirtualFabrics)
+	return 2, nil
+}
+
+func (s *ELPFlags) WriteTo(w io.Writer) (int64, error) {
+	v := s.Reserved &^ (elpFlagBridgePort | elpFlagVirtualFabrics)
+	if s.BridgePort {
+		v |= elpFlagBridgePort
+	}
+	if s.VirtualFabrics {
+		v |= elpFlagVirtualFabrics
+	}
+	var bs [2]byte
+	binary.BigEndian.PutUint16(bs[:], v)
+	n, err := w.Write(bs[:])
+	return int64(n), err
+}
+
+func (s *ClassFParameters) ReadFrom(r io.Reader) (int64, error) {
+	var bs [16]byte
+	if _, err := io.ReadFull(r, bs[:]); err != nil {
+		return 0, err
+	}
+	s.Valid = bs[0]&0x80 == 0x80
+	s.ReceiveDataFieldSize = binary.BigEndian.Uint16(bs[6:])
+	s.ConcurrentSeq = bs[9]
+	s.E2ECredits = binary.BigEndian.Uint16(bs[10:])
+	s.OpenSeqPerExch = bs[13]
+	for i := range bs {
+		s.Reserved[i] = bs[i] &^ classFMask[i]
+	}
+	return 16, nil
+}
+
+func (s *ClassFParameters) WriteTo(w io.Writer) (int64, error) {
+	var bs [16]byte
+	for i := range bs {
+		bs[i] = s.Reserved[i] &^ classFMask[i]
+	}
+	if s.Valid {
+		bs[0] |= 0x80
+	}
+	binary.BigEndian.PutUint16(bs[6:], s.ReceiveDataFieldSize)
+	bs[9] = s.ConcurrentSeq
+	binary.BigEndian.PutUint16(bs[10:], s.E2ECredits)
+	bs[13] = s.OpenSeqPerExch
+	n, err := w.Write(bs[:])
+	return int64(n), err
+}
+
+func (s *ClassParameters) ReadFrom(r io.Reader) (int64, error) {
+	var bs [4]byte
+	if _, err := io.ReadFull(r, bs[:]); err != nil {
+		return 0, err
+	}
+	v := binary.BigEndian.Uint32(bs[:])
+	s.Valid = v&classValid != 0
+	s.SequentialDelivery = v&classSequentialDelivery != 0
+	s.ReceiveDataFieldSize = uint16(v & classReceiveSize)
+	s.Reserved = v &^ (classValid | classSequentialDelivery | classReceiveSize)
+	return 4, nil
+}
+
+func (s *ClassParameters) WriteTo(w io.Writer) (int64, error) {
+	v := s.Reserved&^(classValid|classSequentialDelivery|classReceiveSize) | uint32(s.ReceiveDataFieldSize)
+	if s.Valid {
+		v |= classValid
+	}
+	if s.SequentialDelivery {
+		v |= classSequentialDelivery
+	}
+	var bs [4]byte
+	binary.BigEndian.PutUint32(bs[:], v)
+	n, err := w.Write(bs[:])
+	return int64(n), err
+}
+
+func (s *FlowControl) ReadFrom(r io.Reader) (int64, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, err
+	}
+	s.Mode = binary.BigEndian.Uint16(hdr[0:])
+	b := make([]byte, binary.BigEndian.Uint16(hdr[2:]))
+	n, err := io.ReadFull(r, b)
+	if err != nil {
+		return 4 + int64(n), err
+	}
+
+	s.Params = &VendorFlowControl{Data: b}
+	switch s.Mode {
+	case FlowControlRRDY:
+		if len(b) == 20 {
+			p := &RRDYFlowControl{BBCredit: binary.BigEndian.Uint32(b)}
+			copy(p.Compatibility[:], b[4:])
+			s.Params = p
+		}
+	case FlowControlVCRDY:
+		if len(b) >= 20 && len(b)%2 == 0 {
+			p := &VCRDYFlowControl{BBCredit: binary.BigEndian.Uint32(b)}
+			copy(p.Compatibility[:], b[4:])
+			for i := 20; i < len(b); i += 2 {
+				p.VCCredits = append(p.VCCredits, binary.BigEndian.Uint16(b[i:]))
+			}
+			s.Params = p
+		}
+	}
+	return 4 + int64(n), nil
+}
+
+func (s *FlowControl) WriteTo(w io.Writer) (int64, error) {
+	var b []byte
+	switch p := s.Params.(type) {
+	case *RRDYFlowControl:
+		b = make([]byte, 20)
+		binary.BigEndian.PutUint32(b, p.BBCredit)
+		copy(b[4:], p.Compatibility[:])
+	case *VCRDYFlowControl:
+		b = make([]byte, 20+2*len(p.VCCredits))
+		binary.BigEndian.PutUint32(b, p.BBCredit)
+		copy(b[4:], p.Compatibility[:])
+		for i, c := range p.VCCredits {
+			binary.BigEndian.PutUint16(b[20+2*i:], c)
+		}
+	case *VendorFlowControl:
+		b = p.Data
+	case nil:
+	default:
+		return 0, fmt.Errorf("Unsupported type %v", p)
+	}
+
+	var hdr [4]byte
+	binary.BigEndian.PutUint16(hdr[0:], s.Mode)
+	binary.BigEndian.PutUint16(hdr[2:], uint16(len(b)))
+	n, err := w.Write(append(hdr[:], b...))
+	return int64(n), err
+}
@@ -0,0 +1,105 @@
+package swils
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+const (
+	ProtocolFSPFBackbone = 0x0001 // FSPF-Backbone
+	ProtocolFSPF         = 0x0002 // FSPF
+)
+
+// VendorString is an 8 byte, space padded, vendor identification.
+type VendorString [8]byte
+
+// ESC carries the vendor of the requesting switch and the list of protocols
+// it supports, in order of preference.
+type ESC struct {
+	PayloadLength uint16                 `fc:"@1"`
+	Vendor        VendorString           `fc:"@3"`
+	Protocols     ProtocolDescriptorList `fc:"@11"`
+}
+
+// ProtocolDescriptor names a protocol, scoped to a vendor, that a switch
+// supports.
+type ProtocolDescriptor struct {
+	Vendor     VendorString `fc:"@0"`
+	ProtocolID uint16       `fc:"@10"`
+}
+
+type ProtocolDescriptorList []ProtocolDescriptor
+
+// ESCAccept is the SW_ACC payload for ESC, carrying the single protocol the
+// responding switch selected.
+type ESCAccept struct {
+	Vendor   VendorString       `fc:"@3"`
+	Protocol ProtocolDescriptor `fc:"@11"`
+}
+
+func (s *VendorString) String() string {
+	return string(bytes.TrimRight(s[:], " \x00"))
+}
+
+func (s *ESC) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *ESC) WriteTo(w io.Writer) (int64, error) {
+	o := *s
+	if o.PayloadLength == 0 {
+		o.PayloadLength = uint16(12 + 12*len(o.Protocols))
+	}
+	return encoding.WriteTo(w, &o)
+}
+
+func (s *ProtocolDescriptor) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *ProtocolDescriptor) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, s)
+}
+
+func (p *ProtocolDescriptorList) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	*p = ProtocolDescriptorList{}
+	for {
+		var b [12]byte
+		c, err := io.ReadFull(r, b[:])
+		n += int64(c)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		var d ProtocolDescriptor
+		if _, err := d.ReadFrom(bytes.NewReader(b[:])); err != nil {
+			return n, err
+		}
+		*p = append(*p, d)
+	}
+}
+
+func (p *ProtocolDescriptorList) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for _, d := range *p {
+		c, err := d.WriteTo(w)
+		n += c
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (s *ESCAccept) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *ESCAccept) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, s)
+}
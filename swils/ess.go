@@ -0,0 +1,250 @@
+package swils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	GSTypeKeyDistribution = 0xF7 // Key Distribution Service
+	GSTypeAlias           = 0xF8 // Alias Service
+	GSTypeManagement      = 0xFA // Management Service
+	GSTypeTime            = 0xFB // Time Service
+	GSTypeDirectory       = 0xFC // Directory Service
+	GSTypeVendorSpecific  = 0xE0 // Vendor specific capabilities
+
+	GSSubtypeNameServer = 0x02 // Directory Service: Name Server
+
+	GSSubtypeFabricConfig     = 0x01 // Management Service: Fabric Configuration Server
+	GSSubtypeUnzonedNS        = 0x02 // Management Service: Unzoned Name Server
+	GSSubtypeZoneServer       = 0x03 // Management Service: Fabric Zone Server
+	GSSubtypeLockServer       = 0x04 // Management Service: Lock Server
+	GSSubtypePerformance      = 0x05 // Management Service: Performance Server
+	GSSubtypeSecurityPolicy   = 0x06 // Management Service: Security Policy Server
+	GSSubtypeSecurityInfo     = 0x07 // Management Service: Security Information Server
+	GSSubtypeFDMI             = 0x10 // Management Service: Fabric Device Management Interface
+	GSSubtypeTimeServer       = 0x01 // Time Service: Time Server
+	GSSubtypeKeyDistribution  = 0x00 // Key Distribution Service
+	GSSubtypeAliasServer      = 0x01 // Alias Service: Alias Server
+	GSSubtypeVendorCapability = 0x00 // Vendor specific capabilities
+)
+
+var (
+	errShortCapability = errors.New("capability object exceeds payload")
+)
+
+// ESS advertises the services a switch supports, each described by a
+// capability object.
+type ESS struct {
+	Revision            uint32
+	PayloadLength       uint32
+	InterconnectElement InterconnectElementInfo
+	Capabilities        []interface{}
+}
+
+// InterconnectElementInfo identifies the switch sending the ESS. Length is the
+// size of the string area as received, it is recomputed if zero or too small
+// for the strings. Raw is the string area as received, it is written back
+// as is as long as it holds the same strings.
+type InterconnectElementInfo struct {
+	Length         uint8
+	Vendor         string
+	Model          string
+	ReleaseCode    string
+	VendorSpecific string
+	Raw            []byte
+}
+
+// ServiceCapability is the capability object of a well-known service, like
+// the Name Server or the Fabric Zone Server. Reserved is the reserved byte
+// of the capability header.
+type ServiceCapability struct {
+	GSType     uint8
+	GSSubtype  uint8
+	Reserved   uint8
+	Attributes []CapabilityAttribute
+}
+
+// CapabilityAttribute is one capability entry of a service. Flags are the
+// service defined capability bits, VendorFlags are reserved for vendor use.
+type CapabilityAttribute struct {
+	Flags       uint32
+	VendorFlags uint32
+}
+
+// VendorCapability is a vendor specific capability object. The first entry
+// names the vendor, the remaining entries are vendor defined.
+type VendorCapability struct {
+	GSSubtype uint8
+	Reserved  uint8
+	Vendor    VendorString
+	Data      []byte
+}
+
+// RawCapability is a capability object of a type this package does not
+// know, kept verbatim including its header.
+type RawCapability struct {
+	Data []byte
+}
+
+func (s *ESS) ReadFrom(r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	n := int64(len(b))
+	if len(b) < 15 {
+		return n, io.ErrUnexpectedEOF
+	}
+	s.Revision = binary.BigEndian.Uint32(b[3:])
+	s.PayloadLength = binary.BigEndian.Uint32(b[7:])
+	b = b[11:]
+
+	l, err := s.InterconnectElement.unmarshal(b)
+	if err != nil {
+		return n, err
+	}
+	b = b[l:]
+
+	if len(b) < 4 {
+		return n, io.ErrUnexpectedEOF
+	}
+	cnt := int(binary.BigEndian.Uint16(b))
+	b = b[4:]
+	s.Capabilities = []interface{}{}
+	for i := 0; i < cnt; i++ {
+		if len(b) < 4 {
+			return n, errShortCapability
+		}
+		l := 4 + 8*int(b[3])
+		if len(b) < l {
+			return n, errShortCapability
+		}
+		s.Capabilities = append(s.Capabilities, unmarshalCapability(b[:l]))
+		b = b[l:]
+	}
+	return n, nil
+}
+
+func unmarshalCapability(b []byte) interface{} {
+	switch b[0] {
+	case GSTypeKeyDistribution, GSTypeAlias, GSTypeManagement, GSTypeTime, GSTypeDirectory:
+		c := &ServiceCapability{GSType: b[0], GSSubtype: b[1], Reserved: b[2], Attributes: []CapabilityAttribute{}}
+		for i := 4; i < len(b); i += 8 {
+			c.Attributes = append(c.Attributes, CapabilityAttribute{
+				Flags:       binary.BigEndian.Uint32(b[i:]),
+				VendorFlags: binary.BigEndian.Uint32(b[i+4:]),
+			})
+		}
+		return c
+	case GSTypeVendorSpecific:
+		if len(b) >= 12 {
+			c := &VendorCapability{GSSubtype: b[1], Reserved: b[2], Data: make([]byte, len(b)-12)}
+			copy(c.Vendor[:], b[4:])
+			copy(c.Data, b[12:])
+			return c
+		}
+	}
+	c := &RawCapability{Data: make([]byte, len(b))}
+	copy(c.Data, b)
+	return c
+}
+
+func (s *ESS) WriteTo(w io.Writer) (int64, error) {
+	b := new(bytes.Buffer)
+	caps := new(bytes.Buffer)
+	for _, c := range s.Capabilities {
+		if err := marshalCapability(caps, c); err != nil {
+			return 0, err
+		}
+	}
+	ie := s.InterconnectElement.marshal()
+
+	l := s.PayloadLength
+	if l == 0 {
+		l = uint32(12 + len(ie) + 4 + caps.Len())
+	}
+	b.Write([]byte{0, 0, 0})
+	binary.Write(b, binary.BigEndian, s.Revision)
+	binary.Write(b, binary.BigEndian, l)
+	b.Write(ie)
+	binary.Write(b, binary.BigEndian, uint16(len(s.Capabilities)))
+	b.Write([]byte{0, 0})
+	caps.WriteTo(b)
+	return b.WriteTo(w)
+}
+
+func marshalCapability(b *bytes.Buffer, c interface{}) error {
+	switch c := c.(type) {
+	case *ServiceCapability:
+		b.Write([]byte{c.GSType, c.GSSubtype, c.Reserved, uint8(len(c.Attributes))})
+		for _, a := range c.Attributes {
+			binary.Write(b, binary.BigEndian, a.Flags)
+			binary.Write(b, binary.BigEndian, a.VendorFlags)
+		}
+	case *VendorCapability:
+		if len(c.Data)%8 != 0 {
+			return fmt.Errorf("vendor capability data is %d bytes, not a multiple of 8", len(c.Data))
+		}
+		b.Write([]byte{GSTypeVendorSpecific, c.GSSubtype, c.Reserved, uint8(1 + len(c.Data)/8)})
+		b.Write(c.Vendor[:])
+		b.Write(c.Data)
+	case *RawCapability:
+		b.Write(c.Data)
+	default:
+		return fmt.Errorf("Unsupported type %v", c)
+	}
+	return nil
+}
+
+func (s *InterconnectElementInfo) unmarshal(b []byte) (int, error) {
+	if len(b) < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	s.Length = b[3]
+	l := 4 + int(s.Length)
+	if len(b) < l {
+		return 0, io.ErrUnexpectedEOF
+	}
+	s.Raw = make([]byte, l-4)
+	copy(s.Raw, b[4:l])
+	s.Vendor, s.Model, s.ReleaseCode, s.VendorSpecific = splitStrings(s.Raw)
+	return l, nil
+}
+
+// splitStrings returns the NUL terminated strings of the string area.
+func splitStrings(b []byte) (vendor, model, release, specific string) {
+	strs := bytes.SplitN(b, []byte{0}, 5)
+	ps := []*string{&vendor, &model, &release, &specific}
+	for i := 0; i < len(ps) && i < len(strs); i++ {
+		*ps[i] = string(strs[i])
+	}
+	return
+}
+
+func (s *InterconnectElementInfo) marshal() []byte {
+	if s.Raw != nil && len(s.Raw) <= 0xff {
+		v, m, r, vs := splitStrings(s.Raw)
+		if v == s.Vendor && m == s.Model && r == s.ReleaseCode && vs == s.VendorSpecific {
+			return append([]byte{0, 0, 0, uint8(len(s.Raw))}, s.Raw...)
+		}
+	}
+	b := []byte{0, 0, 0, 0}
+	for _, p := range []string{s.Vendor, s.Model, s.ReleaseCode, s.VendorSpecific} {
+		b = append(b, p...)
+		b = append(b, 0)
+	}
+	l := len(b) - 4
+	if int(s.Length) >= l {
+		l = int(s.Length)
+	} else {
+		// Pad to a word boundary
+		l = (l + 3) &^ 3
+	}
+	b = append(b, make([]byte, 4+l-len(b))...)
+	b[3] = uint8(l)
+	return b
+}
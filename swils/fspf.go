@@ -0,0 +1,249 @@
+package swils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+const (
+	FSPFVersion = 0x02 // FSPF version of FC-SW-2 and later
+
+	LSRTypeSwitchLink = 0x01 // Switch Link Record
+
+	LinkTypePointToPoint = 0x01 // Point-to-point link between two E_Ports
+
+	LSUFlagDBExchange = 0x01 // Initial database exchange
+	LSUFlagDBComplete = 0x02 // Last LSU of the database exchange
+
+	lsrHeaderLength = 24
+	linkLength      = 16
+)
+
+// FSPFHeader starts the payload of the FSPF SW_ILSs, HLO, LSU and LSA.
+type FSPFHeader struct {
+	Version      uint8   `fc:"@0"`
+	ARNumber     uint8   `fc:"@1"`
+	AuthType     uint8   `fc:"@2"`
+	OriginDomain uint8   `fc:"@7"`
+	Auth         [8]byte `fc:"@8"`
+}
+
+// HLO is the Hello of FSPF, establishing and maintaining the adjacency with
+// the neighbour on an E_Port. RecipientDomain is 0 until the Domain_ID of
+// the neighbour is known. PortIndex is the 24 bit index of the E_Port
+// sending the HLO.
+type HLO struct {
+	Header          FSPFHeader `fc:"@3"`
+	Options         uint32     `fc:"@19"`
+	HelloInterval   uint32     `fc:"@23"`
+	DeadInterval    uint32     `fc:"@27"`
+	RecipientDomain uint8      `fc:"@34"`
+	PortIndex       uint32     `fc:"@35"`
+}
+
+// LSU floods Link State Records through the fabric.
+type LSU struct {
+	Header  FSPFHeader `fc:"@3"`
+	Flags   uint8      `fc:"@22"`
+	Records LSRList    `fc:"@23"`
+}
+
+// LSA acknowledges the Link State Records of an LSU, only their headers are
+// carried.
+type LSA struct {
+	Header  FSPFHeader    `fc:"@3"`
+	Flags   uint8         `fc:"@22"`
+	Records LSRHeaderList `fc:"@23"`
+}
+
+// LSR is a Link State Record, describing the links of the switch with the
+// Domain_ID ID. Incarnation orders the records of a switch, the one with
+// the higher Incarnation replaces the other. Length is filled in when
+// written if zero. The Checksum is carried but not verified.
+type LSR struct {
+	Type              uint8
+	Age               uint16
+	ID                uint8
+	AdvertisingDomain uint8
+	Incarnation       uint32
+	Checksum          uint16
+	Length            uint16
+	Links             []Link
+}
+
+// Link is a link of a Switch Link Record to the switch with the Domain_ID
+// Domain. The port indexes are 24 bits.
+type Link struct {
+	Domain            uint8
+	PortIndex         uint32
+	NeighborPortIndex uint32
+	Type              uint8
+	Cost              uint16
+}
+
+// LSRList is a count of Link State Records followed by the records.
+type LSRList []LSR
+
+// LSRHeaderList is a count of Link State Records followed by the headers of
+// the records, without their links.
+type LSRHeaderList []LSR
+
+func (s *FSPFHeader) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *FSPFHeader) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, s)
+}
+
+func (s *HLO) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *HLO) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, s)
+}
+
+func (s *LSU) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *LSU) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, s)
+}
+
+func (s *LSA) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *LSA) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, s)
+}
+
+func (s *LSR) unmarshalHeader(b []byte) {
+	s.Type = b[0]
+	s.Age = binary.BigEndian.Uint16(b[2:])
+	s.ID = b[11]
+	s.AdvertisingDomain = b[15]
+	s.Incarnation = binary.BigEndian.Uint32(b[16:])
+	s.Checksum = binary.BigEndian.Uint16(b[20:])
+	s.Length = binary.BigEndian.Uint16(b[22:])
+}
+
+func (s *LSR) marshalHeader(length uint16) []byte {
+	b := make([]byte, lsrHeaderLength)
+	b[0] = s.Type
+	binary.BigEndian.PutUint16(b[2:], s.Age)
+	b[11] = s.ID
+	b[15] = s.AdvertisingDomain
+	binary.BigEndian.PutUint32(b[16:], s.Incarnation)
+	binary.BigEndian.PutUint16(b[20:], s.Checksum)
+	binary.BigEndian.PutUint16(b[22:], length)
+	return b
+}
+
+func (p *LSRList) ReadFrom(r io.Reader) (int64, error) {
+	var c uint32
+	if err := binary.Read(r, binary.BigEndian, &c); err != nil {
+		return 0, err
+	}
+	n := int64(4)
+	*p = LSRList{}
+	for i := uint32(0); i < c; i++ {
+		var h [lsrHeaderLength]byte
+		m, err := io.ReadFull(r, h[:])
+		n += int64(m)
+		if err != nil {
+			return n, io.ErrUnexpectedEOF
+		}
+		lsr := LSR{}
+		lsr.unmarshalHeader(h[:])
+		if lsr.Length < lsrHeaderLength {
+			return n, fmt.Errorf("invalid LSR length %d", lsr.Length)
+		}
+		body := make([]byte, lsr.Length-lsrHeaderLength)
+		m, err = io.ReadFull(r, body)
+		n += int64(m)
+		if err != nil {
+			return n, io.ErrUnexpectedEOF
+		}
+		if lsr.Type == LSRTypeSwitchLink && len(body) >= 4 {
+			l := int(binary.BigEndian.Uint16(body[2:]))
+			if len(body) < 4+l*linkLength {
+				return n, fmt.Errorf("LSR of %d bytes too short for %d links", lsr.Length, l)
+			}
+			lsr.Links = make([]Link, l)
+			for j := range lsr.Links {
+				b := body[4+j*linkLength:]
+				lsr.Links[j] = Link{
+					Domain:            b[3],
+					PortIndex:         binary.BigEndian.Uint32(b[4:]) & 0xffffff,
+					NeighborPortIndex: binary.BigEndian.Uint32(b[8:]) & 0xffffff,
+					Type:              b[12],
+					Cost:              binary.BigEndian.Uint16(b[14:]),
+				}
+			}
+		}
+		*p = append(*p, lsr)
+	}
+	return n, nil
+}
+
+func (p *LSRList) WriteTo(w io.Writer) (int64, error) {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(len(*p)))
+	for _, lsr := range *p {
+		l := lsr.Length
+		if l == 0 {
+			l = uint16(lsrHeaderLength + 4 + linkLength*len(lsr.Links))
+		}
+		b = append(b, lsr.marshalHeader(l)...)
+		body := make([]byte, 4+linkLength*len(lsr.Links))
+		binary.BigEndian.PutUint16(body[2:], uint16(len(lsr.Links)))
+		for j, l := range lsr.Links {
+			lb := body[4+j*linkLength:]
+			lb[3] = l.Domain
+			binary.BigEndian.PutUint32(lb[4:], l.PortIndex&0xffffff)
+			binary.BigEndian.PutUint32(lb[8:], l.NeighborPortIndex&0xffffff)
+			lb[12] = l.Type
+			binary.BigEndian.PutUint16(lb[14:], l.Cost)
+		}
+		b = append(b, body...)
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+func (p *LSRHeaderList) ReadFrom(r io.Reader) (int64, error) {
+	var c uint32
+	if err := binary.Read(r, binary.BigEndian, &c); err != nil {
+		return 0, err
+	}
+	n := int64(4)
+	*p = LSRHeaderList{}
+	for i := uint32(0); i < c; i++ {
+		var h [lsrHeaderLength]byte
+		m, err := io.ReadFull(r, h[:])
+		n += int64(m)
+		if err != nil {
+			return n, io.ErrUnexpectedEOF
+		}
+		lsr := LSR{}
+		lsr.unmarshalHeader(h[:])
+		*p = append(*p, lsr)
+	}
+	return n, nil
+}
+
+func (p *LSRHeaderList) WriteTo(w io.Writer) (int64, error) {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(len(*p)))
+	for _, lsr := range *p {
+		b = append(b, lsr.marshalHeader(lsr.Length)...)
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
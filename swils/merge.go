@@ -0,0 +1,232 @@
+package swils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/encoding"
+	"github.com/bluecmd/fibrechannel/zone"
+)
+
+const (
+	SFCActivate   = 0x03 // Activate Zone Set
+	SFCDeactivate = 0x04 // Deactivate Zone Set
+)
+
+// MR carries the zoning configuration of a switch to its neighbour when two
+// fabrics merge. ActiveZoneSet is nil if the switch has no active zone set.
+type MR struct {
+	ActiveZoneSet *zone.ZoneSet
+	Database      zone.Database
+}
+
+// MRAccept is the SW_ACC of a MR, telling whether the merge succeeded.
+type MRAccept struct {
+	Reason         ReasonCode        `fc:"@4"`
+	Explanation    ReasonExplanation `fc:"@5"`
+	VendorSpecific uint8             `fc:"@6"`
+}
+
+// ACA is used both for ACA and RCA, listing the Domain_IDs of the switches
+// whose change authorization is acquired or released.
+type ACA struct {
+	PayloadLength uint16       `fc:"@1"`
+	DomainIDs     DomainIDList `fc:"@3"`
+}
+
+type RCA ACA
+
+// SFC stages a zoning change in the fabric. ZoneSet is nil when the
+// operation carries no zone set, like for SFCDeactivate.
+type SFC struct {
+	Operation   uint8
+	ZoneSetName string
+	ZoneSet     *zone.ZoneSet
+}
+
+// UFC commits a staged zoning change, it has no payload besides the command.
+type UFC struct{}
+
+type MRRA struct {
+	Revision   uint32       `fc:"@3"`
+	Size       uint32       `fc:"@7"`
+	Vendor     VendorString `fc:"@11"`
+	VendorInfo [8]byte      `fc:"@19"`
+}
+
+type MRRAAccept struct {
+	Vendor   VendorString `fc:"@3"`
+	Reply    uint32       `fc:"@11"`
+	Size     uint32       `fc:"@15"`
+	WaitTime uint32       `fc:"@19"`
+}
+
+func (s *MR) ReadFrom(r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	n := int64(len(b))
+	if len(b) < 3 {
+		return n, io.ErrUnexpectedEOF
+	}
+	l := int(binary.BigEndian.Uint16(b[1:]))
+	b = b[3:]
+	if len(b) < l+4 {
+		return n, io.ErrUnexpectedEOF
+	}
+	s.ActiveZoneSet = nil
+	if l > 0 {
+		s.ActiveZoneSet = &zone.ZoneSet{}
+		if _, err := s.ActiveZoneSet.ReadFrom(bytes.NewReader(b[:l])); err != nil {
+			return n, err
+		}
+	}
+	b = b[l:]
+	l = int(binary.BigEndian.Uint32(b))
+	b = b[4:]
+	if len(b) < l {
+		return n, io.ErrUnexpectedEOF
+	}
+	if _, err := s.Database.ReadFrom(bytes.NewReader(b[:l])); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+func (s *MR) WriteTo(w io.Writer) (int64, error) {
+	azs := new(bytes.Buffer)
+	if s.ActiveZoneSet != nil {
+		if _, err := s.ActiveZoneSet.WriteTo(azs); err != nil {
+			return 0, err
+		}
+	}
+	db := new(bytes.Buffer)
+	if _, err := s.Database.WriteTo(db); err != nil {
+		return 0, err
+	}
+
+	b := new(bytes.Buffer)
+	b.WriteByte(0)
+	binary.Write(b, binary.BigEndian, uint16(azs.Len()))
+	azs.WriteTo(b)
+	binary.Write(b, binary.BigEndian, uint32(db.Len()))
+	db.WriteTo(b)
+	return b.WriteTo(w)
+}
+
+func (s *MRAccept) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *MRAccept) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, s)
+}
+
+func (s *ACA) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *ACA) WriteTo(w io.Writer) (int64, error) {
+	o := *s
+	if o.PayloadLength == 0 {
+		o.PayloadLength = uint16(4 + 4*len(o.DomainIDs))
+	}
+	return encoding.WriteTo(w, &o)
+}
+
+func (s *RCA) ReadFrom(r io.Reader) (int64, error) {
+	return (*ACA)(s).ReadFrom(r)
+}
+
+func (s *RCA) WriteTo(w io.Writer) (int64, error) {
+	return (*ACA)(s).WriteTo(w)
+}
+
+func (s *SFC) ReadFrom(r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	n := int64(len(b))
+	if len(b) < 3 {
+		return n, io.ErrUnexpectedEOF
+	}
+	s.Operation = b[0]
+	l := int(binary.BigEndian.Uint16(b[1:]))
+	b = b[3:]
+	if len(b) < l+4 {
+		return n, io.ErrUnexpectedEOF
+	}
+	s.ZoneSetName = ""
+	if l > 0 {
+		if s.ZoneSetName, _, err = zone.UnmarshalName(b[:l]); err != nil {
+			return n, err
+		}
+	}
+	b = b[l:]
+	l = int(binary.BigEndian.Uint32(b))
+	b = b[4:]
+	if len(b) < l {
+		return n, io.ErrUnexpectedEOF
+	}
+	s.ZoneSet = nil
+	if l > 0 {
+		s.ZoneSet = &zone.ZoneSet{}
+		if _, err := s.ZoneSet.ReadFrom(bytes.NewReader(b[:l])); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (s *SFC) WriteTo(w io.Writer) (int64, error) {
+	name := new(bytes.Buffer)
+	if s.ZoneSetName != "" {
+		if err := zone.MarshalName(name, s.ZoneSetName); err != nil {
+			return 0, err
+		}
+	}
+	zs := new(bytes.Buffer)
+	if s.ZoneSet != nil {
+		if _, err := s.ZoneSet.WriteTo(zs); err != nil {
+			return 0, err
+		}
+	}
+
+	b := new(bytes.Buffer)
+	b.WriteByte(s.Operation)
+	binary.Write(b, binary.BigEndian, uint16(name.Len()))
+	name.WriteTo(b)
+	binary.Write(b, binary.BigEndian, uint32(zs.Len()))
+	zs.WriteTo(b)
+	return b.WriteTo(w)
+}
+
+func (s *UFC) ReadFrom(r io.Reader) (int64, error) {
+	var bs [3]byte
+	n, err := io.ReadFull(r, bs[:])
+	return int64(n), err
+}
+
+func (s *UFC) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write([]byte{0, 0, 0})
+	return int64(n), err
+}
+
+func (s *MRRA) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *MRRA) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, s)
+}
+
+func (s *MRRAAccept) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *MRRAAccept) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, s)
+}
@@ -0,0 +1,32 @@
+package swils
+
+import (
+	"io"
+
+	"github.com/bluecmd/fibrechannel/common"
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+// RDI is used both for the RDI request and its SW_ACC. In the request
+// DomainIDs holds the requested Domain_IDs, in the accept the granted ones.
+type RDI struct {
+	PageLength    uint8        `fc:"@0"`
+	PayloadLength uint16       `fc:"@1"`
+	SwitchName    common.WWN   `fc:"@3"`
+	DomainIDs     DomainIDList `fc:"@11"`
+}
+
+func (s *RDI) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *RDI) WriteTo(w io.Writer) (int64, error) {
+	o := *s
+	if o.PageLength == 0 {
+		o.PageLength = 4
+	}
+	if o.PayloadLength == 0 {
+		o.PayloadLength = uint16(12 + 4*len(o.DomainIDs))
+	}
+	return encoding.WriteTo(w, &o)
+}
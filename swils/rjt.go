@@ -0,0 +1,144 @@
+package swils
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/encoding"
+)
+
+type ReasonCode uint8
+
+type ReasonExplanation uint8
+
+const (
+	ReasonInvalidCommand      = 0x01 // Invalid SW_ILS command code
+	ReasonInvalidRevision     = 0x02 // Invalid revision level
+	ReasonLogicalError        = 0x03 // Logical error
+	ReasonInvalidPayloadSize  = 0x04 // Invalid payload size
+	ReasonLogicalBusy         = 0x05 // Logical busy
+	ReasonProtocolError       = 0x07 // Protocol error
+	ReasonUnableToPerform     = 0x09 // Unable to perform command request
+	ReasonCommandNotSupported = 0x0B // Command not supported
+	ReasonInvalidAttempt      = 0x0C // Invalid attempt
+	ReasonVendorSpecific      = 0xFF // Vendor specific error, see VendorSpecific
+)
+
+const (
+	ExplNone                     = 0x00 // No additional explanation
+	ExplClassFParamError         = 0x01 // Class F Service Parameter error
+	ExplClassNParamError         = 0x03 // Class "n" Service Parameter error
+	ExplUnknownFlowControlCode   = 0x04 // Unknown Flow Control code
+	ExplInvalidFlowControlParams = 0x05 // Invalid Flow Control Parameters
+	ExplInvalidPortName          = 0x0D // Invalid Port_Name
+	ExplInvalidSwitchName        = 0x0E // Invalid Switch_Name
+	ExplTOVMismatch              = 0x0F // R_A_TOV or E_D_TOV mismatch
+	ExplInvalidDomainIDList      = 0x10 // Invalid Domain_ID_List
+	ExplCommandInProgress        = 0x19 // Command already in progress
+	ExplInsufficientResources    = 0x29 // Insufficient resources available
+	ExplDomainIDNotAvailable     = 0x2A // Domain_ID not available
+	ExplInvalidDomainID          = 0x2B // Invalid Domain_ID
+	ExplRequestNotSupported      = 0x2C // Request not supported
+	ExplLinkParamsNotEstablished = 0x2D // Link Parameters not yet established
+	ExplDomainIDsNotAvailable    = 0x2E // Requested Domain_IDs not available
+	ExplEPortIsolated            = 0x2F // E_Port is Isolated
+	ExplAuthorizationFailed      = 0x31 // Authorization Failed
+	ExplAuthenticationFailed     = 0x32 // Authentication Failed
+	ExplIncompatibleSecurity     = 0x33 // Incompatible Security Attribute
+	ExplChecksInProgress         = 0x34 // Checks in progress
+	ExplPolicySummaryNotEqual    = 0x35 // Policy Summary not equal
+	ExplZoningSummaryNotEqual    = 0x36 // FC-SP Zoning Summary not equal
+	ExplInvalidDataLength        = 0x41 // Invalid Data Length
+	ExplUnsupportedCommand       = 0x42 // Unsupported Command
+	ExplNotAuthorized            = 0x44 // Not Authorized
+	ExplInvalidRequest           = 0x45 // Invalid Request
+	ExplFabricChanging           = 0x46 // Fabric Changing
+	ExplUpdateNotStaged          = 0x47 // Update Not Staged
+	ExplInvalidZoneSetFormat     = 0x48 // Invalid Zone Set Format
+	ExplInvalidData              = 0x49 // Invalid Data
+	ExplUnableToMerge            = 0x4A // Unable to Merge
+	ExplZoneSetSizeNotSupported  = 0x4B // Zone Set Size Not Supported
+	ExplUnableToVerifyConnection = 0x50 // Unable to verify connection
+	ExplAppNotSupported          = 0x58 // Requested application not supported
+)
+
+var (
+	reasonNames = map[ReasonCode]string{
+		ReasonInvalidCommand:      "Invalid SW_ILS command code",
+		ReasonInvalidRevision:     "Invalid revision level",
+		ReasonLogicalError:        "Logical error",
+		ReasonInvalidPayloadSize:  "Invalid payload size",
+		ReasonLogicalBusy:         "Logical busy",
+		ReasonProtocolError:       "Protocol error",
+		ReasonUnableToPerform:     "Unable to perform command request",
+		ReasonCommandNotSupported: "Command not supported",
+		ReasonInvalidAttempt:      "Invalid attempt",
+		ReasonVendorSpecific:      "Vendor specific error",
+	}
+	explanationNames = map[ReasonExplanation]string{
+		ExplNone:                     "No additional explanation",
+		ExplClassFParamError:         "Class F Service Parameter error",
+		ExplClassNParamError:         "Class \"n\" Service Parameter error",
+		ExplUnknownFlowControlCode:   "Unknown Flow Control code",
+		ExplInvalidFlowControlParams: "Invalid Flow Control Parameters",
+		ExplInvalidPortName:          "Invalid Port_Name",
+		ExplInvalidSwitchName:        "Invalid Switch_Name",
+		ExplTOVMismatch:              "R_A_TOV or E_D_TOV mismatch",
+		ExplInvalidDomainIDList:      "Invalid Domain_ID_List",
+		ExplCommandInProgress:        "Command already in progress",
+		ExplInsufficientResources:    "Insufficient resources available",
+		ExplDomainIDNotAvailable:     "Domain_ID not available",
+		ExplInvalidDomainID:          "Invalid Domain_ID",
+		ExplRequestNotSupported:      "Request not supported",
+		ExplLinkParamsNotEstablished: "Link Parameters not yet established",
+		ExplDomainIDsNotAvailable:    "Requested Domain_IDs not available",
+		ExplEPortIsolated:            "E_Port is Isolated",
+		ExplAuthorizationFailed:      "Authorization Failed",
+		ExplAuthenticationFailed:     "Authentication Failed",
+		ExplIncompatibleSecurity:     "Incompatible Security Attribute",
+		ExplChecksInProgress:         "Checks in progress",
+		ExplPolicySummaryNotEqual:    "Policy Summary not equal",
+		ExplZoningSummaryNotEqual:    "FC-SP Zoning Summary not equal",
+		ExplInvalidDataLength:        "Invalid Data Length",
+		ExplUnsupportedCommand:       "Unsupported Command",
+		ExplNotAuthorized:            "Not Authorized",
+		ExplInvalidRequest:           "Invalid Request",
+		ExplFabricChanging:           "Fabric Changing",
+		ExplUpdateNotStaged:          "Update Not Staged",
+		ExplInvalidZoneSetFormat:     "Invalid Zone Set Format",
+		ExplInvalidData:              "Invalid Data",
+		ExplUnableToMerge:            "Unable to Merge",
+		ExplZoneSetSizeNotSupported:  "Zone Set Size Not Supported",
+		ExplUnableToVerifyConnection: "Unable to verify connection",
+		ExplAppNotSupported:          "Requested application not supported",
+	}
+)
+
+// SWRJT is the payload of a SW_RJT as defined in FC-SW-7.
+type SWRJT struct {
+	Reason         ReasonCode        `fc:"@4"`
+	Explanation    ReasonExplanation `fc:"@5"`
+	VendorSpecific uint8             `fc:"@6"`
+}
+
+func (o *ReasonCode) String() string {
+	if n, ok := reasonNames[*o]; ok {
+		return fmt.Sprintf("%s <0x%x>", n, uint8(*o))
+	}
+	return fmt.Sprintf("--Invalid Reason Code-- <0x%x>", uint8(*o))
+}
+
+func (o *ReasonExplanation) String() string {
+	if n, ok := explanationNames[*o]; ok {
+		return fmt.Sprintf("%s <0x%x>", n, uint8(*o))
+	}
+	return fmt.Sprintf("--Invalid Reason Explanation-- <0x%x>", uint8(*o))
+}
+
+func (s *SWRJT) ReadFrom(r io.Reader) (int64, error) {
+	return encoding.ReadFrom(r, s)
+}
+
+func (s *SWRJT) WriteTo(w io.Writer) (int64, error) {
+	return encoding.WriteTo(w, s)
+}
@@ -0,0 +1,95 @@
+package swils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+const (
+	RSCNEventUnspecified      = 0x0 // Event is not specified
+	RSCNEventNameServerObject = 0x1 // Changed Name Server object
+	RSCNEventPortAttribute    = 0x2 // Changed port attribute
+	RSCNEventServiceObject    = 0x3 // Changed service object
+	RSCNEventSwitchConfig     = 0x4 // Changed switch configuration
+	RSCNEventRemovedObject    = 0x5 // Removed object
+
+	RSCNAddressPort   = 0x0 // Port address format
+	RSCNAddressArea   = 0x1 // Area address format
+	RSCNAddressDomain = 0x2 // Domain address format
+	RSCNAddressFabric = 0x3 // Fabric address format
+
+	RSCNDetectedByFabric = 0x1 // Fabric detected the change
+	RSCNDetectedByNPort  = 0x2 // N_Port detected the change
+
+	PortStateNoInfo  = 0x0 // No additional information
+	PortStateOnline  = 0x1 // Port is online
+	PortStateOffline = 0x2 // Port is offline
+
+	swrscnHeaderLen            = 15
+	swrscnAttachedDeviceLength = 20
+)
+
+// SWRSCN distributes a registered state change between switches.
+type SWRSCN struct {
+	EventType         uint8
+	AddressFormat     uint8
+	AffectedPortID    common.FCID
+	DetectionFunction uint32
+	Devices           []AttachedDevice
+}
+
+// AttachedDevice describes a device affected by the state change.
+type AttachedDevice struct {
+	PortState uint8
+	PortID    common.FCID
+	PortName  common.WWN
+	NodeName  common.WWN
+}
+
+func (s *SWRSCN) ReadFrom(r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	n := int64(len(b))
+	if len(b) < swrscnHeaderLen {
+		return n, io.ErrUnexpectedEOF
+	}
+	s.EventType = b[3] >> 4
+	s.AddressFormat = b[3] & 0x0f
+	copy(s.AffectedPortID[:], b[4:7])
+	s.DetectionFunction = binary.BigEndian.Uint32(b[7:])
+	cnt := int(binary.BigEndian.Uint32(b[11:]))
+	b = b[swrscnHeaderLen:]
+	if len(b) < cnt*swrscnAttachedDeviceLength {
+		return n, io.ErrUnexpectedEOF
+	}
+	s.Devices = []AttachedDevice{}
+	for i := 0; i < cnt; i++ {
+		d := AttachedDevice{PortState: b[0] >> 4}
+		copy(d.PortID[:], b[1:4])
+		copy(d.PortName[:], b[4:12])
+		copy(d.NodeName[:], b[12:20])
+		s.Devices = append(s.Devices, d)
+		b = b[swrscnAttachedDeviceLength:]
+	}
+	return n, nil
+}
+
+func (s *SWRSCN) WriteTo(w io.Writer) (int64, error) {
+	b := new(bytes.Buffer)
+	b.Write([]byte{0, 0, 0, s.EventType<<4 | s.AddressFormat&0x0f})
+	b.Write(s.AffectedPortID[:])
+	binary.Write(b, binary.BigEndian, s.DetectionFunction)
+	binary.Write(b, binary.BigEndian, uint32(len(s.Devices)))
+	for _, d := range s.Devices {
+		b.WriteByte(d.PortState << 4)
+		b.Write(d.PortID[:])
+		b.Write(d.PortName[:])
+		b.Write(d.NodeName[:])
+	}
+	return b.WriteTo(w)
+}
@@ -0,0 +1,77 @@
+package swils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+const (
+	strHeaderLen   = 27
+	strPathInfoLen = 28
+)
+
+// STR traces the route between two ports through the fabric. Each switch
+// along the path appends a PathInfo block, the SW_ACC returns the collected
+// blocks using the same layout.
+type STR struct {
+	TransactionID uint32
+	Source        common.WWN
+	Destination   common.WWN
+	Paths         []PathInfo
+}
+
+// PathInfo is the path information added by a single switch (hop).
+type PathInfo struct {
+	SwitchName  common.WWN
+	Domain      uint8
+	IngressPort common.WWN
+	EgressPort  common.WWN
+}
+
+func (s *STR) ReadFrom(r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	n := int64(len(b))
+	if len(b) < strHeaderLen {
+		return n, io.ErrUnexpectedEOF
+	}
+	s.TransactionID = binary.BigEndian.Uint32(b[3:])
+	copy(s.Source[:], b[7:15])
+	copy(s.Destination[:], b[15:23])
+	cnt := int(b[26])
+	b = b[strHeaderLen:]
+	if len(b) < cnt*strPathInfoLen {
+		return n, io.ErrUnexpectedEOF
+	}
+	s.Paths = []PathInfo{}
+	for i := 0; i < cnt; i++ {
+		p := PathInfo{Domain: b[11]}
+		copy(p.SwitchName[:], b[0:8])
+		copy(p.IngressPort[:], b[12:20])
+		copy(p.EgressPort[:], b[20:28])
+		s.Paths = append(s.Paths, p)
+		b = b[strPathInfoLen:]
+	}
+	return n, nil
+}
+
+func (s *STR) WriteTo(w io.Writer) (int64, error) {
+	b := new(bytes.Buffer)
+	b.Write([]byte{0, 0, 0})
+	binary.Write(b, binary.BigEndian, s.TransactionID)
+	b.Write(s.Source[:])
+	b.Write(s.Destination[:])
+	b.Write([]byte{0, 0, 0, uint8(len(s.Paths))})
+	for _, p := range s.Paths {
+		b.Write(p.SwitchName[:])
+		b.Write([]byte{0, 0, 0, p.Domain})
+		b.Write(p.IngressPort[:])
+		b.Write(p.EgressPort[:])
+	}
+	return b.WriteTo(w)
+}
@@ -2,8 +2,10 @@ package swils
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 
+	"github.com/bluecmd/fibrechannel/common"
 	"github.com/bluecmd/fibrechannel/encoding"
 )
 
@@ -40,9 +42,15 @@ const (
 	CmdFFI  = 0x50 // Fast Fabric Initialization for the Avionics Environment
 )
 
+// Frame is a SW_ILS frame. The command code occupies the first byte of the
+// first word, the remaining three bytes of that word are command specific and
+// thus belong to the payload.
+//
+// RawPayload holds the whole payload if the command is not decoded, otherwise
+// any trailing bytes (like padding) not consumed by Payload.
 type Frame struct {
 	Command    Command `fc:"@0"`
-	RawPayload []byte  `fc:"@4"`
+	RawPayload []byte  `fc:"@1"`
 	Payload    interface{}
 }
 
@@ -53,20 +61,102 @@ func (f *Frame) ReadFrom(r io.Reader) (int64, error) {
 func (f *Frame) PostUnmarshal() error {
 	var sf io.ReaderFrom
 	switch f.Command {
+	case CmdSWRJT:
+		sf = &SWRJT{}
 	case CmdELP:
 		sf = &ELP{}
+	case CmdEFP:
+		sf = &EFP{}
+	case CmdDIA:
+		sf = &DIA{}
+	case CmdRDI:
+		sf = &RDI{}
+	case CmdHLO:
+		sf = &HLO{}
+	case CmdLSU:
+		sf = &LSU{}
+	case CmdLSA:
+		sf = &LSA{}
+	case CmdBF:
+		sf = &BF{}
+	case CmdRCF:
+		sf = &RCF{}
+	case CmdESC:
+		sf = &ESC{}
+	case CmdESS:
+		sf = &ESS{}
+	case CmdMR:
+		sf = &MR{}
+	case CmdACA:
+		sf = &ACA{}
+	case CmdRCA:
+		sf = &RCA{}
+	case CmdSFC:
+		sf = &SFC{}
+	case CmdUFC:
+		sf = &UFC{}
+	case CmdMRRA:
+		sf = &MRRA{}
+	case CmdSWRSCN:
+		sf = &SWRSCN{}
+	case CmdSTR:
+		sf = &STR{}
+	case CmdEVFP:
+		sf = &common.EVFP{}
 	}
+	return f.decode(sf)
+}
+
+// DecodeAccept decodes the payload of a SW_ACC frame. The layout of an
+// accept depends on the request it answers, so the command of the
+// originating request has to be supplied by the caller.
+func (f *Frame) DecodeAccept(req Command) error {
+	if f.Command != CmdSWACC {
+		return fmt.Errorf("not a SW_ACC frame: command 0x%02x", uint8(f.Command))
+	}
+	if f.Payload != nil {
+		return nil
+	}
+	var sf io.ReaderFrom
+	switch req {
+	case CmdELP:
+		sf = &ELP{}
+	case CmdEFP:
+		sf = &EFP{}
+	case CmdRDI:
+		sf = &RDI{}
+	case CmdESC:
+		sf = &ESCAccept{}
+	case CmdESS:
+		sf = &ESS{}
+	case CmdMR:
+		sf = &MRAccept{}
+	case CmdMRRA:
+		sf = &MRRAAccept{}
+	case CmdSTR:
+		sf = &STR{}
+	case CmdEVFP:
+		sf = &common.EVFP{}
+	}
+	return f.decode(sf)
+}
 
+func (f *Frame) decode(sf io.ReaderFrom) error {
 	if sf == nil {
 		return nil
 	}
 
-	_, err := sf.ReadFrom(bytes.NewReader(f.RawPayload))
+	r := bytes.NewReader(f.RawPayload)
+	_, err := sf.ReadFrom(r)
 	if err != nil {
 		return err
 	}
 	f.Payload = sf
 	f.RawPayload = nil
+	if r.Len() > 0 {
+		f.RawPayload = make([]byte, r.Len())
+		r.Read(f.RawPayload)
+	}
 	return nil
 }
 
@@ -74,12 +164,19 @@ func (f *Frame) PreMarshal() error {
 	if f.Payload == nil {
 		return nil
 	}
-	b := bytes.NewBuffer(f.RawPayload)
-	_, err := f.Payload.(io.WriterTo).WriteTo(b)
+	b := new(bytes.Buffer)
+	if _, err := f.Payload.(io.WriterTo).WriteTo(b); err != nil {
+		return err
+	}
+	b.Write(f.RawPayload)
 	f.RawPayload = b.Bytes()
-	return err
+	return nil
 }
 
 func (f *Frame) WriteTo(w io.Writer) (int64, error) {
+	// PreMarshal prepends the encoded Payload to RawPayload, restore it
+	// afterwards so that the frame can be written more than once
+	raw := f.RawPayload
+	defer func() { f.RawPayload = raw }()
 	return encoding.WriteToAndPre(w, f)
 }
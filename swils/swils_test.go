@@ -3,6 +3,8 @@ package swils
 import (
 	"bytes"
 	"io"
+	"os"
+	"reflect"
 	"testing"
 
 	"github.com/bluecmd/fibrechannel/common"
@@ -19,3 +21,158 @@ func TestNilBuffer(t *testing.T) {
 func TestFrameFiles(t *testing.T) {
 	common.TestFrameFiles(t, func() common.SerDes { return &Frame{} })
 }
+
+func TestDecodeAccept(t *testing.T) {
+	var tests = []struct {
+		desc string
+		req  Command
+		b    []byte
+		want interface{}
+	}{
+		{
+			desc: "RDI accept",
+			req:  CmdRDI,
+			b: []byte{
+				0x02, 0x04, 0x00, 0x10,
+				0x10, 0x00, 0x00, 0x05, 0x33, 0x27, 0xde, 0xb5,
+				0x00, 0x00, 0x00, 0x05,
+			},
+			want: &RDI{
+				PageLength:    4,
+				PayloadLength: 16,
+				SwitchName:    common.WWN{0x10, 0x00, 0x00, 0x05, 0x33, 0x27, 0xde, 0xb5},
+				DomainIDs:     DomainIDList{5},
+			},
+		},
+		{
+			desc: "ESC accept",
+			req:  CmdESC,
+			b: []byte{
+				0x02, 0x00, 0x00, 0x00,
+				'B', 'R', 'O', 'C', 'A', 'D', 'E', ' ',
+				'B', 'R', 'O', 'C', 'A', 'D', 'E', ' ', 0x00, 0x00, 0x00, 0x02,
+			},
+			want: &ESCAccept{
+				Vendor: VendorString{'B', 'R', 'O', 'C', 'A', 'D', 'E', ' '},
+				Protocol: ProtocolDescriptor{
+					Vendor:     VendorString{'B', 'R', 'O', 'C', 'A', 'D', 'E', ' '},
+					ProtocolID: ProtocolFSPF,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			f := &Frame{}
+			if _, err := f.ReadFrom(bytes.NewReader(tt.b)); err != nil {
+				t.Fatalf("ReadFrom: %v", err)
+			}
+			if f.Payload != nil {
+				t.Fatalf("SW_ACC decoded without request: %v", f.Payload)
+			}
+			if err := f.DecodeAccept(tt.req); err != nil {
+				t.Fatalf("DecodeAccept: %v", err)
+			}
+			if want, got := tt.want, f.Payload; !reflect.DeepEqual(want, got) {
+				t.Fatalf("unexpected payload:\n- want: %v\n-  got: %v", want, got)
+			}
+			b := new(bytes.Buffer)
+			if _, err := f.WriteTo(b); err != nil {
+				t.Fatalf("WriteTo: %v", err)
+			}
+			if want, got := tt.b, b.Bytes(); !bytes.Equal(want, got) {
+				t.Fatalf("unexpected bytes:\n- want: %v\n-  got: %v", want, got)
+			}
+		})
+	}
+}
+
+func TestDecodeAcceptNotAccept(t *testing.T) {
+	f := &Frame{Command: CmdELP}
+	if err := f.DecodeAccept(CmdELP); err == nil {
+		t.Fatalf("got no error, expected one")
+	}
+}
+
+// TestELPRoundTrip flips every byte of an ELP in turn, each must be written
+// back as read. Only the flow control parameter length at bytes 82 and 83
+// cannot be flipped and leave a valid ELP.
+func TestELPRoundTrip(t *testing.T) {
+	d, err := os.ReadFile("testdata/0001-elp.fc")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	for i := range d {
+		b := append([]byte{}, d...)
+		b[i] ^= 0xff
+		f := &Frame{}
+		if _, err := f.ReadFrom(bytes.NewReader(b)); err != nil {
+			if i == 82 || i == 83 {
+				continue
+			}
+			t.Fatalf("ReadFrom with byte %d flipped: %v", i, err)
+		}
+		buf := new(bytes.Buffer)
+		if _, err := f.WriteTo(buf); err != nil {
+			t.Fatalf("WriteTo with byte %d flipped: %v", i, err)
+		}
+		if !bytes.Equal(buf.Bytes(), b) {
+			t.Errorf("byte %d flipped re-serialized to %v, wanted %v", i, buf.Bytes(), b)
+		}
+	}
+}
+
+// TestESSRoundTrip checks that the reserved bytes of the capabilities and
+// the padding of the interconnect element strings are written back.
+func TestESSRoundTrip(t *testing.T) {
+	b, err := os.ReadFile("testdata/0006-ess.fc")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	b[0x32], b[0x5e], b[0x2b] = 0x5a, 0xa5, 'x'
+	f := &Frame{}
+	if _, err := f.ReadFrom(bytes.NewReader(b)); err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	if _, err := f.WriteTo(buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Fatalf("re-serialized to %v, wanted %v", buf.Bytes(), b)
+	}
+
+	ess := f.Payload.(*ESS)
+	ess.InterconnectElement.Model = "DCX"
+	buf.Reset()
+	if _, err := f.WriteTo(buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("BROCADE\x00DCX\x00v7.4.1\x00\x00")) {
+		t.Errorf("changed model not written, got %q", buf.Bytes())
+	}
+}
+
+// TestWriteToUnchanged checks that the length fields filled in when writing
+// are not stored in the payload.
+func TestWriteToUnchanged(t *testing.T) {
+	var tests = []func() io.WriterTo{
+		func() io.WriterTo { return &EFP{Records: EFPRecordList{{Type: EFPRecordDomainIDList, ID: 1}}} },
+		func() io.WriterTo { return &RDI{DomainIDs: DomainIDList{1}} },
+		func() io.WriterTo { return &ESC{Protocols: ProtocolDescriptorList{{ProtocolID: ProtocolFSPF}}} },
+		func() io.WriterTo { return &ACA{DomainIDs: DomainIDList{1}} },
+		func() io.WriterTo { return &RCA{DomainIDs: DomainIDList{1}} },
+		func() io.WriterTo { return &LSRList{{Type: LSRTypeSwitchLink, ID: 1, Links: []Link{{Domain: 2}}}} },
+		func() io.WriterTo { return &ESS{Revision: 1} },
+	}
+	for _, tt := range tests {
+		o := tt()
+		if _, err := o.WriteTo(new(bytes.Buffer)); err != nil {
+			t.Fatalf("WriteTo: %v", err)
+		}
+		if want := tt(); !reflect.DeepEqual(o, want) {
+			t.Errorf("WriteTo changed %T to %+v, wanted %+v", o, o, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package zone
+
+import (
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+// Device is a port as seen by zone enforcement, identified by everything a
+// zone member may name.
+type Device struct {
+	PortName common.WWN
+	NodeName common.WWN
+	PortID   common.FCID
+	Domain   uint8
+	Port     uint16
+}
+
+// Matches reports whether the member names the device. Aliases and
+// members of unknown types never match, resolve aliases before.
+func (m *Member) Matches(d Device) bool {
+	switch id := m.ID.(type) {
+	case common.WWN:
+		switch m.Type {
+		case MemberPortName:
+			return id == d.PortName
+		case MemberNodeName:
+			return id == d.NodeName
+		}
+	case DomainPort:
+		return id.Domain == d.Domain && id.Port == d.Port
+	case PortID:
+		return id == d.PortID
+	}
+	return false
+}
+
+// Has reports whether the device is a member of the zone.
+func (z *Zone) Has(d Device) bool {
+	for i := range z.Members {
+		if z.Members[i].Matches(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// Permits reports whether the two devices share a zone of the zone set and
+// may thus talk to each other.
+func (s *ZoneSet) Permits(a, b Device) bool {
+	for i := range s.Zones {
+		if s.Zones[i].Has(a) && s.Zones[i].Has(b) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,320 @@
+// Package zone implements the zone objects shared by the Fabric Zone Server
+// (FC-GS) and the zone merge and distribution SW_ILS (FC-SW).
+package zone
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+const (
+	MemberPortName   = 0x01 // N_Port_Name
+	MemberDomainPort = 0x02 // Domain_ID and physical port
+	MemberPortID     = 0x03 // N_Port_ID
+	MemberAlias      = 0x04 // Zone Alias name
+	MemberNodeName   = 0x05 // Node_Name
+	MemberFabricPort = 0x06 // F_Port_Name
+
+	ObjectZoneSet = 0x01 // Zone Set object
+	ObjectZone    = 0x02 // Zone object
+	ObjectAlias   = 0x03 // Zone Alias object
+)
+
+var (
+	errShortName = errors.New("name exceeds buffer")
+)
+
+// ZoneSet is a named set of zones, as used for the Active Zone Set.
+type ZoneSet struct {
+	Name  string
+	Zones []Zone
+}
+
+// Zone is a zone of a zone set. It is encoded as a Zone object, see Object.
+type Zone struct {
+	Protocol uint8
+	Name     string
+	Members  []Member
+}
+
+// Member is a zone member. ID holds a common.WWN for name based members,
+// DomainPort, PortID, a string for aliases and []byte for unknown types.
+type Member struct {
+	Type  uint8
+	Flags uint8
+	ID    interface{}
+}
+
+type DomainPort struct {
+	Domain uint8
+	Port   uint16
+}
+
+// PortID is the N_Port_ID of a zone member.
+type PortID = common.FCID
+
+// Object is an entry in the Zoning Database. Members of Zone Set objects
+// name the zones that are part of the set.
+type Object struct {
+	Type     uint8
+	Protocol uint8
+	Name     string
+	Members  []Member
+}
+
+// Database is the Zoning Database, the zone definitions that are not
+// necessarily active.
+type Database []Object
+
+func (s *ZoneSet) ReadFrom(r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	n, err := s.unmarshal(b)
+	return int64(n), err
+}
+
+func (s *ZoneSet) unmarshal(b []byte) (int, error) {
+	var pos int
+	name, n, err := UnmarshalName(b)
+	if err != nil {
+		return n, err
+	}
+	s.Name = name
+	pos += n
+	if len(b) < pos+4 {
+		return pos, io.ErrUnexpectedEOF
+	}
+	cnt := int(binary.BigEndian.Uint32(b[pos:]))
+	pos += 4
+	s.Zones = []Zone{}
+	for i := 0; i < cnt; i++ {
+		var z Zone
+		n, err := z.unmarshal(b[pos:])
+		pos += n
+		if err != nil {
+			return pos, err
+		}
+		s.Zones = append(s.Zones, z)
+	}
+	return pos, nil
+}
+
+func (s *ZoneSet) WriteTo(w io.Writer) (int64, error) {
+	b := new(bytes.Buffer)
+	if err := s.marshal(b); err != nil {
+		return 0, err
+	}
+	return b.WriteTo(w)
+}
+
+func (s *ZoneSet) marshal(b *bytes.Buffer) error {
+	if err := MarshalName(b, s.Name); err != nil {
+		return err
+	}
+	binary.Write(b, binary.BigEndian, uint32(len(s.Zones)))
+	for _, z := range s.Zones {
+		if err := z.marshal(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (z *Zone) unmarshal(b []byte) (int, error) {
+	var o Object
+	n, err := o.unmarshal(b)
+	if err != nil {
+		return n, err
+	}
+	if o.Type != ObjectZone {
+		return n, fmt.Errorf("zone set holds object of type %d", o.Type)
+	}
+	*z = Zone{Protocol: o.Protocol, Name: o.Name, Members: o.Members}
+	return n, nil
+}
+
+func (z *Zone) marshal(b *bytes.Buffer) error {
+	o := Object{Type: ObjectZone, Protocol: z.Protocol, Name: z.Name, Members: z.Members}
+	return o.marshal(b)
+}
+
+// unmarshal decodes an object, its type, protocol and two reserved bytes
+// followed by the name and the members.
+func (o *Object) unmarshal(b []byte) (int, error) {
+	if len(b) < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	o.Type, o.Protocol = b[0], b[1]
+	pos := 4
+	name, n, err := UnmarshalName(b[pos:])
+	pos += n
+	if err != nil {
+		return pos, err
+	}
+	o.Name = name
+	m, n, err := unmarshalMembers(b[pos:])
+	o.Members = m
+	return pos + n, err
+}
+
+func (o *Object) marshal(b *bytes.Buffer) error {
+	b.Write([]byte{o.Type, o.Protocol, 0, 0})
+	if err := MarshalName(b, o.Name); err != nil {
+		return err
+	}
+	return marshalMembers(b, o.Members)
+}
+
+func (d *Database) ReadFrom(r io.Reader) (int64, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	*d = Database{}
+	var pos int
+	for pos < len(b) {
+		var o Object
+		n, err := o.unmarshal(b[pos:])
+		pos += n
+		if err != nil {
+			return int64(pos), err
+		}
+		*d = append(*d, o)
+	}
+	return int64(pos), nil
+}
+
+func (d *Database) WriteTo(w io.Writer) (int64, error) {
+	b := new(bytes.Buffer)
+	for _, o := range *d {
+		if err := o.marshal(b); err != nil {
+			return 0, err
+		}
+	}
+	return b.WriteTo(w)
+}
+
+// Lookup returns the object of the given type and name, or nil.
+func (d Database) Lookup(typ uint8, name string) *Object {
+	for i := range d {
+		if d[i].Type == typ && d[i].Name == name {
+			return &d[i]
+		}
+	}
+	return nil
+}
+
+func unmarshalMembers(b []byte) ([]Member, int, error) {
+	if len(b) < 4 {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	cnt := int(binary.BigEndian.Uint32(b))
+	pos := 4
+	ms := []Member{}
+	for i := 0; i < cnt; i++ {
+		if len(b) < pos+4 {
+			return ms, pos, io.ErrUnexpectedEOF
+		}
+		m := Member{Type: b[pos], Flags: b[pos+2]}
+		l := int(b[pos+3])
+		pos += 4
+		if len(b) < pos+padded(l) {
+			return ms, pos, io.ErrUnexpectedEOF
+		}
+		id := b[pos : pos+l]
+		pos += padded(l)
+		m.ID = unmarshalID(m.Type, id)
+		ms = append(ms, m)
+	}
+	return ms, pos, nil
+}
+
+func unmarshalID(t uint8, id []byte) interface{} {
+	switch t {
+	case MemberPortName, MemberNodeName, MemberFabricPort:
+		if len(id) == 8 {
+			var w common.WWN
+			copy(w[:], id)
+			return w
+		}
+	case MemberDomainPort:
+		if len(id) == 4 {
+			return DomainPort{Domain: id[1], Port: binary.BigEndian.Uint16(id[2:])}
+		}
+	case MemberPortID:
+		if len(id) == 4 {
+			var p PortID
+			copy(p[:], id[1:])
+			return p
+		}
+	case MemberAlias:
+		return string(id)
+	}
+	raw := make([]byte, len(id))
+	copy(raw, id)
+	return raw
+}
+
+func marshalMembers(b *bytes.Buffer, ms []Member) error {
+	binary.Write(b, binary.BigEndian, uint32(len(ms)))
+	for _, m := range ms {
+		var id []byte
+		switch v := m.ID.(type) {
+		case common.WWN:
+			id = v[:]
+		case DomainPort:
+			id = []byte{0, v.Domain, uint8(v.Port >> 8), uint8(v.Port)}
+		case PortID:
+			id = []byte{0, v[0], v[1], v[2]}
+		case string:
+			id = []byte(v)
+		case []byte:
+			id = v
+		default:
+			return fmt.Errorf("Unsupported type %v", v)
+		}
+		if len(id) > 0xff {
+			return fmt.Errorf("zone member identifier too long: %d bytes", len(id))
+		}
+		b.Write([]byte{m.Type, 0, m.Flags, uint8(len(id))})
+		b.Write(id)
+		b.Write(make([]byte, padded(len(id))-len(id)))
+	}
+	return nil
+}
+
+// UnmarshalName decodes a name prefixed by a length byte and three reserved
+// bytes, and padded to a word boundary. The length including the padding
+// is returned as well.
+func UnmarshalName(b []byte) (string, int, error) {
+	if len(b) < 4 {
+		return "", 0, io.ErrUnexpectedEOF
+	}
+	l := int(b[0])
+	if len(b) < 4+padded(l) {
+		return "", 0, errShortName
+	}
+	return string(b[4 : 4+l]), 4 + padded(l), nil
+}
+
+// MarshalName appends the encoded name to b.
+func MarshalName(b *bytes.Buffer, n string) error {
+	if len(n) > 0xff {
+		return fmt.Errorf("zone name too long: %d bytes", len(n))
+	}
+	b.Write([]byte{uint8(len(n)), 0, 0, 0})
+	b.WriteString(n)
+	b.Write(make([]byte, padded(len(n))-len(n)))
+	return nil
+}
+
+func padded(l int) int {
+	return (l + 3) &^ 3
+}
@@ -0,0 +1,139 @@
+package zone
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/bluecmd/fibrechannel/common"
+)
+
+func TestZoneSetRoundTrip(t *testing.T) {
+	zs := &ZoneSet{
+		Name: "cfg",
+		Zones: []Zone{
+			{
+				Name: "z1",
+				Members: []Member{
+					{Type: MemberPortName, ID: common.WWN{0x21, 0, 0, 0x24, 0xff, 0x3d, 0x39, 0xa0}},
+					{Type: MemberDomainPort, ID: DomainPort{Domain: 1, Port: 4}},
+					{Type: MemberPortID, ID: PortID{0x01, 0x02, 0x00}},
+					{Type: MemberAlias, ID: "alias1"},
+					{Type: 0x42, Flags: 0x01, ID: []byte{1, 2, 3}},
+				},
+			},
+		},
+	}
+	b := new(bytes.Buffer)
+	if _, err := zs.WriteTo(b); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if want, got := 0, b.Len()%4; want != got {
+		t.Fatalf("encoding not word aligned, %d bytes", b.Len())
+	}
+	got := &ZoneSet{}
+	if _, err := got.ReadFrom(bytes.NewReader(b.Bytes())); err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+	if !reflect.DeepEqual(zs, got) {
+		t.Fatalf("unexpected zone set:\n- want: %v\n-  got: %v", zs, got)
+	}
+}
+
+func TestDatabaseRoundTrip(t *testing.T) {
+	db := Database{
+		{Type: ObjectZoneSet, Name: "cfg", Members: []Member{{Type: MemberAlias, ID: "z1"}}},
+		{Type: ObjectZone, Name: "z1", Members: []Member{{Type: MemberAlias, ID: "host"}}},
+		{Type: ObjectAlias, Name: "host", Members: []Member{
+			{Type: MemberPortName, ID: common.WWN{0x21, 0, 0, 0x24, 0xff, 0x3d, 0x39, 0xa0}},
+		}},
+	}
+	b := new(bytes.Buffer)
+	if _, err := db.WriteTo(b); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	got := Database{}
+	if _, err := got.ReadFrom(bytes.NewReader(b.Bytes())); err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+	if !reflect.DeepEqual(db, got) {
+		t.Fatalf("unexpected database:\n- want: %v\n-  got: %v", db, got)
+	}
+	if o := got.Lookup(ObjectAlias, "host"); o == nil || len(o.Members) != 1 {
+		t.Fatalf("Lookup of alias failed: %v", o)
+	}
+}
+
+func TestShortBuffer(t *testing.T) {
+	zs := &ZoneSet{}
+	_, err := zs.ReadFrom(bytes.NewReader([]byte{8, 0, 0, 0, 'a'}))
+	if err == nil {
+		t.Fatalf("got no error, expected one")
+	}
+	_, err = zs.ReadFrom(bytes.NewReader([]byte{1, 0, 0, 0, 'a', 0, 0, 0, 0, 0, 0, 1}))
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("got unexpected error %v, wanted io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestPermits(t *testing.T) {
+	host := Device{PortName: common.WWN{0x21, 0, 0, 0x24, 0xff, 0x3d, 0x39, 0xa0}, PortID: common.FCID{0x01, 0x01, 0x00}, Domain: 1, Port: 1}
+	array := Device{PortName: common.WWN{0x50, 0x06, 0x01, 0x60, 0x3e, 0xa0, 0x12, 0x34}, PortID: common.FCID{0x01, 0x04, 0x00}, Domain: 1, Port: 4}
+	tape := Device{PortName: common.WWN{0x50, 0x01, 0x10, 0xa0, 0x00, 0x0b, 0x0c, 0x0d}, PortID: common.FCID{0x01, 0x05, 0x00}, Domain: 1, Port: 5}
+	zs := &ZoneSet{
+		Name: "cfg",
+		Zones: []Zone{
+			{
+				Name: "host_array",
+				Members: []Member{
+					{Type: MemberPortName, ID: host.PortName},
+					{Type: MemberDomainPort, ID: DomainPort{Domain: 1, Port: 4}},
+				},
+			},
+			{
+				Name: "tape",
+				Members: []Member{
+					{Type: MemberPortID, ID: PortID{0x01, 0x05, 0x00}},
+					{Type: MemberAlias, ID: "host"},
+				},
+			},
+		},
+	}
+	if !zs.Permits(host, array) || !zs.Permits(array, host) {
+		t.Errorf("host and array are zoned together")
+	}
+	if zs.Permits(host, tape) || zs.Permits(array, tape) {
+		t.Errorf("tape is not zoned with host or array")
+	}
+	if !zs.Permits(tape, tape) {
+		t.Errorf("tape is a member of a zone")
+	}
+}
+
+// TestZoneObject checks that the zones of a zone set are encoded as the
+// Zone objects of the Zoning Database.
+func TestZoneObject(t *testing.T) {
+	z := Zone{Name: "z1", Members: []Member{
+		{Type: MemberPortName, ID: common.WWN{0x21, 0, 0, 0x24, 0xff, 0x3d, 0x39, 0xa0}},
+		{Type: MemberPortID, ID: PortID{0x01, 0x02, 0x00}},
+	}}
+	zs := new(bytes.Buffer)
+	if _, err := (&ZoneSet{Name: "cfg", Zones: []Zone{z}}).WriteTo(zs); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	db := new(bytes.Buffer)
+	if _, err := (&Database{{Type: ObjectZone, Name: z.Name, Members: z.Members}}).WriteTo(db); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	// The zone follows the name of the zone set and the count of zones
+	if want, got := db.Bytes(), zs.Bytes()[12:]; !bytes.Equal(want, got) {
+		t.Fatalf("zone encoded as %v, wanted the Zone object %v", got, want)
+	}
+
+	b := append([]byte{}, zs.Bytes()...)
+	b[12] = ObjectAlias
+	if _, err := (&ZoneSet{}).ReadFrom(bytes.NewReader(b)); err == nil {
+		t.Fatalf("got no error for an alias in a zone set, expected one")
+	}
+}